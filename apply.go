@@ -0,0 +1,23 @@
+package pipeline
+
+import "context"
+
+// Apply transforms each input from the `in <-chan interface{}` with `apply` and sends the result to the out `<-chan interface{}`.
+// It is useful for transformations that can not fail, since it does not require a Processor.
+// Apply is implemented with Process, so once the context is canceled the remaining inputs are dropped.
+func Apply(ctx context.Context, apply func(ctx context.Context, i interface{}) interface{}, in <-chan interface{}) <-chan interface{} {
+	return Process(ctx, applyProcessor(apply), in)
+}
+
+// ApplyConcurrently is like Apply, except that up to `concurrently` inputs are transformed at once.
+// It is implemented with ProcessConcurrently.
+func ApplyConcurrently(ctx context.Context, concurrently int, apply func(ctx context.Context, i interface{}) interface{}, in <-chan interface{}) <-chan interface{} {
+	return ProcessConcurrently(ctx, concurrently, applyProcessor(apply), in)
+}
+
+// applyProcessor turns an apply func into a Processor that never fails
+func applyProcessor(apply func(ctx context.Context, i interface{}) interface{}) Processor {
+	return ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+		return apply(ctx, i), nil
+	})
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// double multiplies an int by 2
+func double(_ context.Context, i interface{}) interface{} {
+	return i.(int) * 2
+}
+
+func TestApply(t *testing.T) {
+	t.Run("every input is transformed", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Apply(context.Background(), double, Emit(1, 2, 3)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{2, 4, 6}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("inputs are dropped after the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for o := range Apply(ctx, double, Emit(1, 2, 3)) {
+			t.Errorf("%v should have been dropped", o)
+		}
+	})
+}
+
+func TestApplyConcurrently(t *testing.T) {
+	slowDouble := func(ctx context.Context, i interface{}) interface{} {
+		time.Sleep(100 * time.Millisecond)
+		return double(ctx, i)
+	}
+
+	// 4 inputs transformed at once should take about as long as one
+	start := time.Now()
+	var outs []int
+	for o := range ApplyConcurrently(context.Background(), 4, slowDouble, Emit(1, 2, 3, 4)) {
+		outs = append(outs, o.(int))
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("elapsed = %s, want about 100ms", elapsed)
+	}
+	sort.Ints(outs)
+	if want := []int{2, 4, 6, 8}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+}
+
+// emitN sends 0..n-1 to a chan and closes it
+func emitN(n int) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			out <- i
+		}
+	}()
+	return out
+}
+
+func BenchmarkApply(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		for range Apply(context.Background(), double, emitN(1000)) {
+		}
+	}
+}
+
+// BenchmarkApplyHandRolled is the baseline that BenchmarkApply is compared against
+func BenchmarkApplyHandRolled(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		in := emitN(1000)
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			for i := range in {
+				out <- double(context.Background(), i)
+			}
+		}()
+		for range out {
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// ProcessAutoscale is like ProcessConcurrently, except that the number of workers scales with the load.
+// It starts with `min` workers and adds workers, up to `max`, while inputs keep waiting for a free worker.
+// Workers that stay idle are retired, down to `min`.
+// Use WithAutoscaling to tune how fast it scales and to observe the number of workers.
+func ProcessAutoscale(ctx context.Context, min, max int, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	return core.ProcessAutoscale[interface{}, interface{}](ctx, min, max, p, in, newConfig(opts).Config)
+}
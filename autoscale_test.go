@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProcessAutoscale(t *testing.T) {
+	t.Run("scales up under load and back down when idle", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+
+		var mu sync.Mutex
+		var scales []int
+		scaled := func(workers int) {
+			mu.Lock()
+			defer mu.Unlock()
+			scales = append(scales, workers)
+		}
+		slow := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			time.Sleep(20 * time.Millisecond)
+			return i, nil
+		})
+
+		// Send a burst of slow inputs, then go quiet for longer than the cooldown
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+			time.Sleep(200 * time.Millisecond)
+		}()
+
+		var outs []interface{}
+		for o := range ProcessAutoscale(context.Background(), 1, 4, slow, in,
+			WithAutoscaling(5*time.Millisecond, 50*time.Millisecond, scaled),
+		) {
+			outs = append(outs, o)
+		}
+
+		if len(outs) != 100 {
+			t.Errorf("len(out) = %d, want 100", len(outs))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var peak int
+		for _, s := range scales {
+			if s > peak {
+				peak = s
+			}
+		}
+		if peak != 4 {
+			t.Errorf("peak workers = %d, want 4 (scales = %v)", peak, scales)
+		}
+		if len(scales) == 0 || scales[len(scales)-1] != 1 {
+			t.Errorf("scales = %v, want it to end with 1", scales)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("goroutines = %d, want <= %d", after, before)
+		}
+	})
+
+	t.Run("every input is processed or canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var canceled int32
+		p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+				return i, nil
+			}
+		}, func(i interface{}, err error) {
+			atomic.AddInt32(&canceled, 1)
+		})
+		var outs []interface{}
+		for o := range ProcessAutoscale(ctx, 2, 8, p, emitN(100), WithAutoscaling(time.Millisecond, time.Second, nil)) {
+			outs = append(outs, o)
+			if len(outs) == 10 {
+				cancel()
+			}
+		}
+		if got := len(outs) + int(atomic.LoadInt32(&canceled)); got != 100 {
+			t.Errorf("processed + canceled = %d, want 100", got)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+// Broadcast copies every input from the `in <-chan interface{}` to `n` out channels.
+// By default, each input is sent to every out channel before the next input is read,
+// so a stalled out channel stalls all of them.
+// With `WithOverflow(DropNewest, dropped)`, an input is dropped for every out channel that is not ready to receive it.
+// DropOldest behaves like DropNewest since Broadcast does not buffer inputs.
+// All of the out channels are closed when the `in <-chan interface{}` is closed.
+func Broadcast(in <-chan interface{}, n int, opts ...Option) []<-chan interface{} {
+	c := newConfig(opts)
+	outs := make([]chan interface{}, n)
+	results := make([]<-chan interface{}, n)
+	for k := range outs {
+		outs[k] = make(chan interface{})
+		results[k] = outs[k]
+	}
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for i := range in {
+			for _, out := range outs {
+				if c.overflow == Block {
+					out <- i
+					continue
+				}
+				select {
+				case out <- i:
+				default:
+					c.drop(i)
+				}
+			}
+		}
+	}()
+	return results
+}
+
+// Tee copies every input from the `in <-chan interface{}` to both of the out channels.
+// It behaves like Broadcast with two out channels.
+func Tee(in <-chan interface{}, opts ...Option) (<-chan interface{}, <-chan interface{}) {
+	outs := Broadcast(in, 2, opts...)
+	return outs[0], outs[1]
+}
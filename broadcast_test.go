@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	t.Run("every out receives every input", func(t *testing.T) {
+		outs := Broadcast(Emit(1, 2, 3, 4, 5), 3)
+		results := make([][]interface{}, len(outs))
+		var wg sync.WaitGroup
+		for k, out := range outs {
+			wg.Add(1)
+			go func(k int, out <-chan interface{}) {
+				defer wg.Done()
+				for o := range out {
+					results[k] = append(results[k], o)
+				}
+			}(k, out)
+		}
+		wg.Wait()
+
+		want := []interface{}{1, 2, 3, 4, 5}
+		for k, result := range results {
+			if !reflect.DeepEqual(want, result) {
+				t.Errorf("out[%d] = %+v, want %+v", k, result, want)
+			}
+		}
+	})
+
+	t.Run("a stalled out drops inputs with DropNewest", func(t *testing.T) {
+		in := make(chan interface{})
+		var dropped int32
+		fast, stalled := Tee(in, WithOverflow(DropNewest, func(interface{}) {
+			atomic.AddInt32(&dropped, 1)
+		}))
+
+		go func() {
+			defer close(in)
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+		}()
+
+		// Only read from the fast out until it closes.
+		// Every input is dropped by the stalled out and the fast out may drop some too.
+		var count int
+		timeout := time.After(time.Second)
+	loop:
+		for {
+			select {
+			case _, open := <-fast:
+				if !open {
+					break loop
+				}
+				count++
+			case <-timeout:
+				t.Fatal("the fast out was blocked by the stalled out")
+			}
+		}
+
+		// The stalled out is closed without receiving anything
+		for o := range stalled {
+			t.Errorf("%v was not dropped", o)
+		}
+		if d := int(atomic.LoadInt32(&dropped)); d != 20-count {
+			t.Errorf("dropped = %d, want %d", d, 20-count)
+		}
+	})
+}
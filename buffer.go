@@ -0,0 +1,90 @@
+package pipeline
+
+import "fmt"
+
+// Buffer decouples the stages before and after it by holding up to `size` inputs in a ring buffer.
+// The inputs are sent to the out chan in the order they were read, and a fast producer is only blocked once the buffer is full.
+// The out chan is closed when the `in <-chan interface{}` is closed and the buffer is empty.
+// Use WithOverflow to choose what happens when the buffer is full:
+// Block stops reading until there is room, DropNewest drops the input that was just read,
+// and DropOldest drops the oldest buffered input to make room for it.
+// It panics if `size` is not positive.
+func Buffer(size int, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	if size < 1 {
+		panic(fmt.Sprintf("pipeline: buffer size must be positive, got %d", size))
+	}
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		buf := newRing(size)
+		for in != nil || buf.len() > 0 {
+			// Only read while there is room in the buffer, unless inputs are dropped when it is full,
+			// and only send while there is something in it
+			var recv <-chan interface{}
+			if buf.len() < size || c.overflow != Block {
+				recv = in
+			}
+			var send chan<- interface{}
+			var next interface{}
+			if buf.len() > 0 {
+				send = out
+				next = buf.peek()
+			}
+			select {
+			case i, open := <-recv:
+				if !open {
+					in = nil
+					continue
+				}
+				switch {
+				case buf.len() < size:
+					buf.push(i)
+				case c.overflow == DropOldest:
+					c.drop(buf.pop())
+					buf.push(i)
+				default:
+					c.drop(i)
+				}
+			case send <- next:
+				buf.pop()
+			}
+		}
+	}()
+	return out
+}
+
+// ring is a fixed size FIFO queue
+type ring struct {
+	items      []interface{}
+	head, size int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{items: make([]interface{}, capacity)}
+}
+
+func (r *ring) len() int {
+	return r.size
+}
+
+// push adds i to the back of the queue, which must not be full
+func (r *ring) push(i interface{}) {
+	r.items[(r.head+r.size)%len(r.items)] = i
+	r.size++
+}
+
+// peek returns the front of the queue, which must not be empty
+func (r *ring) peek() interface{} {
+	return r.items[r.head]
+}
+
+// pop removes the front of the queue, which must not be empty
+func (r *ring) pop() interface{} {
+	i := r.items[r.head]
+	// Release the reference so it can be garbage collected
+	r.items[r.head] = nil
+	r.head = (r.head + 1) % len(r.items)
+	r.size--
+	return i
+}
@@ -0,0 +1,174 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuffer(t *testing.T) {
+	t.Run("a slow consumer does not block the producer until the buffer is full", func(t *testing.T) {
+		in := make(chan interface{})
+		sent := make(chan int)
+		go func() {
+			defer close(sent)
+			defer close(in)
+			for i := 0; i < 5; i++ {
+				in <- i
+				sent <- i
+			}
+		}()
+		out := Buffer(3, in)
+
+		// Nothing is read from out, yet the producer can send as many inputs as the buffer holds
+		for i := 0; i < 3; i++ {
+			select {
+			case <-sent:
+			case <-time.After(time.Second):
+				t.Fatalf("the producer was blocked after %d inputs", i)
+			}
+		}
+		select {
+		case i := <-sent:
+			t.Fatalf("the producer sent input %d to a full buffer", i)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// Reading from out unblocks the producer
+		var outs []interface{}
+		go func() {
+			for range sent {
+			}
+		}()
+		for o := range out {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{0, 1, 2, 3, 4}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("out is closed after in is closed and the buffer is drained", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Buffer(10, Emit(1, 2, 3)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("a size that is not positive panics", func(t *testing.T) {
+		for _, size := range []int{0, -1} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Buffer(%d) did not panic", size)
+					}
+				}()
+				Buffer(size, Emit(1, 2, 3))
+			}()
+		}
+	})
+}
+
+func TestBufferOverflow(t *testing.T) {
+	type args struct {
+		policy OverflowPolicy
+	}
+	type want struct {
+		out     []interface{}
+		dropped []interface{}
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		"DropNewest keeps the first inputs",
+		args{DropNewest},
+		want{
+			out:     []interface{}{0, 1, 2},
+			dropped: []interface{}{3, 4, 5, 6, 7, 8, 9},
+		},
+	}, {
+		"DropOldest keeps the last inputs",
+		args{DropOldest},
+		want{
+			out:     []interface{}{7, 8, 9},
+			dropped: []interface{}{0, 1, 2, 3, 4, 5, 6},
+		},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			var dropped []interface{}
+			in := make(chan interface{})
+			out := Buffer(3, in, WithOverflow(test.args.policy, func(i interface{}) {
+				dropped = append(dropped, i)
+			}))
+
+			// The buffer never blocks the producer, even though nothing is read from out yet
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+			close(in)
+
+			var outs []interface{}
+			for o := range out {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want.out)
+			}
+			if !reflect.DeepEqual(test.want.dropped, dropped) {
+				t.Errorf("dropped = %+v, want %+v", dropped, test.want.dropped)
+			}
+		})
+	}
+
+	// An empty buffer has nothing to evict, so it is rejected before the stage starts
+	t.Run("a size that is not positive panics with every policy", func(t *testing.T) {
+		for _, policy := range []OverflowPolicy{Block, DropNewest, DropOldest} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Buffer(0) with policy %d did not panic", policy)
+					}
+				}()
+				Buffer(0, Emit(1, 2, 3), WithOverflow(policy, nil))
+			}()
+		}
+	})
+
+	// The consumer races with the eviction of the input it is about to receive:
+	// every input must be either received or dropped exactly once, in order
+	t.Run("DropOldest with a concurrent consumer", func(t *testing.T) {
+		const n = 10000
+		var dropped []int
+		out := Buffer(4, emitN(n), WithOverflow(DropOldest, func(i interface{}) {
+			dropped = append(dropped, i.(int))
+		}))
+		var received []int
+		for o := range out {
+			received = append(received, o.(int))
+			if len(received)%100 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		if got := len(received) + len(dropped); got != n {
+			t.Fatalf("received + dropped = %d, want %d", got, n)
+		}
+		seen := make([]bool, n)
+		for _, is := range [][]int{received, dropped} {
+			for k, i := range is {
+				if seen[i] {
+					t.Fatalf("input %d was delivered twice", i)
+				}
+				seen[i] = true
+				if k > 0 && is[k-1] > i {
+					t.Fatalf("input %d came after input %d", i, is[k-1])
+				}
+			}
+		}
+	})
+}
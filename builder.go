@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Builder composes the stages of a pipeline in the order they run, instead of nesting their calls:
+//
+//	err := pipeline.New(ctx).
+//		Emit(1, 2, 3).
+//		Process(p).
+//		ProcessConcurrently(4, q).
+//		Collect(100, time.Second).
+//		Sink(fn)
+//
+// Each step calls the function with the same name in this package, so the stages behave exactly the same way.
+// Nothing runs until a terminal call, Sink or Out, wires the stages together.
+type Builder struct {
+	ctx    context.Context
+	source *stage
+	stages []stage
+}
+
+// stage is a named step of a Builder
+type stage struct {
+	name string
+	run  func(ctx context.Context, in <-chan interface{}) <-chan interface{}
+}
+
+// errNoSource is returned by Sink when the Builder has no Emit or From step
+var errNoSource = errors.New("pipeline: the builder has no source, call Emit or From first")
+
+// New starts a Builder whose stages all run with the same context
+func New(ctx context.Context) *Builder {
+	return &Builder{ctx: ctx}
+}
+
+// Emit sets the inputs of the pipeline, see EmitContext
+func (b *Builder) Emit(is ...interface{}) *Builder {
+	return b.from("Emit", func(ctx context.Context, _ <-chan interface{}) <-chan interface{} {
+		return EmitContext(ctx, is...)
+	})
+}
+
+// From sets the `in <-chan interface{}` of the pipeline
+func (b *Builder) From(in <-chan interface{}) *Builder {
+	return b.from("From", func(context.Context, <-chan interface{}) <-chan interface{} {
+		return in
+	})
+}
+
+// Process adds a Process stage
+func (b *Builder) Process(p Processor, opts ...Option) *Builder {
+	return b.Then("Process", func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		return Process(ctx, p, in, opts...)
+	})
+}
+
+// ProcessConcurrently adds a ProcessConcurrently stage
+func (b *Builder) ProcessConcurrently(concurrently int, p Processor, opts ...Option) *Builder {
+	return b.Then("ProcessConcurrently", func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		return ProcessConcurrently(ctx, concurrently, p, in, opts...)
+	})
+}
+
+// Collect adds a Collect stage, so the next stages receive `[]interface{}` batches
+func (b *Builder) Collect(maxSize int, maxDuration time.Duration) *Builder {
+	return b.Then("Collect", func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		return Collect(ctx, maxSize, maxDuration, in)
+	})
+}
+
+// Filter adds a Filter stage
+func (b *Builder) Filter(filter func(i interface{}) bool) *Builder {
+	return b.Then("Filter", func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		return Filter(ctx, filter, in)
+	})
+}
+
+// Serial runs every stage of the pipeline with a single worker, see SerialExecution
+func (b *Builder) Serial() *Builder {
+	b.ctx = SerialExecution(b.ctx)
+	return b
+}
+
+// Then adds any stage under the given name, for the stages that do not have a step of their own
+func (b *Builder) Then(name string, run func(ctx context.Context, in <-chan interface{}) <-chan interface{}) *Builder {
+	b.stages = append(b.stages, stage{name, run})
+	return b
+}
+
+// Stages returns the names of the stages in the order they run, starting with the source
+func (b *Builder) Stages() []string {
+	var names []string
+	if b.source != nil {
+		names = append(names, b.source.name)
+	}
+	for _, s := range b.stages {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// Out wires the stages together and returns the out chan of the last stage.
+// It panics with errNoSource if the Builder has no source, since ranging over a nil chan would block forever.
+func (b *Builder) Out() <-chan interface{} {
+	if b.source == nil {
+		panic(errNoSource)
+	}
+	out := b.source.run(b.ctx, nil)
+	for _, s := range b.stages {
+		out = s.run(b.ctx, out)
+	}
+	return out
+}
+
+// Sink runs the pipeline and calls `fn` with every output of the last stage, see ForEach.
+// It returns the error of `fn`, or the `Context.Err()` if the context was canceled before the pipeline finished.
+func (b *Builder) Sink(fn func(i interface{}) error) error {
+	if b.source == nil {
+		return errNoSource
+	}
+	if err := ForEach(b.ctx, b.Out(), fn); err != nil {
+		return err
+	}
+	// The stages may close their out chans early after the context is canceled
+	return b.ctx.Err()
+}
+
+// Run runs the pipeline and calls `fn` with every output of the last stage, like Sink,
+// but it returns only once every stage is done, see Run.
+func (b *Builder) Run(fn func(i interface{}) error) error {
+	if b.source == nil {
+		return errNoSource
+	}
+	stages := []Stage{b.source.run}
+	for _, s := range b.stages {
+		stages = append(stages, s.run)
+	}
+	return Run(b.ctx, append(stages, Sink(fn))...)
+}
+
+// from sets the source stage of b
+func (b *Builder) from(name string, run func(ctx context.Context, in <-chan interface{}) <-chan interface{}) *Builder {
+	b.source = &stage{name, run}
+	return b
+}
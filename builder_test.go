@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	t.Run("runs the stages in order", func(t *testing.T) {
+		b := New(context.Background()).
+			Emit(1, 2, 3, 4, 5, 6).
+			Process(ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+				return i.(int) * 10, nil
+			})).
+			Filter(func(i interface{}) bool {
+				return i.(int) != 30
+			}).
+			Collect(10, time.Second)
+
+		if want := []string{"Emit", "Process", "Filter", "Collect"}; !reflect.DeepEqual(want, b.Stages()) {
+			t.Errorf("stages = %v, want %v", b.Stages(), want)
+		}
+		var batches []interface{}
+		err := b.Sink(func(i interface{}) error {
+			batches = append(batches, i)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		if want := []interface{}{[]interface{}{10, 20, 40, 50, 60}}; !reflect.DeepEqual(want, batches) {
+			t.Errorf("batches = %+v, want %+v", batches, want)
+		}
+	})
+
+	t.Run("the error of the sink is returned", func(t *testing.T) {
+		errSink := errors.New("sink")
+		err := New(context.Background()).
+			From(emitN(100)).
+			ProcessConcurrently(4, noopProcessor).
+			Sink(func(interface{}) error {
+				return errSink
+			})
+		if !errors.Is(err, errSink) {
+			t.Errorf("err = %v, want %s", err, errSink)
+		}
+	})
+
+	t.Run("the context error is returned", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := New(ctx).
+			From(emitN(100)).
+			Process(ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+				time.Sleep(5 * time.Millisecond)
+				return i, nil
+			})).
+			Sink(func(interface{}) error {
+				return nil
+			})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want %s", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("a builder without a source returns an error", func(t *testing.T) {
+		err := New(context.Background()).Process(noopProcessor).Sink(func(interface{}) error {
+			return nil
+		})
+		if err == nil {
+			t.Error("err = nil, want an error")
+		}
+	})
+	t.Run("Out panics without a source", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != errNoSource {
+				t.Errorf("recover() = %v, want %s", r, errNoSource)
+			}
+		}()
+		for range New(context.Background()).Process(noopProcessor).Out() {
+			t.Error("Out returned an output without a source")
+		}
+	})
+}
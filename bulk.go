@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// BulkMismatchPolicy is what the Processor of AdaptBulk does when its func returns fewer results than it was given inputs
+type BulkMismatchPolicy int
+
+const (
+	// FailBatch fails the whole batch with a *BulkMismatchError, which is passed to the cancel func. This is the default.
+	FailBatch BulkMismatchPolicy = iota
+	// CancelTail aligns the results with the inputs by index, sends them, and passes the inputs that have no result
+	// to the cancel func with a *BulkMismatchError.
+	CancelTail
+)
+
+// BulkMismatchError is the error of the inputs of a batch whose results do not match them one for one, see AdaptBulk
+type BulkMismatchError struct {
+	Inputs  int
+	Results int
+}
+
+func (e *BulkMismatchError) Error() string {
+	return fmt.Sprintf("pipeline: %d results for %d inputs", e.Results, e.Inputs)
+}
+
+// AdaptBulk creates a Processor for ProcessBatch and ProcessBatchConcurrently from a func that maps a batch of inputs
+// to one result per input, in the same order, such as the bulk call of an API client:
+//
+//	p := pipeline.AdaptBulk(func(ctx context.Context, is []interface{}) ([]interface{}, error) {
+//		return client.GetOrders(ctx, is)
+//	}, cancel, pipeline.CancelTail)
+//	out := pipeline.ProcessBatch(ctx, 100, time.Second, p, in)
+//
+// If `fn` returns fewer results than inputs, `policy` decides whether the whole batch fails or only the inputs without a result.
+// If it returns more results than inputs, the whole batch fails with a *BulkMismatchError whatever the policy,
+// since the results cannot be told apart. A nil slice with a nil error means that every input was filtered out, which is not an error.
+// `cancel` is called with a []interface{} of the inputs that failed or were canceled, which is the whole batch unless
+// the tail of a batch is canceled with CancelTail. It may be nil, in which case they are ignored.
+func AdaptBulk(fn func(ctx context.Context, is []interface{}) ([]interface{}, error), cancel func(i interface{}, err error), policy BulkMismatchPolicy) Processor {
+	return &bulk{fn: fn, cancel: cancel, policy: policy}
+}
+
+// bulk implements Processor
+type bulk struct {
+	fn     func(ctx context.Context, is []interface{}) ([]interface{}, error)
+	cancel func(i interface{}, err error)
+	policy BulkMismatchPolicy
+}
+
+func (b *bulk) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	is := i.([]interface{})
+	results, err := b.fn(ctx, is)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil || len(results) == len(is) {
+		return results, nil
+	}
+	mErr := &BulkMismatchError{Inputs: len(is), Results: len(results)}
+	if b.policy != CancelTail || len(results) > len(is) {
+		return nil, mErr
+	}
+	// ProcessBatch only cancels the batches that fail, so the inputs without a result are canceled here
+	b.Cancel(is[len(results):], mErr)
+	return results, nil
+}
+
+func (b *bulk) Cancel(i interface{}, err error) {
+	if b.cancel != nil {
+		b.cancel(i, err)
+	}
+}
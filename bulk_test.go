@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdaptBulk(t *testing.T) {
+	failed := errors.New("failed")
+	for _, test := range []struct {
+		name         string
+		results      func(is []interface{}) ([]interface{}, error)
+		policy       BulkMismatchPolicy
+		wantOut      []interface{}
+		wantCanceled []interface{}
+		wantErr      error
+	}{{
+		name: "a result for each input is sent",
+		results: func(is []interface{}) ([]interface{}, error) {
+			return is, nil
+		},
+		wantOut: []interface{}{1, 2, 3},
+	}, {
+		name: "a nil slice filters out the batch",
+		results: func([]interface{}) ([]interface{}, error) {
+			return nil, nil
+		},
+	}, {
+		name: "an error cancels the batch",
+		results: func([]interface{}) ([]interface{}, error) {
+			return nil, failed
+		},
+		wantCanceled: []interface{}{[]interface{}{1, 2, 3}},
+		wantErr:      failed,
+	}, {
+		name: "fewer results fail the batch with FailBatch",
+		results: func(is []interface{}) ([]interface{}, error) {
+			return is[:1], nil
+		},
+		wantCanceled: []interface{}{[]interface{}{1, 2, 3}},
+		wantErr:      &BulkMismatchError{Inputs: 3, Results: 1},
+	}, {
+		name: "fewer results cancel the inputs without a result with CancelTail",
+		results: func(is []interface{}) ([]interface{}, error) {
+			return is[:1], nil
+		},
+		policy:       CancelTail,
+		wantOut:      []interface{}{1},
+		wantCanceled: []interface{}{[]interface{}{2, 3}},
+		wantErr:      &BulkMismatchError{Inputs: 3, Results: 1},
+	}, {
+		name: "an empty slice is fewer results",
+		results: func([]interface{}) ([]interface{}, error) {
+			return []interface{}{}, nil
+		},
+		policy:       CancelTail,
+		wantCanceled: []interface{}{[]interface{}{1, 2, 3}},
+		wantErr:      &BulkMismatchError{Inputs: 3, Results: 0},
+	}, {
+		name: "more results fail the batch whatever the policy",
+		results: func(is []interface{}) ([]interface{}, error) {
+			return append(is, 4), nil
+		},
+		policy:       CancelTail,
+		wantCanceled: []interface{}{[]interface{}{1, 2, 3}},
+		wantErr:      &BulkMismatchError{Inputs: 3, Results: 4},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var canceled []interface{}
+			var errs []error
+			p := AdaptBulk(func(_ context.Context, is []interface{}) ([]interface{}, error) {
+				return test.results(is)
+			}, func(i interface{}, err error) {
+				canceled = append(canceled, i)
+				errs = append(errs, err)
+			}, test.policy)
+			var outs []interface{}
+			for o := range ProcessBatch(context.Background(), 3, time.Second, p, Emit(1, 2, 3)) {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(test.wantOut, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.wantOut)
+			}
+			if !reflect.DeepEqual(test.wantCanceled, canceled) {
+				t.Errorf("canceled = %+v, want %+v", canceled, test.wantCanceled)
+			}
+			if test.wantErr == nil {
+				return
+			}
+			// The errors of the batches that fail are wrapped in a *ProcessError
+			var mErr *BulkMismatchError
+			if len(errs) != 1 || !errors.Is(errs[0], test.wantErr) && !(errors.As(errs[0], &mErr) && reflect.DeepEqual(mErr, test.wantErr)) {
+				t.Errorf("errs = %+v, want %s", errs, test.wantErr)
+			}
+		})
+	}
+}
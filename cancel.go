@@ -23,7 +23,7 @@ func Cancel(ctx context.Context, cancel func(interface{}, error), in <-chan inte
 			// cancel fun until in is closed
 			case <-ctx.Done():
 				for i := range in {
-					cancel(i, ctx.Err())
+					cancel(i, &CanceledError{Err: ctx.Err()})
 				}
 				return
 			}
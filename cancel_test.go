@@ -6,6 +6,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -13,9 +14,12 @@ import (
 func TestCancel(t *testing.T) {
 	const testDuration = time.Second
 
-	// Collect logs
+	// Collect logs from the test, the producer and the cancel func
+	var mu sync.Mutex
 	var logs []string
 	logf := func(v string, is ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
 		logs = append(logs, fmt.Sprintf(v, is...))
 	}
 
@@ -46,6 +50,8 @@ func TestCancel(t *testing.T) {
 	}
 
 	// There should be some logs
+	mu.Lock()
+	defer mu.Unlock()
 	lenLogs := len(logs)
 	if lenLogs < 2 {
 		t.Errorf("len(logs) = %d, wanted > 2", lenLogs)
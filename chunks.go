@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrMissingChunk is wrapped by the error of ReassembleChunks when its inputs end before a chunk of the stream arrived
+var ErrMissingChunk = errors.New("missing chunk")
+
+// Chunk is a piece of a byte stream read by EmitChunks.
+// Its Data is held in a buffer that is reused for the next chunks once the chunk is released,
+// so it must not be used, nor retained, after Release.
+type Chunk struct {
+	// Seq is the position of the chunk in the stream, from 0
+	Seq  uint64
+	Data []byte
+	buf  *[]byte
+	pool *sync.Pool
+}
+
+// Release returns the buffer of the chunk to the pool of EmitChunks, after which its Data must not be used.
+// ReassembleChunks releases the chunks once it wrote them, so only the chunks that do not reach it must be released,
+// such as the ones that are filtered out. A chunk that is not released is garbage collected, but its buffer is not reused.
+// Releasing a chunk twice, or a chunk that was not emitted by EmitChunks, does nothing.
+func (c *Chunk) Release() {
+	if c.buf == nil {
+		return
+	}
+	c.pool.Put(c.buf)
+	c.Data, c.buf, c.pool = nil, nil, nil
+}
+
+// chunkPools holds a *sync.Pool of the buffers of each chunk size, which the calls to EmitChunks share
+var chunkPools sync.Map
+
+// chunkAllocs counts the buffers allocated by the pools of EmitChunks, which tells the tests how well they are reused
+var chunkAllocs int64
+
+// chunkPool returns the pool of the buffers of `size` bytes
+func chunkPool(size int) *sync.Pool {
+	if p, ok := chunkPools.Load(size); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := chunkPools.LoadOrStore(size, &sync.Pool{
+		New: func() interface{} {
+			atomic.AddInt64(&chunkAllocs, 1)
+			b := make([]byte, size)
+			return &b
+		},
+	})
+	return p.(*sync.Pool)
+}
+
+// EmitChunks streams the bytes read from `r` to the out `<-chan interface{}` as *Chunk of `chunkSize` bytes,
+// except for the last one, which holds the rest of the stream, so a large file can go through the stages without loading it into memory:
+//
+//	chunks, errs := pipeline.EmitChunks(ctx, f, 1<<20)
+//	compressed := pipeline.ProcessConcurrently(ctx, 4, compressor, chunks)
+//	err := pipeline.ReassembleChunks(ctx, w, compressed)
+//
+// The buffers of the chunks come from a pool, and each chunk must be released once it is no longer used, see `Chunk.Release`.
+// The error of the reader is sent to the errs chan, which never blocks.
+// Both chans are closed at the end of `r`, after an error, or when the context is canceled, which is checked between reads.
+// It panics if `chunkSize` is not positive.
+func EmitChunks(ctx context.Context, r io.Reader, chunkSize int) (<-chan interface{}, <-chan error) {
+	if chunkSize < 1 {
+		panic(fmt.Sprintf("pipeline: EmitChunks chunk size must be positive, got %d", chunkSize))
+	}
+	pool := chunkPool(chunkSize)
+	out := make(chan interface{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		for seq := uint64(0); ctx.Err() == nil; seq++ {
+			buf := pool.Get().(*[]byte)
+			n, err := io.ReadFull(r, *buf)
+			if n == 0 {
+				pool.Put(buf)
+				if err != io.EOF {
+					errs <- err
+				}
+				return
+			}
+			c := &Chunk{Seq: seq, Data: (*buf)[:n], buf: buf, pool: pool}
+			select {
+			case out <- c:
+			case <-ctx.Done():
+				c.Release()
+				return
+			}
+			if err == io.ErrUnexpectedEOF {
+				// The stream ended within the chunk
+				return
+			} else if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
+// ReassembleChunks writes the Data of every *Chunk from the `in <-chan interface{}` to `w` in the order of their Seq,
+// whatever the order they arrive in, until it is closed, and releases each chunk once it is written.
+// The chunks that arrive before the ones they follow are held until then, so a stage that reorders them,
+// such as ProcessConcurrently, should not get far ahead of the others.
+// It stops at the first error of `w` and returns it, or returns the `Context.Err()` if the context is canceled,
+// or an error that wraps ErrMissingChunk if the `in <-chan interface{}` is closed before every chunk up to the last one arrived.
+// The chunks held are then released, and the remaining inputs are discarded in the background, like ForEach.
+func ReassembleChunks(ctx context.Context, w io.Writer, in <-chan interface{}) error {
+	var next uint64
+	held := map[uint64]*Chunk{}
+	defer func() {
+		for _, c := range held {
+			c.Release()
+		}
+	}()
+	err := ForEach(ctx, in, func(i interface{}) error {
+		c := i.(*Chunk)
+		held[c.Seq] = c
+		for c, ok := held[next]; ok; c, ok = held[next] {
+			delete(held, next)
+			_, err := w.Write(c.Data)
+			c.Release()
+			if err != nil {
+				return err
+			}
+			next++
+		}
+		return nil
+	})
+	if err == nil && len(held) > 0 {
+		return fmt.Errorf("%w: %d", ErrMissingChunk, next)
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// patternReader reads `size` bytes whose value is their offset modulo 251, without allocating them
+type patternReader struct {
+	offset, size int64
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if rest := r.size - r.offset; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	for k := range p {
+		p[k] = byte((r.offset + int64(k)) % 251)
+	}
+	r.offset += int64(len(p))
+	return len(p), nil
+}
+
+// patternWriter checks that the bytes written to it are the ones of a patternReader
+type patternWriter struct {
+	offset int64
+	err    error
+}
+
+func (w *patternWriter) Write(p []byte) (int, error) {
+	for k, b := range p {
+		if want := byte((w.offset + int64(k)) % 251); b != want && w.err == nil {
+			w.err = fmt.Errorf("byte %d = %d, want %d", w.offset+int64(k), b, want)
+		}
+	}
+	w.offset += int64(len(p))
+	return len(p), nil
+}
+
+func TestChunks(t *testing.T) {
+	// emit returns the chunks of s
+	emit := func(s string, size int) []*Chunk {
+		out, errs := EmitChunks(context.Background(), strings.NewReader(s), size)
+		var chunks []*Chunk
+		for o := range out {
+			chunks = append(chunks, o.(*Chunk))
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("err = %s, want none", err)
+		}
+		return chunks
+	}
+
+	t.Run("the stream is cut into chunks of the size and the last one holds the rest", func(t *testing.T) {
+		var data []string
+		for k, c := range emit("abcdefgh", 3) {
+			if c.Seq != uint64(k) {
+				t.Errorf("chunk %d has Seq %d", k, c.Seq)
+			}
+			data = append(data, string(c.Data))
+			c.Release()
+		}
+		if want := []string{"abc", "def", "gh"}; fmt.Sprint(want) != fmt.Sprint(data) {
+			t.Errorf("chunks = %q, want %q", data, want)
+		}
+		if chunks := emit("", 3); len(chunks) > 0 {
+			t.Errorf("chunks of an empty stream = %+v, want none", chunks)
+		}
+	})
+
+	t.Run("the chunks are reassembled in order whatever the order they arrive in", func(t *testing.T) {
+		chunks := emit("abcdefgh", 3)
+		var b bytes.Buffer
+		if err := ReassembleChunks(context.Background(), &b, Emit(chunks[2], chunks[0], chunks[1])); err != nil {
+			t.Errorf("err = %s, want none", err)
+		}
+		if b.String() != "abcdefgh" {
+			t.Errorf("reassembled %q, want abcdefgh", b.String())
+		}
+		for _, c := range chunks {
+			if c.Data != nil {
+				t.Errorf("chunk %d was not released", c.Seq)
+			}
+		}
+	})
+
+	t.Run("a missing chunk is reported and the chunks held are released", func(t *testing.T) {
+		chunks := emit("abcdefgh", 3)
+		var b bytes.Buffer
+		err := ReassembleChunks(context.Background(), &b, Emit(chunks[0], chunks[2]))
+		if !errors.Is(err, ErrMissingChunk) || b.String() != "abc" {
+			t.Errorf("err = %v and reassembled %q, want %s and abc", err, b.String(), ErrMissingChunk)
+		}
+		if chunks[2].Data != nil {
+			t.Error("the chunk held was not released")
+		}
+		chunks[1].Release()
+	})
+
+	t.Run("the error of the writer stops the reassembly", func(t *testing.T) {
+		failed := errors.New("failed")
+		chunks := emit("abcdefgh", 3)
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for _, c := range chunks {
+				in <- c
+			}
+		}()
+		if err := ReassembleChunks(context.Background(), &failingWriter{n: 1, err: failed}, in); err != failed {
+			t.Errorf("err = %v, want %s", err, failed)
+		}
+	})
+
+	t.Run("the buffers of a large stream are reused", func(t *testing.T) {
+		if raceEnabled {
+			t.Skip("the race detector makes the pool drop a part of the buffers put back at random")
+		}
+		// A garbage collection would empty the pool, and with several Ps a buffer put back to the pool by one P
+		// is not seen by the others until it moves to the shared part of the pool
+		defer debug.SetGCPercent(debug.SetGCPercent(-1))
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+		const size, chunkSize = 100 << 20, 64 << 10
+		allocs := atomic.LoadInt64(&chunkAllocs)
+		out, errs := EmitChunks(context.Background(), &patternReader{size: size}, chunkSize)
+		w := &patternWriter{}
+		if err := ReassembleChunks(context.Background(), w, out); err != nil {
+			t.Fatalf("err = %s, want none", err)
+		}
+		if err := <-errs; err != nil || w.err != nil || w.offset != size {
+			t.Fatalf("reassembled %d bytes with %v and %v, want %d bytes", w.offset, err, w.err, size)
+		}
+		// The chunks arrive in order, so at most 2 buffers are in use at once: the one being written and the one being read
+		if n := atomic.LoadInt64(&chunkAllocs) - allocs; n > 2 {
+			t.Errorf("allocated %d buffers for %d chunks, want at most 2", n, size/chunkSize)
+		}
+	})
+
+	t.Run("a chunk size that is not positive panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("EmitChunks did not panic")
+			}
+		}()
+		EmitChunks(context.Background(), strings.NewReader("a"), 0)
+	})
+}
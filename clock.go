@@ -0,0 +1,24 @@
+package pipeline
+
+import "github.com/deliveryhero/pipeline/internal/core"
+
+// Clock tells the time and makes the timers of the time-based stages.
+// The stages use the real clock by default; pass a fake Clock, such as the one of the pipelinetest package,
+// with WithClock to control the time in tests rather than sleep.
+type Clock = core.Clock
+
+// Timer is the time.Timer of a Clock
+type Timer = core.Timer
+
+// Ticker is the time.Ticker of a Clock
+type Ticker = core.Ticker
+
+// WithClock makes the time-based stages tell the time with `clock`:
+// Collect, ProcessBatch, ProcessBatchConcurrently, Delay, Throttle, Debounce, Watch, EmitEvery, EmitFileChanges, Dedup, Join,
+// the windows, Reorder, MergeOrdered, SampleEvery, ExpireAfter, Record and Replay, NewMemoryIdempotencyStore,
+// and the process stages with WithLazyWorkers or WithGracefulStop.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.Clock = clock
+	}
+}
@@ -10,13 +10,19 @@ import (
 // That means when `maxSize` is reached before `maxDuration`, `[maxSize]interface{}` will be passed to the out channel.
 // But if `maxDuration` is reached before `maxSize` inputs are collected, `[< maxSize]interface{}` will be passed to the out channel.
 // When the `context` is canceled, everything in the buffer will be flushed to the out channel.
-func Collect(ctx context.Context, maxSize int, maxDuration time.Duration, in <-chan interface{}) <-chan interface{} {
+//
+// Each batch's `maxDuration` is measured from its first input, so a quiet in channel never produces empty batches.
+//
+// `generic.Collect` reads a `<-chan T` and returns typed batches from a `<-chan []T`.
+func Collect(ctx context.Context, maxSize int, maxDuration time.Duration, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
 	out := make(chan interface{})
 	go func() {
 		for {
-			is, open := collect(ctx, maxSize, maxDuration, in)
+			is, open := collect(ctx, c.Clock, c.Idle, maxSize, maxDuration, in)
 			if is != nil {
 				out <- is
+				c.Idle.Add(-len(is))
 			}
 			if !open {
 				close(out)
@@ -27,23 +33,29 @@ func Collect(ctx context.Context, maxSize int, maxDuration time.Duration, in <-c
 	return out
 }
 
-func collect(ctx context.Context, maxSize int, maxDuration time.Duration, in <-chan interface{}) ([]interface{}, bool) {
+func collect(ctx context.Context, clock Clock, idle *IdleNotifier, maxSize int, maxDuration time.Duration, in <-chan interface{}) ([]interface{}, bool) {
 	var buffer []interface{}
-	timeout := time.After(maxDuration)
+	// The timeout starts when the first input of the batch arrives
+	var timeout <-chan time.Time
+	done, canceled := ctx.Done(), false
 	for {
 		lenBuffer := len(buffer)
 		select {
-		case <-ctx.Done():
-			// Reduce the timeout to 1/10th of a second
-			bs, open := collect(context.Background(), maxSize, 100*time.Millisecond, in)
-			return append(buffer, bs...), open
+		case <-done:
+			// Reduce the timeout to 1/10th of a second from now
+			done, canceled, timeout = nil, true, clock.After(100*time.Millisecond)
 		case <-timeout:
 			return buffer, true
 		case i, open := <-in:
 			if !open {
 				return buffer, false
-			} else if lenBuffer < maxSize-1 {
+			}
+			idle.Add(1)
+			if lenBuffer < maxSize-1 {
 				// There is still room in the buffer
+				if lenBuffer == 0 && !canceled {
+					timeout = clock.After(maxDuration)
+				}
 				buffer = append(buffer, i)
 			} else {
 				// There is no room left in the buffer
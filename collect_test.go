@@ -5,154 +5,124 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
 )
 
-// TestCollect tests the following cases of the Collect func
-// 1. Closes when in closes
-// 2. Remains Open if in remains open
-// 3. Collects max and returns immediately
-// 4. Returns everything passed in if less than max after duration
-// 5. After duration with nothing in the buffer, nothing is returned, channel remains open
-// 6. Flushes the buffer if the context is canceled
 func TestCollect(t *testing.T) {
-	const maxTestDuration = time.Second
-	type args struct {
-		maxSize     int
-		maxDuration time.Duration
-		in          []interface{}
-		inDelay     time.Duration
-		ctxTimeout  time.Duration
-	}
-	type want struct {
-		out  []interface{}
-		open bool
+	const maxDuration = time.Minute
+	// batchOf receives the next batch from out
+	batchOf := func(t *testing.T, out <-chan interface{}) []interface{} {
+		t.Helper()
+		batch, open := <-out
+		if !open {
+			t.Fatal("out is closed, want a batch")
+		}
+		return batch.([]interface{})
 	}
-	for _, test := range []struct {
-		name string
-		args args
-		want want
-	}{{
-		name: "out closes when in closes",
-		args: args{
-			maxSize:     20,
-			maxDuration: maxTestDuration,
-			in:          nil,
-			inDelay:     0,
-			ctxTimeout:  maxTestDuration,
-		},
-		want: want{
-			out:  nil,
-			open: false,
-		},
-	}, {
-		name: "out remains open if in remains open",
-		args: args{
-			maxSize:     2,
-			maxDuration: maxTestDuration,
-			in:          []interface{}{1, 2, 3},
-			inDelay:     (maxTestDuration / 2) - (10 * time.Millisecond),
-			ctxTimeout:  maxTestDuration,
-		},
-		want: want{
-			out:  []interface{}{[]interface{}{1, 2}},
-			open: true,
-		},
-	}, {
-		name: "collects maxSize inputs and returns",
-		args: args{
-			maxSize:     2,
-			maxDuration: maxTestDuration / 10 * 9,
-			inDelay:     maxTestDuration / 10,
-			in:          []interface{}{1, 2, 3, 4, 5},
-			ctxTimeout:  maxTestDuration / 10 * 9,
-		},
-		want: want{
-			out: []interface{}{
-				[]interface{}{1, 2},
-				[]interface{}{3, 4},
-				[]interface{}{5},
-			},
-			open: false,
-		},
-	}, {
-		name: "collection returns after maxDuration with < maxSize",
-		args: args{
-			maxSize:     10,
-			maxDuration: maxTestDuration / 4,
-			inDelay:     (maxTestDuration / 4) - (25 * time.Millisecond),
-			in:          []interface{}{1, 2, 3, 4, 5},
-			ctxTimeout:  maxTestDuration / 4,
-		},
-		want: want{
-			out: []interface{}{
-				[]interface{}{1},
-				[]interface{}{2},
-				[]interface{}{3},
-				[]interface{}{4},
-			},
-			open: true,
-		},
-	}, {
-		name: "collection flushes buffer when the context is canceled",
-		args: args{
-			maxSize:     10,
-			maxDuration: maxTestDuration,
-			inDelay:     0,
-			in:          []interface{}{1, 2, 3, 4, 5},
-			ctxTimeout:  0,
-		},
-		want: want{
-			out: []interface{}{
-				[]interface{}{1, 2, 3, 4, 5},
-			},
-			open: false,
-		},
-	}} {
-		t.Run(test.name, func(t *testing.T) {
-			// Create the in channel
-			in := make(chan interface{})
-			go func() {
-				defer close(in)
-				for _, i := range test.args.in {
-					time.Sleep(test.args.inDelay)
-					in <- i
-				}
-			}()
 
-			// Create the context
-			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
-			defer cancel()
+	t.Run("out closes when in closes", func(t *testing.T) {
+		for o := range Collect(context.Background(), 20, maxDuration, Emit()) {
+			t.Errorf("out sent %v, want it closed", o)
+		}
+	})
 
-			// Collect responses
-			collect := Collect(ctx, test.args.maxSize, test.args.maxDuration, in)
-			timeout := time.After(maxTestDuration)
-			var outs []interface{}
-			var isOpen bool
-		loop:
-			for {
-				select {
-				case out, open := <-collect:
-					if !open {
-						isOpen = false
-						break loop
-					}
-					isOpen = true
-					outs = append(outs, out)
-				case <-timeout:
-					break loop
-				}
-			}
+	t.Run("collects maxSize inputs and returns", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Collect(context.Background(), 2, maxDuration, Emit(1, 2, 3, 4, 5)) {
+			outs = append(outs, o)
+		}
+		want := []interface{}{[]interface{}{1, 2}, []interface{}{3, 4}, []interface{}{5}}
+		if !reflect.DeepEqual(outs, want) {
+			t.Errorf("out = %v, want %v", outs, want)
+		}
+	})
 
-			// Expecting to close or stay open
-			if test.want.open != isOpen {
-				t.Errorf("open = %t, want %t", isOpen, test.want.open)
-			}
+	t.Run("every input is its own batch when maxSize is 1", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Collect(context.Background(), 1, maxDuration, Emit(1, 2, 3)) {
+			outs = append(outs, o)
+		}
+		want := []interface{}{[]interface{}{1}, []interface{}{2}, []interface{}{3}}
+		if !reflect.DeepEqual(outs, want) {
+			t.Errorf("out = %v, want %v", outs, want)
+		}
+	})
 
-			// Expecting outputs
-			if !reflect.DeepEqual(test.want.out, outs) {
-				t.Errorf("out = %v, want %v", outs, test.want.out)
-			}
+	t.Run("collection returns after maxDuration with < maxSize", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(time.Time{})
+		in := make(chan interface{})
+		defer close(in)
+		out := Collect(context.Background(), 10, maxDuration, in, WithClock(clock))
+		in <- 1
+		in <- 2
+		clock.BlockUntil(1)
+		clock.Advance(maxDuration)
+		if got, want := batchOf(t, out), []interface{}{1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("batch = %v, want %v", got, want)
+		}
+	})
 
-		})
-	}
+	t.Run("nothing is returned when in is quiet", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(time.Time{})
+		in := make(chan interface{})
+		defer close(in)
+		out := Collect(context.Background(), 2, maxDuration, in, WithClock(clock))
+		clock.Advance(10 * maxDuration)
+		assertNothing(t, out)
+	})
+
+	t.Run("maxDuration is measured from the first input of the batch", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(time.Time{})
+		in := make(chan interface{})
+		defer close(in)
+		out := Collect(context.Background(), 10, maxDuration, in, WithClock(clock))
+		clock.Advance(maxDuration / 2)
+		in <- 1
+		clock.BlockUntil(1)
+		clock.Advance(maxDuration - time.Nanosecond)
+		in <- 2
+		assertNothing(t, out)
+		clock.Advance(time.Nanosecond)
+		if got, want := batchOf(t, out), []interface{}{1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("batch = %v, want %v", got, want)
+		}
+		// The next batch starts with the next input
+		in <- 3
+		clock.BlockUntil(1)
+		clock.Advance(maxDuration)
+		if got, want := batchOf(t, out), []interface{}{3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("batch = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("collection flushes buffer when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var outs []interface{}
+		for o := range Collect(ctx, 10, maxDuration, Emit(1, 2, 3, 4, 5)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{[]interface{}{1, 2, 3, 4, 5}}; !reflect.DeepEqual(outs, want) {
+			t.Errorf("out = %v, want %v", outs, want)
+		}
+	})
+
+	t.Run("a partial batch is flushed when the context is canceled", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(time.Time{})
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		defer close(in)
+		out := Collect(ctx, 10, maxDuration, in, WithClock(clock))
+		in <- 1
+		in <- 2
+		in <- 3
+		cancel()
+		// The batch is flushed 100ms after the cancellation, in addition to the timer of maxDuration
+		clock.BlockUntil(2)
+		clock.Advance(100 * time.Millisecond)
+		if got, want := batchOf(t, out), []interface{}{1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("batch = %v, want %v", got, want)
+		}
+	})
 }
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// EmitCSV streams the rows of the CSV read from `r` to the out `<-chan interface{}`.
+// Each row is a []string, or a map[string]string keyed by the columns of the header if `hasHeader` is true,
+// in which case the header itself is not emitted.
+// Malformed rows are skipped and their *csv.ParseError, which holds their line number, is sent to the errs chan,
+// unless WithStrict is set, in which case the first one stops the stream.
+// The errs chan must be read along with the out chan, since sending an error blocks until it is received or the context is canceled.
+// Both chans are closed at the end of `r`, after an error that stops the stream, or when the context is canceled.
+func EmitCSV(ctx context.Context, r io.Reader, hasHeader bool, opts ...Option) (<-chan interface{}, <-chan error) {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	errs := make(chan error)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		cr := csv.NewReader(r)
+		// report sends err to the errs chan and returns true if the stream can go on
+		report := func(err error) bool {
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+				return false
+			}
+			_, malformed := err.(*csv.ParseError)
+			return malformed && !c.strict
+		}
+		var header []string
+		for ctx.Err() == nil {
+			record, err := cr.Read()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				if !report(err) {
+					return
+				}
+				continue
+			}
+			if hasHeader && header == nil {
+				header = record
+				continue
+			}
+			var row interface{} = record
+			if header != nil {
+				m := make(map[string]string, len(header))
+				for n, column := range header {
+					m[column] = record[n]
+				}
+				row = m
+			}
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
+// WithStrict makes EmitCSV stop at the first malformed row instead of skipping it
+func WithStrict() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// WriteCSV writes every input from the `in <-chan interface{}` to `w` as a row of CSV, until it is closed.
+// The inputs must be []string, or map[string]string whose values are written in the order of the columns of `header`.
+// If `header` is not nil, it is written first.
+// It stops at the first input that cannot be written and returns the error, or returns the `Context.Err()` if the context is canceled.
+// Either way, the rows written so far are flushed to `w`, and the remaining inputs are discarded in the background, like ForEach.
+func WriteCSV(ctx context.Context, w io.Writer, header []string, in <-chan interface{}) error {
+	cw := csv.NewWriter(w)
+	err := func() error {
+		if header != nil {
+			if err := cw.Write(header); err != nil {
+				go discard(in)
+				return err
+			}
+		}
+		return ForEach(ctx, in, func(i interface{}) error {
+			row, err := csvRow(header, i)
+			if err != nil {
+				return err
+			}
+			return cw.Write(row)
+		})
+	}()
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// csvRow returns the fields of the input i of WriteCSV
+func csvRow(header []string, i interface{}) ([]string, error) {
+	switch row := i.(type) {
+	case []string:
+		return row, nil
+	case map[string]string:
+		if header == nil {
+			return nil, fmt.Errorf("pipeline: a map[string]string row needs a header")
+		}
+		fields := make([]string, len(header))
+		for n, column := range header {
+			fields[n] = row[column]
+		}
+		return fields, nil
+	default:
+		return nil, fmt.Errorf("pipeline: %T is not a CSV row", i)
+	}
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEmitCSV(t *testing.T) {
+	const malformed = "id,name\n1,a\n2\n3,\"c\n"
+
+	for _, test := range []struct {
+		name      string
+		in        string
+		hasHeader bool
+		opts      []Option
+		want      []interface{}
+		wantLines []int
+	}{{
+		name: "rows are emitted as slices without a header",
+		in:   "1,a\n2,b\n",
+		want: []interface{}{[]string{"1", "a"}, []string{"2", "b"}},
+	}, {
+		name:      "rows are emitted as maps with a header",
+		in:        "id,name\n1,a\n2,b\n",
+		hasHeader: true,
+		want: []interface{}{
+			map[string]string{"id": "1", "name": "a"},
+			map[string]string{"id": "2", "name": "b"},
+		},
+	}, {
+		name:      "malformed rows are reported with their line and skipped",
+		in:        malformed,
+		hasHeader: true,
+		want:      []interface{}{map[string]string{"id": "1", "name": "a"}},
+		wantLines: []int{3, 4},
+	}, {
+		name:      "the first malformed row stops the stream when it is strict",
+		in:        malformed,
+		hasHeader: true,
+		opts:      []Option{WithStrict()},
+		want:      []interface{}{map[string]string{"id": "1", "name": "a"}},
+		wantLines: []int{3},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			rows, errs := EmitCSV(context.Background(), strings.NewReader(test.in), test.hasHeader, test.opts...)
+			// Read the errors along with the rows
+			var lines []int
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for err := range errs {
+					var pErr *csv.ParseError
+					if !errors.As(err, &pErr) {
+						t.Errorf("err = %v, want a *csv.ParseError", err)
+						continue
+					}
+					lines = append(lines, pErr.Line)
+				}
+			}()
+			var outs []interface{}
+			for row := range rows {
+				outs = append(outs, row)
+			}
+			<-done
+			if !reflect.DeepEqual(test.want, outs) {
+				t.Errorf("rows = %+v, want %+v", outs, test.want)
+			}
+			if !reflect.DeepEqual(test.wantLines, lines) {
+				t.Errorf("malformed lines = %v, want %v", lines, test.wantLines)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Run("slices and maps are written after the header", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := WriteCSV(context.Background(), &buf, []string{"id", "name"}, Emit(
+			[]string{"1", "a"},
+			map[string]string{"name": "b, c", "id": "2"},
+		))
+		if err != nil {
+			t.Fatalf("err = %s, want nil", err)
+		}
+		if want := "id,name\n1,a\n2,\"b, c\"\n"; buf.String() != want {
+			t.Errorf("written = %q, want %q", buf.String(), want)
+		}
+	})
+
+	t.Run("the rows written before an error are flushed", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := WriteCSV(context.Background(), &buf, nil, Emit([]string{"1", "a"}, 3, []string{"4", "d"}))
+		if err == nil {
+			t.Error("err = nil, want an error for the input that is not a row")
+		}
+		if want := "1,a\n"; buf.String() != want {
+			t.Errorf("written = %q, want %q", buf.String(), want)
+		}
+	})
+
+	t.Run("the rows written before the context is canceled are flushed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		go func() {
+			in <- []string{"1", "a"}
+			cancel()
+		}()
+		var buf bytes.Buffer
+		if err := WriteCSV(ctx, &buf, nil, in); !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want %s", err, context.Canceled)
+		}
+		close(in)
+		if want := "1,a\n"; buf.String() != want {
+			t.Errorf("written = %q, want %q", buf.String(), want)
+		}
+	})
+}
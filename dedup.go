@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+	"time"
+)
+
+// Dedup passes the inputs from the `in <-chan interface{}` to the out `<-chan interface{}`,
+// except for the inputs whose key, as returned by `keyFn`, was already seen within the last `ttl`.
+// Every time a key is seen, duplicate or not, its `ttl` starts again.
+// The keys are held in a cache that evicts the expired keys when the next input arrives.
+// Use WithMaxEntries to bound the cache, in which case the least recently seen key is evicted to make room for a new one.
+// The duplicates are passed to the func set by WithDuplicates, if there is one.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func Dedup(ctx context.Context, keyFn func(i interface{}) string, ttl time.Duration, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		seen := newDedupCache(ttl, c.maxEntries)
+		for i := range in {
+			if seen.add(keyFn(i), c.Clock.Now()) {
+				if c.duplicates != nil {
+					c.duplicates(i)
+				}
+				continue
+			}
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before Dedup are never blocked
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithMaxEntries bounds the number of keys that Dedup remembers, the number of sessions that SessionWindow keeps open,
+// and the number of groups that GroupBy keeps open.
+// The default of 0 bounds the keys by their ttl and the sessions by their gap only, and does not bound the groups.
+func WithMaxEntries(maxEntries int) Option {
+	return func(c *config) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// WithDuplicates sets the func that Dedup passes the duplicates to, and that ProcessIdempotent passes the inputs it skips to,
+// which is useful for counting them.
+// It must not block.
+func WithDuplicates(duplicates func(i interface{})) Option {
+	return func(c *config) {
+		c.duplicates = duplicates
+	}
+}
+
+// dedupEntry is a key of the dedupCache and when it was last seen
+type dedupEntry struct {
+	key  string
+	seen time.Time
+}
+
+// dedupCache holds the keys seen by Dedup.
+// It is only used by the Dedup goroutine, so it needs no locking.
+type dedupCache struct {
+	ttl        time.Duration
+	maxEntries int
+	// order holds the entries from the least to the most recently seen, which is also the order they expire in
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+// newDedupCache returns an empty dedupCache
+func newDedupCache(ttl time.Duration, maxEntries int) *dedupCache {
+	return &dedupCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		keys:       map[string]*list.Element{},
+	}
+}
+
+// add marks key as seen at now and returns true if it was already seen within the ttl
+func (d *dedupCache) add(key string, now time.Time) bool {
+	d.expire(now)
+	if e, ok := d.keys[key]; ok {
+		e.Value.(*dedupEntry).seen = now
+		d.order.MoveToBack(e)
+		return true
+	}
+	d.keys[key] = d.order.PushBack(&dedupEntry{key: key, seen: now})
+	if d.maxEntries > 0 && d.order.Len() > d.maxEntries {
+		d.remove(d.order.Front())
+	}
+	return false
+}
+
+// expire removes the keys that were last seen `ttl` or more before now
+func (d *dedupCache) expire(now time.Time) {
+	for e := d.order.Front(); e != nil && now.Sub(e.Value.(*dedupEntry).seen) >= d.ttl; e = d.order.Front() {
+		d.remove(e)
+	}
+}
+
+// remove removes the entry e
+func (d *dedupCache) remove(e *list.Element) {
+	d.order.Remove(e)
+	delete(d.keys, e.Value.(*dedupEntry).key)
+}
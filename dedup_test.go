@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	key := func(i interface{}) string {
+		return fmt.Sprint(i)
+	}
+
+	t.Run("duplicates within the ttl are dropped and reported", func(t *testing.T) {
+		var outs, duplicates []interface{}
+		for o := range Dedup(context.Background(), key, time.Hour, Emit(1, 2, 1, 3, 2, 1), WithDuplicates(func(i interface{}) {
+			duplicates = append(duplicates, i)
+		})) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if want := []interface{}{1, 2, 1}; !reflect.DeepEqual(want, duplicates) {
+			t.Errorf("duplicates = %+v, want %+v", duplicates, want)
+		}
+	})
+
+	t.Run("a key is passed through again after the ttl", func(t *testing.T) {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			in <- 1
+			in <- 1
+			time.Sleep(50 * time.Millisecond)
+			in <- 1
+		}()
+		var outs []interface{}
+		for o := range Dedup(context.Background(), key, 20*time.Millisecond, in) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 1}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("the least recently seen key is evicted when the cache is full", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Dedup(context.Background(), key, time.Hour, Emit(1, 2, 1, 3, 2, 1), WithMaxEntries(2)) {
+			outs = append(outs, o)
+		}
+		// 3 evicts 2, since 1 was seen more recently, and then 2 evicts 1
+		if want := []interface{}{1, 2, 3, 2, 1}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("out closes when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := Dedup(ctx, key, time.Hour, emitN(1000))
+		<-out
+		cancel()
+		select {
+		case <-waitClosed(out):
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed after the context was canceled")
+		}
+	})
+}
+
+func TestDedupCache(t *testing.T) {
+	start := time.Now()
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	t.Run("expired keys are evicted", func(t *testing.T) {
+		d := newDedupCache(10*time.Second, 0)
+		for k := 0; k < 100; k++ {
+			d.add(fmt.Sprint(k), at(0))
+		}
+		if d.add("new", at(10)) {
+			t.Error("a new key was a duplicate")
+		}
+		if n := len(d.keys); n != 1 {
+			t.Errorf("len(keys) = %d, want 1", n)
+		}
+	})
+
+	t.Run("the cache never holds more than maxEntries keys", func(t *testing.T) {
+		d := newDedupCache(time.Hour, 10)
+		for k := 0; k < 1000; k++ {
+			d.add(fmt.Sprint(k), at(k))
+			if n := len(d.keys); n > 10 || d.order.Len() != n {
+				t.Fatalf("len(keys) = %d and len(order) = %d, want <= 10", n, d.order.Len())
+			}
+		}
+	})
+
+	t.Run("seeing a duplicate restarts its ttl", func(t *testing.T) {
+		d := newDedupCache(10*time.Second, 0)
+		d.add("a", at(0))
+		if !d.add("a", at(9)) {
+			t.Error("a was not a duplicate at 9s")
+		}
+		if !d.add("a", at(18)) {
+			t.Error("a was not a duplicate at 18s")
+		}
+		if d.add("a", at(28)) {
+			t.Error("a was a duplicate at 28s")
+		}
+	})
+}
@@ -2,26 +2,65 @@ package pipeline
 
 import (
 	"context"
+	"math/rand"
 	"time"
 )
 
 // Delay delays reading each input by `duration`.
-// If the context is canceled, the delay will not be applied.
-func Delay(ctx context.Context, duration time.Duration, in <-chan interface{}) <-chan interface{} {
+// With `WithJitter(j)`, each delay is randomized between `duration - j` and `duration + j`.
+// When the context is canceled, Delay stops waiting right away and the remaining inputs are dropped
+// until the `in <-chan interface{}` is closed, even if nothing is reading from the out chan anymore.
+// Chain it after Cancel to pass the remaining inputs to a cancel func instead.
+func Delay(ctx context.Context, duration time.Duration, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
 	out := make(chan interface{})
 	go func() {
 		defer close(out)
+		rng := c.rand()
 		// Keep reading from in until its closed
 		for i := range in {
-			// Take one element from in and pass it to out
-			out <- i
+			// Take one element from in and pass it to out, unless the context is canceled
+			if ctx.Err() == nil {
+				select {
+				case out <- i:
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
+				// Drop the remaining inputs so that the stages before Delay are never blocked
+				for range in {
+				}
+				return
+			}
+			timer := c.Clock.NewTimer(c.delay(rng, duration))
 			select {
 			// Wait duration before reading another input
-			case <-time.After(duration):
+			case <-timer.C():
 			// Don't wait if the context is canceled
 			case <-ctx.Done():
+				timer.Stop()
 			}
 		}
 	}()
 	return out
 }
+
+// WithJitter randomizes the delay of Delay by up to `jitter` in either direction,
+// which keeps several delayed stages from writing in lockstep
+func WithJitter(jitter time.Duration) Option {
+	return func(c *config) {
+		c.jitter = jitter
+	}
+}
+
+// delay returns d randomized by the jitter, if there is one
+func (c *config) delay(rng *rand.Rand, d time.Duration) time.Duration {
+	if c.jitter <= 0 {
+		return d
+	}
+	d += time.Duration(rng.Int63n(int64(2*c.jitter)+1)) - c.jitter
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -2,102 +2,107 @@ package pipeline
 
 import (
 	"context"
+	"math/rand"
 	"reflect"
+	"runtime"
 	"testing"
 	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
 )
 
-func TestDelay(t *testing.T) {
-	const maxTestDuration = time.Second
-	type args struct {
-		ctxTimeout time.Duration
-		duration   time.Duration
-		in         []interface{}
-	}
-	type want struct {
-		out  []interface{}
-		open bool
+// assertNothing fails the test if out has an output ready, which the stages of a fake clock can not have before it is advanced
+func assertNothing(t *testing.T, out <-chan interface{}) {
+	t.Helper()
+	select {
+	case o, open := <-out:
+		t.Fatalf("got %v (open = %t) before the clock was advanced", o, open)
+	default:
 	}
-	for _, test := range []struct {
-		name string
-		args args
-		want want
-	}{{
-		name: "out closes after duration when in closes",
-		args: args{
-			ctxTimeout: maxTestDuration,
-			duration:   maxTestDuration - 100*time.Millisecond,
-			in:         []interface{}{1},
-		},
-		want: want{
-			out:  []interface{}{1},
-			open: false,
-		},
-	}, {
-		name: "delay is not applied when the context is canceled",
-		args: args{
-			ctxTimeout: 10 * time.Millisecond,
-			duration:   maxTestDuration,
-			in:         []interface{}{1, 2, 3, 4, 5},
-		},
-		want: want{
-			out:  []interface{}{1, 2, 3, 4, 5},
-			open: false,
-		},
-	}, {
-		name: "out is delayed by duration",
-		args: args{
-			ctxTimeout: maxTestDuration,
-			duration:   maxTestDuration / 4,
-			in:         []interface{}{1, 2, 3, 4, 5},
-		},
-		want: want{
-			out:  []interface{}{1, 2, 3, 4},
-			open: true,
-		},
-	}} {
-		t.Run(test.name, func(t *testing.T) {
-			// Create in channel
-			in := make(chan interface{})
-			go func() {
-				defer close(in)
-				for _, i := range test.args.in {
-					in <- i
-				}
-			}()
+}
 
-			// Create a context with a timeut
-			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
-			defer cancel()
+func TestDelay(t *testing.T) {
+	const duration = time.Minute
 
-			// Start reading from in
-			delay := Delay(ctx, test.args.duration, in)
-			timeout := time.After(maxTestDuration)
-			var isOpen bool
-			var outs []interface{}
-		loop:
-			for {
-				select {
-				case i, open := <-delay:
-					isOpen = open
-					if !open {
-						break loop
-					}
-					outs = append(outs, i)
-				case <-timeout:
-					break loop
-				}
+	t.Run("out is delayed by duration", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(time.Time{})
+		out := Delay(context.Background(), duration, Emit(1, 2, 3), WithClock(clock))
+		for _, want := range []interface{}{1, 2, 3} {
+			if o := <-out; o != want {
+				t.Fatalf("out = %v, want %v", o, want)
 			}
+			clock.BlockUntil(1)
+			clock.Advance(duration - time.Nanosecond)
+			assertNothing(t, out)
+			clock.Advance(time.Nanosecond)
+		}
+		// out closes after the last delay since in is closed
+		if o, open := <-out; open {
+			t.Errorf("out sent %v, want it closed", o)
+		}
+	})
 
-			// Expecting the out channel to be open or closed
-			if test.want.open != isOpen {
-				t.Errorf("%t != %t", test.want.open, isOpen)
-			}
+	t.Run("delay is not applied and the remaining inputs are dropped when the context is canceled", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(time.Time{})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Delay(ctx, duration, Emit(1, 2, 3, 4, 5), WithClock(clock))
+		if o := <-out; o != 1 {
+			t.Fatalf("out = %v, want 1", o)
+		}
+		cancel()
+		if o, open := <-out; open {
+			t.Errorf("out sent %v, want it closed", o)
+		}
+	})
+}
 
-			// Expecting processed outputs
-			if !reflect.DeepEqual(test.want.out, outs) {
-				t.Errorf("%+v != %+v", test.want.out, outs)
+func TestDelayJitter(t *testing.T) {
+	t.Run("each delay is within the jitter of duration", func(t *testing.T) {
+		const duration, jitter = 40 * time.Millisecond, 20 * time.Millisecond
+		clock := pipelinetest.NewFakeClock(time.Time{})
+		out := Delay(context.Background(), duration, emitN(6), WithJitter(jitter), WithClock(clock), WithRandSource(rand.NewSource(1)))
+		<-out
+		for n := 1; n < 6; n++ {
+			clock.BlockUntil(1)
+			clock.Advance(duration - jitter - time.Nanosecond)
+			assertNothing(t, out)
+			clock.Advance(2*jitter + time.Nanosecond)
+			if o := <-out; o != n {
+				t.Fatalf("out = %v, want %d", o, n)
 			}
-		})
+		}
+	})
+
+	t.Run("a canceled context stops the delays and drops the remaining inputs", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		var outs []interface{}
+		for o := range Delay(ctx, 100*time.Millisecond, emitN(10), WithJitter(50*time.Millisecond)) {
+			outs = append(outs, o)
+		}
+		if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+			t.Errorf("elapsed = %s, want well under the total delay of 1s", elapsed)
+		}
+		if want := []interface{}{0}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+}
+
+func TestDelayAbandonedOut(t *testing.T) {
+	// Nothing reads from out after the first input
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	in := emitN(10)
+	out := Delay(ctx, time.Millisecond, in)
+	<-out
+	cancel()
+
+	// Delay stops sending and drains in, so both goroutines exit
+	time.Sleep(50 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d, want <= %d", after, before)
 	}
 }
@@ -1,5 +1,7 @@
 package pipeline
 
+import "context"
+
 // Emit fans `is ...interface{}`` out to a `<-chan interface{}`
 func Emit(is ...interface{}) <-chan interface{} {
 	out := make(chan interface{})
@@ -11,3 +13,81 @@ func Emit(is ...interface{}) <-chan interface{} {
 	}()
 	return out
 }
+
+// EmitContext is like Emit, except that it stops emitting and closes the out `<-chan interface{}` when the context is canceled,
+// so its goroutine never blocks forever if the consumer goes away.
+func EmitContext(ctx context.Context, is ...interface{}) <-chan interface{} {
+	return EmitFromSlice(ctx, is)
+}
+
+// EmitFromSlice emits each element of `is` to the out `<-chan interface{}` and closes it.
+// It stops emitting when the context is canceled.
+func EmitFromSlice(ctx context.Context, is []interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for _, i := range is {
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// EmitFunc calls `next` over and over and emits its results to the out `<-chan interface{}` until it returns false,
+// which makes it the emitter for cursors, paginated APIs and other iterators that do not fit in a slice.
+// `next` is called for the following result while the previous one waits to be emitted.
+// The first error returned by `next` is sent to the errs chan, which never blocks, and stops EmitFunc.
+// When the context is canceled both chans are closed straight away, even if `next` is blocked.
+// `next` cannot be interrupted, so it should watch the context itself, but whatever it returns after the context is canceled is discarded.
+func EmitFunc(ctx context.Context, next func(ctx context.Context) (interface{}, bool, error)) (<-chan interface{}, <-chan error) {
+	type result struct {
+		i   interface{}
+		ok  bool
+		err error
+	}
+	out := make(chan interface{})
+	errs := make(chan error, 1)
+	results := make(chan result)
+	// Call next in its own goroutine so that a blocked call cannot keep out open after the context is canceled
+	go func() {
+		defer close(results)
+		for {
+			i, ok, err := next(ctx)
+			select {
+			case results <- result{i: i, ok: ok, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if !ok || err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(errs)
+		defer close(out)
+		for {
+			var r result
+			select {
+			case <-ctx.Done():
+				return
+			case r = <-results:
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if r.err != nil {
+				errs <- r.err
+				return
+			}
+			if !r.ok || !send(ctx, r.i, out) {
+				return
+			}
+		}
+	}()
+	return out, errs
+}
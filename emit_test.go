@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestEmitContext(t *testing.T) {
+	t.Run("emits every value and closes", func(t *testing.T) {
+		var outs []interface{}
+		for o := range EmitContext(context.Background(), 1, 2, 3) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("the goroutine exits when the consumer goes away and the context is canceled", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		out := EmitFromSlice(ctx, []interface{}{1, 2, 3})
+		<-out
+		cancel()
+
+		time.Sleep(10 * time.Millisecond)
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("goroutines = %d, want <= %d", after, before)
+		}
+	})
+}
+
+// countTo returns a next func for EmitFunc that counts from 1 to n
+func countTo(n int) func(context.Context) (interface{}, bool, error) {
+	i := 0
+	return func(context.Context) (interface{}, bool, error) {
+		if i == n {
+			return nil, false, nil
+		}
+		i++
+		return i, true, nil
+	}
+}
+
+func TestEmitFunc(t *testing.T) {
+	t.Run("emits every result until next returns false", func(t *testing.T) {
+		out, errs := EmitFunc(context.Background(), countTo(3))
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+	})
+
+	t.Run("the first error is sent to errs and stops emitting", func(t *testing.T) {
+		failed := errors.New("failed")
+		calls := 0
+		out, errs := EmitFunc(context.Background(), func(context.Context) (interface{}, bool, error) {
+			if calls++; calls == 3 {
+				return nil, true, failed
+			}
+			return calls, true, nil
+		})
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if err := <-errs; err != failed {
+			t.Errorf("err = %v, want %v", err, failed)
+		}
+		if calls != 3 {
+			t.Errorf("calls = %d, want 3", calls)
+		}
+	})
+
+	t.Run("out is closed while next is blocked and its late result is discarded", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		blocked, release := make(chan struct{}), make(chan struct{})
+		out, errs := EmitFunc(ctx, func(context.Context) (interface{}, bool, error) {
+			// Ignore the context like a call that cannot be interrupted
+			close(blocked)
+			<-release
+			return "late", true, errors.New("late")
+		})
+		<-blocked
+		cancel()
+		select {
+		case o, open := <-out:
+			if open {
+				t.Errorf("out = %v, want out to be closed", o)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed while next was blocked")
+		}
+		if err, open := <-errs; open {
+			t.Errorf("err = %v, want errs to be closed", err)
+		}
+
+		close(release)
+		for start := time.Now(); runtime.NumGoroutine() > before && time.Since(start) < time.Second; {
+			time.Sleep(time.Millisecond)
+		}
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("goroutines = %d, want <= %d", after, before)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package pipeline
+
+import "github.com/deliveryhero/pipeline/internal/core"
+
+// ErrCanceled matches, with errors.Is, the errors passed to `Processor.Cancel` for the inputs
+// that were not processed because the context was done.
+var ErrCanceled = core.ErrCanceled
+
+// CanceledError is passed to `Processor.Cancel` for an input that was not processed because the context was done.
+// It matches ErrCanceled and wraps the `Context.Err()`, so its error message is the same as the `Context.Err()`.
+type CanceledError = core.CanceledError
+
+// ErrUndelivered matches, with errors.Is, the errors passed to `Processor.Cancel` for the inputs
+// that were processed, but whose result was not received from the out chan before the context was done.
+// Unlike the inputs that match ErrCanceled, the side effects of `Processor.Process` happened for them.
+var ErrUndelivered = core.ErrUndelivered
+
+// UndeliveredError is passed to `Processor.Cancel` for an input whose result was not delivered because the context was done,
+// for example because the receiver of the out chan went away. It carries the result, matches ErrUndelivered and wraps the `Context.Err()`.
+type UndeliveredError = core.UndeliveredError
+
+// ProcessError is returned when `Processor.Process` fails.
+// It carries the input that could not be processed along with the original error,
+// which makes it possible to build dead-letter handling on top of ProcessWithErrors.
+// It is also what is passed to `Processor.Cancel` when `Processor.Process` fails.
+type ProcessError = core.ProcessError
+
+// PanicError is the error of `Processor.Process` when it panics, which is passed to `Processor.Cancel` wrapped in a *ProcessError.
+// Its error message is "panic: " followed by the panic value and it carries the stack trace of the panic.
+type PanicError = core.PanicError
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// EmitEvery calls `fn` every `d` and emits its results to the out `<-chan interface{}`, so that Process can poll
+// a queue or an API, or run a scheduled job, on an interval.
+// The first call happens after `d` by default, use WithImmediate to make it as soon as EmitEvery is called.
+// Ticks are skipped while `fn` or the consumer of out are too slow to keep up, so calls never pile up.
+// Errors returned by `fn` are skipped, use WithErrors to handle them.
+// EmitEvery stops and closes out when the context is canceled; a result that `fn` returns after that is never emitted.
+func EmitEvery(ctx context.Context, d time.Duration, fn func(ctx context.Context) (interface{}, error), opts ...Option) <-chan interface{} {
+	cfg := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		ticker := cfg.Clock.NewTicker(d)
+		defer ticker.Stop()
+		if cfg.immediate && !emitNext(ctx, fn, cfg.errored, out) {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				if !emitNext(ctx, fn, cfg.errored, out) {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// emitNext calls fn and sends its result to out, passing its error to errored instead if there is one.
+// It returns false once the context is canceled.
+func emitNext(ctx context.Context, fn func(ctx context.Context) (interface{}, error), errored func(err error), out chan<- interface{}) bool {
+	i, err := fn(ctx)
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		if errored != nil {
+			errored(err)
+		}
+		return true
+	}
+	return send(ctx, i, out)
+}
+
+// WithImmediate makes EmitEvery call its func as soon as it starts, rather than waiting for the first tick
+func WithImmediate() Option {
+	return func(c *config) {
+		c.immediate = true
+	}
+}
+
+// WithErrors passes the errors returned by the func of EmitEvery, the marshal func of NewSSEHandler,
+// the watcher of EmitFileChanges, the encode func and the writer of Record, or the IdempotencyStore of ProcessIdempotent with WithFailOpen
+// to `errored`, rather than skipping them silently
+func WithErrors(errored func(err error)) Option {
+	return func(c *config) {
+		c.errored = errored
+	}
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEmitEvery(t *testing.T) {
+	t.Run("fn is called on every tick until the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 105*time.Millisecond)
+		defer cancel()
+		calls := 0
+		out := EmitEvery(ctx, 10*time.Millisecond, func(context.Context) (interface{}, error) {
+			calls++
+			return calls, nil
+		})
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		// Allow for a slow scheduler but never more ticks than fit in the duration
+		if len(outs) < 5 || len(outs) > 10 {
+			t.Errorf("emitted %d times in 105ms at a 10ms interval, want between 5 and 10", len(outs))
+		}
+		for i, o := range outs {
+			if o != i+1 {
+				t.Fatalf("out = %+v, want the calls in order", outs)
+			}
+		}
+	})
+
+	t.Run("WithImmediate calls fn before the first tick", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := EmitEvery(ctx, time.Hour, func(context.Context) (interface{}, error) {
+			return "now", nil
+		}, WithImmediate())
+		select {
+		case o := <-out:
+			if o != "now" {
+				t.Errorf("out = %v, want now", o)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("nothing was emitted before the first tick")
+		}
+	})
+
+	t.Run("errors are skipped and passed to WithErrors", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		failed := errors.New("failed")
+		var mu sync.Mutex
+		var errs []error
+		calls := 0
+		out := EmitEvery(ctx, time.Millisecond, func(context.Context) (interface{}, error) {
+			if calls++; calls%2 == 1 {
+				return nil, failed
+			}
+			return calls, nil
+		}, WithErrors(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}))
+		outs := []interface{}{<-out, <-out}
+		cancel()
+		for range out {
+		}
+		if want := []interface{}{2, 4}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(errs) < 2 || errs[0] != failed {
+			t.Errorf("errs = %v, want at least 2 %v", errs, failed)
+		}
+	})
+
+	t.Run("a result returned after the context is canceled is not emitted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		called := make(chan struct{})
+		out := EmitEvery(ctx, time.Hour, func(ctx context.Context) (interface{}, error) {
+			close(called)
+			<-ctx.Done()
+			return "late", nil
+		}, WithImmediate())
+		<-called
+		cancel()
+		select {
+		case o, open := <-out:
+			if open {
+				t.Errorf("out = %v, want out to be closed", o)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed after the context was canceled")
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package pipeline
+
+import "context"
+
+// Expand calls `expand` on each input from the `in <-chan interface{}` and sends each element of the slice it returns
+// to the out `<-chan interface{}` in order, such as the records of a page of results.
+// It is implemented with Process, so the inputs that fail are passed to `cancel` with their error,
+// and once the context is canceled the remaining inputs are passed to `cancel` too.
+// The elements of an input that are not sent yet when the context is canceled are dropped.
+// `cancel` may be nil, in which case the inputs that fail are ignored.
+func Expand(ctx context.Context, expand func(ctx context.Context, i interface{}) ([]interface{}, error), cancel func(i interface{}, err error), in <-chan interface{}, opts ...Option) <-chan interface{} {
+	return Flatten(ctx, Process(ctx, expandProcessor(expand, cancel), in, opts...))
+}
+
+// ExpandConcurrently is like Expand, except that up to `concurrently` inputs are expanded at once.
+// The elements of each input are still sent in order, but the elements of different inputs can be interleaved.
+// It is implemented with ProcessConcurrently.
+func ExpandConcurrently(ctx context.Context, concurrently int, expand func(ctx context.Context, i interface{}) ([]interface{}, error), cancel func(i interface{}, err error), in <-chan interface{}, opts ...Option) <-chan interface{} {
+	return Flatten(ctx, ProcessConcurrently(ctx, concurrently, expandProcessor(expand, cancel), in, opts...))
+}
+
+// expandProcessor turns an expand func into a Processor whose outputs are the slices it returns
+func expandProcessor(expand func(ctx context.Context, i interface{}) ([]interface{}, error), cancel func(i interface{}, err error)) Processor {
+	return NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+		return expand(ctx, i)
+	}, cancel)
+}
@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// repeat expands an int n into n copies of itself, and fails on 0
+func repeat(_ context.Context, i interface{}) ([]interface{}, error) {
+	n := i.(int)
+	if n == 0 {
+		return nil, errors.New("nothing to repeat")
+	}
+	is := make([]interface{}, n)
+	for k := range is {
+		is[k] = n
+	}
+	return is, nil
+}
+
+func TestExpand(t *testing.T) {
+	t.Run("the elements of each input are sent in order", func(t *testing.T) {
+		var canceled []interface{}
+		cancel := func(i interface{}, err error) {
+			canceled = append(canceled, i)
+		}
+		var outs []interface{}
+		for o := range Expand(context.Background(), repeat, cancel, Emit(1, 0, 3, 2)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 3, 3, 3, 2, 2}; !reflect.DeepEqual(outs, want) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if want := []interface{}{0}; !reflect.DeepEqual(canceled, want) {
+			t.Errorf("canceled = %+v, want %+v", canceled, want)
+		}
+	})
+
+	t.Run("the remaining elements are dropped after the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := make(chan interface{})
+		out := Expand(ctx, repeat, nil, in)
+		in <- 1000
+		if o := <-out; o != 1000 {
+			t.Fatalf("out = %v, want 1000", o)
+		}
+		cancel()
+		close(in)
+		var n int
+		for range out {
+			n++
+		}
+		// The element that was being sent when the context was canceled can still be received
+		if n > 1 {
+			t.Errorf("got %d more elements after the context was canceled", n)
+		}
+	})
+}
+
+func TestExpandConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	var canceled []interface{}
+	cancel := func(i interface{}, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		canceled = append(canceled, i)
+	}
+	var outs []int
+	for o := range ExpandConcurrently(context.Background(), 3, repeat, cancel, Emit(1, 0, 3, 2)) {
+		outs = append(outs, o.(int))
+	}
+	sort.Ints(outs)
+	if want := []int{1, 2, 2, 3, 3, 3}; !reflect.DeepEqual(outs, want) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+	if want := []interface{}{0}; !reflect.DeepEqual(canceled, want) {
+		t.Errorf("canceled = %+v, want %+v", canceled, want)
+	}
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrExpired is passed to the cancel func of ExpireAfter with the inputs whose deadline passed before they were sent on
+var ErrExpired = errors.New("pipeline: expired")
+
+// ExpireAfter sends each input from the `in <-chan interface{}` to the out `<-chan interface{}` unless its deadline,
+// returned by `deadlineFn`, passes first, such as 30 seconds after the time of an event:
+// an input that is already expired when it is read, or that expires while it waits for the next stage to receive it,
+// is passed to `cancel` with ErrExpired instead, so that no Process call is wasted on it.
+// Unlike WithTimeout, which bounds how long an input is processed, ExpireAfter bounds how long it waits in the pipeline.
+// Use WithDropCount to count the inputs that expired. Once the context is canceled,
+// the remaining inputs are passed to `cancel` with a *CanceledError until the `in <-chan interface{}` is closed.
+// `cancel` may be nil, in which case the inputs that expire are dropped silently.
+func ExpireAfter(ctx context.Context, deadlineFn func(i interface{}) time.Time, cancel func(i interface{}, err error), in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	if cancel == nil {
+		cancel = func(interface{}, error) {}
+	}
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var expired int64
+		expire := func(i interface{}) {
+			expired++
+			cancel(i, ErrExpired)
+			c.countDrop(expired)
+		}
+		timer := c.Clock.NewTimer(time.Hour)
+		defer timer.Stop()
+		stopTimer(timer)
+		for i := range in {
+			if err := ctx.Err(); err != nil {
+				cancel(i, &CanceledError{Err: err})
+				continue
+			}
+			wait := deadlineFn(i).Sub(c.Clock.Now())
+			if wait <= 0 {
+				expire(i)
+				continue
+			}
+			timer.Reset(wait)
+			select {
+			case out <- i:
+				stopTimer(timer)
+			case <-timer.C():
+				expire(i)
+			case <-ctx.Done():
+				stopTimer(timer)
+				cancel(i, &CanceledError{Err: ctx.Err()})
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
+)
+
+func TestExpireAfter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The inputs are their own deadlines
+	deadline := func(i interface{}) time.Time {
+		return i.(time.Time)
+	}
+	type cancellation struct {
+		i   interface{}
+		err error
+	}
+
+	t.Run("the inputs that are expired when they are read are canceled", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(start)
+		var canceled []cancellation
+		var counts []int64
+		expired, late := start.Add(-time.Second), start.Add(time.Minute)
+		out := ExpireAfter(context.Background(), deadline, func(i interface{}, err error) {
+			canceled = append(canceled, cancellation{i, err})
+		}, Emit(expired, late, start), WithClock(clock), WithDropCount(func(dropped int64) {
+			counts = append(counts, dropped)
+		}))
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		if len(outs) != 1 || outs[0] != late {
+			t.Errorf("out = %v, want %v", outs, late)
+		}
+		// An input whose deadline is now is expired
+		if len(canceled) != 2 || canceled[0].i != expired || canceled[1].i != start ||
+			!errors.Is(canceled[0].err, ErrExpired) || !errors.Is(canceled[1].err, ErrExpired) {
+			t.Errorf("canceled = %+v, want %v and %v with %v", canceled, expired, start, ErrExpired)
+		}
+		if len(counts) != 2 || counts[1] != 2 {
+			t.Errorf("counts = %v, want 1 and 2", counts)
+		}
+	})
+
+	t.Run("an input that expires while it waits to be received is canceled", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(start)
+		canceled := make(chan cancellation, 1)
+		soon := start.Add(10 * time.Second)
+		out := ExpireAfter(context.Background(), deadline, func(i interface{}, err error) {
+			canceled <- cancellation{i, err}
+		}, Emit(soon), WithClock(clock))
+		clock.BlockUntil(1)
+		clock.Advance(10 * time.Second)
+		if c := <-canceled; c.i != soon || !errors.Is(c.err, ErrExpired) {
+			t.Errorf("canceled %+v, want %v with %v", c, soon, ErrExpired)
+		}
+		for o := range out {
+			t.Errorf("out = %v after it expired", o)
+		}
+	})
+
+	t.Run("the inputs are canceled once the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		clock := pipelinetest.NewFakeClock(start)
+		var canceled []cancellation
+		in := make(chan interface{})
+		out := ExpireAfter(ctx, deadline, func(i interface{}, err error) {
+			canceled = append(canceled, cancellation{i, err})
+		}, in, WithClock(clock))
+		late := start.Add(time.Minute)
+		in <- late
+		cancel()
+		in <- late
+		close(in)
+		for o := range out {
+			t.Errorf("out = %v after the context was canceled", o)
+		}
+		if len(canceled) != 2 || !errors.Is(canceled[0].err, ErrCanceled) || !errors.Is(canceled[1].err, ErrCanceled) {
+			t.Errorf("canceled = %+v, want 2 inputs with %v", canceled, ErrCanceled)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// FailFast stops a pipeline at its first failure, instead of only canceling the input that failed.
+// The stages that it is attached to with WithFailFast report each input that `Processor.Process` fails to it,
+// and the first one cancels the context returned by NewFailFast.
+// The stages that use that context then pass their remaining inputs to `Processor.Cancel`, as they do whenever their context is canceled.
+//
+//	ctx, ff := pipeline.NewFailFast(ctx)
+//	out := pipeline.ProcessConcurrently(ctx, 8, p, in, pipeline.WithFailFast(ff))
+//	for o := range out {
+//		// ...
+//	}
+//	if err := ff.Err(); err != nil {
+//		// err is the *ProcessError of the input that stopped the pipeline
+//	}
+type FailFast struct {
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	err    error
+}
+
+// NewFailFast returns a FailFast and a context derived from ctx that it cancels at the first failure
+func NewFailFast(ctx context.Context) (context.Context, *FailFast) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, &FailFast{cancel: cancel}
+}
+
+// Err returns the *ProcessError of the failure that canceled the context, or nil if nothing failed.
+// Only the first failure is kept, even when several workers fail at the same time.
+func (f *FailFast) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// Stop cancels the context of the FailFast without an error, which releases its resources once the pipeline is done
+func (f *FailFast) Stop() {
+	f.cancel()
+}
+
+// fail records err if it is the first failure and cancels the context
+func (f *FailFast) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err == nil {
+		f.err = err
+		f.cancel()
+	}
+}
+
+// WithFailFast reports the inputs that `Processor.Process` fails to `f`, so the first one stops the pipeline.
+// It applies to the process stages, including ProcessWithErrors and ProcessBatch.
+func WithFailFast(f *FailFast) Option {
+	return func(c *config) {
+		c.Failed = f.fail
+	}
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestFailFast(t *testing.T) {
+	t.Run("the first failure cancels the remaining inputs", func(t *testing.T) {
+		ctx, ff := NewFailFast(context.Background())
+		defer ff.Stop()
+		var canceled []interface{}
+		p := NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+			if i == 3 {
+				return nil, errProcess
+			}
+			return i, nil
+		}, func(i interface{}, err error) {
+			canceled = append(canceled, i)
+		})
+
+		var outs []interface{}
+		for o := range Process(ctx, p, Emit(1, 2, 3, 4, 5), WithFailFast(ff)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if want := []interface{}{3, 4, 5}; !reflect.DeepEqual(want, canceled) {
+			t.Errorf("canceled = %+v, want %+v", canceled, want)
+		}
+		var pErr *ProcessError
+		if err := ff.Err(); !errors.As(err, &pErr) || pErr.Input != 3 || !errors.Is(err, errProcess) {
+			t.Errorf("Err() = %v, want the *ProcessError of 3", err)
+		}
+	})
+
+	t.Run("one failure is reported when several workers fail at once", func(t *testing.T) {
+		const workers = 8
+		ctx, ff := NewFailFast(context.Background())
+		defer ff.Stop()
+
+		// Every worker waits until all of them are processing, then they all fail
+		var ready sync.WaitGroup
+		ready.Add(workers)
+		var mu sync.Mutex
+		var failed, canceled int
+		p := NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+			ready.Done()
+			ready.Wait()
+			return nil, errProcess
+		}, func(i interface{}, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, ErrCanceled) {
+				canceled++
+			} else {
+				failed++
+			}
+		})
+		for range ProcessConcurrently(ctx, workers, p, emitN(workers*10), WithFailFast(ff)) {
+		}
+
+		if failed != workers || canceled != workers*9 {
+			t.Errorf("failed = %d and canceled = %d, want %d and %d", failed, canceled, workers, workers*9)
+		}
+		var pErr *ProcessError
+		if err := ff.Err(); !errors.As(err, &pErr) {
+			t.Fatalf("Err() = %v, want a *ProcessError", err)
+		}
+		if err := ff.Err(); err != error(pErr) {
+			t.Errorf("Err() = %v, then %v, want the same failure", pErr, err)
+		}
+	})
+
+	t.Run("Err is nil when nothing fails", func(t *testing.T) {
+		ctx, ff := NewFailFast(context.Background())
+		defer ff.Stop()
+		for range Process(ctx, noopProcessor, Emit(1, 2, 3), WithFailFast(ff)) {
+		}
+		if err := ff.Err(); err != nil {
+			t.Errorf("Err() = %v, want nil", err)
+		}
+	})
+
+	t.Run("failures after the parent context is canceled are not reported", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		ctx, ff := NewFailFast(parent)
+		defer ff.Stop()
+		p := ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+			cancel()
+			return nil, ctx.Err()
+		})
+		for range Process(ctx, p, Emit(1, 2, 3), WithFailFast(ff)) {
+		}
+		if err := ff.Err(); err != nil {
+			t.Errorf("Err() = %v, want nil", err)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+)
+
+// Fallback creates a Processor that calls `primary.Process` first and, if it fails, `secondary.Process` with the same input.
+// Only the error of the secondary reaches the stage, and it is passed to `secondary.Cancel`, as are the inputs that are canceled
+// by the context. `primary.Cancel` is never called, since every failure of the primary is handled by the secondary.
+// If the context is canceled when the primary fails, the secondary is not called and the `Context.Err()` is returned.
+// Both Processors are set up and torn down with the Fallback, which is only cloned for each worker if both of them are WorkerCloners,
+// and the batches of canceled inputs go to the secondary if it is a BatchCanceler.
+func Fallback(primary, secondary Processor) Processor {
+	return forwardBatches(&fallback{
+		primary:   primary,
+		secondary: secondary,
+	}, secondary, passBatch)
+}
+
+// fallback implements Processor
+type fallback struct {
+	primary   Processor
+	secondary Processor
+}
+
+func (f *fallback) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	out, err := f.primary.Process(ctx, i)
+	if err == nil {
+		return out, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.secondary.Process(ctx, i)
+}
+
+func (f *fallback) Cancel(i interface{}, err error) {
+	f.secondary.Cancel(i, err)
+}
+
+func (f *fallback) CancelContext(ctx context.Context, i interface{}, err error) {
+	cancelContext(ctx, f.secondary, i, err)
+}
+
+func (f *fallback) Setup(ctx context.Context) error {
+	if err := setupWrapped(ctx, f.primary); err != nil {
+		return err
+	}
+	if err := setupWrapped(ctx, f.secondary); err != nil {
+		// The primary is not torn down by the stage when the Setup fails
+		_ = teardownWrapped(f.primary)
+		return err
+	}
+	return nil
+}
+
+func (f *fallback) Teardown() error {
+	return errors.Join(teardownWrapped(f.primary), teardownWrapped(f.secondary))
+}
+
+func (f *fallback) CloneForWorker() Processor {
+	primary, ok := cloneWrapped(f.primary)
+	if !ok {
+		return nil
+	}
+	secondary, ok := cloneWrapped(f.secondary)
+	if !ok {
+		return nil
+	}
+	return Fallback(primary, secondary)
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	errPrimary := errors.New("primary")
+	errSecondary := errors.New("secondary")
+	always := func(int) bool { return true }
+	never := func(int) bool { return false }
+
+	for _, test := range []struct {
+		name                  string
+		in                    []interface{}
+		primaryFails          func(i int) bool
+		secondaryFails        func(i int) bool
+		wantOut               []interface{}
+		wantSecondaryCanceled []interface{}
+	}{{
+		name:           "the secondary processes every input when the primary always fails",
+		in:             []interface{}{1, 2, 3},
+		primaryFails:   always,
+		secondaryFails: never,
+		wantOut:        []interface{}{"secondary 1", "secondary 2", "secondary 3"},
+	}, {
+		name:           "the secondary is not called when the primary succeeds",
+		in:             []interface{}{1, 2, 3},
+		primaryFails:   never,
+		secondaryFails: always,
+		wantOut:        []interface{}{"primary 1", "primary 2", "primary 3"},
+	}, {
+		name:                  "only the inputs that both fail are canceled",
+		in:                    []interface{}{1, 2, 3, 4, 5, 6},
+		primaryFails:          func(i int) bool { return i%2 == 0 },
+		secondaryFails:        func(i int) bool { return i%3 == 0 },
+		wantOut:               []interface{}{"primary 1", "secondary 2", "primary 3", "secondary 4", "primary 5"},
+		wantSecondaryCanceled: []interface{}{6},
+	}, {
+		name:                  "every input is canceled when both always fail",
+		in:                    []interface{}{1, 2, 3},
+		primaryFails:          always,
+		secondaryFails:        always,
+		wantSecondaryCanceled: []interface{}{1, 2, 3},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// failing tags its outputs with name and fails the inputs that fails returns true for
+			failing := func(name string, err error, fails func(i int) bool, cancel func(i interface{}, err error)) Processor {
+				return NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+					if fails(i.(int)) {
+						return nil, err
+					}
+					return fmt.Sprintf("%s %d", name, i), nil
+				}, cancel)
+			}
+			primary := failing("primary", errPrimary, test.primaryFails, func(i interface{}, err error) {
+				t.Errorf("the primary canceled %v with %s", i, err)
+			})
+			var secondaryCanceled []interface{}
+			secondary := failing("secondary", errSecondary, test.secondaryFails, func(i interface{}, err error) {
+				secondaryCanceled = append(secondaryCanceled, i)
+				// Only the error of the secondary reaches Cancel
+				if !errors.Is(err, errSecondary) || errors.Is(err, errPrimary) {
+					t.Errorf("the secondary canceled %v with %s, want %s only", i, err, errSecondary)
+				}
+			})
+
+			var outs []interface{}
+			for o := range Process(context.Background(), Fallback(primary, secondary), Emit(test.in...)) {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(test.wantOut, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.wantOut)
+			}
+			if !reflect.DeepEqual(test.wantSecondaryCanceled, secondaryCanceled) {
+				t.Errorf("the secondary canceled %+v, want %+v", secondaryCanceled, test.wantSecondaryCanceled)
+			}
+		})
+	}
+
+	t.Run("the secondary is not called once the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		primary := ProcessorFunc(func(context.Context, interface{}) (interface{}, error) {
+			cancel()
+			return nil, errPrimary
+		})
+		secondary := ProcessorFunc(func(context.Context, interface{}) (interface{}, error) {
+			t.Error("the secondary was called after the context was canceled")
+			return nil, nil
+		})
+		if _, err := Fallback(primary, secondary).Process(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want %s", err, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// FanOut distributes the inputs from the `in <-chan interface{}` across `n` out channels,
+// so each downstream consumer gets a share of the work.
+// Distribution is "first available receiver" rather than strict round-robin:
+// each out channel receives its next input as soon as its consumer is ready for it,
+// so a slow consumer only holds on to the one input it is waiting to receive and never blocks the others.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// All of the out channels are closed when the `in <-chan interface{}` is closed.
+// FanOut panics if `n` is not positive.
+func FanOut(ctx context.Context, in <-chan interface{}, n int) []<-chan interface{} {
+	if n < 1 {
+		panic(fmt.Sprintf("pipeline: fan out must be positive, got %d", n))
+	}
+	outs := make([]<-chan interface{}, n)
+	for k := range outs {
+		out := make(chan interface{})
+		outs[k] = out
+		go func() {
+			defer close(out)
+			for i := range in {
+				if !send(ctx, i, out) {
+					break
+				}
+			}
+			discard(in)
+		}()
+	}
+	return outs
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFanOut(t *testing.T) {
+	t.Run("every input is received by exactly one out", func(t *testing.T) {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+		}()
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var outs []int
+		counts := make([]int, 4)
+		for k, out := range FanOut(context.Background(), in, 4) {
+			wg.Add(1)
+			go func(k int, out <-chan interface{}) {
+				defer wg.Done()
+				for o := range out {
+					mu.Lock()
+					outs = append(outs, o.(int))
+					counts[k]++
+					mu.Unlock()
+				}
+			}(k, out)
+		}
+		wg.Wait()
+
+		sort.Ints(outs)
+		if len(outs) != 100 {
+			t.Fatalf("len(out) = %d, want 100", len(outs))
+		}
+		for i, o := range outs {
+			if i != o {
+				t.Fatalf("out[%d] = %d, want %d", i, o, i)
+			}
+		}
+		for k, count := range counts {
+			if count == 0 {
+				t.Errorf("out %d received nothing", k)
+			}
+		}
+	})
+
+	t.Run("a slow consumer does not block the others", func(t *testing.T) {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+		}()
+		outs := FanOut(context.Background(), in, 2)
+
+		// Read one input from the slow out and then stop reading until the fast out is done
+		slow, fast := outs[0], outs[1]
+		<-slow
+		var count int
+		timeout := time.After(time.Second)
+	loop:
+		for {
+			select {
+			case _, open := <-fast:
+				if !open {
+					break loop
+				}
+				count++
+			case <-timeout:
+				t.Fatal("the fast out was blocked by the slow out")
+			}
+		}
+
+		// The slow out only holds on to the input it is waiting to send
+		for range slow {
+			count++
+		}
+		if count != 99 {
+			t.Errorf("count = %d, want 99", count)
+		}
+	})
+
+	t.Run("the inputs are drained once the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		outs := FanOut(ctx, in, 2)
+		cancel()
+		// No out is read, so the producer only finishes if FanOut drains the inputs
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+			close(in)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the producer was blocked after the context was canceled")
+		}
+		for _, out := range outs {
+			for range out {
+			}
+		}
+	})
+
+	t.Run("a count that is not positive panics", func(t *testing.T) {
+		for _, n := range []int{0, -1} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("FanOut(%d) did not panic", n)
+					}
+				}()
+				FanOut(context.Background(), Emit(1, 2, 3), n)
+			}()
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// FileOp is what happened to a file reported by a FileWatcher
+type FileOp int
+
+const (
+	// FileCreated is reported when a file appears in the directory, including when it is renamed into it
+	FileCreated FileOp = iota
+	// FileWritten is reported when a file is modified
+	FileWritten
+	// FileRemoved is reported when a file is removed from the directory, including when it is renamed out of it
+	FileRemoved
+)
+
+// FileEvent is a change of a file reported by a FileWatcher
+type FileEvent struct {
+	// Name is the path of the file, in the directory that is watched
+	Name string
+	Op   FileOp
+}
+
+// FileWatcher reports the changes of the files of a directory, the way an fsnotify.Watcher does.
+// Wrap an fsnotify.Watcher in a FileWatcher and pass it to EmitFileChanges with WithFileWatcher
+// to be notified by the OS rather than poll the directory.
+type FileWatcher interface {
+	Events() <-chan FileEvent
+	// Errors reports the errors of the watcher, which keeps watching after them
+	Errors() <-chan error
+	// Close stops the watcher and closes its chans
+	Close() error
+}
+
+// defaultQuietPeriod is how long a file must go unchanged before EmitFileChanges emits it when WithQuietPeriod is not set
+const defaultQuietPeriod = 100 * time.Millisecond
+
+// pollInterval is how often the default FileWatcher of EmitFileChanges reads the directory
+var pollInterval = time.Second
+
+// EmitFileChanges emits the paths of the files of `dir` whose names match `pattern`, as in filepath.Match,
+// when they are created or modified, which turns Process into a hot folder processor.
+// The files that are already in `dir` are not emitted, and the subdirectories are not watched.
+// A path is only emitted once the file has not changed for 100ms, use WithQuietPeriod to wait longer
+// for slow writers, so that a file written in many chunks is emitted once.
+// The errors of the watcher are skipped, use WithErrors to handle them; the watch goes on after them.
+// By default `dir` is read every second, use WithFileWatcher to be notified by fsnotify instead.
+// The out chan is closed when the context is canceled, or right away if the watcher fails to start.
+// EmitFileChanges panics if `pattern` is malformed.
+func EmitFileChanges(ctx context.Context, dir, pattern string, opts ...Option) <-chan interface{} {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("pipeline: bad file pattern %q", pattern))
+	}
+	cfg := newConfig(opts)
+	if cfg.quiet <= 0 {
+		cfg.quiet = defaultQuietPeriod
+	}
+	if cfg.newWatcher == nil {
+		cfg.newWatcher = func(dir string) (FileWatcher, error) {
+			return newPollWatcher(dir, pollInterval)
+		}
+	}
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		w, err := cfg.newWatcher(dir)
+		if err != nil {
+			if cfg.errored != nil {
+				cfg.errored(err)
+			}
+			return
+		}
+		defer w.Close()
+		emitFileChanges(ctx, w, pattern, cfg, out)
+	}()
+	return out
+}
+
+// emitFileChanges debounces the events of w for each file, and sends the paths that are quiet to out
+func emitFileChanges(ctx context.Context, w FileWatcher, pattern string, cfg *config, out chan<- interface{}) {
+	timer := cfg.Clock.NewTimer(cfg.quiet)
+	defer timer.Stop()
+	stopTimer(timer)
+	// quietAt is when each changed file becomes quiet
+	quietAt := map[string]time.Time{}
+	events, errs := w.Events(), w.Errors()
+	for {
+		select {
+		case e, open := <-events:
+			if !open {
+				return
+			}
+			if ok, _ := filepath.Match(pattern, filepath.Base(e.Name)); !ok {
+				continue
+			}
+			if e.Op == FileRemoved {
+				delete(quietAt, e.Name)
+				continue
+			}
+			if len(quietAt) == 0 {
+				timer.Reset(cfg.quiet)
+			}
+			quietAt[e.Name] = cfg.Clock.Now().Add(cfg.quiet)
+		case err, open := <-errs:
+			if !open {
+				errs = nil
+				continue
+			}
+			if cfg.errored != nil {
+				cfg.errored(err)
+			}
+		case now := <-timer.C():
+			var quiet []string
+			next := time.Duration(-1)
+			for name, at := range quietAt {
+				if wait := at.Sub(now); wait > 0 {
+					if next < 0 || wait < next {
+						next = wait
+					}
+					continue
+				}
+				quiet = append(quiet, name)
+			}
+			sort.Slice(quiet, func(a, b int) bool { return quietAt[quiet[a]].Before(quietAt[quiet[b]]) })
+			for _, name := range quiet {
+				delete(quietAt, name)
+				if !send(ctx, name, out) {
+					return
+				}
+			}
+			if next >= 0 {
+				timer.Reset(next)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WithQuietPeriod sets how long a file must go unchanged before EmitFileChanges emits it, 100ms by default
+func WithQuietPeriod(quiet time.Duration) Option {
+	return func(c *config) {
+		c.quiet = quiet
+	}
+}
+
+// WithFileWatcher makes EmitFileChanges watch its directory with the FileWatcher returned by `newWatcher`
+func WithFileWatcher(newWatcher func(dir string) (FileWatcher, error)) Option {
+	return func(c *config) {
+		c.newWatcher = newWatcher
+	}
+}
+
+// pollWatcher is a FileWatcher that reads its directory on an interval and compares the files with the last read
+type pollWatcher struct {
+	events chan FileEvent
+	errs   chan error
+	done   chan struct{}
+}
+
+// newPollWatcher reads dir, and then watches its files by reading it again every interval
+func newPollWatcher(dir string, interval time.Duration) (*pollWatcher, error) {
+	files, err := readFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &pollWatcher{
+		events: make(chan FileEvent),
+		errs:   make(chan error),
+		done:   make(chan struct{}),
+	}
+	go w.poll(dir, interval, files)
+	return w, nil
+}
+
+func (w *pollWatcher) poll(dir string, interval time.Duration, files map[string]os.FileInfo) {
+	defer close(w.events)
+	defer close(w.errs)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.done:
+			return
+		}
+		current, err := readFiles(dir)
+		if err != nil {
+			select {
+			case w.errs <- err:
+				continue
+			case <-w.done:
+				return
+			}
+		}
+		for _, e := range diffFiles(files, current) {
+			select {
+			case w.events <- e:
+			case <-w.done:
+				return
+			}
+		}
+		files = current
+	}
+}
+
+func (w *pollWatcher) Events() <-chan FileEvent {
+	return w.events
+}
+
+func (w *pollWatcher) Errors() <-chan error {
+	return w.errs
+}
+
+func (w *pollWatcher) Close() error {
+	close(w.done)
+	return nil
+}
+
+// readFiles returns the info of the files of dir by path
+func readFiles(dir string) (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]os.FileInfo, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		// A file can be removed between ReadDir and Info, in which case it is left for the next read
+		if info, err := entry.Info(); err == nil {
+			files[filepath.Join(dir, entry.Name())] = info
+		}
+	}
+	return files, nil
+}
+
+// diffFiles returns the events that turn the files of before into the files of after
+func diffFiles(before, after map[string]os.FileInfo) []FileEvent {
+	var events []FileEvent
+	for name, info := range after {
+		if prev, ok := before[name]; !ok {
+			events = append(events, FileEvent{Name: name, Op: FileCreated})
+		} else if !info.ModTime().Equal(prev.ModTime()) || info.Size() != prev.Size() {
+			events = append(events, FileEvent{Name: name, Op: FileWritten})
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			events = append(events, FileEvent{Name: name, Op: FileRemoved})
+		}
+	}
+	return events
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeWatcher is a FileWatcher whose events and errors are sent by the test
+type fakeWatcher struct {
+	events chan FileEvent
+	errs   chan error
+	closed chan struct{}
+}
+
+func newFakeWatcher() *fakeWatcher {
+	return &fakeWatcher{
+		events: make(chan FileEvent),
+		errs:   make(chan error),
+		closed: make(chan struct{}),
+	}
+}
+
+func (w *fakeWatcher) Events() <-chan FileEvent { return w.events }
+func (w *fakeWatcher) Errors() <-chan error     { return w.errs }
+func (w *fakeWatcher) Close() error {
+	close(w.closed)
+	return nil
+}
+
+func TestEmitFileChanges(t *testing.T) {
+	const quiet = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := newFakeWatcher()
+	var errs []error
+	out := EmitFileChanges(ctx, "in", "*.csv",
+		WithQuietPeriod(quiet),
+		WithErrors(func(err error) { errs = append(errs, err) }),
+		WithFileWatcher(func(dir string) (FileWatcher, error) {
+			if dir != "in" {
+				t.Errorf("dir = %q, want in", dir)
+			}
+			return w, nil
+		}),
+	)
+
+	// a.csv is written in chunks, b.txt does not match, and c.csv is removed before it is quiet
+	w.events <- FileEvent{Name: "in/a.csv", Op: FileCreated}
+	w.events <- FileEvent{Name: "in/b.txt", Op: FileCreated}
+	w.events <- FileEvent{Name: "in/c.csv", Op: FileCreated}
+	w.events <- FileEvent{Name: "in/c.csv", Op: FileRemoved}
+	w.errs <- errors.New("overflow")
+	for i := 0; i < 5; i++ {
+		time.Sleep(quiet / 5)
+		w.events <- FileEvent{Name: "in/a.csv", Op: FileWritten}
+	}
+	w.events <- FileEvent{Name: "in/d.csv", Op: FileCreated}
+
+	var got []interface{}
+	for len(got) < 2 {
+		select {
+		case path := <-out:
+			got = append(got, path)
+		case <-time.After(time.Second):
+			t.Fatalf("got %v, want 2 paths", got)
+		}
+	}
+	if want := []interface{}{"in/a.csv", "in/d.csv"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("paths = %v, want %v", got, want)
+	}
+	select {
+	case path := <-out:
+		t.Errorf("got %v, want each path once", path)
+	case <-time.After(2 * quiet):
+	}
+	if len(errs) != 1 {
+		t.Errorf("errs = %v, want the error of the watcher", errs)
+	}
+
+	cancel()
+	if _, open := <-out; open {
+		t.Error("out is open after the context is canceled")
+	}
+	select {
+	case <-w.closed:
+	case <-time.After(time.Second):
+		t.Error("the watcher was not closed")
+	}
+}
+
+func TestEmitFileChanges_poll(t *testing.T) {
+	defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+	pollInterval = 5 * time.Millisecond
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "old.csv"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := EmitFileChanges(ctx, dir, "*.csv", WithQuietPeriod(20*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	path := filepath.Join(dir, "new.csv")
+	if err := os.WriteFile(path, []byte("a,b"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-out:
+		if got != path {
+			t.Errorf("path = %v, want %v", got, path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the new file was not emitted")
+	}
+
+	var errs []error
+	out = EmitFileChanges(ctx, filepath.Join(dir, "missing"), "*", WithErrors(func(err error) { errs = append(errs, err) }))
+	if _, open := <-out; open || len(errs) != 1 {
+		t.Errorf("open = %t, errs = %v, want out closed with the error of the missing dir", open, errs)
+	}
+}
@@ -0,0 +1,28 @@
+package pipeline
+
+import "context"
+
+// Filter passes the inputs from the `in <-chan interface{}` that `filter` returns true for to the out `<-chan interface{}`.
+// All other inputs are discarded.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed,
+// even if nothing is reading from the out `<-chan interface{}` anymore.
+func Filter(ctx context.Context, filter func(i interface{}) bool, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for i := range in {
+			if !filter(i) {
+				continue
+			}
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before Filter are never blocked
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	isEven := func(i interface{}) bool {
+		return i.(int)%2 == 0
+	}
+
+	t.Run("only matching inputs are passed through", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Filter(context.Background(), isEven, Emit(1, 2, 3, 4, 5, 6)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{2, 4, 6}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("out closes with zero items when nothing matches", func(t *testing.T) {
+		never := func(interface{}) bool { return false }
+		for o := range Filter(context.Background(), never, Emit(1, 2, 3)) {
+			t.Errorf("%v should have been filtered", o)
+		}
+	})
+
+	t.Run("out closes when the context is canceled mid-stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+		}()
+		out := Filter(ctx, isEven, in)
+
+		// Read a few inputs, then stop reading and cancel the context
+		<-out
+		<-out
+		cancel()
+
+		select {
+		case <-waitClosed(out):
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed after the context was canceled")
+		}
+	})
+}
+
+// waitClosed discards everything from in and closes the returned chan once in is closed
+func waitClosed(in <-chan interface{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range in {
+		}
+	}()
+	return done
+}
@@ -0,0 +1,30 @@
+package pipeline
+
+import "context"
+
+// Flatten is the inverse of Collect: it sends the elements of each `[]interface{}` from the `in <-chan interface{}`
+// to the out `<-chan interface{}` one at a time, in order, such as the results of ProcessBatch.
+// Like Split, a Tracked `[]interface{}` is flattened into a Tracked per element, which acknowledge it once all of them are acknowledged.
+// Flatten stops between the elements of a slice when the context is canceled, so a large slice does not delay the shutdown:
+// the remaining elements and inputs are dropped until the `in <-chan interface{}` is closed.
+//
+// `generic.Flatten` reads a `<-chan []T` and returns the elements from a `<-chan T`.
+func Flatten(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for is := range in {
+			if t, ok := is.(Tracked); ok {
+				is = splitTracked(t)
+			}
+			for _, i := range is.([]interface{}) {
+				// Check the context first, since send may pick the out chan even after it is canceled
+				if ctx.Err() != nil || !send(ctx, i, out) {
+					dropAll(in)
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
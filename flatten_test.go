@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	t.Run("the elements of each slice are sent in order", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Flatten(context.Background(), Emit([]interface{}{1, 2}, []interface{}{}, []interface{}{3})) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(outs, want) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("a large slice stops when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Flatten(ctx, Emit(make([]interface{}, 1000), []interface{}{1}))
+		<-out
+		cancel()
+		var n int
+		for range out {
+			n++
+		}
+		// The element that was being sent when the context was canceled can still be received
+		if n > 1 {
+			t.Errorf("got %d more elements after the context was canceled", n)
+		}
+	})
+
+	t.Run("a Tracked slice is acknowledged once its elements are", func(t *testing.T) {
+		acked := make(chan error, 1)
+		tracked := Tracked{Value: []interface{}{1, 2}, Ack: func(err error) { acked <- err }}
+		for o := range Flatten(context.Background(), Emit(tracked)) {
+			o.(Tracked).Ack(nil)
+		}
+		if err := <-acked; err != nil {
+			t.Errorf("acked with %v, want nil", err)
+		}
+	})
+}
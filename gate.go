@@ -0,0 +1,66 @@
+package pipeline
+
+import "context"
+
+// Gate passes the inputs from the `in <-chan interface{}` to the out `<-chan interface{}` while the last value read from `allow`
+// is true, and stops reading from in while it is false, which pushes back on the stages before Gate until it is true again.
+// It lets a signal from outside of the pipeline, such as the 429s or the queue depth of a dependency, pause the flow:
+//
+//	allow := make(chan bool)
+//	out := pipeline.Gate(ctx, allow, in)
+//	// ...
+//	allow <- false // slow down
+//
+// The gate is open until it reads false. It never drops an input: an input it read before it was closed is sent once it opens again.
+// Once `allow` is closed, the gate stays open for good, or closed with WithGateLockedOnClose.
+// The out chan is closed once the `in <-chan interface{}` is closed and every input was sent.
+// After the context is canceled, the out chan is closed and the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func Gate(ctx context.Context, allow <-chan bool, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		open := true
+		var pending interface{}
+		held := false
+		for {
+			// Read an input while the gate is open, then send it while the gate is open
+			var read <-chan interface{}
+			var send chan<- interface{}
+			if open && !held {
+				read = in
+			} else if open {
+				send = out
+			}
+			select {
+			case a, ok := <-allow:
+				if !ok {
+					allow = nil
+					open = !c.gateLocked
+					continue
+				}
+				open = a
+			case i, ok := <-read:
+				if !ok {
+					return
+				}
+				pending, held = i, true
+			case send <- pending:
+				pending, held = nil, false
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before Gate are never blocked
+				discard(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithGateLockedOnClose makes Gate stay closed once its `allow` chan is closed, rather than open,
+// so that it sends no more inputs until the context is canceled
+func WithGateLockedOnClose() Option {
+	return func(c *config) {
+		c.gateLocked = true
+	}
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGate(t *testing.T) {
+	// inputs returns a closed chan of is
+	inputs := func(is ...interface{}) <-chan interface{} {
+		in := make(chan interface{}, len(is))
+		for _, i := range is {
+			in <- i
+		}
+		close(in)
+		return in
+	}
+	// checkPaused fails the test if out sends anything for a moment
+	checkPaused := func(t *testing.T, out <-chan interface{}) {
+		t.Helper()
+		select {
+		case o := <-out:
+			t.Fatalf("out = %v while the gate is closed", o)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	t.Run("the gate is open until it reads false", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Gate(context.Background(), make(chan bool), Emit(1, 2, 3)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("a closed gate sends nothing until it opens again and drops nothing", func(t *testing.T) {
+		allow := make(chan bool)
+		out := Gate(context.Background(), allow, inputs(1, 2, 3))
+		if o := <-out; o != 1 {
+			t.Fatalf("out = %v, want 1", o)
+		}
+		allow <- false
+		checkPaused(t, out)
+		allow <- true
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("the gate opens for good once allow is closed", func(t *testing.T) {
+		allow := make(chan bool)
+		out := Gate(context.Background(), allow, inputs(1, 2))
+		allow <- false
+		checkPaused(t, out)
+		close(allow)
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("WithGateLockedOnClose closes the gate for good once allow is closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		allow := make(chan bool)
+		out := Gate(ctx, allow, inputs(1, 2), WithGateLockedOnClose())
+		allow <- false
+		close(allow)
+		checkPaused(t, out)
+		cancel()
+		for o := range out {
+			t.Errorf("out = %v, want none", o)
+		}
+	})
+}
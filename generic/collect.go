@@ -0,0 +1,90 @@
+package generic
+
+import (
+	"context"
+	"time"
+)
+
+// Collect collects `T`s from its in channel and returns `[]T` from its out channel.
+// A batch is sent to the out channel as soon as it contains `maxSize` items,
+// or `maxDuration` after the first item of the batch was received, whichever comes first.
+// No empty batches are ever sent.
+// A partially filled batch is flushed when the in channel closes or the context is canceled.
+// After the context is canceled, batches are flushed as soon as the in channel has no more items ready to be read.
+func Collect[T any](ctx context.Context, maxSize int, maxDuration time.Duration, in <-chan T) <-chan []T {
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		var batch []T
+		timer := time.NewTimer(maxDuration)
+		stopTimer(timer)
+		defer timer.Stop()
+		// flush sends the batch to the out channel and starts a new one
+		flush := func() {
+			stopTimer(timer)
+			if len(batch) > 0 {
+				out <- batch
+				batch = nil
+			}
+		}
+		done, canceled := ctx.Done(), false
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					flush()
+					return
+				}
+				batch = append(batch, i)
+				if len(batch) == 1 {
+					// Start timing the batch when its first item arrives
+					timer.Reset(maxDuration)
+				}
+				if canceled {
+					// The context is canceled, so only collect the items that are ready
+					batch, open = collectReady(maxSize, batch, in)
+					flush()
+					if !open {
+						return
+					}
+				} else if len(batch) >= maxSize {
+					flush()
+				}
+			case <-timer.C:
+				flush()
+			case <-done:
+				// Stop waiting for the timer and never select on the context again
+				done, canceled = nil, true
+				flush()
+			}
+		}
+	}()
+	return out
+}
+
+// collectReady appends items from in to batch until batch contains maxSize items or in has no items ready.
+// It returns false if in is closed.
+func collectReady[T any](maxSize int, batch []T, in <-chan T) ([]T, bool) {
+	for len(batch) < maxSize {
+		select {
+		case i, open := <-in:
+			if !open {
+				return batch, false
+			}
+			batch = append(batch, i)
+		default:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// stopTimer stops a timer and drains its channel so that it can be safely reset
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
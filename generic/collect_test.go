@@ -0,0 +1,167 @@
+package generic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollect(t *testing.T) {
+	const maxTestDuration = time.Second
+	type args struct {
+		maxSize     int
+		maxDuration time.Duration
+		in          []int
+		inDelay     time.Duration
+		keepOpen    bool
+		ctxTimeout  time.Duration
+	}
+	type want struct {
+		out  [][]int
+		open bool
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want want
+	}{{
+		name: "out closes when in closes",
+		args: args{
+			maxSize:     20,
+			maxDuration: maxTestDuration,
+			ctxTimeout:  maxTestDuration,
+		},
+		want: want{
+			out:  nil,
+			open: false,
+		},
+	}, {
+		name: "a partial batch is flushed when in closes",
+		args: args{
+			maxSize:     20,
+			maxDuration: maxTestDuration,
+			in:          []int{1, 2, 3},
+			ctxTimeout:  2 * maxTestDuration,
+		},
+		want: want{
+			out:  [][]int{{1, 2, 3}},
+			open: false,
+		},
+	}, {
+		name: "every item is its own batch when maxSize is 1",
+		args: args{
+			maxSize:     1,
+			maxDuration: maxTestDuration,
+			in:          []int{1, 2, 3},
+			ctxTimeout:  2 * maxTestDuration,
+		},
+		want: want{
+			out:  [][]int{{1}, {2}, {3}},
+			open: false,
+		},
+	}, {
+		name: "nothing is emitted when in is quiet",
+		args: args{
+			maxSize:     2,
+			maxDuration: maxTestDuration / 10,
+			keepOpen:    true,
+			ctxTimeout:  2 * maxTestDuration,
+		},
+		want: want{
+			out:  nil,
+			open: true,
+		},
+	}, {
+		name: "maxDuration is measured from the first item of the batch",
+		args: args{
+			maxSize:     10,
+			maxDuration: maxTestDuration / 4,
+			inDelay:     maxTestDuration / 3,
+			in:          []int{1, 2},
+			keepOpen:    true,
+			ctxTimeout:  2 * maxTestDuration,
+		},
+		want: want{
+			// 1 arrives at 333ms and is flushed at 583ms, 2 arrives at 666ms and is flushed at 916ms
+			out:  [][]int{{1}, {2}},
+			open: true,
+		},
+	}, {
+		name: "a partial batch is flushed when the context is canceled",
+		args: args{
+			maxSize:     10,
+			maxDuration: 2 * maxTestDuration,
+			in:          []int{1, 2, 3},
+			keepOpen:    true,
+			ctxTimeout:  maxTestDuration / 2,
+		},
+		want: want{
+			out:  [][]int{{1, 2, 3}},
+			open: true,
+		},
+	}, {
+		name: "items are flushed as soon as they are ready after the context is canceled",
+		args: args{
+			maxSize:     10,
+			maxDuration: 2 * maxTestDuration,
+			inDelay:     maxTestDuration / 4,
+			in:          []int{1, 2, 3},
+			ctxTimeout:  0,
+		},
+		want: want{
+			out:  [][]int{{1}, {2}, {3}},
+			open: false,
+		},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			// Create the in channel
+			in := make(chan int)
+			args := test.args
+			go func() {
+				for _, i := range args.in {
+					time.Sleep(args.inDelay)
+					in <- i
+				}
+				if !args.keepOpen {
+					close(in)
+				}
+			}()
+
+			// Create the context
+			ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+			defer cancel()
+
+			// Collect responses
+			collect := Collect(ctx, test.args.maxSize, test.args.maxDuration, in)
+			timeout := time.After(maxTestDuration)
+			var outs [][]int
+			var isOpen bool
+		loop:
+			for {
+				select {
+				case out, open := <-collect:
+					if !open {
+						isOpen = false
+						break loop
+					}
+					isOpen = true
+					outs = append(outs, out)
+				case <-timeout:
+					isOpen = true
+					break loop
+				}
+			}
+
+			// Expecting to close or stay open
+			if test.want.open != isOpen {
+				t.Errorf("open = %t, want %t", isOpen, test.want.open)
+			}
+
+			// Expecting outputs
+			if !reflect.DeepEqual(test.want.out, outs) {
+				t.Errorf("out = %v, want %v", outs, test.want.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package generic
+
+import "context"
+
+// Emit fans `is ...T` out to a `<-chan T`.
+// It stops emitting and closes the out `<-chan T` when the context is canceled,
+// so its goroutine never blocks forever if the consumer goes away.
+func Emit[T any](ctx context.Context, is ...T) <-chan T {
+	return EmitFromSlice(ctx, is)
+}
+
+// EmitFromSlice emits each element of `is` to the out `<-chan T` and closes it.
+// It stops emitting when the context is canceled.
+func EmitFromSlice[T any](ctx context.Context, is []T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, i := range is {
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,31 @@
+package generic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestEmit(t *testing.T) {
+	t.Run("emits every value and closes", func(t *testing.T) {
+		var outs []payload
+		for o := range Emit(context.Background(), newPayload(1), newPayload(2)) {
+			outs = append(outs, o)
+		}
+		if want := []payload{newPayload(1), newPayload(2)}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("stops emitting when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := EmitFromSlice(ctx, []int{1, 2, 3})
+		<-out
+		cancel()
+		for o := range out {
+			if o == 3 {
+				t.Errorf("%d was emitted after the context was canceled", o)
+			}
+		}
+	})
+}
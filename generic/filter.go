@@ -0,0 +1,28 @@
+package generic
+
+import "context"
+
+// Filter passes the inputs from the `in <-chan T` that `filter` returns true for to the out `<-chan T`.
+// All other inputs are discarded.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan T` is closed,
+// even if nothing is reading from the out `<-chan T` anymore.
+func Filter[T any](ctx context.Context, filter func(i T) bool, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := range in {
+			if !filter(i) {
+				continue
+			}
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before Filter are never blocked
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
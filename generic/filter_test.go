@@ -0,0 +1,57 @@
+package generic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	isEven := func(i int) bool {
+		return i%2 == 0
+	}
+
+	t.Run("only matching inputs are passed through", func(t *testing.T) {
+		var outs []int
+		for o := range Filter(context.Background(), isEven, Emit(context.Background(), 1, 2, 3, 4, 5, 6)) {
+			outs = append(outs, o)
+		}
+		if want := []int{2, 4, 6}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("out closes with zero items when nothing matches", func(t *testing.T) {
+		never := func(payload) bool { return false }
+		for o := range Filter(context.Background(), never, Emit(context.Background(), newPayload(1), newPayload(2))) {
+			t.Errorf("%v should have been filtered", o)
+		}
+	})
+
+	t.Run("the stage stops when the context is canceled mid-stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int)
+		go func() {
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+		}()
+		out := Filter(ctx, isEven, in)
+
+		// Read one input, then stop reading and cancel the context
+		<-out
+		cancel()
+
+		// The stage drains in without anyone reading from out
+		time.Sleep(100 * time.Millisecond)
+		count := 0
+		for range out {
+			count++
+		}
+		if count > 1 {
+			t.Errorf("%d inputs were sent after the context was canceled", count)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package generic
+
+import "context"
+
+// Flatten is the inverse of Collect: it sends the elements of each `[]T` from the `in <-chan []T`
+// to the out `<-chan T` one at a time, in order.
+// Flatten stops between the elements of a slice when the context is canceled, so a large slice does not delay the shutdown:
+// the remaining elements and inputs are dropped until the `in <-chan []T` is closed.
+func Flatten[T any](ctx context.Context, in <-chan []T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for is := range in {
+			if !flatten(ctx, is, out) {
+				drain(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// flatten sends the elements of is to out, and returns false if the context is canceled first
+func flatten[T any](ctx context.Context, is []T, out chan<- T) bool {
+	for _, i := range is {
+		// Check the context first, since the select may pick the out chan even after it is canceled
+		if ctx.Err() != nil {
+			return false
+		}
+		select {
+		case out <- i:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
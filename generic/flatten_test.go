@@ -0,0 +1,35 @@
+package generic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	t.Run("the elements of each slice are sent in order", func(t *testing.T) {
+		var outs []int
+		for o := range Flatten(context.Background(), Emit(context.Background(), []int{1, 2}, nil, []int{3})) {
+			outs = append(outs, o)
+		}
+		if want := []int{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("a large slice stops when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Flatten(ctx, Emit(context.Background(), make([]int, 1000), []int{1}))
+		<-out
+		cancel()
+		var n int
+		for range out {
+			n++
+		}
+		// The element that was being sent when the context was canceled can still be received
+		if n > 1 {
+			t.Errorf("got %d more elements after the context was canceled", n)
+		}
+	})
+}
@@ -0,0 +1,20 @@
+// Package generic is the type-safe version of the pipeline package.
+// Every stage is parameterized by the types flowing through its channels,
+// so pipelines are checked at compile time instead of with type assertions.
+//
+// Example Usage
+//
+//	// itoa converts ints to strings
+//	type itoa struct{}
+//
+//	func (itoa) Process(_ context.Context, i int) (string, error) {
+//		return strconv.Itoa(i), nil
+//	}
+//
+//	func (itoa) Cancel(i int, err error) {
+//		log.Printf("could not convert %d: %s", i, err)
+//	}
+//
+//	// strs is a <-chan string
+//	strs := generic.Process[int, string](ctx, itoa{}, ints)
+package generic
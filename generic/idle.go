@@ -0,0 +1,22 @@
+package generic
+
+import "github.com/deliveryhero/pipeline/internal/core"
+
+// IdleNotifier counts the inputs in flight in the stages that report to it with WithIdleNotifier,
+// and tells when none has been for a while, such as to commit the work of a source that never closes its chan.
+// An input is in flight from the time a process stage reads it until the stage sends its result or cancels it.
+// The inputs in the chans between the stages are not counted, so the `quiet` period of `IdleNotifier.AwaitIdle`
+// must be longer than the stages take to hand over an input.
+type IdleNotifier = core.IdleNotifier
+
+// NewIdleNotifier returns an IdleNotifier with no input in flight
+func NewIdleNotifier() *IdleNotifier {
+	return core.NewIdleNotifier(core.RealClock{})
+}
+
+// WithIdleNotifier makes the process stages count the inputs they hold in `idle`, see IdleNotifier
+func WithIdleNotifier(idle *IdleNotifier) Option {
+	return func(c *config) {
+		c.Idle = idle
+	}
+}
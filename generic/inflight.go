@@ -0,0 +1,45 @@
+package generic
+
+import "github.com/deliveryhero/pipeline/internal/core"
+
+// InflightLimit bounds the number of items in flight across a chain of stages, see pipeline.InflightLimit
+type InflightLimit = core.InflightLimit
+
+// NewInflightLimit returns an InflightLimit of `n` slots.
+// It panics if `n` is not positive.
+func NewInflightLimit(n int) *InflightLimit {
+	return core.NewInflightLimit(n)
+}
+
+// WithInflightLimit makes Process, ProcessConcurrently and ProcessConcurrentlyOrdered take a slot of `lim`
+// before they read each input, and release the slots of the inputs they cancel.
+// Each worker holds a slot while it waits for an input, so `lim` should have more slots than the stage has workers.
+func WithInflightLimit(lim *InflightLimit) Option {
+	return func(c *config) {
+		c.InflightAcquire = lim
+		c.InflightRelease = lim
+	}
+}
+
+// WithInflightRelease makes the process stages release the slots of `lim` of the inputs they cancel,
+// for the stages that come after the one with WithInflightLimit
+func WithInflightRelease(lim *InflightLimit) Option {
+	return func(c *config) {
+		c.InflightRelease = lim
+	}
+}
+
+// ReleaseInflight sends each input of the `in <-chan T` to the out chan and then releases its slot of `lim`,
+// at the end of the chain of stages that `lim` bounds.
+// The out chan is closed when the `in <-chan T` is closed.
+func ReleaseInflight[T any](lim *InflightLimit, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := range in {
+			out <- i
+			lim.Release(1)
+		}
+	}()
+	return out
+}
@@ -0,0 +1,22 @@
+package generic
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEmitLines(t *testing.T) {
+	lines, errs := EmitLines(context.Background(), strings.NewReader("a\nb\n"+strings.Repeat("x", 100000)), WithMaxLineSize(200000))
+	var outs []string
+	for l := range lines {
+		outs = append(outs, l)
+	}
+	if want := []string{"a", "b", strings.Repeat("x", 100000)}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("got %d lines, want %d", len(outs), len(want))
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("err = %s, want nil", err)
+	}
+}
@@ -0,0 +1,14 @@
+package generic
+
+import "log/slog"
+
+// WithLogger makes the stages log to `logger`:
+// the inputs passed to `Processor.Cancel` are logged with their error, as errors when `Processor.Process` failed,
+// and at the debug level when the context was canceled.
+// The process stages also log when they start and stop.
+// Nothing is logged by default, nor for the inputs that are processed successfully, so a logger never slows down the path of the results.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.Logger = logger
+	}
+}
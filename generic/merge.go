@@ -0,0 +1,36 @@
+package generic
+
+import "sync"
+
+// Merge fans multiple channels in to a single channel.
+// The out channel is closed after all of the ins are closed.
+func Merge[T any](ins ...<-chan T) <-chan T {
+	// Don't merge anything if we don't have to
+	if l := len(ins); l == 0 {
+		out := make(chan T)
+		close(out)
+		return out
+	} else if l == 1 {
+		return ins[0]
+	}
+	out := make(chan T)
+	// Create a WaitGroup that waits for all of the ins to close
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	go func() {
+		// When all of the ins are closed, close the out
+		wg.Wait()
+		close(out)
+	}()
+	for i := range ins {
+		go func(in <-chan T) {
+			// Fan the contents of each in into the out
+			for i := range in {
+				out <- i
+			}
+			// Tell the WaitGroup that one of the channels is closed
+			wg.Done()
+		}(ins[i])
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package generic
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// produce sends count ints starting at start to a chan, waiting interval between each one
+func produce(start, count int, interval time.Duration) <-chan int {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for i := start; i < start+count; i++ {
+			time.Sleep(interval)
+			out <- i
+		}
+	}()
+	return out
+}
+
+func TestMerge(t *testing.T) {
+	t.Run("closes after all of the producers close", func(t *testing.T) {
+		var outs []int
+		for o := range Merge(
+			produce(0, 100, 0),
+			produce(100, 20, time.Millisecond),
+			produce(200, 5, 10*time.Millisecond),
+		) {
+			outs = append(outs, o)
+		}
+		if len(outs) != 125 {
+			t.Fatalf("len(out) = %d, want 125", len(outs))
+		}
+		sort.Ints(outs)
+		for k, i := range append(append(seq(0, 100), seq(100, 20)...), seq(200, 5)...) {
+			if outs[k] != i {
+				t.Fatalf("out[%d] = %d, want %d", k, outs[k], i)
+			}
+		}
+	})
+
+	t.Run("zero ins returns a closed channel", func(t *testing.T) {
+		if _, open := <-Merge[int](); open {
+			t.Error("out is open")
+		}
+	})
+}
+
+// seq returns count ints starting at start
+func seq(start, count int) []int {
+	is := make([]int, 0, count)
+	for i := start; i < start+count; i++ {
+		is = append(is, i)
+	}
+	return is
+}
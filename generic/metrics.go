@@ -0,0 +1,43 @@
+package generic
+
+import "github.com/deliveryhero/pipeline/internal/core"
+
+// Metrics receives the events of the stages it is attached to with WithMetrics, tagged with the name of the stage.
+// Its methods are called from the workers of the stages, so they must be safe for concurrent use and must not block.
+type Metrics = core.Metrics
+
+// WorkerMetrics is an optional interface of a Metrics that receives the number of workers of the process stages,
+// when they start them and each time WithLazyWorkers adds one. MemoryMetrics implements it.
+type WorkerMetrics = core.WorkerMetrics
+
+// BlockedMetrics is an optional interface of a Metrics that receives how long the process stages wait for their inputs
+// and for their out chan to take their results, which tells where the bottleneck of a pipeline is.
+// Only the waits for a chan that is not ready are timed. MemoryMetrics implements it,
+// see `StageMetrics.Starved` and `StageMetrics.Backpressured`.
+type BlockedMetrics = core.BlockedMetrics
+
+// NoopMetrics is a Metrics that does nothing.
+// Embed it to implement only some of the methods of Metrics.
+type NoopMetrics = core.NoopMetrics
+
+// MemoryMetrics is a Metrics that counts the events of each stage with atomic counters, which is useful in tests.
+// Its zero value is ready to use.
+type MemoryMetrics = core.MemoryMetrics
+
+// StageMetrics are the counts that MemoryMetrics holds for a stage
+type StageMetrics = core.StageMetrics
+
+// WithMetrics reports the events of the process stages to `m` under the name `stage`.
+// An input is received when the stage reads it, emitted when its result is sent to the out chan,
+// and canceled when it is passed to `Processor.Cancel`. Each call to `Processor.Process` reports its duration,
+// and the waits for the inputs and for the out chan are reported if `m` implements BlockedMetrics.
+// The stages report nothing by default, and a NoopMetrics costs nothing either.
+func WithMetrics(stage string, m Metrics) Option {
+	return func(c *config) {
+		c.Stage = stage
+		c.Metrics = m
+		if _, noop := m.(NoopMetrics); noop {
+			c.Metrics = nil
+		}
+	}
+}
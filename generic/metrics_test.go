@@ -0,0 +1,22 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithMetrics(t *testing.T) {
+	m := &MemoryMetrics{}
+	p := ProcessorFunc[int, int](func(_ context.Context, i int) (int, error) {
+		if i == 3 {
+			return 0, errors.New("3")
+		}
+		return i, nil
+	})
+	for range Process[int, int](context.Background(), p, Emit(context.Background(), 1, 2, 3, 4, 5), WithMetrics("enrich", m)) {
+	}
+	if got := m.Stage("enrich"); got.Received != 5 || got.Emitted != 4 || got.Canceled != 1 || got.Processed != 5 {
+		t.Errorf("enrich = %+v, want 5 received and processed, 4 emitted and 1 canceled", got)
+	}
+}
@@ -0,0 +1,125 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// payload is a struct used to make sure the generic funcs work with non-primitive types
+type payload struct {
+	ID   int
+	Name string
+}
+
+// newPayload converts an int into a payload
+func newPayload(i int) payload {
+	return payload{ID: i, Name: fmt.Sprintf("payload %d", i)}
+}
+
+// mockProcessor is a mock of the Processor interface
+type mockProcessor[T any] struct {
+	processDuration    time.Duration
+	cancelDuration     time.Duration
+	processReturnsErrs bool
+
+	mu        sync.Mutex
+	processed []T
+	canceled  []T
+	errs      []error
+}
+
+// Process waits processDuration before returning its input as its output
+func (m *mockProcessor[T]) Process(ctx context.Context, i T) (T, error) {
+	var zero T
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-time.After(m.processDuration):
+		break
+	}
+	if m.processReturnsErrs {
+		return zero, fmt.Errorf("%w: %v", errProcess, i)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed = append(m.processed, i)
+	return i, nil
+}
+
+// Cancel collects all inputs that were canceled in m.canceled
+func (m *mockProcessor[T]) Cancel(i T, err error) {
+	time.Sleep(m.cancelDuration)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.canceled = append(m.canceled, i)
+	m.errs = append(m.errs, err)
+}
+
+// mapSlice converts a slice of ints into a slice of Ts
+func mapSlice[T any](is []int, fn func(int) T) []T {
+	if is == nil {
+		return nil
+	}
+	ts := make([]T, 0, len(is))
+	for _, i := range is {
+		ts = append(ts, fn(i))
+	}
+	return ts
+}
+
+// containsAll returns true if a and b contain all of the same elements
+// in any order or if both are empty / nil
+func containsAll[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	} else if len(a) == 0 {
+		return true
+	}
+	aMap := make(map[T]bool)
+	for _, i := range a {
+		aMap[i] = true
+	}
+	for _, i := range b {
+		if !aMap[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// errorsMatch returns true if each error in got matches the error at the same index in want with errors.Is
+func errorsMatch(want, got []error) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for k := range want {
+		if !errors.Is(got[k], want[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// errorsMatchAll returns true if each error in got matches a different error in want with errors.Is, in any order
+func errorsMatchAll(want, got []error) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	used := make([]bool, len(want))
+	for _, err := range got {
+		found := false
+		for k := range want {
+			if !used[k] && errors.Is(err, want[k]) {
+				used[k], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package generic
+
+import (
+	"context"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// Process takes each input from the `in <-chan I` and calls `Processor.Process` on it.
+// When `Processor.Process` returns an `O`, it will be sent to the output `<-chan O`.
+// If `Processor.Process` returns an error, `Processor.Cancel` will be called with the corresponding input and error message.
+// Finally, if the `Context` is canceled, all inputs remaining in the `in <-chan I` will go directly to `Processor.Cancel`.
+// If `Processor.Process` panics, the panic is passed to `Processor.Cancel` as a *PanicError, unless WithPanicRecovery(false) is set.
+// With WithConcurrency, WithOrderedOutput and WithBufferedOutput, Process covers the variants of ProcessConcurrently
+// without changing its signature.
+func Process[I, O any](ctx context.Context, processor Processor[I, O], in <-chan I, opts ...Option) <-chan O {
+	return core.Process[I, O](ctx, processor, in, processConfig[I, O](opts))
+}
+
+// ProcessConcurrently fans the in channel out to multiple Processors running concurrently,
+// then it fans the out channels of the Processors back into a single out chan
+func ProcessConcurrently[I, O any](ctx context.Context, concurrently int, processor Processor[I, O], in <-chan I, opts ...Option) <-chan O {
+	cfg := processConfig[I, O](opts)
+	cfg.SetConcurrency(concurrently)
+	return core.ProcessConcurrently[I, O](ctx, concurrently, processor, in, cfg)
+}
+
+// ProcessConcurrentlyOrdered is like ProcessConcurrently, except that the results are sent to the out chan
+// in the same order that their inputs were read from the in chan.
+// Inputs that fail to process are skipped and passed to `Processor.Cancel`.
+// At most `concurrently` results can wait to be re-sequenced before ProcessConcurrentlyOrdered stops reading from in.
+func ProcessConcurrentlyOrdered[I, O any](ctx context.Context, concurrently int, processor Processor[I, O], in <-chan I, opts ...Option) <-chan O {
+	cfg := processConfig[I, O](opts)
+	cfg.SetConcurrency(concurrently)
+	cfg.SetOrdering(core.Ordered)
+	return core.ProcessConcurrentlyOrdered[I, O](ctx, concurrently, processor, in, cfg)
+}
+
+// ProcessWithErrors is like Process, except that the errors returned by `Processor.Process` are sent to the errs chan
+// as a *ProcessError instead of being passed to `Processor.Cancel`.
+// `Processor.Cancel` is only called for the inputs that are canceled by the context.
+// The errs chan is closed when the out chan is closed, so both chans must be read until they are closed.
+func ProcessWithErrors[I, O any](ctx context.Context, processor Processor[I, O], in <-chan I, opts ...Option) (out <-chan O, errs <-chan error) {
+	return core.ProcessWithErrors[I, O](ctx, processor, in, processConfig[I, O](opts))
+}
+
+// processConfig returns the settings of a process stage of Processor[I, O]s, which clones the processors that implement WorkerCloner
+func processConfig[I, O any](opts []Option) core.Config {
+	cfg := newConfig(opts).Config
+	cfg.CloneWorker = core.CloneWorker[Processor[I, O]]
+	return cfg
+}
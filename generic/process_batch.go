@@ -0,0 +1,76 @@
+package generic
+
+import (
+	"context"
+	"time"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+	"github.com/deliveryhero/pipeline/semaphore"
+)
+
+// ProcessBatch collects up to maxSize elements over maxDuration and processes them together as a `[]I`.
+// It passes the `[]I` to the `Processor.Process` method and sends each element of the `[]O` it returns to the out chan.
+// If `Processor.Process` returns an error or the context is canceled, the whole `[]I` batch is passed to `Processor.Cancel`.
+// See Collect for the details of how batches are collected.
+func ProcessBatch[I, O any](
+	ctx context.Context,
+	maxSize int,
+	maxDuration time.Duration,
+	processor Processor[[]I, []O],
+	in <-chan I,
+) <-chan O {
+	out := make(chan O)
+	go func() {
+		defer close(out)
+		for batch := range Collect(ctx, maxSize, maxDuration, in) {
+			processBatch(ctx, processor, batch, out)
+		}
+	}()
+	return out
+}
+
+// ProcessBatchConcurrently is like ProcessBatch, except that up to `concurrently` batches are processed at once.
+func ProcessBatchConcurrently[I, O any](
+	ctx context.Context,
+	concurrently,
+	maxSize int,
+	maxDuration time.Duration,
+	processor Processor[[]I, []O],
+	in <-chan I,
+) <-chan O {
+	out := make(chan O)
+	go func() {
+		// Process up to concurrently batches at once
+		sem := semaphore.New(core.Workers(ctx, concurrently))
+		for batch := range Collect(ctx, maxSize, maxDuration, in) {
+			sem.Add(1)
+			go func(batch []I) {
+				processBatch(ctx, processor, batch, out)
+				sem.Done()
+			}(batch)
+		}
+		// Close the out chan after all of the batches are processed
+		sem.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// processBatch processes one batch and splits its results into the out chan
+func processBatch[I, O any](ctx context.Context, processor Processor[[]I, []O], batch []I, out chan<- O) {
+	select {
+	// Cancel the batch during shutdown
+	case <-ctx.Done():
+		core.Cancel[[]I, []O](ctx, core.DefaultConfig(), processor, batch, &CanceledError{Err: ctx.Err()})
+	// Otherwise Process the batch
+	default:
+		results, err := processor.Process(ctx, batch)
+		if err != nil {
+			core.Cancel[[]I, []O](ctx, core.DefaultConfig(), processor, batch, &ProcessError{Input: batch, Err: err})
+			return
+		}
+		for _, result := range results {
+			out <- result
+		}
+	}
+}
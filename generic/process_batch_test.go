@@ -0,0 +1,113 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchItoa converts batches of ints into strings, failing any batch that contains a negative number
+type batchItoa struct {
+	processDuration time.Duration
+
+	mu       sync.Mutex
+	canceled [][]int
+	errs     []error
+}
+
+func (b *batchItoa) Process(ctx context.Context, is []int) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(b.processDuration):
+	}
+	strs := make([]string, 0, len(is))
+	for _, i := range is {
+		if i < 0 {
+			return nil, errors.New("negative number")
+		}
+		strs = append(strs, strconv.Itoa(i))
+	}
+	return strs, nil
+}
+
+func (b *batchItoa) Cancel(is []int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.canceled = append(b.canceled, is)
+	b.errs = append(b.errs, err)
+}
+
+func TestProcessBatch(t *testing.T) {
+	t.Run("results are split back into individual outputs", func(t *testing.T) {
+		processor := &batchItoa{}
+		var outs []string
+		for o := range ProcessBatch[int, string](context.Background(), 2, time.Second, processor, Emit(context.Background(), 1, 2, 3, 4, 5)) {
+			outs = append(outs, o)
+		}
+		if want := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("the whole batch is canceled when process fails", func(t *testing.T) {
+		processor := &batchItoa{}
+		var outs []string
+		for o := range ProcessBatch[int, string](context.Background(), 2, time.Second, processor, Emit(context.Background(), 1, 2, -3, 4, 5)) {
+			outs = append(outs, o)
+		}
+		if want := []string{"1", "2", "5"}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if want := [][]int{{-3, 4}}; !reflect.DeepEqual(want, processor.canceled) {
+			t.Errorf("canceled = %+v, want %+v", processor.canceled, want)
+		}
+	})
+
+	t.Run("batches are canceled after the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		processor := &batchItoa{}
+		for o := range ProcessBatch[int, string](ctx, 2, time.Second, processor, Emit(context.Background(), 1, 2, 3)) {
+			t.Errorf("%s should not be processed", o)
+		}
+		var canceled []int
+		for _, batch := range processor.canceled {
+			canceled = append(canceled, batch...)
+		}
+		if want := []int{1, 2, 3}; !reflect.DeepEqual(want, canceled) {
+			t.Errorf("canceled = %+v, want %+v", canceled, want)
+		}
+		for _, err := range processor.errs {
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %s, want %s", err, context.Canceled)
+			}
+		}
+	})
+}
+
+func TestProcessBatchConcurrently(t *testing.T) {
+	const maxTestDuration = time.Second
+	processor := &batchItoa{processDuration: maxTestDuration / 2}
+
+	// 3 batches of 2 processed at once should take about half of the test duration
+	start := time.Now()
+	var outs []string
+	for o := range ProcessBatchConcurrently[int, string](context.Background(), 3, 2, maxTestDuration, processor, Emit(context.Background(), 1, 2, 3, 4, 5, 6)) {
+		outs = append(outs, o)
+	}
+	if elapsed := time.Since(start); elapsed > maxTestDuration {
+		t.Errorf("elapsed = %s, want < %s", elapsed, maxTestDuration)
+	}
+
+	sort.Strings(outs)
+	if want := []string{"1", "2", "3", "4", "5", "6"}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+}
@@ -0,0 +1,532 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errProcess is wrapped by the errors of the mock processor
+var errProcess = errors.New("process error")
+
+type processTestArgs struct {
+	ctxTimeout           time.Duration
+	processDuration      time.Duration
+	processReturnsErrors bool
+	cancelDuration       time.Duration
+	concurrently         int
+	in                   []int
+}
+
+type processTestWant struct {
+	open         bool
+	out          []int
+	canceled     []int
+	canceledErrs []error
+}
+
+type processTest struct {
+	name string
+	args processTestArgs
+	want processTestWant
+}
+
+func TestProcess(t *testing.T) {
+	const maxTestDuration = time.Second
+	tests := []processTest{
+		{
+			name: "out closes if in closes but the context isn't canceled",
+			args: processTestArgs{
+				ctxTimeout:      2 * maxTestDuration,
+				processDuration: 0,
+				in:              []int{1, 2, 3},
+			},
+			want: processTestWant{
+				open:     false,
+				out:      []int{1, 2, 3},
+				canceled: nil,
+			},
+		}, {
+			name: "cancel is called on elements after the context is canceled",
+			args: processTestArgs{
+				ctxTimeout:      maxTestDuration / 2,
+				processDuration: maxTestDuration / 11,
+				in:              []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			},
+			want: processTestWant{
+				open:     false,
+				out:      []int{1, 2, 3, 4, 5},
+				canceled: []int{6, 7, 8, 9, 10},
+				canceledErrs: []error{
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+				},
+			},
+		}, {
+			name: "out stays open as long as in is open",
+			args: processTestArgs{
+				ctxTimeout:      maxTestDuration / 2,
+				processDuration: (maxTestDuration / 2) - (100 * time.Millisecond),
+				cancelDuration:  (maxTestDuration / 2) - (100 * time.Millisecond),
+				in:              []int{1, 2, 3},
+			},
+			want: processTestWant{
+				open:     true,
+				out:      []int{1},
+				canceled: []int{2},
+				canceledErrs: []error{
+					context.DeadlineExceeded,
+				},
+			},
+		}, {
+			name: "when an error is returned during process, it is passed to cancel",
+			args: processTestArgs{
+				ctxTimeout:           maxTestDuration - 100*time.Millisecond,
+				processDuration:      (maxTestDuration - 200*time.Millisecond) / 2,
+				processReturnsErrors: true,
+				cancelDuration:       0,
+				in:                   []int{1, 2, 3},
+			},
+			want: processTestWant{
+				open:     false,
+				out:      nil,
+				canceled: []int{1, 2, 3},
+				canceledErrs: []error{
+					errProcess,
+					errProcess,
+					context.DeadlineExceeded,
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Run("int", func(t *testing.T) {
+				t.Parallel()
+				testProcess(t, maxTestDuration, test, func(i int) int { return i }, true, Process[int, int])
+			})
+			t.Run("struct", func(t *testing.T) {
+				t.Parallel()
+				testProcess(t, maxTestDuration, test, newPayload, true, Process[payload, payload])
+			})
+		})
+	}
+}
+
+func TestProcessConcurrently(t *testing.T) {
+	const maxTestDuration = time.Second
+	tests := []processTest{
+		{
+			name: "out closes if in closes but the context isn't canceled",
+			args: processTestArgs{
+				ctxTimeout:      2 * maxTestDuration,                          // context never times out
+				processDuration: maxTestDuration/3 - (100 * time.Millisecond), // 3 processed per processor
+				concurrently:    2,                                            // * 2 processors = 6 processed, pipe closes
+				in:              []int{1, 2, 3, 4, 5, 6},
+			},
+			want: processTestWant{
+				open:     false,
+				out:      []int{1, 2, 3, 4, 5, 6},
+				canceled: nil,
+			},
+		}, {
+			name: "cancel is called on elements after the context is canceled",
+			args: processTestArgs{
+				ctxTimeout:      maxTestDuration / 2,                             // context times out before the test ends
+				processDuration: (maxTestDuration / 4) - (10 * time.Millisecond), // 2 processed per processor before timeout
+				concurrently:    3,                                               // * 3 processors = 6 processed, 4 canceled, pipe closes
+				in:              []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			},
+			want: processTestWant{
+				open:     false,
+				out:      []int{1, 2, 3, 4, 5, 6},
+				canceled: []int{7, 8, 9, 10},
+				canceledErrs: []error{
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+				},
+			},
+		}, {
+			name: "out stays open as long as in is open",
+			args: processTestArgs{
+				ctxTimeout:      maxTestDuration / 2,                              // context times out half way through the test
+				processDuration: (maxTestDuration / 2) - (100 * time.Millisecond), // process fires onces per processor
+				cancelDuration:  (maxTestDuration / 2) - (100 * time.Millisecond), // cancel fires once per process
+				concurrently:    3,                                                // * 3 proceses = 3 canceled, 3 processed, 1 still in the pipe
+				in:              []int{1, 2, 3, 4, 5, 6, 7},
+			},
+			want: processTestWant{
+				open:     true,
+				out:      []int{1, 2, 3},
+				canceled: []int{4, 5, 6},
+				canceledErrs: []error{
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+				},
+			},
+		}, {
+			name: "when an error is returned during process, it is passed to cancel",
+			args: processTestArgs{
+				ctxTimeout:           maxTestDuration - 100*time.Millisecond,
+				processDuration:      (maxTestDuration - 200*time.Millisecond) / 2,
+				processReturnsErrors: true,
+				cancelDuration:       0,
+				concurrently:         1,
+				in:                   []int{1, 2, 3},
+			},
+			want: processTestWant{
+				open:     false,
+				out:      nil,
+				canceled: []int{1, 2, 3},
+				canceledErrs: []error{
+					errProcess,
+					errProcess,
+					context.DeadlineExceeded,
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Run("int", func(t *testing.T) {
+				t.Parallel()
+				testProcess(t, maxTestDuration, test, func(i int) int { return i }, false,
+					func(ctx context.Context, p Processor[int, int], in <-chan int, _ ...Option) <-chan int {
+						return ProcessConcurrently(ctx, test.args.concurrently, p, in)
+					},
+				)
+			})
+			t.Run("struct", func(t *testing.T) {
+				t.Parallel()
+				testProcess(t, maxTestDuration, test, newPayload, false,
+					func(ctx context.Context, p Processor[payload, payload], in <-chan payload, _ ...Option) <-chan payload {
+						return ProcessConcurrently(ctx, test.args.concurrently, p, in)
+					},
+				)
+			})
+		})
+	}
+}
+
+// testProcess runs a processTest against a process func after converting its ints to Ts.
+// If ordered is false, the outputs and cancellations may arrive in any order.
+func testProcess[T comparable](
+	t *testing.T,
+	maxTestDuration time.Duration,
+	test processTest,
+	toT func(int) T,
+	ordered bool,
+	process func(ctx context.Context, p Processor[T, T], in <-chan T, opts ...Option) <-chan T,
+) {
+	// Create the in channel
+	in := EmitFromSlice(context.Background(), mapSlice(test.args.in, toT))
+
+	// Setup the Processor
+	ctx, cancel := context.WithTimeout(context.Background(), test.args.ctxTimeout)
+	defer cancel()
+	processor := &mockProcessor[T]{
+		processDuration:    test.args.processDuration,
+		processReturnsErrs: test.args.processReturnsErrors,
+		cancelDuration:     test.args.cancelDuration,
+	}
+	out := process(ctx, processor, in)
+
+	// Collect the outputs
+	timeout := time.After(maxTestDuration)
+	var outs []T
+	var isOpen bool
+loop:
+	for {
+		select {
+		case o, open := <-out:
+			if !open {
+				isOpen = false
+				break loop
+			}
+			isOpen = true
+			outs = append(outs, o)
+		case <-timeout:
+			break loop
+		}
+	}
+
+	// Build the expected values
+	wantOut := mapSlice(test.want.out, toT)
+	wantCanceled := mapSlice(test.want.canceled, toT)
+	wantErrs := test.want.canceledErrs
+
+	processor.mu.Lock()
+	defer processor.mu.Unlock()
+
+	// Expecting the out channel to be open or closed
+	if test.want.open != isOpen {
+		t.Errorf("open = %t, want %t", isOpen, test.want.open)
+	}
+
+	equal := containsAll[T]
+	equalErrs := errorsMatchAll
+	if ordered {
+		equal = func(a, b []T) bool { return reflect.DeepEqual(a, b) }
+		equalErrs = errorsMatch
+	}
+
+	// Expecting processed outputs
+	if !equal(wantOut, outs) {
+		t.Errorf("out = %+v, want %+v", outs, wantOut)
+	}
+
+	// Expecting canceled inputs
+	if !equal(wantCanceled, processor.canceled) {
+		t.Errorf("canceled = %+v, want %+v", processor.canceled, wantCanceled)
+	}
+
+	// Expecting canceled errors
+	if !equalErrs(wantErrs, processor.errs) {
+		t.Errorf("canceledErrs = %+v, want %+v", processor.errs, wantErrs)
+	}
+}
+
+func TestProcessConcurrentlyOrdered(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Feed 1..100 into the pipeline
+	in := EmitFromSlice(ctx, seq(1, 100))
+
+	// Process each input for a random duration and fail every 10th input
+	var mu sync.Mutex
+	var canceled []int
+	p := NewProcessor(func(ctx context.Context, i int) (payload, error) {
+		time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond) // #nosec
+		if i%10 == 0 {
+			return payload{}, fmt.Errorf("process error: %d", i)
+		}
+		return newPayload(i), nil
+	}, func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		canceled = append(canceled, i)
+	})
+
+	// Expecting strictly increasing outputs
+	var count, prev int
+	for o := range ProcessConcurrentlyOrdered(ctx, 8, p, in) {
+		if o.ID <= prev {
+			t.Errorf("%d was emitted after %d", o.ID, prev)
+		}
+		prev = o.ID
+		count++
+	}
+	if count != 90 {
+		t.Errorf("len(out) = %d, want 90", count)
+	}
+	if len(canceled) != 10 {
+		t.Errorf("len(canceled) = %d, want 10", len(canceled))
+	}
+}
+
+func TestProcessWithErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Fail to process every even number
+	processor := NewProcessor(func(ctx context.Context, i int) (payload, error) {
+		if i%2 == 0 {
+			return payload{}, fmt.Errorf("process error: %d", i)
+		}
+		return newPayload(i), nil
+	}, func(i int, err error) {
+		t.Errorf("%d was canceled: %s", i, err)
+	})
+
+	// Read from out and errs until both are closed
+	var outs []payload
+	var failed []interface{}
+	out, errs := ProcessWithErrors(ctx, processor, Emit(context.Background(), 1, 2, 3, 4, 5))
+	for out != nil || errs != nil {
+		select {
+		case o, open := <-out:
+			if !open {
+				out = nil
+				continue
+			}
+			outs = append(outs, o)
+		case err, open := <-errs:
+			if !open {
+				errs = nil
+				continue
+			}
+			var pErr *ProcessError
+			if !errors.As(err, &pErr) {
+				t.Fatalf("%T is not a *ProcessError", err)
+			}
+			failed = append(failed, pErr.Input)
+		}
+	}
+
+	if want := mapSlice([]int{1, 3, 5}, newPayload); !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+	if want := []interface{}{2, 4}; !reflect.DeepEqual(want, failed) {
+		t.Errorf("failed = %+v, want %+v", failed, want)
+	}
+}
+
+func TestProcessPanicRecovery(t *testing.T) {
+	var mu sync.Mutex
+	var canceled []int
+	processor := NewProcessor(func(ctx context.Context, i int) (payload, error) {
+		if i%3 == 0 {
+			panic(fmt.Sprintf("can not process %d", i))
+		}
+		return newPayload(i), nil
+	}, func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		var pErr *PanicError
+		if !errors.As(err, &pErr) {
+			t.Errorf("%T is not a *PanicError", err)
+		} else if want := fmt.Sprintf("panic: can not process %d", i); err.Error() != want {
+			t.Errorf("err = %s, want %s", err, want)
+		}
+		canceled = append(canceled, i)
+	})
+
+	var outs []payload
+	for o := range ProcessConcurrently(context.Background(), 2, processor, EmitFromSlice(context.Background(), seq(1, 7))) {
+		outs = append(outs, o)
+	}
+	if want := mapSlice([]int{1, 2, 4, 5, 7}, newPayload); !containsAll(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+	if want := []int{3, 6}; !containsAll(want, canceled) {
+		t.Errorf("canceled = %+v, want %+v", canceled, want)
+	}
+}
+
+// workerProcessor doubles its inputs with the resource of its worker, which it acquires in Setup
+type workerProcessor struct {
+	mu       *sync.Mutex
+	setups   *int
+	resource *int
+}
+
+func (p *workerProcessor) Process(_ context.Context, i int) (int, error) {
+	if p.resource == nil {
+		return 0, errors.New("not set up")
+	}
+	return *p.resource * i, nil
+}
+
+func (p *workerProcessor) Cancel(int, error) {}
+
+func (p *workerProcessor) CloneForWorker() Processor[int, int] {
+	return &workerProcessor{mu: p.mu, setups: p.setups}
+}
+
+func (p *workerProcessor) Setup(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*p.setups++
+	p.resource = new(int)
+	*p.resource = 2
+	return nil
+}
+
+func TestProcessWorkerCloner(t *testing.T) {
+	var setups int
+	p := &workerProcessor{mu: &sync.Mutex{}, setups: &setups}
+	var outs []int
+	for o := range ProcessConcurrently[int, int](context.Background(), 3, p, EmitFromSlice(context.Background(), seq(1, 6))) {
+		outs = append(outs, o)
+	}
+	if want := []int{2, 4, 6, 8, 10, 12}; !containsAll(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+	// Each worker sets up its own clone, and the processor itself is never set up
+	if setups != 3 || p.resource != nil {
+		t.Errorf("setups = %d, want 3 clones set up", setups)
+	}
+}
+
+// intBatchCanceler records the ints passed to CancelBatch and to Cancel
+type intBatchCanceler struct {
+	batches  [][]int
+	canceled []int
+}
+
+func (p *intBatchCanceler) Process(_ context.Context, i int) (int, error) {
+	return i, nil
+}
+
+func (p *intBatchCanceler) Cancel(i int, _ error) {
+	p.canceled = append(p.canceled, i)
+}
+
+func (p *intBatchCanceler) CancelBatch(is []int, _ error) {
+	p.batches = append(p.batches, append([]int(nil), is...))
+}
+
+func TestProcessBatchCanceler(t *testing.T) {
+	in := make(chan int, 10)
+	for _, i := range seq(1, 10) {
+		in <- i
+	}
+	close(in)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := &intBatchCanceler{}
+	for range Process[int, int](ctx, p, in, WithCancelBatchSize(4)) {
+	}
+	var canceled []int
+	for _, batch := range p.batches {
+		if len(batch) > 4 {
+			t.Errorf("batch = %+v, want at most 4 ints", batch)
+		}
+		canceled = append(canceled, batch...)
+	}
+	if !reflect.DeepEqual(canceled, seq(1, 10)) || len(p.canceled) > 0 {
+		t.Errorf("batches = %+v, canceled = %+v, want %+v in batches", p.batches, p.canceled, seq(1, 10))
+	}
+}
+
+// BenchmarkProcess processes b.N ints with a processor that does nothing, so ns/op and allocs/op are the cost of the stage per item
+func BenchmarkProcess(b *testing.B) {
+	noop := ProcessorFunc[int, int](func(_ context.Context, i int) (int, error) {
+		return i, nil
+	})
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "serial"},
+		{name: "concurrently=8", opts: []Option{WithConcurrency(8)}},
+		{name: "concurrently=64", opts: []Option{WithConcurrency(64)}},
+		{name: "concurrently=8/ordered", opts: []Option{WithConcurrency(8), WithOrderedOutput()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			in := make(chan int)
+			go func() {
+				defer close(in)
+				for n := 0; n < b.N; n++ {
+					in <- n
+				}
+			}()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range Process[int, int](context.Background(), noop, in, bench.opts...) {
+			}
+		})
+	}
+}
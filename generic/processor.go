@@ -0,0 +1,101 @@
+package generic
+
+import (
+	"context"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// Processor represents a blocking operation in a pipeline that turns an `I` into an `O`.
+// Implementing `Processor` will allow you to add business logic to your pipelines without directly managing channels.
+// This simplifies your unit tests and eliminates channel management related bugs.
+type Processor[I, O any] interface {
+	// Process processes an input and returns an output or an error, if the output could not be processed.
+	// When the context is canceled, process should stop all blocking operations and return the `Context.Err()`.
+	Process(ctx context.Context, i I) (O, error)
+
+	// Cancel is called if process returns an error or if the context is canceled while there are still items in the `in <-chan I`.
+	// Use errors.Is(err, ErrCanceled) to tell the inputs that were canceled by the context from the ones that failed,
+	// whose err is a *ProcessError, and errors.Is(err, ErrUndelivered) to tell the inputs that were processed
+	// but whose result was not received before the context was done.
+	Cancel(i I, err error)
+}
+
+// ContextCanceler is an optional interface of a Processor that needs a context to bound the work it does when an input is canceled,
+// such as writing the input to a fallback store.
+// If a Processor implements it, CancelContext is called instead of `Processor.Cancel`.
+// Its context carries the values of the stage context and is done a grace period after the stage context is done,
+// which is 1 second by default and can be changed with WithCancelTimeout.
+type ContextCanceler[I any] interface {
+	CancelContext(ctx context.Context, i I, err error)
+}
+
+// BatchCanceler is an optional interface of a Processor whose cancels are cheaper in bulk, such as a network write.
+// If a Processor implements it, Process, ProcessConcurrently and ProcessWithErrors pass the inputs they drain
+// once the context is done to CancelBatch rather than to `Processor.Cancel` one at a time, with a *CanceledError.
+// Each batch holds the inputs that are ready at once, up to 100 by default, which can be changed with WithCancelBatchSize.
+// The inputs that fail or are interrupted while they are processed are still passed to `Processor.Cancel`.
+type BatchCanceler[I any] interface {
+	CancelBatch(is []I, err error)
+}
+
+// Setupper is an optional interface of a Processor that acquires resources, such as a DB connection, before its first input.
+// If a Processor implements it, Setup is called once by the process stages before the first call to `Processor.Process`.
+// If Setup fails, every input of the stage fails with a *SetupError, which is passed to `Processor.Cancel` wrapped in a *ProcessError.
+type Setupper = core.Setupper
+
+// Teardowner is an optional interface of a Processor that releases the resources it acquired in Setup.
+// If a Processor implements it, Teardown is called once by the process stages after the last call to `Processor.Process`,
+// once the `in <-chan I` is closed, which the stages wait for even after the context is canceled,
+// so that the inputs that are canceled are passed to `Processor.Cancel` before the Processor is torn down.
+// It also runs if `Processor.Process` panics and WithPanicRecovery(false) is set.
+// Its error is logged, or passed to the func set by WithTeardownErrors.
+type Teardowner = core.Teardowner
+
+// SetupError is the error of the inputs of a stage whose `Setupper.Setup` failed, which wraps the error of Setup
+type SetupError = core.SetupError
+
+// WorkerCloner is an optional interface of a Processor whose resources cannot be shared by the workers of the concurrent stages.
+// If a Processor implements it, ProcessConcurrently and ProcessConcurrentlyOrdered call CloneForWorker once for each worker,
+// and set up and tear down each clone, so that each worker owns its resources.
+// Otherwise, the workers share the Processor, which is set up and torn down once.
+type WorkerCloner[I, O any] interface {
+	CloneForWorker() Processor[I, O]
+}
+
+// NewProcessor creates a process and cancel func.
+// The cancel func may be nil, in which case canceled inputs are ignored.
+func NewProcessor[I, O any](
+	process func(ctx context.Context, i I) (O, error),
+	cancel func(i I, err error),
+) Processor[I, O] {
+	return &processor[I, O]{process, cancel}
+}
+
+// processor implements Processor
+type processor[I, O any] struct {
+	process func(ctx context.Context, i I) (O, error)
+	cancel  func(i I, err error)
+}
+
+func (p *processor[I, O]) Process(ctx context.Context, i I) (O, error) {
+	return p.process(ctx, i)
+}
+
+func (p *processor[I, O]) Cancel(i I, err error) {
+	if p.cancel != nil {
+		p.cancel(i, err)
+	}
+}
+
+// ProcessorFunc is an adapter that allows a bare process func to be used as a Processor.
+// Its Cancel method is a no-op.
+type ProcessorFunc[I, O any] func(ctx context.Context, i I) (O, error)
+
+// Process calls f(ctx, i)
+func (f ProcessorFunc[I, O]) Process(ctx context.Context, i I) (O, error) {
+	return f(ctx, i)
+}
+
+// Cancel does nothing
+func (f ProcessorFunc[I, O]) Cancel(I, error) {}
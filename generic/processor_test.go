@@ -0,0 +1,102 @@
+package generic
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewProcessor(t *testing.T) {
+	t.Run("cancel is passed canceled inputs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var canceled []int
+		p := NewProcessor(func(ctx context.Context, i int) (string, error) {
+			return strconv.Itoa(i), nil
+		}, func(i int, err error) {
+			canceled = append(canceled, i)
+		})
+		for range Process(ctx, p, Emit(context.Background(), 1, 2, 3)) {
+			t.Error("nothing should be processed after the context is canceled")
+		}
+
+		if want := []int{1, 2, 3}; !reflect.DeepEqual(want, canceled) {
+			t.Errorf("canceled = %+v, want %+v", canceled, want)
+		}
+	})
+
+	t.Run("nil cancel does not panic when the context is canceled mid-stream", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		p := NewProcessor(func(ctx context.Context, i int) (string, error) {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(20 * time.Millisecond):
+				return strconv.Itoa(i), nil
+			}
+		}, nil)
+
+		var outs []string
+		for o := range ProcessConcurrently(ctx, 2, p, Emit(context.Background(), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)) {
+			outs = append(outs, o)
+		}
+		if len(outs) == 0 || len(outs) == 10 {
+			t.Errorf("len(outs) = %d, want some but not all inputs processed", len(outs))
+		}
+	})
+}
+
+func TestProcessorFunc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	itoa := ProcessorFunc[int, string](func(ctx context.Context, i int) (string, error) {
+		time.Sleep(40 * time.Millisecond)
+		return strconv.Itoa(i), nil
+	})
+
+	var outs []string
+	for o := range Process[int, string](ctx, itoa, Emit(context.Background(), 1, 2, 3, 4, 5)) {
+		outs = append(outs, o)
+	}
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+}
+
+// contextCanceler records the inputs passed to CancelContext
+type contextCanceler[I, O any] struct {
+	ProcessorFunc[I, O]
+	canceled []I
+	errs     []error
+}
+
+func (c *contextCanceler[I, O]) CancelContext(ctx context.Context, i I, err error) {
+	c.canceled = append(c.canceled, i)
+	c.errs = append(c.errs, ctx.Err())
+}
+
+func TestContextCanceler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := &contextCanceler[int, int]{ProcessorFunc: func(ctx context.Context, i int) (int, error) {
+		return i, nil
+	}}
+	for range Process[int, int](ctx, c, Emit(context.Background(), 1, 2, 3)) {
+		t.Error("nothing should be processed after the context is canceled")
+	}
+
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(want, c.canceled) {
+		t.Errorf("canceled = %+v, want %+v", c.canceled, want)
+	}
+	// The context of CancelContext is live during the grace period
+	if want := []error{nil, nil, nil}; !reflect.DeepEqual(want, c.errs) {
+		t.Errorf("errs = %+v, want %+v", c.errs, want)
+	}
+}
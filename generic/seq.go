@@ -0,0 +1,68 @@
+package generic
+
+import (
+	"context"
+	"iter"
+)
+
+// FromSeq emits the values of `seq` to the out `<-chan T`, so range-over-func iterators can feed a pipeline.
+// It stops pulling values from `seq` and closes the out `<-chan T` when the context is canceled.
+func FromSeq[T any](ctx context.Context, seq iter.Seq[T]) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := range seq {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// ToSeq returns an iter.Seq that yields the values of the `in <-chan T`, so the end of a pipeline can be consumed with a plain for-range loop.
+// The loop ends when the `in <-chan T` is closed or the context is canceled.
+// When the loop ends early, the remaining inputs are drained in the background so that the stages before ToSeq are never blocked,
+// but those stages keep running until the `in <-chan T` is closed; use ToSeqFunc to stop them as well.
+func ToSeq[T any](ctx context.Context, in <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					return
+				}
+				if !yield(i) {
+					go drain(in)
+					return
+				}
+			case <-ctx.Done():
+				go drain(in)
+				return
+			}
+		}
+	}
+}
+
+// ToSeqFunc returns an iter.Seq that starts the pipeline built by `pipeline` and yields the values of its out chan.
+// The pipeline is built with a context derived from `ctx` that is canceled when the loop ends,
+// so breaking out of the loop early stops every stage of the pipeline instead of leaving them running.
+// Each loop over the iter.Seq builds and runs the pipeline again.
+func ToSeqFunc[T any](ctx context.Context, pipeline func(ctx context.Context) <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		ToSeq(ctx, pipeline(ctx))(yield)
+	}
+}
+
+// drain discards everything from in until it is closed
+func drain[T any](in <-chan T) {
+	for range in {
+	}
+}
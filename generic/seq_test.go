@@ -0,0 +1,146 @@
+package generic
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// naturals is an endless iter.Seq of 1, 2, 3... that counts how many values were pulled from it
+func naturals(pulled *int64) func(yield func(int) bool) {
+	return func(yield func(int) bool) {
+		for i := 1; ; i++ {
+			atomic.AddInt64(pulled, 1)
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// waitGoroutines waits for the number of goroutines to drop back to before
+func waitGoroutines(t *testing.T, before int) {
+	t.Helper()
+	for start := time.Now(); runtime.NumGoroutine() > before && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d, want <= %d", after, before)
+	}
+}
+
+func TestFromSeq(t *testing.T) {
+	t.Run("emits every value of the seq and closes", func(t *testing.T) {
+		var outs []int
+		for o := range FromSeq(context.Background(), func(yield func(int) bool) {
+			_ = yield(1) && yield(2) && yield(3)
+		}) {
+			outs = append(outs, o)
+		}
+		if want := []int{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("stops pulling from the seq when the context is canceled", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		var pulled int64
+		out := FromSeq(ctx, naturals(&pulled))
+		<-out
+		<-out
+		cancel()
+		for range out {
+		}
+		// The value pulled while the context was canceled may still be sent, and one more pulled before stopping
+		if n := atomic.LoadInt64(&pulled); n > 4 {
+			t.Errorf("pulled %d values, want at most 4", n)
+		}
+		waitGoroutines(t, before)
+	})
+}
+
+func TestToSeq(t *testing.T) {
+	t.Run("yields every input until in is closed", func(t *testing.T) {
+		var outs []int
+		for o := range ToSeq(context.Background(), Emit(context.Background(), 1, 2, 3)) {
+			outs = append(outs, o)
+		}
+		if want := []int{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("breaking out of the loop drains in so the stages before are not blocked", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		in := Emit(context.Background(), 1, 2, 3, 4, 5)
+		for o := range ToSeq(context.Background(), in) {
+			if o == 2 {
+				break
+			}
+		}
+		waitGoroutines(t, before)
+	})
+
+	t.Run("the loop ends when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int)
+		defer close(in)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range ToSeq(ctx, in) {
+				t.Error("nothing should be yielded")
+			}
+		}()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the loop did not end after the context was canceled")
+		}
+	})
+}
+
+func TestToSeqFunc(t *testing.T) {
+	t.Run("breaking out of the loop stops an endless pipeline", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		var pulled int64
+		double := ProcessorFunc[int, int](func(_ context.Context, i int) (int, error) {
+			return i * 2, nil
+		})
+		seq := ToSeqFunc(context.Background(), func(ctx context.Context) <-chan int {
+			return ProcessConcurrently(ctx, 4, double, FromSeq(ctx, naturals(&pulled)))
+		})
+		count := 0
+		for range seq {
+			if count++; count == 10 {
+				break
+			}
+		}
+		waitGoroutines(t, before)
+		n := atomic.LoadInt64(&pulled)
+		time.Sleep(10 * time.Millisecond)
+		if after := atomic.LoadInt64(&pulled); after != n {
+			t.Errorf("pulled %d more values after the loop ended", after-n)
+		}
+	})
+
+	t.Run("each loop runs the pipeline again", func(t *testing.T) {
+		seq := ToSeqFunc(context.Background(), func(ctx context.Context) <-chan int {
+			return Emit(ctx, 1, 2)
+		})
+		for n := 0; n < 2; n++ {
+			var outs []int
+			for o := range seq {
+				outs = append(outs, o)
+			}
+			if want := []int{1, 2}; !reflect.DeepEqual(want, outs) {
+				t.Errorf("loop %d out = %+v, want %+v", n, outs, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,15 @@
+package generic
+
+import (
+	"context"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// SerialExecution returns a context derived from ctx that collapses the concurrency of the stages that run with it, for debugging.
+// ProcessConcurrently, ProcessConcurrentlyOrdered and ProcessBatchConcurrently then run a single worker whatever their concurrency,
+// so each of them processes its inputs one at a time and sends its results in the order it read its inputs.
+// The stages that depend on which of several chans is ready first, such as Merge, are not made deterministic.
+func SerialExecution(ctx context.Context) context.Context {
+	return core.Serial(ctx)
+}
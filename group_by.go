@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+)
+
+// Group is a key of GroupBy and the chan of its inputs
+type Group struct {
+	Key   string
+	Items <-chan interface{}
+}
+
+// GroupBy sends a Group to the out chan the first time it reads an input with a key, as returned by `keyFn`,
+// and sends that input and the next ones with the same key to the Items chan of the Group, in the order they were read,
+// so that each key can be given its own stages:
+//
+//	for g := range pipeline.GroupBy(ctx, customerID, in) {
+//		go run(ctx, g.Key, g.Items)
+//	}
+//
+// An input waits until its Items chan is read, which blocks the inputs of every other key meanwhile,
+// so the Items chans must be read concurrently. With WithBufferedOutput, each Items chan buffers up to `size` inputs.
+// Use WithMaxEntries to bound the number of open groups, in which case the group that has been idle the longest
+// is closed to make room for a new one, and a new Group is sent if its key comes back.
+// The Items chans and the out chan are closed once the `in <-chan interface{}` is closed.
+// After the context is canceled, they are closed and the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func GroupBy(ctx context.Context, keyFn func(i interface{}) string, in <-chan interface{}, opts ...Option) <-chan Group {
+	c := newConfig(opts)
+	out := make(chan Group)
+	go func() {
+		defer close(out)
+		gs := &groups{
+			size:       c.OutputBuffer,
+			maxEntries: c.maxEntries,
+			order:      list.New(),
+			keys:       map[string]*list.Element{},
+		}
+		defer gs.closeAll()
+		for i := range in {
+			key := keyFn(i)
+			items, added := gs.get(key)
+			if added {
+				select {
+				case out <- Group{Key: key, Items: items}:
+				case <-ctx.Done():
+					gs.closeAll()
+					discard(in)
+					return
+				}
+			}
+			select {
+			case items <- i:
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before GroupBy are never blocked
+				gs.closeAll()
+				discard(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// group is an open group of GroupBy
+type group struct {
+	key   string
+	items chan interface{}
+}
+
+// groups holds the open groups of GroupBy.
+// It is only used by the GroupBy goroutine, so it needs no locking.
+type groups struct {
+	size       int
+	maxEntries int
+	// order holds the groups from the least to the most recently used, which is also the order they are evicted in
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+// get returns the Items chan of the group of key, and true if the group was added,
+// in which case the group that has been idle the longest is closed if there are too many
+func (gs *groups) get(key string) (chan interface{}, bool) {
+	if e, ok := gs.keys[key]; ok {
+		gs.order.MoveToBack(e)
+		return e.Value.(*group).items, false
+	}
+	g := &group{key: key, items: make(chan interface{}, gs.size)}
+	gs.keys[key] = gs.order.PushBack(g)
+	if gs.maxEntries > 0 && gs.order.Len() > gs.maxEntries {
+		gs.close(gs.order.Front())
+	}
+	return g.items, true
+}
+
+// closeAll closes every open group
+func (gs *groups) closeAll() {
+	for e := gs.order.Front(); e != nil; e = gs.order.Front() {
+		gs.close(e)
+	}
+}
+
+// close closes the group e and forgets it
+func (gs *groups) close(e *list.Element) {
+	gs.order.Remove(e)
+	g := e.Value.(*group)
+	delete(gs.keys, g.key)
+	close(g.items)
+}
@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestGroupBy(t *testing.T) {
+	// The key of an input is its first letter
+	key := func(i interface{}) string {
+		return i.(string)[:1]
+	}
+	// read reads every Group of out and its Items concurrently, and returns the keys in the order their Group was sent,
+	// and the items of each Group in the same order
+	read := func(out <-chan Group) ([]string, [][]interface{}) {
+		var keys []string
+		var items []*[]interface{}
+		var wg sync.WaitGroup
+		for g := range out {
+			keys = append(keys, g.Key)
+			is := &[]interface{}{}
+			items = append(items, is)
+			wg.Add(1)
+			go func(g Group) {
+				defer wg.Done()
+				for i := range g.Items {
+					*is = append(*is, i)
+				}
+			}(g)
+		}
+		wg.Wait()
+		var all [][]interface{}
+		for _, is := range items {
+			all = append(all, *is)
+		}
+		return keys, all
+	}
+
+	for _, test := range []struct {
+		name      string
+		opts      []Option
+		wantKeys  []string
+		wantItems [][]interface{}
+	}{{
+		name:      "the inputs of each key are sent to its group",
+		wantKeys:  []string{"a", "b", "c"},
+		wantItems: [][]interface{}{{"a1", "a2", "a3"}, {"b1", "b2"}, {"c1"}},
+	}, {
+		name:      "the groups are buffered",
+		opts:      []Option{WithBufferedOutput(2)},
+		wantKeys:  []string{"a", "b", "c"},
+		wantItems: [][]interface{}{{"a1", "a2", "a3"}, {"b1", "b2"}, {"c1"}},
+	}, {
+		name:      "the group idle the longest is closed to make room and sent again when its key comes back",
+		opts:      []Option{WithMaxEntries(2)},
+		wantKeys:  []string{"a", "b", "c", "b", "a"},
+		wantItems: [][]interface{}{{"a1", "a2"}, {"b1"}, {"c1"}, {"b2"}, {"a3"}},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			keys, items := read(GroupBy(context.Background(), key, Emit("a1", "b1", "a2", "c1", "b2", "a3"), test.opts...))
+			if !reflect.DeepEqual(test.wantKeys, keys) {
+				t.Errorf("keys = %+v, want %+v", keys, test.wantKeys)
+			}
+			if !reflect.DeepEqual(test.wantItems, items) {
+				t.Errorf("items = %+v, want %+v", items, test.wantItems)
+			}
+		})
+	}
+
+	t.Run("the groups are closed when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := GroupBy(ctx, key, in)
+		in <- "a1"
+		g := <-out
+		if i := <-g.Items; i != "a1" {
+			t.Errorf("item = %v, want a1", i)
+		}
+		cancel()
+		// The inputs after the cancel are dropped
+		in <- "a2"
+		in <- "b1"
+		close(in)
+		for i := range g.Items {
+			t.Errorf("item = %v, want none", i)
+		}
+		if _, open := <-out; open {
+			t.Error("out is still open")
+		}
+	})
+}
@@ -0,0 +1,80 @@
+// Package grpcstream exposes pipelines over gRPC streams without depending on gRPC:
+// the Recv and Send methods of any generated stream fit FromStream and ToStream once wrapped in a func.
+//
+// Serve runs a pipeline over a bidirectional stream in a handler, so that the items received from the client
+// feed the pipeline and its outputs are sent back, until either side is done:
+//
+//	func (s *server) Process(stream pb.Service_ProcessServer) error {
+//		return grpcstream.Serve(stream.Context(),
+//			func() (interface{}, error) { return stream.Recv() },
+//			func(i interface{}) error { return stream.Send(i.(*pb.Result)) },
+//			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+//				return pipeline.ProcessConcurrently(ctx, 8, p, in)
+//			})
+//	}
+package grpcstream
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// FromStream emits the messages returned by `recv` to the out `<-chan interface{}`.
+// It closes both chans when `recv` returns io.EOF, which is how a stream ends.
+// Any other error of `recv` is sent to the errs chan first.
+// When the context is canceled both chans are closed straight away, even if `recv` is blocked, see pipeline.EmitFunc.
+// Cancel the context of the stream to unblock `recv`.
+func FromStream(ctx context.Context, recv func() (interface{}, error)) (<-chan interface{}, <-chan error) {
+	return pipeline.EmitFunc(ctx, func(context.Context) (interface{}, bool, error) {
+		i, err := recv()
+		if errors.Is(err, io.EOF) {
+			return nil, false, nil
+		}
+		return i, err == nil, err
+	})
+}
+
+// ToStream calls `send` with each input from the `in <-chan interface{}` until it is closed.
+// It returns the first error of `send`, which usually means that the client is gone,
+// or the `Context.Err()` if the context is canceled. The remaining inputs are then discarded.
+func ToStream(ctx context.Context, send func(i interface{}) error, in <-chan interface{}) error {
+	return pipeline.ForEach(ctx, in, send)
+}
+
+// Serve runs the pipeline built by `stages` with the messages received with `recv` and sends its outputs with `send`.
+// The stages run with a context derived from ctx, which is canceled as soon as `recv` or `send` fails
+// or ctx is canceled, such as when the client disconnects, so that the pipeline stops promptly.
+// Serve returns once the outputs of the pipeline are all sent or the stream failed, with the first error of the stream or the context.
+// A call to `recv` that is blocked when Serve returns is left to return on its own, which gRPC makes it do when the handler returns.
+func Serve(
+	ctx context.Context,
+	recv func() (interface{}, error),
+	send func(i interface{}) error,
+	stages func(ctx context.Context, in <-chan interface{}) <-chan interface{},
+) error {
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	in, recvErrs := FromStream(sctx, recv)
+	// Stop the pipeline as soon as recv fails, instead of after the inputs before the failure are done
+	recvErr := make(chan error, 1)
+	go func() {
+		if err, failed := <-recvErrs; failed {
+			recvErr <- err
+			cancel()
+		}
+		close(recvErr)
+	}()
+	err := ToStream(sctx, send, stages(sctx, in))
+	cancel()
+	if rErr := <-recvErr; rErr != nil {
+		return rErr
+	}
+	// The pipeline may close its outputs before ToStream notices that ctx is done
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
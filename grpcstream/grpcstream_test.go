@@ -0,0 +1,169 @@
+package grpcstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// stream is a fake bidirectional stream, whose Recv returns the messages of recvs and then io.EOF,
+// or blocks until the stream context is canceled when it is open
+type stream struct {
+	ctx     context.Context
+	recvs   chan interface{}
+	recvErr error
+	sent    []interface{}
+	sendErr error
+	failAt  int
+}
+
+func newStream(ctx context.Context, msgs ...interface{}) *stream {
+	recvs := make(chan interface{}, len(msgs))
+	for _, msg := range msgs {
+		recvs <- msg
+	}
+	return &stream{ctx: ctx, recvs: recvs}
+}
+
+func (s *stream) Recv() (interface{}, error) {
+	select {
+	case msg, open := <-s.recvs:
+		if !open {
+			if s.recvErr != nil {
+				return nil, s.recvErr
+			}
+			return nil, io.EOF
+		}
+		return msg, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func (s *stream) Send(i interface{}) error {
+	if s.sendErr != nil && len(s.sent) == s.failAt {
+		return s.sendErr
+	}
+	s.sent = append(s.sent, i)
+	return nil
+}
+
+// double is a pipeline that doubles its inputs
+func double(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+	return pipeline.Process(ctx, pipeline.ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		return i.(int) * 2, nil
+	}), in)
+}
+
+// endless is a pipeline that emits numbers until its context is canceled, whatever its inputs
+func endless(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+	go func() {
+		for range in {
+		}
+	}()
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for n := 0; ; n++ {
+			select {
+			case out <- n:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// checkGoroutines waits for the number of goroutines to drop back to before
+func checkGoroutines(t *testing.T, before int) {
+	t.Helper()
+	for start := time.Now(); runtime.NumGoroutine() > before && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d, want <= %d", after, before)
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Run("the outputs of the received messages are sent until the client closes the stream", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		s := newStream(context.Background(), 1, 2, 3)
+		close(s.recvs)
+		if err := Serve(context.Background(), s.Recv, s.Send, double); err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		if want := []interface{}{2, 4, 6}; !reflect.DeepEqual(want, s.sent) {
+			t.Errorf("sent = %+v, want %+v", s.sent, want)
+		}
+		checkGoroutines(t, before)
+	})
+
+	t.Run("a recv error stops the pipeline and is returned", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		s := newStream(context.Background(), 1)
+		s.recvErr = errors.New("stream reset")
+		close(s.recvs)
+		if err := Serve(context.Background(), s.Recv, s.Send, endless); err != s.recvErr {
+			t.Errorf("err = %v, want %v", err, s.recvErr)
+		}
+		checkGoroutines(t, before)
+	})
+
+	t.Run("a send error stops the pipeline and is returned", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		s := newStream(ctx)
+		s.sendErr, s.failAt = errors.New("client gone"), 10
+		if err := Serve(ctx, s.Recv, s.Send, endless); err != s.sendErr {
+			t.Errorf("err = %v, want %v", err, s.sendErr)
+		}
+		// gRPC cancels the stream context when the handler returns, which unblocks Recv
+		cancel()
+		checkGoroutines(t, before)
+	})
+
+	t.Run("a client disconnect cancels the pipeline promptly", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		s := newStream(ctx)
+		done := make(chan error)
+		go func() {
+			done <- Serve(ctx, s.Recv, func(interface{}) error { return nil }, endless)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf("err = %v, want %v", err, context.Canceled)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Serve did not return after the client disconnected")
+		}
+		checkGoroutines(t, before)
+	})
+}
+
+func TestFromStream(t *testing.T) {
+	s := newStream(context.Background(), 1, 2)
+	close(s.recvs)
+	out, errs := FromStream(context.Background(), s.Recv)
+	var outs []interface{}
+	for o := range out {
+		outs = append(outs, o)
+	}
+	if want := []interface{}{1, 2}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+	if err, open := <-errs; err != nil || open {
+		t.Errorf("errs = %v, %t, want it closed by io.EOF", err, open)
+	}
+}
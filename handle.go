@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Handle manages a pipeline that Start runs in the background, for example from the Start and Stop of a service:
+//
+//	h := pipeline.Start(ctx, source, stage, pipeline.Sink(save))
+//	// ...
+//	h.Stop(true)
+//	if err := h.Wait(); err != nil {
+//		// ...
+//	}
+//
+// It is safe for concurrent use.
+type Handle struct {
+	ctx context.Context
+	ff  *FailFast
+	// cancel cancels the context of every stage, stopSource only the context of the first stage
+	cancel, stopSource context.CancelFunc
+	// stopping is closed by a graceful Stop, which makes the first tap stop forwarding the inputs of the first stage
+	stopping chan struct{}
+	stopOnce sync.Once
+	// taps are the goroutines that pass the out chan of each stage to the next one
+	taps sync.WaitGroup
+	done chan struct{}
+	err  error
+}
+
+// Start wires the stages together like Run and runs them in the background, returning a Handle to stop and wait for them.
+// The pipeline ends once its last stage closes its out chan, so Wait returns even if Stop is never called.
+// Start passes the out chan of each stage to the next one through a goroutine of its own,
+// which is how Wait knows that every stage closed its out chan.
+func Start(ctx context.Context, stages ...Stage) *Handle {
+	sctx, ff := NewFailFast(ctx)
+	sctx = context.WithValue(sctx, runKey{}, ff)
+	sctx, cancel := context.WithCancel(sctx)
+	srcCtx, stopSource := context.WithCancel(sctx)
+	h := &Handle{
+		ctx:        ctx,
+		ff:         ff,
+		cancel:     cancel,
+		stopSource: stopSource,
+		stopping:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	var out <-chan interface{}
+	for k, s := range stages {
+		switch k {
+		case 0:
+			out = s(srcCtx, nil)
+		case 1:
+			out = s(sctx, h.tap(out, h.stopping))
+		default:
+			out = s(sctx, h.tap(out, nil))
+		}
+	}
+	go func() {
+		for range out {
+		}
+		// The last stage may close its out chan before the stages before it are done, like Take, so stop them
+		cancel()
+		stopSource()
+		h.taps.Wait()
+		ff.Stop()
+		if err := ff.Err(); err != nil {
+			h.err = err
+		} else {
+			h.err = ctx.Err()
+		}
+		close(h.done)
+	}()
+	return h
+}
+
+// Stop stops the pipeline and returns right away, call Wait to wait for it to end.
+// A graceful Stop cancels the context of the first stage and passes none of its remaining outputs on,
+// so that the other stages finish the inputs they already have before they close their out chans.
+// Otherwise Stop cancels the context of every stage, which pass their remaining inputs to `Processor.Cancel`.
+// Stop can be called any number of times, and a Stop that is not graceful cuts a graceful one short.
+func (h *Handle) Stop(graceful bool) {
+	if !graceful {
+		h.cancel()
+		return
+	}
+	h.stopOnce.Do(func() {
+		close(h.stopping)
+		h.stopSource()
+	})
+}
+
+// Wait waits until every stage closed its out chan, and returns Err.
+// The stages of this package close their out chan as their goroutines return, so none of them is left running.
+func (h *Handle) Wait() error {
+	<-h.done
+	return h.Err()
+}
+
+// Err returns the first error of the pipeline: the *ProcessError of a stage attached with WithFailFastFrom,
+// the error of a Sink, or the `Context.Err()` of the context of Start once the pipeline ended.
+// It returns nil while the pipeline runs without failing, and once a Stop ended it without a failure.
+func (h *Handle) Err() error {
+	select {
+	case <-h.done:
+		return h.err
+	default:
+		return h.ff.Err()
+	}
+}
+
+// Done returns a chan that is closed once the pipeline ended
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// tap passes the inputs of in on to the chan it returns until in is closed or `stopping` is, and then discards the rest of in.
+// An input that it already read is always passed on, so that no input is lost.
+func (h *Handle) tap(in <-chan interface{}, stopping <-chan struct{}) <-chan interface{} {
+	out := make(chan interface{})
+	h.taps.Add(1)
+	go func() {
+		defer h.taps.Done()
+		defer discard(in)
+		defer close(out)
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					return
+				}
+				out <- i
+			case <-stopping:
+				return
+			}
+		}
+	}()
+	return out
+}
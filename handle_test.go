@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	// slowDouble doubles its inputs after a millisecond, and records the inputs it cancels
+	type slowDouble struct {
+		mu       sync.Mutex
+		canceled []interface{}
+	}
+	newSlowDouble := func() (*slowDouble, Processor) {
+		s := &slowDouble{}
+		return s, NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			time.Sleep(time.Millisecond)
+			return i.(int) * 2, nil
+		}, func(i interface{}, err error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.canceled = append(s.canceled, i)
+		})
+	}
+
+	t.Run("Wait returns once the inputs are exhausted", func(t *testing.T) {
+		before := pipelineGoroutines()
+		var outs []interface{}
+		h := Start(context.Background(),
+			func(ctx context.Context, _ <-chan interface{}) <-chan interface{} {
+				return EmitContext(ctx, 1, 2, 3)
+			},
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Apply(ctx, double, in)
+			},
+			Sink(func(i interface{}) error {
+				outs = append(outs, i)
+				return nil
+			}),
+		)
+		if err := h.Wait(); err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		checkNoNewGoroutines(t, before)
+		if want := []interface{}{2, 4, 6}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("a graceful Stop finishes the inputs the stages already have", func(t *testing.T) {
+		before := pipelineGoroutines()
+		s, p := newSlowDouble()
+		var mu sync.Mutex
+		var outs []interface{}
+		h := Start(context.Background(),
+			endless,
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Process(ctx, p, in, WithConcurrency(4))
+			},
+			Sink(func(i interface{}) error {
+				mu.Lock()
+				defer mu.Unlock()
+				outs = append(outs, i)
+				return nil
+			}),
+		)
+		time.Sleep(20 * time.Millisecond)
+		h.Stop(true)
+		if err := h.Wait(); err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		checkNoNewGoroutines(t, before)
+		if len(s.canceled) > 0 {
+			t.Errorf("canceled = %+v, want none", s.canceled)
+		}
+		if len(outs) == 0 {
+			t.Error("out is empty, want the outputs of the inputs read before the Stop")
+		}
+	})
+
+	t.Run("Stop can be called any number of times", func(t *testing.T) {
+		before := pipelineGoroutines()
+		_, p := newSlowDouble()
+		h := Start(context.Background(),
+			endless,
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Process(ctx, p, in, WithConcurrency(4))
+			},
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Collect(ctx, 10, time.Millisecond, in)
+			},
+			Sink(func(interface{}) error {
+				return nil
+			}),
+		)
+		time.Sleep(20 * time.Millisecond)
+		var wg sync.WaitGroup
+		for n := 0; n < 4; n++ {
+			wg.Add(1)
+			go func(graceful bool) {
+				defer wg.Done()
+				h.Stop(graceful)
+			}(n%2 == 0)
+		}
+		wg.Wait()
+		select {
+		case <-h.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("the pipeline is still running after Stop")
+		}
+		if err := h.Wait(); err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		checkNoNewGoroutines(t, before)
+	})
+
+	t.Run("Err returns the error of a sink", func(t *testing.T) {
+		failed := errors.New("failed")
+		h := Start(context.Background(),
+			endless,
+			Sink(func(i interface{}) error {
+				if i == 10 {
+					return failed
+				}
+				return nil
+			}),
+		)
+		if err := h.Wait(); err != failed {
+			t.Errorf("Wait() = %v, want %v", err, failed)
+		}
+		if err := h.Err(); err != failed {
+			t.Errorf("Err() = %v, want %v", err, failed)
+		}
+	})
+
+	t.Run("Wait waits for the stages before a stage that ends early", func(t *testing.T) {
+		before := pipelineGoroutines()
+		_, p := newSlowDouble()
+		h := Start(context.Background(),
+			endless,
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Process(ctx, p, in, WithConcurrency(4))
+			},
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Take(ctx, 5, in)
+			},
+		)
+		if err := h.Wait(); err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		checkNoNewGoroutines(t, before)
+	})
+}
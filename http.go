@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// HTTPProcessor is a Processor that calls an HTTP endpoint for each input.
+// `Build` builds the request of an input with the context it is given, which is derived from the stage context,
+// so the request is aborted when the stage is canceled or a WithTimeout wrapper gives up on it.
+// `Parse` turns a 2xx response into the result. Any other status is returned as an *HTTPError.
+//
+// The response body is always drained and closed after `Parse` returns, so that its connection is reused.
+// Wrap an HTTPProcessor with RetryIf and IsRetryable to retry the failures that may succeed later:
+//
+//	p := pipeline.RetryIf(3, pipeline.ExponentialBackoff(100*time.Millisecond, time.Second), pipeline.IsRetryable, &pipeline.HTTPProcessor{
+//		Build: func(ctx context.Context, i interface{}) (*http.Request, error) {
+//			return http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com/items/"+i.(string), nil)
+//		},
+//		Parse: parseItem,
+//	})
+type HTTPProcessor struct {
+	// Client sends the requests, http.DefaultClient is used if it is nil
+	Client *http.Client
+	// Build builds the request of an input
+	Build func(ctx context.Context, i interface{}) (*http.Request, error)
+	// Parse parses a 2xx response into the result. If it is nil, the result is the body as a []byte.
+	Parse func(resp *http.Response) (interface{}, error)
+	// OnCancel is called with the inputs that are canceled, it may be nil
+	OnCancel func(i interface{}, err error)
+}
+
+// maxDrain is how much of an unread response body is drained so its connection can be reused.
+// A longer body is cheaper to abandon along with its connection.
+const maxDrain = 64 << 10
+
+// Process sends the request of i and parses its response
+func (h *HTTPProcessor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	req, err := h.Build(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = io.CopyN(io.Discard, resp.Body, maxDrain)
+		resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if h.Parse == nil {
+		return io.ReadAll(resp.Body)
+	}
+	return h.Parse(resp)
+}
+
+// Cancel passes i and err to OnCancel, if it is set
+func (h *HTTPProcessor) Cancel(i interface{}, err error) {
+	if h.OnCancel != nil {
+		h.OnCancel(i, err)
+	}
+}
+
+// HTTPError is returned by HTTPProcessor for a response whose status is not 2xx
+type HTTPError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("pipeline: unexpected HTTP status %s", e.Status)
+}
+
+// Retryable returns true for the statuses that may succeed later: 408, 429 and 5xx, except for 501
+func (e *HTTPError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusRequestTimeout, e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode == http.StatusNotImplemented:
+		return false
+	default:
+		return e.StatusCode >= 500
+	}
+}
+
+// IsRetryable returns true if err may not happen again when the input is retried:
+// a retryable *HTTPError, a timeout, or a network error. It is meant to be passed to RetryIf.
+// The cancellation of the context is not retryable.
+func IsRetryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Retryable()
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPProcessor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = io.WriteString(w, strings.Repeat("ok", 24<<10))
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, "try later")
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer srv.Close()
+
+	// dials counts the connections that the client opens
+	var dials int64
+	dialer := &net.Dialer{}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt64(&dials, 1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+	get := func(ctx context.Context, i interface{}) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+i.(string), nil)
+	}
+	status := func(resp *http.Response) (interface{}, error) {
+		// Leave the body unread for HTTPProcessor to drain
+		return resp.StatusCode, nil
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		parse         func(resp *http.Response) (interface{}, error)
+		want          interface{}
+		wantStatus    int
+		wantRetryable bool
+	}{{
+		name:  "a 2xx response is parsed",
+		path:  "/ok",
+		parse: status,
+		want:  http.StatusOK,
+	}, {
+		name: "without Parse the result is the body",
+		path: "/ok",
+		want: strings.Repeat("ok", 24<<10),
+	}, {
+		name:          "a 5xx response is a retryable error",
+		path:          "/unavailable",
+		parse:         status,
+		wantStatus:    http.StatusServiceUnavailable,
+		wantRetryable: true,
+	}, {
+		name:       "a 4xx response is an error that is not retryable",
+		path:       "/missing",
+		parse:      status,
+		wantStatus: http.StatusNotFound,
+	}}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			p := &HTTPProcessor{Client: client, Build: get, Parse: test.parse}
+			out, err := p.Process(context.Background(), test.path)
+			if b, ok := out.([]byte); ok {
+				out = string(b)
+			}
+			if out != test.want && test.want != nil {
+				t.Errorf("out = %v, want %v", out, test.want)
+			}
+			var httpErr *HTTPError
+			if test.wantStatus == 0 && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			} else if test.wantStatus != 0 && (!errors.As(err, &httpErr) || httpErr.StatusCode != test.wantStatus) {
+				t.Errorf("err = %v, want an *HTTPError with status %d", err, test.wantStatus)
+			}
+			if retryable := err != nil && IsRetryable(err); retryable != test.wantRetryable {
+				t.Errorf("IsRetryable = %t, want %t", retryable, test.wantRetryable)
+			}
+		})
+	}
+
+	t.Run("a timeout is retryable", func(t *testing.T) {
+		p := WithTimeout(20*time.Millisecond, &HTTPProcessor{Client: client, Build: get})
+		_, err := p.Process(context.Background(), "/slow")
+		if !errors.Is(err, context.DeadlineExceeded) || !IsRetryable(err) {
+			t.Errorf("err = %v, want a retryable context.DeadlineExceeded", err)
+		}
+		if IsRetryable(context.Canceled) {
+			t.Error("context.Canceled is retryable")
+		}
+	})
+
+	t.Run("connections are reused when the body is not read", func(t *testing.T) {
+		atomic.StoreInt64(&dials, 0)
+		p := &HTTPProcessor{Client: client, Build: get, Parse: status}
+		for o := range Process(context.Background(), p, Emit("/ok", "/unavailable", "/ok", "/missing", "/ok", "/ok")) {
+			if o != http.StatusOK {
+				t.Errorf("out = %v, want %d", o, http.StatusOK)
+			}
+		}
+		if n := atomic.LoadInt64(&dials); n > 1 {
+			t.Errorf("dialed %d connections, want at most 1", n)
+		}
+	})
+
+	t.Run("composes with RetryIf", func(t *testing.T) {
+		var calls int64
+		flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt64(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}))
+		defer flaky.Close()
+		p := RetryIf(3, ConstantBackoff(time.Millisecond), IsRetryable, &HTTPProcessor{
+			Build: func(ctx context.Context, _ interface{}) (*http.Request, error) {
+				return http.NewRequestWithContext(ctx, http.MethodGet, flaky.URL, nil)
+			},
+			Parse: status,
+		})
+		out, err := p.Process(context.Background(), nil)
+		if err != nil || out != http.StatusOK {
+			t.Errorf("out, err = %v, %v, want %d, nil", out, err, http.StatusOK)
+		}
+	})
+}
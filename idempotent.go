@@ -0,0 +1,206 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// IdempotencyStore remembers the keys of the inputs that ProcessIdempotent already processed,
+// so that they are skipped when they come again, even after a restart if the store is persistent, such as Redis.
+// Its methods may be called by several workers at once.
+type IdempotencyStore interface {
+	// Seen returns true if `key` was marked and has not expired
+	Seen(ctx context.Context, key string) (bool, error)
+	// Mark marks `key` for `ttl`, or for good if `ttl` is 0
+	Mark(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// IdempotencyError is the error of an input whose key could not be checked or marked in the IdempotencyStore of ProcessIdempotent
+type IdempotencyError struct {
+	Key string
+	// Op is the method of the IdempotencyStore that failed: "seen" or "mark"
+	Op  string
+	Err error
+}
+
+func (e *IdempotencyError) Error() string {
+	return fmt.Sprintf("idempotency %s of key %q: %s", e.Op, e.Key, e.Err)
+}
+
+func (e *IdempotencyError) Unwrap() error {
+	return e.Err
+}
+
+// ProcessIdempotent is like Process, except that it skips the inputs whose key, as returned by `keyFn`, is seen in `store`,
+// and marks the key of each input in `store` for `ttl` once `Processor.Process` succeeds:
+//
+//	store := pipeline.NewMemoryIdempotencyStore()
+//	out := pipeline.ProcessIdempotent(ctx, store, orderID, 24*time.Hour, charger, in)
+//
+// Since a key is marked after its input is processed, an input whose mark fails or that comes again while it is processed
+// is processed twice. Use WithMarkBeforeProcess to mark the key before instead, in which case an input that fails
+// is not processed again. The inputs that are skipped are passed to the func set by WithDuplicates, if there is one,
+// and are reported as skipped rather than emitted to a Metrics that implements SkippedMetrics.
+// If `store` fails, the input fails with an *IdempotencyError by default, which is passed to `Processor.Cancel`
+// wrapped in a *ProcessError, even if the input was processed. With WithFailOpen, the input is processed as if its key
+// was not seen and its result is sent, and the error is passed to the func set by WithErrors, if there is one.
+// The other options are those of Process, such as WithConcurrency.
+func ProcessIdempotent(ctx context.Context, store IdempotencyStore, keyFn func(i interface{}) string, ttl time.Duration, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	idem := &idempotent{
+		store:     store,
+		keyFn:     keyFn,
+		ttl:       ttl,
+		processor: p,
+		cfg:       c,
+	}
+	c.Skip = func(o interface{}) bool {
+		_, skipped := o.(idempotentSkip)
+		return skipped
+	}
+	return core.Process[interface{}, interface{}](ctx, idem, in, c.Config)
+}
+
+// WithMarkBeforeProcess makes ProcessIdempotent mark the key of an input before it is processed rather than after it succeeds,
+// so that each input is processed at most once rather than at least once
+func WithMarkBeforeProcess() Option {
+	return func(c *config) {
+		c.markBefore = true
+	}
+}
+
+// WithFailOpen makes ProcessIdempotent process the inputs whose key cannot be checked or marked in its IdempotencyStore,
+// rather than fail them
+func WithFailOpen() Option {
+	return func(c *config) {
+		c.failOpen = true
+	}
+}
+
+// idempotentSkip is the result of an input that idempotent skips, which the stage of ProcessIdempotent drops
+// and reports as skipped rather than emitted
+type idempotentSkip struct{}
+
+// idempotent implements Processor
+type idempotent struct {
+	store     IdempotencyStore
+	keyFn     func(i interface{}) string
+	ttl       time.Duration
+	processor Processor
+	cfg       *config
+}
+
+func (p *idempotent) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	key := p.keyFn(i)
+	seen, err := p.store.Seen(ctx, key)
+	if err != nil {
+		if err := p.storeFailed(key, "seen", err); err != nil {
+			return nil, err
+		}
+	}
+	if seen {
+		if p.cfg.duplicates != nil {
+			p.cfg.duplicates(i)
+		}
+		return idempotentSkip{}, nil
+	}
+	if p.cfg.markBefore {
+		if err := p.mark(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	out, err := p.processor.Process(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	if !p.cfg.markBefore {
+		// The input was processed, so its key is marked even if the context is done meanwhile
+		if err := p.mark(context.WithoutCancel(ctx), key); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// mark marks key in the store and returns the error to fail the input with, if any
+func (p *idempotent) mark(ctx context.Context, key string) error {
+	if err := p.store.Mark(ctx, key, p.ttl); err != nil {
+		return p.storeFailed(key, "mark", err)
+	}
+	return nil
+}
+
+// storeFailed returns the *IdempotencyError of the `op` of key, or passes it to the func set by WithErrors and returns nil with WithFailOpen
+func (p *idempotent) storeFailed(key, op string, err error) error {
+	err = &IdempotencyError{Key: key, Op: op, Err: err}
+	if !p.cfg.failOpen {
+		return err
+	}
+	if p.cfg.errored != nil {
+		p.cfg.errored(err)
+	}
+	return nil
+}
+
+func (p *idempotent) Cancel(i interface{}, err error) {
+	p.processor.Cancel(i, err)
+}
+
+func (p *idempotent) CancelContext(ctx context.Context, i interface{}, err error) {
+	cancelContext(ctx, p.processor, i, err)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that holds its keys in memory, so they are forgotten when the process stops.
+// It is meant for tests and for the pipelines that only need to skip the duplicates within a run.
+type MemoryIdempotencyStore struct {
+	clock Clock
+	mu    sync.Mutex
+	// expiries maps each key to the time it expires at, which is zero for the keys marked for good
+	expiries map[string]time.Time
+	// sweepAt is the number of keys at which the expired keys are removed next
+	sweepAt int
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+// Use WithClock to tell when the keys expire with another Clock than the real one.
+func NewMemoryIdempotencyStore(opts ...Option) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		clock:    newConfig(opts).Clock,
+		expiries: map[string]time.Time{},
+		sweepAt:  1,
+	}
+}
+
+// Seen returns true if `key` was marked and has not expired. It never fails.
+func (s *MemoryIdempotencyStore) Seen(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, ok := s.expiries[key]
+	return ok && (expiry.IsZero() || s.clock.Now().Before(expiry)), nil
+}
+
+// Mark marks `key` for `ttl`, or for good if `ttl` is 0. It never fails.
+func (s *MemoryIdempotencyStore) Mark(_ context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	var expiry time.Time
+	if ttl > 0 {
+		expiry = now.Add(ttl)
+	}
+	s.expiries[key] = expiry
+	// Remove the expired keys each time the keys double, so that the store does not grow with them
+	if len(s.expiries) >= s.sweepAt {
+		for k, e := range s.expiries {
+			if !e.IsZero() && !now.Before(e) {
+				delete(s.expiries, k)
+			}
+		}
+		s.sweepAt = max(2*len(s.expiries), 1)
+	}
+	return nil
+}
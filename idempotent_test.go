@@ -0,0 +1,232 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
+)
+
+// failingStore is an IdempotencyStore whose Seen or Mark fail
+type failingStore struct {
+	*MemoryIdempotencyStore
+	seenErr, markErr error
+}
+
+func (s *failingStore) Seen(ctx context.Context, key string) (bool, error) {
+	if s.seenErr != nil {
+		return false, s.seenErr
+	}
+	return s.MemoryIdempotencyStore.Seen(ctx, key)
+}
+
+func (s *failingStore) Mark(ctx context.Context, key string, ttl time.Duration) error {
+	if s.markErr != nil {
+		return s.markErr
+	}
+	return s.MemoryIdempotencyStore.Mark(ctx, key, ttl)
+}
+
+func TestProcessIdempotent(t *testing.T) {
+	key := func(i interface{}) string {
+		return i.(string)
+	}
+	// recorder processes the inputs and records them, and fails the inputs in fail
+	type recorder struct {
+		mu        sync.Mutex
+		processed []interface{}
+		canceled  map[interface{}]error
+	}
+	newProcessor := func(r *recorder, fail ...string) Processor {
+		r.canceled = map[interface{}]error{}
+		return NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.processed = append(r.processed, i)
+			for _, f := range fail {
+				if i == f {
+					return nil, errProcess
+				}
+			}
+			return i, nil
+		}, func(i interface{}, err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.canceled[i] = err
+		})
+	}
+	run := func(store IdempotencyStore, p Processor, opts []Option, is ...interface{}) []interface{} {
+		var outs []interface{}
+		for o := range ProcessIdempotent(context.Background(), store, key, time.Hour, p, Emit(is...), opts...) {
+			outs = append(outs, o)
+		}
+		return outs
+	}
+
+	t.Run("the inputs already seen are skipped, including in a later run", func(t *testing.T) {
+		store := NewMemoryIdempotencyStore()
+		var r recorder
+		var skipped []interface{}
+		opts := []Option{WithDuplicates(func(i interface{}) {
+			skipped = append(skipped, i)
+		})}
+		if outs, want := run(store, newProcessor(&r), opts, "a", "b", "a"), []interface{}{"a", "b"}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if outs, want := run(store, newProcessor(&r), opts, "b", "c"), []interface{}{"c"}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out of the second run = %+v, want %+v", outs, want)
+		}
+		if want := []interface{}{"a", "b", "c"}; !reflect.DeepEqual(want, r.processed) {
+			t.Errorf("processed = %+v, want %+v", r.processed, want)
+		}
+		if want := []interface{}{"a", "b"}; !reflect.DeepEqual(want, skipped) {
+			t.Errorf("skipped = %+v, want %+v", skipped, want)
+		}
+	})
+
+	t.Run("an input that fails is not marked and is processed again", func(t *testing.T) {
+		var r recorder
+		outs := run(NewMemoryIdempotencyStore(), newProcessor(&r, "a"), nil, "a", "a")
+		if len(outs) > 0 {
+			t.Errorf("out = %+v, want none", outs)
+		}
+		if want := []interface{}{"a", "a"}; !reflect.DeepEqual(want, r.processed) {
+			t.Errorf("processed = %+v, want %+v", r.processed, want)
+		}
+	})
+
+	t.Run("WithMarkBeforeProcess does not process again an input that fails", func(t *testing.T) {
+		var r recorder
+		outs := run(NewMemoryIdempotencyStore(), newProcessor(&r, "a"), []Option{WithMarkBeforeProcess()}, "a", "a")
+		if len(outs) > 0 {
+			t.Errorf("out = %+v, want none", outs)
+		}
+		if want := []interface{}{"a"}; !reflect.DeepEqual(want, r.processed) {
+			t.Errorf("processed = %+v, want %+v", r.processed, want)
+		}
+	})
+
+	t.Run("the inputs skipped are counted as skipped rather than emitted", func(t *testing.T) {
+		var m MemoryMetrics
+		var r recorder
+		run(NewMemoryIdempotencyStore(), newProcessor(&r), []Option{WithMetrics("idempotent", &m)}, "a", "b", "a")
+		if got, want := m.Stage("idempotent"), (StageMetrics{Received: 3, Emitted: 2, Skipped: 1}); got.Received != want.Received ||
+			got.Emitted != want.Emitted || got.Skipped != want.Skipped || got.InFlight() != 0 {
+			t.Errorf("metrics = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("a result that is not sent before the context is canceled is canceled with an *UndeliveredError", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var r recorder
+		p := newProcessor(&r)
+		processed := make(chan struct{})
+		notify := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			defer close(processed)
+			return p.Process(ctx, i)
+		}, p.Cancel)
+		out := ProcessIdempotent(ctx, NewMemoryIdempotencyStore(), key, time.Hour, notify, Emit("a"))
+		<-processed
+		cancel()
+		for range out {
+		}
+		var uErr *UndeliveredError
+		if err := r.canceled["a"]; !errors.As(err, &uErr) || uErr.Result != "a" {
+			t.Errorf("canceled with %v, want an *UndeliveredError of a", err)
+		}
+	})
+
+	t.Run("WithBufferedOutput buffers the out chan", func(t *testing.T) {
+		var r recorder
+		out := ProcessIdempotent(context.Background(), NewMemoryIdempotencyStore(), key, time.Hour, newProcessor(&r), Emit("a"), WithBufferedOutput(2))
+		if cap(out) != 2 {
+			t.Errorf("cap(out) = %d, want 2", cap(out))
+		}
+		for range out {
+		}
+	})
+
+	failed := errors.New("store down")
+	for _, test := range []struct {
+		name          string
+		store         *failingStore
+		opts          []Option
+		wantProcessed []interface{}
+		wantOp        string
+	}{{
+		name:   "the inputs fail if the store cannot check their key",
+		store:  &failingStore{seenErr: failed},
+		wantOp: "seen",
+	}, {
+		name:          "the inputs fail if the store cannot mark their key after they are processed",
+		store:         &failingStore{markErr: failed},
+		wantProcessed: []interface{}{"a"},
+		wantOp:        "mark",
+	}, {
+		name:   "the inputs fail if the store cannot mark their key before they are processed",
+		store:  &failingStore{markErr: failed},
+		opts:   []Option{WithMarkBeforeProcess()},
+		wantOp: "mark",
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.store.MemoryIdempotencyStore = NewMemoryIdempotencyStore()
+			var r recorder
+			if outs := run(test.store, newProcessor(&r), test.opts, "a"); len(outs) > 0 {
+				t.Errorf("out = %+v, want none", outs)
+			}
+			if !reflect.DeepEqual(test.wantProcessed, r.processed) {
+				t.Errorf("processed = %+v, want %+v", r.processed, test.wantProcessed)
+			}
+			var iErr *IdempotencyError
+			if err := r.canceled["a"]; !errors.As(err, &iErr) || iErr.Op != test.wantOp || iErr.Key != "a" || !errors.Is(err, failed) {
+				t.Errorf("canceled with %v, want the *IdempotencyError of %s", err, test.wantOp)
+			}
+		})
+	}
+
+	t.Run("WithFailOpen processes the inputs when the store fails and reports the errors", func(t *testing.T) {
+		store := &failingStore{MemoryIdempotencyStore: NewMemoryIdempotencyStore(), seenErr: failed, markErr: failed}
+		var r recorder
+		var errs []error
+		opts := []Option{WithFailOpen(), WithErrors(func(err error) {
+			errs = append(errs, err)
+		})}
+		if outs, want := run(store, newProcessor(&r), opts, "a", "a"), []interface{}{"a", "a"}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if len(errs) != 4 || !errors.Is(errs[0], failed) {
+			t.Errorf("errs = %+v, want 4 errors of the store", errs)
+		}
+	})
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	clock := pipelinetest.NewFakeClock(time.Unix(0, 0))
+	store := NewMemoryIdempotencyStore(WithClock(clock))
+	seen := func(key string) bool {
+		s, _ := store.Seen(ctx, key)
+		return s
+	}
+	store.Mark(ctx, "a", time.Minute)
+	store.Mark(ctx, "b", 0)
+	if !seen("a") || !seen("b") || seen("c") {
+		t.Errorf("seen a, b, c = %t, %t, %t, want true, true, false", seen("a"), seen("b"), seen("c"))
+	}
+	clock.Advance(time.Minute)
+	if seen("a") || !seen("b") {
+		t.Errorf("seen a, b = %t, %t after the ttl of a, want false, true", seen("a"), seen("b"))
+	}
+	// The expired keys are removed once the keys double
+	store.Mark(ctx, "c", time.Minute)
+	store.Mark(ctx, "d", time.Minute)
+	if _, ok := store.expiries["a"]; ok || len(store.expiries) != 3 {
+		t.Errorf("keys = %+v, want b, c and d", store.expiries)
+	}
+}
@@ -0,0 +1,36 @@
+package pipeline
+
+import "github.com/deliveryhero/pipeline/internal/core"
+
+// IdleNotifier counts the inputs in flight in the stages that report to it with WithIdleNotifier,
+// and tells when none has been for a while, such as to commit the work of a source that never closes its chan:
+//
+//	idle := pipeline.NewIdleNotifier()
+//	parsed := pipeline.ProcessConcurrently(ctx, 4, parser, in, pipeline.WithIdleNotifier(idle))
+//	saved := pipeline.ProcessBatch(ctx, 100, time.Second, saver, parsed, pipeline.WithIdleNotifier(idle))
+//	go func() {
+//		for idle.AwaitIdle(ctx, time.Second) == nil {
+//			commit()
+//		}
+//	}()
+//
+// An input is in flight from the time a stage reads it until the stage sends its result, or the last result of its batch,
+// or cancels it. Between two stages, an input is in the chan that joins them and is not counted,
+// so the `quiet` period of `IdleNotifier.AwaitIdle` must be longer than the stages take to hand over an input.
+// The stages that do not take options, such as Merge, cannot report to it, and neither can the custom stages
+// unless they call `IdleNotifier.Add` themselves.
+type IdleNotifier = core.IdleNotifier
+
+// NewIdleNotifier returns an IdleNotifier with no input in flight.
+// Use WithClock to time the quiet periods with another Clock than the real one.
+func NewIdleNotifier(opts ...Option) *IdleNotifier {
+	return core.NewIdleNotifier(newConfig(opts).Clock)
+}
+
+// WithIdleNotifier makes the process stages, ProcessBatch, ProcessBatchConcurrently, Collect, MergeFair and MergeOrdered
+// count the inputs they hold in `idle`, see IdleNotifier
+func WithIdleNotifier(idle *IdleNotifier) Option {
+	return func(c *config) {
+		c.Idle = idle
+	}
+}
@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdleNotifier(t *testing.T) {
+	t.Run("each burst is reported once it is drained", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		idle := NewIdleNotifier()
+		// The source never closes its chan
+		in := make(chan interface{})
+		slow := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			time.Sleep(time.Millisecond)
+			return i, nil
+		})
+		batched := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			return i, nil
+		})
+		out := ProcessConcurrently(ctx, 2, slow, in, WithIdleNotifier(idle))
+		out = ProcessBatch(ctx, 4, 20*time.Millisecond, batched, out, WithIdleNotifier(idle))
+		out = MergeFair(ctx, []<-chan interface{}{out}, WithIdleNotifier(idle))
+		go discard(out)
+
+		var notified int32
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for idle.AwaitIdle(ctx, 50*time.Millisecond) == nil {
+				atomic.AddInt32(&notified, 1)
+			}
+		}()
+		for burst := 0; burst < 2; burst++ {
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+			time.Sleep(200 * time.Millisecond)
+			if n := atomic.LoadInt32(&notified); n != int32(burst+1) {
+				t.Errorf("notified %d times after burst %d, want %d", n, burst+1, burst+1)
+			}
+		}
+		cancel()
+		<-done
+		if n := atomic.LoadInt32(&notified); n != 2 {
+			t.Errorf("notified %d times, want 2", n)
+		}
+	})
+
+	t.Run("the inputs that fail, are canceled or are dropped leave the count", func(t *testing.T) {
+		idle := NewIdleNotifier()
+		failOdd := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			if i.(int)%2 == 1 {
+				return nil, errProcess
+			}
+			return i, nil
+		})
+		for range Process(context.Background(), failOdd, emitN(10), WithIdleNotifier(idle)) {
+		}
+		out, errs := ProcessWithErrors(context.Background(), failOdd, Emit(1, 2), WithIdleNotifier(idle))
+		go func() {
+			for range errs {
+			}
+		}()
+		for range Collect(context.Background(), 4, time.Second, out, WithIdleNotifier(idle)) {
+		}
+		// The late duplicate of 0 is dropped
+		stamped := Emit(Stamped{Seq: 0}, Stamped{Seq: 1}, Stamped{Seq: 0})
+		for range MergeOrderedWith(context.Background(), StampedSeq, []Option{WithIdleNotifier(idle)}, stamped) {
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for range Process(ctx, failOdd, emitN(10), WithIdleNotifier(idle)) {
+		}
+		if n := idle.InFlight(); n != 0 {
+			t.Errorf("in flight = %d, want 0", n)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import "github.com/deliveryhero/pipeline/internal/core"
+
+// InflightLimit bounds the number of items in flight across a chain of stages, which bounds the memory that holds them
+// whatever the concurrency and the buffers of the stages are:
+//
+//	lim := pipeline.NewInflightLimit(256)
+//	parsed := pipeline.ProcessConcurrently(ctx, 8, parser, in, pipeline.WithInflightLimit(lim))
+//	enriched := pipeline.ProcessConcurrently(ctx, 8, enricher, parsed, pipeline.WithInflightRelease(lim))
+//	for o := range pipeline.ReleaseInflight(lim, enriched) {
+//		...
+//	}
+//
+// The first stage takes a slot for each input it reads and waits while all of the slots are taken.
+// A slot is released when its item leaves the chain: through ReleaseInflight, through `InflightLimit.Release`,
+// or when its input is canceled by a stage that has WithInflightLimit or WithInflightRelease.
+// Each input is expected to stay one item to the end of the chain:
+// the stages that drop, merge or split items must release the slots of the items that disappear themselves.
+type InflightLimit = core.InflightLimit
+
+// NewInflightLimit returns an InflightLimit of `n` slots.
+// It panics if `n` is not positive.
+func NewInflightLimit(n int) *InflightLimit {
+	return core.NewInflightLimit(n)
+}
+
+// WithInflightLimit makes Process, ProcessConcurrently and ProcessConcurrentlyOrdered take a slot of `lim`
+// before they read each input, and release the slots of the inputs they cancel.
+// Each worker holds a slot while it waits for an input, so `lim` should have more slots than the stage has workers.
+// See InflightLimit.
+func WithInflightLimit(lim *InflightLimit) Option {
+	return func(c *config) {
+		c.InflightAcquire = lim
+		c.InflightRelease = lim
+	}
+}
+
+// WithInflightRelease makes the process stages release the slots of `lim` of the inputs they cancel,
+// for the stages that come after the one with WithInflightLimit. The batch stages release one slot per input of the batch.
+// See InflightLimit.
+func WithInflightRelease(lim *InflightLimit) Option {
+	return func(c *config) {
+		c.InflightRelease = lim
+	}
+}
+
+// ReleaseInflight sends each input of the `in <-chan interface{}` to the out chan and then releases its slot of `lim`,
+// at the end of the chain of stages that `lim` bounds.
+// The out chan is closed when the `in <-chan interface{}` is closed.
+func ReleaseInflight(lim *InflightLimit, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for i := range in {
+			out <- i
+			lim.Release(1)
+		}
+	}()
+	return out
+}
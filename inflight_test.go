@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInflightLimit(t *testing.T) {
+	t.Run("the first stage waits for a free slot", func(t *testing.T) {
+		lim := NewInflightLimit(3)
+		var processed int32
+		p := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			atomic.AddInt32(&processed, 1)
+			return i, nil
+		})
+		out := ReleaseInflight(lim, ProcessConcurrently(context.Background(), 2, p, emitN(10), WithInflightLimit(lim)))
+
+		// Nothing is received, so 3 inputs are in flight and the stage waits
+		time.Sleep(50 * time.Millisecond)
+		if got := atomic.LoadInt32(&processed); got != 3 {
+			t.Errorf("processed = %d, want 3", got)
+		}
+		if got := lim.InUse(); got != 3 {
+			t.Errorf("InUse() = %d, want 3", got)
+		}
+		var n int
+		for range out {
+			n++
+		}
+		if n != 10 {
+			t.Errorf("received %d outputs, want 10", n)
+		}
+		if got := lim.InUse(); got != 0 {
+			t.Errorf("InUse() = %d after the last output, want 0", got)
+		}
+	})
+
+	t.Run("the canceled inputs release their slots", func(t *testing.T) {
+		seed := time.Now().UnixNano()
+		t.Logf("seed = %d", seed)
+		rng := rand.New(rand.NewSource(seed)) // #nosec
+		for run := 0; run < 20; run++ {
+			c := randomAccountingCase(rng, "")
+			lim := NewInflightLimit(1 + rng.Intn(16))
+			// Each stage fails some of its inputs and the context is canceled at some point
+			processor := func() *accountingProcessor {
+				return &accountingProcessor{rng: rand.New(rand.NewSource(rng.Int63())), errRate: c.errRate, maxDuration: c.maxDuration} // #nosec
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), c.cancelAfter)
+			out := ProcessConcurrently(ctx, c.concurrency, processor(), emitN(c.items), WithInflightLimit(lim))
+			out = ProcessBatchConcurrently(ctx, 2, c.batchSize, c.maxDuration, processor(), out, WithInflightRelease(lim))
+			out = ProcessConcurrentlyOrdered(ctx, c.concurrency, processor(), out, WithInflightRelease(lim))
+			out = ReleaseInflight(lim, out)
+
+			timeout := time.After(10 * time.Second)
+		receive:
+			for {
+				select {
+				case _, open := <-out:
+					if !open {
+						break receive
+					}
+				case <-timeout:
+					t.Fatalf("%+v: the pipeline is stuck with %d slots in use", c, lim.InUse())
+				}
+			}
+			cancel()
+			if got := lim.InUse(); got != 0 {
+				t.Errorf("%+v: InUse() = %d after the last output, want 0", c, got)
+			}
+		}
+	})
+
+	t.Run("releasing a free slot panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Release did not panic")
+			}
+		}()
+		NewInflightLimit(1).Release(1)
+	})
+}
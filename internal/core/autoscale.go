@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProcessAutoscale is like ProcessConcurrently, except that the number of workers changes with the load.
+// It starts `min` workers and adds one, up to `max`, each time an input has waited `cfg.ScaleWindow` for a free worker.
+// A worker that has been idle for `cfg.ScaleCooldown` is retired, down to `min`.
+// Each worker is set up as it starts and torn down as it retires, with a copy of p from `cfg.CloneWorker` if p can be cloned.
+func ProcessAutoscale[I, O any](ctx context.Context, min, max int, p Processor[I, O], in <-chan I, cfg Config) <-chan O {
+	min, max = Workers(ctx, min), Workers(ctx, max)
+	out := make(chan O, cfg.OutputBuffer)
+	// work hands the inputs to the workers, a send only succeeds when a worker is free
+	work := make(chan I)
+	s := &scaler{min: min, max: max, scaled: func(workers int) {
+		cfg.LogWorkers(ctx, workers)
+		cfg.ReportWorkers(workers)
+		if cfg.Scaled != nil {
+			cfg.Scaled(workers)
+		}
+	}}
+	worker := func(p Processor[I, O]) {
+		idle := time.NewTimer(cfg.ScaleCooldown)
+		defer idle.Stop()
+		for {
+			select {
+			case i, ok := <-work:
+				if !ok {
+					return
+				}
+				stopTimer(idle)
+				process(ctx, cfg, p, i, out)
+				idle.Reset(cfg.ScaleCooldown)
+			case <-idle.C:
+				if s.retire() {
+					return
+				}
+				idle.Reset(cfg.ScaleCooldown)
+			}
+		}
+	}
+	workers := &workerSet[I, O]{ctx: ctx, cfg: cfg, p: p, work: worker}
+	cfg.LogStarted(ctx, min)
+	go func() {
+		for w := 0; w < min; w++ {
+			if err := workers.start(); err != nil {
+				cfg.logSetupFailed(ctx, err)
+				break
+			}
+			s.mu.Lock()
+			s.workers++
+			s.mu.Unlock()
+		}
+		cfg.ReportWorkers(s.count())
+		window := time.NewTimer(cfg.ScaleWindow)
+		stopTimer(window)
+		for i := range in {
+			// Hand the input over right away if a worker is free
+			select {
+			case work <- i:
+				continue
+			default:
+			}
+			// Otherwise add a worker every time the input has waited for a whole window
+			window.Reset(cfg.ScaleWindow)
+			for sent := false; !sent; {
+				select {
+				case work <- i:
+					stopTimer(window)
+					sent = true
+				case <-window.C:
+					// Only this goroutine adds workers, so there is still room for one when it is set up
+					if !s.full() {
+						if err := workers.start(); err != nil {
+							cfg.logSetupFailed(ctx, err)
+						} else {
+							s.grow()
+						}
+					}
+					window.Reset(cfg.ScaleWindow)
+				}
+			}
+		}
+		// Close the out chan after all of the workers finish executing
+		close(work)
+		workers.wait()
+		cfg.LogStopped(ctx)
+		close(out)
+	}()
+	return out
+}
+
+// scaler keeps count of the workers of ProcessAutoscale
+type scaler struct {
+	mu       sync.Mutex
+	min, max int
+	workers  int
+	scaled   func(workers int)
+}
+
+// count returns the number of workers
+func (s *scaler) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workers
+}
+
+// full returns true if there are already max workers
+func (s *scaler) full() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workers >= s.max
+}
+
+// grow adds a worker and returns true, unless there are already max workers
+func (s *scaler) grow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workers >= s.max {
+		return false
+	}
+	s.workers++
+	s.notify()
+	return true
+}
+
+// retire removes a worker and returns true, unless there are only min workers left
+func (s *scaler) retire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workers <= s.min {
+		return false
+	}
+	s.workers--
+	s.notify()
+	return true
+}
+
+// notify reports the number of workers to the scaled callback
+func (s *scaler) notify() {
+	s.scaled(s.workers)
+}
+
+// stopTimer stops t and drains its chan so that it can be safely Reset
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
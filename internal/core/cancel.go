@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// ContextCanceler is the generic form of pipeline.ContextCanceler.
+type ContextCanceler[I any] interface {
+	// CancelContext is called instead of Cancel with a context that ends a grace period after the stage context is done.
+	CancelContext(ctx context.Context, i I, err error)
+}
+
+// Cancel passes i to `ContextCanceler.CancelContext` if the processor implements it, otherwise to `Processor.Cancel`.
+// The context passed to `ContextCanceler.CancelContext` keeps the values of ctx,
+// and is done `cfg.CancelTimeout` after ctx is done or when CancelContext returns.
+// The calls are serialized by `cfg.CancelLock`, if it is set, and their panics are recovered if `cfg.RecoverPanics` is set,
+// so that a worker that fails to cancel an input still closes the out chan.
+// The slot of i is then released to `cfg.InflightRelease`, if it is set.
+func Cancel[I, O any](ctx context.Context, cfg Config, p Processor[I, O], i I, err error) {
+	cfg.Canceled()
+	cfg.logCanceled(ctx, err)
+	if cfg.InflightRelease != nil {
+		defer cfg.InflightRelease.Release(1)
+	}
+	if cfg.CancelLock != nil {
+		cfg.CancelLock.Lock()
+		defer cfg.CancelLock.Unlock()
+	}
+	if cfg.RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				cfg.cancelPanicked(ctx, &ProcessError{Input: i, Err: &PanicError{Value: r, Stack: debug.Stack()}})
+			}
+		}()
+	}
+	c, ok := p.(ContextCanceler[I])
+	if !ok {
+		p.Cancel(i, err)
+		return
+	}
+	gctx, stop := graceContext(ctx, cfg.CancelTimeout)
+	defer stop()
+	c.CancelContext(gctx, i, err)
+}
+
+// BatchCanceler is the generic form of pipeline.BatchCanceler.
+type BatchCanceler[I any] interface {
+	// CancelBatch is called instead of Cancel with the inputs that are drained once the context is done
+	CancelBatch(is []I, err error)
+}
+
+// cancelBatches passes i and the inputs that are ready in `in` to `BatchCanceler.CancelBatch`, in a batch of up to `cfg.CancelBatchSize` inputs,
+// once the context is done. It returns false if the context is not done or p is not a BatchCanceler, in which case i is left to the caller.
+func cancelBatches[I, O any](ctx context.Context, cfg Config, p Processor[I, O], i I, in <-chan I) bool {
+	if ctx.Err() == nil || cfg.CancelBatchSize < 2 {
+		return false
+	}
+	bc, ok := p.(BatchCanceler[I])
+	if !ok {
+		return false
+	}
+	batch := make([]I, 1, cfg.CancelBatchSize)
+	batch[0] = i
+	cfg.Received()
+	for len(batch) < cfg.CancelBatchSize {
+		i, ok := ready(in, cfg)
+		if !ok {
+			break
+		}
+		cfg.Received()
+		batch = append(batch, i)
+	}
+	cancelBatch(ctx, cfg, bc, batch, &CanceledError{Err: ctx.Err()})
+	return true
+}
+
+// cancelBatch is Cancel for a batch of inputs
+func cancelBatch[I any](ctx context.Context, cfg Config, bc BatchCanceler[I], batch []I, err error) {
+	for range batch {
+		cfg.Canceled()
+	}
+	cfg.logCanceled(ctx, err)
+	if cfg.InflightRelease != nil {
+		defer cfg.InflightRelease.Release(len(batch))
+	}
+	if cfg.CancelLock != nil {
+		cfg.CancelLock.Lock()
+		defer cfg.CancelLock.Unlock()
+	}
+	if cfg.RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				cfg.cancelPanicked(ctx, &ProcessError{Input: batch, Err: &PanicError{Value: r, Stack: debug.Stack()}})
+			}
+		}()
+	}
+	bc.CancelBatch(batch, err)
+}
+
+// cancelPanicked passes the error of a panic in `Processor.Cancel` to CancelPanicked, if it is set,
+// or logs it otherwise, if the stage has a Logger
+func (c Config) cancelPanicked(ctx context.Context, err error) {
+	if c.CancelPanicked != nil {
+		c.CancelPanicked(err)
+		return
+	}
+	if c.Logger != nil {
+		c.Logger.LogAttrs(ctx, slog.LevelError, "pipeline: cancel panicked", slog.String("stage", c.Stage), slog.Any("error", err))
+	}
+}
+
+// graceContext returns a context with the values of ctx that is done `grace` after ctx is done
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	if ctx.Err() != nil {
+		return context.WithTimeout(detached{ctx}, grace)
+	}
+	gctx, cancel := context.WithCancel(detached{ctx})
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			timer := time.NewTimer(grace)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				cancel()
+			case <-stop:
+			}
+		case <-stop:
+		}
+	}()
+	return gctx, func() {
+		close(stop)
+		cancel()
+	}
+}
+
+// detached is a context with the values of its parent that is never canceled
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
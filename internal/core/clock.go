@@ -0,0 +1,96 @@
+package core
+
+import "time"
+
+// Clock tells the time and makes the timers of the time-based stages, so that tests can control the time
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After returns a chan that receives the current time once `d` has passed
+	After(d time.Duration) <-chan time.Time
+	// NewTimer returns a Timer that fires once `d` has passed
+	NewTimer(d time.Duration) Timer
+	// NewTicker returns a Ticker that fires every `d`
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the time.Timer of a Clock
+type Timer interface {
+	// C returns the chan that receives the time when the timer fires
+	C() <-chan time.Time
+	// Stop stops the timer, and returns false if it had already fired or been stopped
+	Stop() bool
+	// Reset makes the timer fire once `d` has passed, and returns false if it had already fired or been stopped
+	Reset(d time.Duration) bool
+}
+
+// Ticker is the time.Ticker of a Clock
+type Ticker interface {
+	// C returns the chan that receives the time at each tick
+	C() <-chan time.Time
+	// Stop stops the ticker
+	Stop()
+}
+
+// RealClock is the Clock of the time package
+type RealClock struct{}
+
+// Now returns time.Now()
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns time.After(d)
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// NewTimer returns a time.Timer
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// NewTicker returns a time.Ticker
+func (RealClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTimer is a Timer backed by a time.Timer
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
+
+// realTicker is a Ticker backed by a time.Ticker
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// stopClockTimer stops t and drains its chan so that it can be safely Reset
+func stopClockTimer(t Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C():
+		default:
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Config holds the settings of the processing engine
+type Config struct {
+	// RecoverPanics converts panics in `Processor.Process` into a *PanicError that is passed to `Processor.Cancel`
+	RecoverPanics bool
+	// CancelTimeout is how long `ContextCanceler.CancelContext` can keep running after the stage context is done
+	CancelTimeout time.Duration
+	// Concurrency is the number of inputs Process processes at once, 0 means that it is not set
+	Concurrency int
+	// Ordering is whether the results of the concurrent process stages keep the order of their inputs
+	Ordering Ordering
+	// OutputBuffer is the capacity of the out chans of the process stages
+	OutputBuffer int
+	// ScaleWindow is how long an input must wait for a free worker before ProcessAutoscale adds a worker
+	ScaleWindow time.Duration
+	// ScaleCooldown is how long a worker must be idle before ProcessAutoscale retires it
+	ScaleCooldown time.Duration
+	// Scaled is called with the new number of workers each time ProcessAutoscale adds or retires a worker
+	Scaled func(workers int)
+	// Stage is the name of the stage that tags its Metrics
+	Stage string
+	// Metrics receives the events of the stage, nil means that they are not reported
+	Metrics Metrics
+	// Failed is called with the *ProcessError of each input that `Processor.Process` fails while the context is not done
+	Failed func(err error)
+	// ProfilerLabels labels the calls to `Processor.Process` with the name of the stage in CPU profiles
+	ProfilerLabels bool
+	// Stop makes the process stages stop reading new inputs when it is closed, nil means that they never stop early
+	Stop <-chan struct{}
+	// Grace is how long the inputs that are being processed when Stop is closed have to finish before they are canceled
+	Grace time.Duration
+	// Logger receives the lifecycle events of the stage and the errors of its canceled inputs, nil means that they are not logged
+	Logger *slog.Logger
+	// CancelLock serializes the calls to `Processor.Cancel` of the workers of the stage, nil means that they can run at once
+	CancelLock *sync.Mutex
+	// CancelPanicked is called with a *ProcessError that wraps the *PanicError of each panic recovered in `Processor.Cancel`
+	CancelPanicked func(err error)
+	// Clock tells the time to the time-based stages
+	Clock Clock
+	// CancelBatchSize is the most inputs passed to `BatchCanceler.CancelBatch` at once
+	CancelBatchSize int
+	// CloneWorker returns a copy of a processor for each worker of the concurrent process stages if it can be cloned,
+	// nil means that the workers share the processor
+	CloneWorker func(p interface{}) (interface{}, bool)
+	// TeardownFailed is called with the error or the *PanicError of each `Teardowner.Teardown` that fails
+	TeardownFailed func(err error)
+	// LazyWorkers is how long an input must wait for a free worker before ProcessConcurrently starts another one,
+	// 0 means that it starts all of its workers at once
+	LazyWorkers time.Duration
+	// InflightAcquire is the limit a slot of which the process stages take before they read each input, nil means that they do not wait
+	InflightAcquire *InflightLimit
+	// InflightRelease is the limit the slots of the canceled inputs are released to, nil means that they are not released
+	InflightRelease *InflightLimit
+	// Skip tells the results that the process stages do not send, which are counted as skipped rather than emitted,
+	// nil means that every result is sent
+	Skip func(result interface{}) bool
+	// Idle counts the inputs in flight in the stage, nil means that they are not counted
+	Idle *IdleNotifier
+}
+
+// DefaultConfig returns the default settings of the processing engine
+func DefaultConfig() Config {
+	return Config{
+		RecoverPanics:   true,
+		CancelTimeout:   time.Second,
+		ScaleWindow:     10 * time.Millisecond,
+		ScaleCooldown:   time.Second,
+		Clock:           RealClock{},
+		CancelBatchSize: 100,
+	}
+}
+
+// Ordering is whether the results of the concurrent process stages keep the order of their inputs
+type Ordering int
+
+const (
+	// UnspecifiedOrder leaves the order up to the stage
+	UnspecifiedOrder Ordering = iota
+	// Ordered keeps the results in the order of their inputs
+	Ordered
+	// Unordered sends the results as soon as they are ready
+	Unordered
+)
+
+// SetConcurrency sets Concurrency and panics if it is not positive or conflicts with a Concurrency that was already set
+func (c *Config) SetConcurrency(concurrency int) {
+	if concurrency < 1 {
+		panic(fmt.Sprintf("pipeline: concurrency must be positive, got %d", concurrency))
+	}
+	if c.Concurrency != 0 && c.Concurrency != concurrency {
+		panic(fmt.Sprintf("pipeline: conflicting concurrency %d and %d", c.Concurrency, concurrency))
+	}
+	c.Concurrency = concurrency
+}
+
+// SetOrdering sets Ordering and panics if it conflicts with an Ordering that was already set
+func (c *Config) SetOrdering(ordering Ordering) {
+	if c.Ordering != UnspecifiedOrder && c.Ordering != ordering {
+		panic("pipeline: ordered and unordered output conflict")
+	}
+	c.Ordering = ordering
+}
+
+// SetOutputBuffer sets OutputBuffer and panics if it is negative
+func (c *Config) SetOutputBuffer(size int) {
+	if size < 0 {
+		panic(fmt.Sprintf("pipeline: output buffer must not be negative, got %d", size))
+	}
+	c.OutputBuffer = size
+}
+
+// Fail passes err to Failed, if it is set, unless the context is done,
+// since the inputs that fail after that were most likely interrupted by the cancellation
+func (c Config) Fail(ctx context.Context, err error) {
+	if c.Failed != nil && ctx.Err() == nil {
+		c.Failed(err)
+	}
+}
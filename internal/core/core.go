@@ -0,0 +1,14 @@
+// Package core implements the processing engine shared by the `interface{}` based pipeline package
+// and its type-safe generic sub-package, so both APIs behave exactly the same way.
+package core
+
+import "context"
+
+// Processor is the generic form of pipeline.Processor.
+type Processor[I, O any] interface {
+	// Process processes an input and returns an output or an error, if the output could not be processed.
+	Process(ctx context.Context, i I) (O, error)
+
+	// Cancel is called if process returns an error or if the context is canceled.
+	Cancel(i I, err error)
+}
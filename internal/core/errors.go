@@ -0,0 +1,91 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCanceled matches, with errors.Is, the errors of the inputs that were not processed because the context was done.
+var ErrCanceled = errors.New("canceled")
+
+// CanceledError is passed to `Processor.Cancel` for an input that was not processed because the context was done.
+// It matches ErrCanceled and wraps the `Context.Err()`.
+type CanceledError struct {
+	// Err is the `Context.Err()`
+	Err error
+}
+
+// Error returns the message of the `Context.Err()`
+func (e *CanceledError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the `Context.Err()`
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrCanceled
+func (e *CanceledError) Is(target error) bool {
+	return target == ErrCanceled
+}
+
+// ErrUndelivered matches, with errors.Is, the errors of the inputs that were processed
+// but whose result was not delivered because the context was done first.
+var ErrUndelivered = errors.New("undelivered")
+
+// UndeliveredError is passed to `Processor.Cancel` for an input that was processed, but whose result was not received
+// from the out chan before the context was done. It matches ErrUndelivered, not ErrCanceled, and wraps the `Context.Err()`.
+type UndeliveredError struct {
+	// Result is the result of `Processor.Process` that was not delivered
+	Result interface{}
+	// Err is the `Context.Err()`
+	Err error
+}
+
+// Error returns "undelivered: " followed by the message of the `Context.Err()`
+func (e *UndeliveredError) Error() string {
+	return fmt.Sprintf("undelivered: %s", e.Err)
+}
+
+// Unwrap returns the `Context.Err()`
+func (e *UndeliveredError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrUndelivered
+func (e *UndeliveredError) Is(target error) bool {
+	return target == ErrUndelivered
+}
+
+// ProcessError is returned when `Processor.Process` fails.
+// It carries the input that could not be processed along with the original error.
+type ProcessError struct {
+	// Input is the input that could not be processed
+	Input interface{}
+	// Err is the error returned by `Processor.Process`
+	Err error
+}
+
+// Error returns the original error message
+func (e *ProcessError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error
+func (e *ProcessError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is the error of `Processor.Process` when it panics.
+type PanicError struct {
+	// Value is the value that was passed to panic
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked
+	Stack []byte
+}
+
+// Error returns the panic value
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
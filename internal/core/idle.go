@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IdleNotifier counts the inputs in flight in the stages that report to it, and tells when none has been for a while
+type IdleNotifier struct {
+	clock Clock
+	mu    sync.Mutex
+	// inflight is the number of inputs in flight, busy the number of times it went up from 0,
+	// and notified the value of busy when AwaitIdle last returned
+	inflight int64
+	busy     uint64
+	notified uint64
+	// idleSince is when inflight last went down to 0
+	idleSince time.Time
+	// changed is closed and replaced each time inflight goes up from 0 or down to 0
+	changed chan struct{}
+}
+
+// NewIdleNotifier returns an IdleNotifier that tells the time with clock
+func NewIdleNotifier(clock Clock) *IdleNotifier {
+	return &IdleNotifier{clock: clock, changed: make(chan struct{})}
+}
+
+// Add adds delta to the number of inputs in flight. It does nothing if n is nil.
+func (n *IdleNotifier) Add(delta int) {
+	if n == nil || delta == 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	was := n.inflight
+	n.inflight += int64(delta)
+	switch {
+	case was <= 0 && n.inflight > 0:
+		n.busy++
+	case was > 0 && n.inflight <= 0:
+		n.idleSince = n.clock.Now()
+	default:
+		return
+	}
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+// InFlight returns the number of inputs in flight
+func (n *IdleNotifier) InFlight() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.inflight
+}
+
+// AwaitIdle waits until no input has been in flight for `quiet` after a period of activity that it did not report yet,
+// and returns nil, or returns the `Context.Err()` if the context is canceled first.
+// Each period of activity is reported once, to a single caller, so a loop over AwaitIdle returns once after each of them.
+func (n *IdleNotifier) AwaitIdle(ctx context.Context, quiet time.Duration) error {
+	for {
+		n.mu.Lock()
+		changed := n.changed
+		var timer Timer
+		var wait <-chan time.Time
+		if n.inflight <= 0 && n.busy > n.notified {
+			left := quiet - n.clock.Now().Sub(n.idleSince)
+			if left <= 0 {
+				n.notified = n.busy
+				n.mu.Unlock()
+				return nil
+			}
+			timer = n.clock.NewTimer(left)
+			wait = timer.C()
+		}
+		n.mu.Unlock()
+		select {
+		case <-changed:
+		case <-wait:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
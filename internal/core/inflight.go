@@ -0,0 +1,81 @@
+package core
+
+import "fmt"
+
+// InflightLimit bounds the number of items in flight between the stage that acquires their slots and the stage that releases them
+type InflightLimit struct {
+	slots chan struct{}
+}
+
+// NewInflightLimit returns an InflightLimit of `n` slots and panics if `n` is not positive
+func NewInflightLimit(n int) *InflightLimit {
+	if n <= 0 {
+		panic(fmt.Sprintf("pipeline: inflight limit must be positive, got %d", n))
+	}
+	return &InflightLimit{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a free slot and takes it, unless stop is closed first
+func (l *InflightLimit) acquire(stop <-chan struct{}) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// Release frees `n` slots. It panics if fewer than `n` slots are taken.
+func (l *InflightLimit) Release(n int) {
+	for ; n > 0; n-- {
+		select {
+		case <-l.slots:
+		default:
+			panic("pipeline: inflight limit released more slots than were acquired")
+		}
+	}
+}
+
+// InUse returns the number of slots that are taken
+func (l *InflightLimit) InUse() int {
+	return len(l.slots)
+}
+
+// receive is next, after it takes a slot of `cfg.InflightAcquire`, if it is set
+func receive[I any](in <-chan I, cfg Config) (I, bool) {
+	if cfg.InflightAcquire == nil {
+		return next(in, cfg)
+	}
+	if !cfg.InflightAcquire.acquire(cfg.Stop) {
+		var zero I
+		return zero, false
+	}
+	i, ok := next(in, cfg)
+	if !ok {
+		cfg.InflightAcquire.Release(1)
+	}
+	return i, ok
+}
+
+// ready is receive, except that it returns false right away rather than wait for a slot or an input
+func ready[I any](in <-chan I, cfg Config) (I, bool) {
+	var zero I
+	if cfg.InflightAcquire != nil {
+		select {
+		case cfg.InflightAcquire.slots <- struct{}{}:
+		default:
+			return zero, false
+		}
+	}
+	select {
+	case i, open := <-in:
+		if open {
+			return i, true
+		}
+	default:
+	}
+	if cfg.InflightAcquire != nil {
+		cfg.InflightAcquire.Release(1)
+	}
+	return zero, false
+}
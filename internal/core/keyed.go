@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// ProcessKeyed processes the inputs with `concurrency` workers, where each input is sent to the worker chosen by hashing its key.
+// Inputs that share a key are processed by the same worker in the order they were read from the in chan,
+// while inputs with different keys can be processed in parallel.
+// The workers are set up like the ones of RunWorkers.
+func ProcessKeyed[I, O any](ctx context.Context, concurrency int, keyFn func(I) string, p Processor[I, O], in <-chan I, cfg Config) <-chan O {
+	concurrency = Workers(ctx, concurrency)
+	out := make(chan O, cfg.OutputBuffer)
+	works := make([]chan I, concurrency)
+	for k := range works {
+		works[k] = make(chan I)
+	}
+	go func() {
+		// Each worker reads its own chan, so the workers are set up here rather than by RunWorkers
+		workers := setupWorkers(ctx, cfg, p, concurrency)
+		cfg.ReportWorkers(concurrency)
+		var wg sync.WaitGroup
+		wg.Add(concurrency)
+		for k, w := range workers {
+			go func(w *worker[I, O], work <-chan I) {
+				defer wg.Done()
+				w.run(ctx, cfg, func(p Processor[I, O]) {
+					for i := range work {
+						process(ctx, cfg, p, i, out)
+					}
+				})
+			}(w, works[k])
+		}
+		for i := range in {
+			works[KeyIndex(keyFn(i), concurrency)] <- i
+		}
+		// Close the out chan after all of the workers finish executing
+		for _, work := range works {
+			close(work)
+		}
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// KeyIndex hashes key with 32-bit FNV-1a to an index in [0, n), so a key always gets the same index
+func KeyIndex(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
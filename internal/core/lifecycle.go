@@ -0,0 +1,344 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Setupper is an optional interface of a Processor that acquires resources before its first input
+type Setupper interface {
+	// Setup is called once before the first call to `Processor.Process`
+	Setup(ctx context.Context) error
+}
+
+// Teardowner is an optional interface of a Processor that releases resources after its last input
+type Teardowner interface {
+	// Teardown is called once after the last call to `Processor.Process`
+	Teardown() error
+}
+
+// SetupError is the error of `Setupper.Setup`, which fails every input of the stage
+type SetupError struct {
+	// Err is the error returned by Setup
+	Err error
+}
+
+// Error returns the error message of Setup
+func (e *SetupError) Error() string {
+	return fmt.Sprintf("setup: %s", e.Err)
+}
+
+// Unwrap returns the error returned by Setup
+func (e *SetupError) Unwrap() error {
+	return e.Err
+}
+
+// worker is the processor of one or more of the workers of a stage
+type worker[I, O any] struct {
+	p Processor[I, O]
+	// users is the number of workers that still use p, it is torn down when it reaches 0
+	users    int32
+	tornDown sync.Once
+}
+
+// RunWorkers sets up the processors of `n` workers and runs `work` with each of them, in a goroutine for each worker if `n` is more than 1.
+// Each worker gets a copy of p from `cfg.CloneWorker` if p can be cloned, otherwise they share p.
+// Each processor is torn down once the workers that use it return, even if they panic.
+// If a Setup fails, the processors that were set up are torn down and `work` is run with a processor
+// that fails each input with the *SetupError instead, so that every input is still passed to `Processor.Cancel`.
+// RunWorkers returns once all of the workers returned.
+func RunWorkers[I, O any](ctx context.Context, cfg Config, p Processor[I, O], n int, work func(p Processor[I, O])) {
+	workers := setupWorkers(ctx, cfg, p, n)
+	cfg.ReportWorkers(n)
+	if n == 1 {
+		workers[0].run(ctx, cfg, work)
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for _, w := range workers {
+		go func(w *worker[I, O]) {
+			defer wg.Done()
+			w.run(ctx, cfg, work)
+		}(w)
+	}
+	wg.Wait()
+}
+
+// run runs `work` with the processor of w, and releases it once `work` returns
+func (w *worker[I, O]) run(ctx context.Context, cfg Config, work func(p Processor[I, O])) {
+	returned := false
+	defer func() {
+		if !returned {
+			// The worker panicked, which crashes the program: tear down its processor while there is time
+			w.teardown(ctx, cfg)
+			return
+		}
+		w.release(ctx, cfg)
+	}()
+	work(w.p)
+	returned = true
+}
+
+// workerSet starts the workers of a stage one at a time, each of which runs `work` until it returns.
+// Each worker is set up as it starts, with a copy of p from `cfg.CloneWorker` if p can be cloned, otherwise they share p.
+type workerSet[I, O any] struct {
+	ctx  context.Context
+	cfg  Config
+	p    Processor[I, O]
+	work func(p Processor[I, O])
+	wg   sync.WaitGroup
+	// shared is the worker of p if the workers share it, tried is set once the first Setup ran
+	shared *worker[I, O]
+	tried  bool
+}
+
+// start sets up a worker and runs `work` with it in a goroutine.
+// If the Setup of the first worker fails, `work` is run with a processor that fails each input with the *SetupError instead,
+// like RunWorkers. If a Setup fails after the first one, start returns its error and starts no worker.
+func (s *workerSet[I, O]) start() error {
+	w, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		w.run(s.ctx, s.cfg, s.work)
+	}()
+	return nil
+}
+
+// acquire returns the shared worker while one of its workers still runs, or sets up a new one
+func (s *workerSet[I, O]) acquire() (*worker[I, O], error) {
+	if w := s.shared; w != nil {
+		// A shared worker is torn down once its last worker returns, after which it cannot be used again
+		for users := atomic.LoadInt32(&w.users); users > 0; users = atomic.LoadInt32(&w.users) {
+			if atomic.CompareAndSwapInt32(&w.users, users, users+1) {
+				return w, nil
+			}
+		}
+	}
+	proc, cloned := s.p, false
+	if c, ok := s.cfg.clone(s.p); ok {
+		proc, cloned = c.(Processor[I, O]), true
+	}
+	if err := setup(s.ctx, s.cfg, proc); err != nil {
+		if s.tried {
+			return nil, err
+		}
+		proc, cloned = &setupFailed[I, O]{Processor: s.p, err: &SetupError{Err: err}}, false
+	}
+	s.tried = true
+	w := &worker[I, O]{p: proc, users: 1}
+	if !cloned {
+		s.shared = w
+	}
+	return w, nil
+}
+
+// wait waits for the workers to return
+func (s *workerSet[I, O]) wait() {
+	s.wg.Wait()
+}
+
+// logSetupFailed logs the error of a Setup that failed after the first one, if the stage has a Logger
+func (c Config) logSetupFailed(ctx context.Context, err error) {
+	if c.Logger != nil {
+		c.Logger.LogAttrs(ctx, slog.LevelError, "pipeline: setup failed", slog.String("stage", c.Stage), slog.Any("error", err))
+	}
+}
+
+// lazyWorkers starts the workers of a stage one at a time, up to `max`, see `Config.LazyWorkers`
+type lazyWorkers[I, O any] struct {
+	workerSet[I, O]
+	max int
+	// started is the number of workers
+	started int
+	// full is set once a Setup fails after the first one, which stops adding workers
+	full bool
+}
+
+// add starts a worker and returns true, unless there are already `max` workers or a Setup failed.
+// If the Setup of the first worker fails, it fails each input with the *SetupError instead, like RunWorkers.
+// Setups that fail after the first one are logged, and the workers that already run keep processing the inputs.
+// It must not be called once wait is.
+func (l *lazyWorkers[I, O]) add() bool {
+	if !l.growing() {
+		return false
+	}
+	if err := l.start(); err != nil {
+		l.full = true
+		l.cfg.logSetupFailed(l.ctx, err)
+		return false
+	}
+	l.started++
+	if l.started > 1 {
+		l.cfg.LogWorkers(l.ctx, l.started)
+	}
+	l.cfg.ReportWorkers(l.started)
+	return true
+}
+
+// growing returns false once no more workers can be added
+func (l *lazyWorkers[I, O]) growing() bool {
+	return l.started < l.max && !l.full
+}
+
+// setupWorkers returns the processors of `n` workers once they are set up
+func setupWorkers[I, O any](ctx context.Context, cfg Config, p Processor[I, O], n int) []*worker[I, O] {
+	// procs are the distinct processors of the workers
+	procs := []Processor[I, O]{p}
+	for k := 0; n > 1 && k < n; k++ {
+		c, ok := cfg.clone(p)
+		if !ok {
+			break
+		}
+		procs = append(procs[:k], c.(Processor[I, O]))
+	}
+	// Set up the processors at once
+	errs := make([]error, len(procs))
+	var wg sync.WaitGroup
+	wg.Add(len(procs))
+	for k, proc := range procs {
+		go func(k int, proc Processor[I, O]) {
+			defer wg.Done()
+			errs[k] = setup(ctx, cfg, proc)
+		}(k, proc)
+	}
+	wg.Wait()
+	var err error
+	for _, e := range errs {
+		if e != nil {
+			err = e
+			break
+		}
+	}
+	workers := make([]*worker[I, O], n)
+	if err != nil {
+		// Tear down the processors that were set up, and fail every input instead
+		for k, proc := range procs {
+			if errs[k] == nil {
+				teardown(ctx, cfg, proc)
+			}
+		}
+		procs = []Processor[I, O]{&setupFailed[I, O]{Processor: p, err: &SetupError{Err: err}}}
+	}
+	if len(procs) == 1 {
+		shared := &worker[I, O]{p: procs[0], users: int32(n)}
+		for k := range workers {
+			workers[k] = shared
+		}
+		return workers
+	}
+	for k, proc := range procs {
+		workers[k] = &worker[I, O]{p: proc, users: 1}
+	}
+	return workers
+}
+
+// release tears down the processor of w once the last of its workers returns
+func (w *worker[I, O]) release(ctx context.Context, cfg Config) {
+	if atomic.AddInt32(&w.users, -1) == 0 {
+		w.teardown(ctx, cfg)
+	}
+}
+
+// teardown tears down the processor of w, unless it already was
+func (w *worker[I, O]) teardown(ctx context.Context, cfg Config) {
+	w.tornDown.Do(func() {
+		teardown(ctx, cfg, w.p)
+	})
+}
+
+// clone returns a copy of p for a worker, if `cfg.CloneWorker` is set and can clone p
+func (c Config) clone(p interface{}) (interface{}, bool) {
+	if c.CloneWorker == nil {
+		return nil, false
+	}
+	return c.CloneWorker(p)
+}
+
+// CloneWorker returns `p.CloneForWorker()` if p implements it and it does not return nil, to set `Config.CloneWorker`.
+// P is the Processor type of the API whose clones are returned.
+func CloneWorker[P any](p interface{}) (interface{}, bool) {
+	w, ok := p.(interface{ CloneForWorker() P })
+	if !ok {
+		return nil, false
+	}
+	c := w.CloneForWorker()
+	if interface{}(c) == nil {
+		return nil, false
+	}
+	return c, true
+}
+
+// setup calls `Setupper.Setup` if p implements it, and converts a panic into a *PanicError if `cfg.RecoverPanics` is set
+func setup(ctx context.Context, cfg Config, p interface{}) (err error) {
+	s, ok := p.(Setupper)
+	if !ok {
+		return nil
+	}
+	if cfg.RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return s.Setup(ctx)
+}
+
+// teardown calls `Teardowner.Teardown` if p implements it, and passes its error or its recovered panic to `cfg.TeardownFailed`,
+// or logs it otherwise, if the stage has a Logger
+func teardown(ctx context.Context, cfg Config, p interface{}) {
+	t, ok := p.(Teardowner)
+	if !ok {
+		return
+	}
+	var err error
+	func() {
+		if cfg.RecoverPanics {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r, Stack: debug.Stack()}
+				}
+			}()
+		}
+		err = t.Teardown()
+	}()
+	if err == nil {
+		return
+	}
+	if cfg.TeardownFailed != nil {
+		cfg.TeardownFailed(err)
+		return
+	}
+	if cfg.Logger != nil {
+		cfg.Logger.LogAttrs(ctx, slog.LevelError, "pipeline: teardown failed", slog.String("stage", cfg.Stage), slog.Any("error", err))
+	}
+}
+
+// setupFailed is the processor of the workers of a stage whose Setup failed: it fails each input with the *SetupError,
+// and cancels it with the processor of the stage
+type setupFailed[I, O any] struct {
+	Processor[I, O]
+	err *SetupError
+}
+
+func (s *setupFailed[I, O]) Process(context.Context, I) (O, error) {
+	var zero O
+	return zero, s.err
+}
+
+func (s *setupFailed[I, O]) CancelContext(ctx context.Context, i I, err error) {
+	if c, ok := s.Processor.(ContextCanceler[I]); ok {
+		c.CancelContext(ctx, i, err)
+		return
+	}
+	s.Processor.Cancel(i, err)
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// EmitLines sends each line read from r, converted by `line`, to the out chan, without the end of line marker.
+// Lines can be up to `maxLineSize` bytes long, or bufio.MaxScanTokenSize if it is not positive.
+// The error of the scanner, if there is one, is sent to the errs chan, which has room for it, so it never blocks.
+// Both chans are closed at the end of r, after an error, or when the context is canceled, which is checked between reads.
+func EmitLines[T any](ctx context.Context, r io.Reader, maxLineSize int, line func(string) T) (<-chan T, <-chan error) {
+	out := make(chan T)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		scanner := bufio.NewScanner(r)
+		if maxLineSize > 0 {
+			initial := bufio.MaxScanTokenSize
+			if maxLineSize < initial {
+				initial = maxLineSize
+			}
+			scanner.Buffer(make([]byte, 0, initial), maxLineSize)
+		}
+		for ctx.Err() == nil && scanner.Scan() {
+			select {
+			case out <- line(scanner.Text()):
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			errs <- err
+		}
+	}()
+	return out, errs
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// LogStarted logs that the stage started with `workers` workers, if it has a Logger
+func (c Config) LogStarted(ctx context.Context, workers int) {
+	if c.Logger != nil {
+		c.Logger.LogAttrs(ctx, slog.LevelDebug, "pipeline: stage started", slog.String("stage", c.Stage), slog.Int("workers", workers))
+	}
+}
+
+// LogStopped logs that the stage is done and about to close its out chan, if it has a Logger
+func (c Config) LogStopped(ctx context.Context) {
+	if c.Logger != nil {
+		c.Logger.LogAttrs(ctx, slog.LevelDebug, "pipeline: stage stopped", slog.String("stage", c.Stage))
+	}
+}
+
+// LogWorkers logs that the stage now has `workers` workers, if it has a Logger
+func (c Config) LogWorkers(ctx context.Context, workers int) {
+	if c.Logger != nil {
+		c.Logger.LogAttrs(ctx, slog.LevelDebug, "pipeline: workers scaled", slog.String("stage", c.Stage), slog.Int("workers", workers))
+	}
+}
+
+// LogBatch logs that the stage processed a batch of `size` inputs, if it has a Logger
+func (c Config) LogBatch(ctx context.Context, size int) {
+	if c.Logger != nil {
+		c.Logger.LogAttrs(ctx, slog.LevelDebug, "pipeline: batch flushed", slog.String("stage", c.Stage), slog.Int("size", size))
+	}
+}
+
+// logCanceled logs the error that an input is canceled with, if the stage has a Logger.
+// The inputs that are canceled because the context is done are expected on shutdown,
+// so they are logged at the debug level while the failures are logged as errors.
+func (c Config) logCanceled(ctx context.Context, err error) {
+	if c.Logger == nil {
+		return
+	}
+	level := slog.LevelError
+	var canceled *CanceledError
+	if errors.As(err, &canceled) {
+		level = slog.LevelDebug
+	}
+	c.Logger.LogAttrs(ctx, level, "pipeline: input canceled", slog.String("stage", c.Stage), slog.Any("error", err))
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives the events of the stages it is attached to, tagged with the name of the stage.
+// Its methods are called from the workers of the stages, so they must be safe for concurrent use and must not block.
+type Metrics interface {
+	// ItemReceived is called when a stage reads an input that it is about to process
+	ItemReceived(stage string)
+	// ItemEmitted is called when a stage sends a result to its out chan
+	ItemEmitted(stage string)
+	// ItemCanceled is called when a stage passes an input to `Processor.Cancel`
+	ItemCanceled(stage string)
+	// ProcessDuration is called with how long each call to `Processor.Process` took
+	ProcessDuration(stage string, d time.Duration)
+}
+
+// WorkerMetrics is an optional interface of a Metrics that receives the number of workers of the process stages
+type WorkerMetrics interface {
+	// Workers is called with the number of workers of a stage when it starts them, and each time it adds or retires one
+	Workers(stage string, workers int)
+}
+
+// BlockedMetrics is an optional interface of a Metrics that receives how long the stages wait on their chans,
+// which tells whether a stage is starved by the stages before it or held back by the stages after it.
+// Only the waits for a chan that is not ready are timed, so a stage that never waits costs nothing more.
+type BlockedMetrics interface {
+	// ReceiveBlocked is called with how long a stage waited for an input that was not ready
+	ReceiveBlocked(stage string, d time.Duration)
+	// SendBlocked is called with how long a stage waited for its out chan to take a result that it could not take right away
+	SendBlocked(stage string, d time.Duration)
+}
+
+// SkippedMetrics is an optional interface of a Metrics that receives the inputs whose result a stage does not send,
+// such as the inputs that ProcessIdempotent skips
+type SkippedMetrics interface {
+	// ItemSkipped is called when a stage drops the result of an input rather than send it to its out chan
+	ItemSkipped(stage string)
+}
+
+// NoopMetrics is a Metrics that does nothing.
+// Embed it to implement only some of the methods of Metrics.
+type NoopMetrics struct{}
+
+func (NoopMetrics) ItemReceived(string)                   {}
+func (NoopMetrics) ItemEmitted(string)                    {}
+func (NoopMetrics) ItemCanceled(string)                   {}
+func (NoopMetrics) ProcessDuration(string, time.Duration) {}
+
+// StageMetrics are the counts that MemoryMetrics holds for a stage
+type StageMetrics struct {
+	Received int64
+	Emitted  int64
+	Canceled int64
+	// Skipped is the number of inputs whose result was not sent, see SkippedMetrics
+	Skipped int64
+	// Processed is the number of calls to `Processor.Process` and ProcessTime is their total duration
+	Processed   int64
+	ProcessTime time.Duration
+	// Workers is the number of workers the stage runs, 0 if it did not report them
+	Workers int64
+	// ReceiveBlocked and SendBlocked are the total durations the stage waited for its inputs and for its out chan
+	ReceiveBlocked time.Duration
+	SendBlocked    time.Duration
+}
+
+// InFlight returns the number of inputs that were received but not emitted, canceled or skipped yet
+func (s StageMetrics) InFlight() int64 {
+	return s.Received - s.Emitted - s.Canceled - s.Skipped
+}
+
+// Starved returns the share of the time of the stage that it waited for its inputs, out of the time it processed them,
+// waited for them and waited to send their results. It is close to 1 when the stages before it are the bottleneck.
+func (s StageMetrics) Starved() float64 {
+	return s.share(s.ReceiveBlocked)
+}
+
+// Backpressured returns the share of the time of the stage that it waited to send its results, out of the time it processed
+// its inputs, waited for them and waited to send their results. It is close to 1 when the stages after it are the bottleneck.
+func (s StageMetrics) Backpressured() float64 {
+	return s.share(s.SendBlocked)
+}
+
+// share returns the share of d out of the time the stage processed, waited for inputs and waited to send
+func (s StageMetrics) share(d time.Duration) float64 {
+	total := s.ProcessTime + s.ReceiveBlocked + s.SendBlocked
+	if total <= 0 {
+		return 0
+	}
+	return float64(d) / float64(total)
+}
+
+// MemoryMetrics is a Metrics that counts the events of each stage with atomic counters
+type MemoryMetrics struct {
+	stages sync.Map
+}
+
+// Stage returns the counts of the stage
+func (m *MemoryMetrics) Stage(stage string) StageMetrics {
+	s := m.stage(stage)
+	return StageMetrics{
+		Received:       atomic.LoadInt64(&s.Received),
+		Emitted:        atomic.LoadInt64(&s.Emitted),
+		Canceled:       atomic.LoadInt64(&s.Canceled),
+		Skipped:        atomic.LoadInt64(&s.Skipped),
+		Processed:      atomic.LoadInt64(&s.Processed),
+		ProcessTime:    time.Duration(atomic.LoadInt64((*int64)(&s.ProcessTime))),
+		Workers:        atomic.LoadInt64(&s.Workers),
+		ReceiveBlocked: time.Duration(atomic.LoadInt64((*int64)(&s.ReceiveBlocked))),
+		SendBlocked:    time.Duration(atomic.LoadInt64((*int64)(&s.SendBlocked))),
+	}
+}
+
+// Stages returns the counts of every stage that reported an event
+func (m *MemoryMetrics) Stages() map[string]StageMetrics {
+	stages := map[string]StageMetrics{}
+	m.stages.Range(func(stage, _ interface{}) bool {
+		stages[stage.(string)] = m.Stage(stage.(string))
+		return true
+	})
+	return stages
+}
+
+func (m *MemoryMetrics) ItemReceived(stage string) {
+	atomic.AddInt64(&m.stage(stage).Received, 1)
+}
+
+func (m *MemoryMetrics) ItemEmitted(stage string) {
+	atomic.AddInt64(&m.stage(stage).Emitted, 1)
+}
+
+func (m *MemoryMetrics) ItemCanceled(stage string) {
+	atomic.AddInt64(&m.stage(stage).Canceled, 1)
+}
+
+func (m *MemoryMetrics) ItemSkipped(stage string) {
+	atomic.AddInt64(&m.stage(stage).Skipped, 1)
+}
+
+func (m *MemoryMetrics) ProcessDuration(stage string, d time.Duration) {
+	s := m.stage(stage)
+	atomic.AddInt64(&s.Processed, 1)
+	atomic.AddInt64((*int64)(&s.ProcessTime), int64(d))
+}
+
+func (m *MemoryMetrics) Workers(stage string, workers int) {
+	atomic.StoreInt64(&m.stage(stage).Workers, int64(workers))
+}
+
+func (m *MemoryMetrics) ReceiveBlocked(stage string, d time.Duration) {
+	atomic.AddInt64((*int64)(&m.stage(stage).ReceiveBlocked), int64(d))
+}
+
+func (m *MemoryMetrics) SendBlocked(stage string, d time.Duration) {
+	atomic.AddInt64((*int64)(&m.stage(stage).SendBlocked), int64(d))
+}
+
+// stage returns the counters of the stage, creating them if needed
+func (m *MemoryMetrics) stage(stage string) *StageMetrics {
+	if s, ok := m.stages.Load(stage); ok {
+		return s.(*StageMetrics)
+	}
+	s, _ := m.stages.LoadOrStore(stage, &StageMetrics{})
+	return s.(*StageMetrics)
+}
+
+// Received reports that the stage read an input, if it has Metrics, and counts it in flight, if it has an IdleNotifier
+func (c Config) Received() {
+	c.Idle.Add(1)
+	if c.Metrics != nil {
+		c.Metrics.ItemReceived(c.Stage)
+	}
+}
+
+// Emitted reports that the stage sent a result, if it has Metrics, and counts its input out of flight, if it has an IdleNotifier
+func (c Config) Emitted() {
+	c.Idle.Add(-1)
+	if c.Metrics != nil {
+		c.Metrics.ItemEmitted(c.Stage)
+	}
+}
+
+// Canceled reports that the stage canceled an input, if it has Metrics, and counts it out of flight, if it has an IdleNotifier
+func (c Config) Canceled() {
+	c.Idle.Add(-1)
+	if c.Metrics != nil {
+		c.Metrics.ItemCanceled(c.Stage)
+	}
+}
+
+// Skipped reports that the stage dropped the result of an input, if it has SkippedMetrics, counts the input out of flight,
+// if it has an IdleNotifier, and releases its slot to InflightRelease, if it is set, since no stage after it will
+func (c Config) Skipped() {
+	c.Idle.Add(-1)
+	if c.InflightRelease != nil {
+		c.InflightRelease.Release(1)
+	}
+	if m, ok := c.Metrics.(SkippedMetrics); ok {
+		m.ItemSkipped(c.Stage)
+	}
+}
+
+// Processed reports the duration of a call to `Processor.Process` that started at start, if the stage has Metrics
+func (c Config) Processed(start time.Time) {
+	if c.Metrics != nil {
+		c.Metrics.ProcessDuration(c.Stage, time.Since(start))
+	}
+}
+
+// ReportWorkers reports the number of workers of the stage, if its Metrics implement WorkerMetrics
+func (c Config) ReportWorkers(workers int) {
+	if m, ok := c.Metrics.(WorkerMetrics); ok {
+		m.Workers(c.Stage, workers)
+	}
+}
+
+// TimesBlocked returns true if the Metrics of the stage implement BlockedMetrics, in which case its waits are worth timing
+func (c Config) TimesBlocked() bool {
+	_, ok := c.Metrics.(BlockedMetrics)
+	return ok
+}
+
+// ReceiveBlocked reports that the stage waited for an input since start, if its Metrics implement BlockedMetrics
+func (c Config) ReceiveBlocked(start time.Time) {
+	if m, ok := c.Metrics.(BlockedMetrics); ok {
+		m.ReceiveBlocked(c.Stage, time.Since(start))
+	}
+}
+
+// SendBlocked reports that the stage waited for its out chan since start, if its Metrics implement BlockedMetrics
+func (c Config) SendBlocked(start time.Time) {
+	if m, ok := c.Metrics.(BlockedMetrics); ok {
+		m.SendBlocked(c.Stage, time.Since(start))
+	}
+}
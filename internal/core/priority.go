@@ -0,0 +1,137 @@
+package core
+
+import (
+	"container/heap"
+	"context"
+)
+
+// ProcessPriority processes the inputs with `concurrency` workers, which take the pending input of highest priority
+// each time they are free, rather than the oldest one. Inputs of the same priority are taken in the order they were read.
+// Up to `maxPending` inputs wait in a heap, after which the in chan is not read until a worker takes one.
+// When the context is canceled, the pending inputs and the remaining inputs of the in chan are canceled.
+// The workers are set up like the ones of RunWorkers.
+func ProcessPriority[I, O any](
+	ctx context.Context,
+	concurrency, maxPending int,
+	priorityFn func(I) int,
+	p Processor[I, O],
+	in <-chan I,
+	cfg Config,
+) <-chan O {
+	concurrency = Workers(ctx, concurrency)
+	out := make(chan O, cfg.OutputBuffer)
+	// work hands the inputs to the workers, a send only succeeds when a worker is free
+	work := make(chan I)
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		RunWorkers(ctx, cfg, p, concurrency, func(p Processor[I, O]) {
+			for i := range work {
+				process(ctx, cfg, p, i, out)
+			}
+		})
+	}()
+	cfg.LogStarted(ctx, concurrency)
+	go func() {
+		dispatch(ctx, cfg, maxPending, priorityFn, p, in, work)
+		// Close the out chan after all of the workers finish executing
+		close(work)
+		<-workersDone
+		cfg.LogStopped(ctx)
+		close(out)
+	}()
+	return out
+}
+
+// dispatch reads the inputs into the pending heap and hands its top to a free worker, until the in chan is closed and the heap is empty
+func dispatch[I, O any](ctx context.Context, cfg Config, maxPending int, priorityFn func(I) int, p Processor[I, O], in <-chan I, work chan<- I) {
+	pending := &priorityHeap[I]{}
+	for in != nil || pending.Len() > 0 {
+		// Only read while the heap has room, which pushes back on the stages before
+		read := in
+		if pending.Len() >= maxPending {
+			read = nil
+		}
+		// Prefer reading a ready input, so that it competes with the pending ones for the next free worker
+		select {
+		case i, open := <-read:
+			if !open {
+				in = nil
+				continue
+			}
+			heap.Push(pending, prioritized[I]{i: i, priority: priorityFn(i), seq: pending.seq})
+			continue
+		default:
+		}
+		var free chan<- I
+		var top I
+		if pending.Len() > 0 {
+			free, top = work, pending.items[0].i
+		}
+		select {
+		case i, open := <-read:
+			if !open {
+				in = nil
+				continue
+			}
+			heap.Push(pending, prioritized[I]{i: i, priority: priorityFn(i), seq: pending.seq})
+		case free <- top:
+			heap.Pop(pending)
+		case <-ctx.Done():
+			for pending.Len() > 0 {
+				i := heap.Pop(pending).(prioritized[I]).i
+				cfg.Received()
+				Cancel(ctx, cfg, p, i, &CanceledError{Err: ctx.Err()})
+			}
+			if in != nil {
+				for i := range in {
+					cfg.Received()
+					Cancel(ctx, cfg, p, i, &CanceledError{Err: ctx.Err()})
+				}
+			}
+			return
+		}
+	}
+}
+
+// prioritized is an input waiting in a priorityHeap
+type prioritized[I any] struct {
+	i        I
+	priority int
+	// seq is the order in which the input was pushed, which breaks the ties between priorities
+	seq uint64
+}
+
+// priorityHeap is a heap.Interface of inputs whose top is the oldest input of the highest priority
+type priorityHeap[I any] struct {
+	items []prioritized[I]
+	seq   uint64
+}
+
+func (h *priorityHeap[I]) Len() int {
+	return len(h.items)
+}
+
+func (h *priorityHeap[I]) Less(a, b int) bool {
+	if h.items[a].priority != h.items[b].priority {
+		return h.items[a].priority > h.items[b].priority
+	}
+	return h.items[a].seq < h.items[b].seq
+}
+
+func (h *priorityHeap[I]) Swap(a, b int) {
+	h.items[a], h.items[b] = h.items[b], h.items[a]
+}
+
+func (h *priorityHeap[I]) Push(x interface{}) {
+	h.items = append(h.items, x.(prioritized[I]))
+	h.seq++
+}
+
+func (h *priorityHeap[I]) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	var zero prioritized[I]
+	h.items[len(h.items)-1] = zero
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
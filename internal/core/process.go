@@ -0,0 +1,335 @@
+package core
+
+import (
+	"context"
+	"runtime/debug"
+	"runtime/pprof"
+	"time"
+)
+
+// Process takes each input from the in chan and calls `Processor.Process` on it.
+// Results are sent to the out chan and failures are passed to `Processor.Cancel`.
+// If the context is canceled while a result is waiting to be received, its input is passed to `Processor.Cancel` instead.
+// It processes `cfg.Concurrency` inputs at once when it is more than 1, see ProcessConcurrently.
+func Process[I, O any](ctx context.Context, processor Processor[I, O], in <-chan I, cfg Config) <-chan O {
+	if Workers(ctx, cfg.Concurrency) > 1 {
+		return ProcessConcurrently(ctx, cfg.Concurrency, processor, in, cfg)
+	}
+	out := make(chan O, cfg.OutputBuffer)
+	ctx, stopped := stopContext(ctx, cfg)
+	cfg.LogStarted(ctx, 1)
+	go func() {
+		RunWorkers(ctx, cfg, processor, 1, func(processor Processor[I, O]) {
+			for i, ok := receive(in, cfg); ok; i, ok = receive(in, cfg) {
+				if !cancelBatches(ctx, cfg, processor, i, in) {
+					process(ctx, cfg, processor, i, out)
+				}
+			}
+		})
+		cfg.LogStopped(ctx)
+		close(out)
+		stopped()
+	}()
+	return out
+}
+
+// ProcessConcurrently fans the in channel out to a pool of `concurrently` workers that share the same Processor,
+// then it fans the results of the workers back into a single out chan.
+// If `cfg.Ordering` is Ordered, it is the same as ProcessConcurrentlyOrdered.
+func ProcessConcurrently[I, O any](ctx context.Context, concurrently int, p Processor[I, O], in <-chan I, cfg Config) <-chan O {
+	concurrently = Workers(ctx, concurrently)
+	if cfg.Ordering == Ordered {
+		return ProcessConcurrentlyOrdered(ctx, concurrently, p, in, cfg)
+	}
+	if cfg.LazyWorkers > 0 {
+		return processLazily(ctx, concurrently, p, in, cfg)
+	}
+	// Create the out chan
+	out := make(chan O, cfg.OutputBuffer)
+	ctx, stopped := stopContext(ctx, cfg)
+	cfg.LogStarted(ctx, concurrently)
+	go func() {
+		// Run the workers, each of which reads from the shared in chan until it is closed
+		RunWorkers(ctx, cfg, p, concurrently, func(p Processor[I, O]) {
+			for i, ok := receive(in, cfg); ok; i, ok = receive(in, cfg) {
+				if !cancelBatches(ctx, cfg, p, i, in) {
+					process(ctx, cfg, p, i, out)
+				}
+			}
+		})
+		// Close the out chan after all of the workers finish executing
+		cfg.LogStopped(ctx)
+		close(out)
+		stopped()
+	}()
+	return out
+}
+
+// processLazily is ProcessConcurrently with `Config.LazyWorkers`: it starts a single worker, and starts another one,
+// up to `concurrently`, each time an input has waited `cfg.LazyWorkers` for a free worker.
+// The workers it starts run until the stage ends.
+func processLazily[I, O any](ctx context.Context, concurrently int, p Processor[I, O], in <-chan I, cfg Config) <-chan O {
+	out := make(chan O, cfg.OutputBuffer)
+	ctx, stopped := stopContext(ctx, cfg)
+	// work hands the inputs to the workers, a send only succeeds when a worker is free
+	work := make(chan I)
+	// The inputs took their inflight slot when they were read from in, so the workers do not take another one
+	wcfg := cfg
+	wcfg.InflightAcquire = nil
+	workers := &lazyWorkers[I, O]{workerSet: workerSet[I, O]{ctx: ctx, cfg: cfg, p: p, work: func(p Processor[I, O]) {
+		for i := range work {
+			if !cancelBatches(ctx, wcfg, p, i, work) {
+				process(ctx, cfg, p, i, out)
+			}
+		}
+	}}, max: concurrently}
+	cfg.LogStarted(ctx, 1)
+	go func() {
+		workers.add()
+		wait := cfg.Clock.NewTimer(cfg.LazyWorkers)
+		stopClockTimer(wait)
+		for i, ok := receive(in, cfg); ok; i, ok = receive(in, cfg) {
+			// Hand the input over right away if a worker is free
+			select {
+			case work <- i:
+				continue
+			default:
+			}
+			if !workers.growing() {
+				work <- i
+				continue
+			}
+			// Otherwise start a worker every time the input has waited for the threshold
+			wait.Reset(cfg.LazyWorkers)
+			for sent := false; !sent; {
+				select {
+				case work <- i:
+					stopClockTimer(wait)
+					sent = true
+				case <-wait.C():
+					if workers.add() {
+						wait.Reset(cfg.LazyWorkers)
+						continue
+					}
+					work <- i
+					sent = true
+				}
+			}
+		}
+		// Close the out chan after all of the workers finish executing
+		close(work)
+		workers.wait()
+		cfg.LogStopped(ctx)
+		close(out)
+		stopped()
+	}()
+	return out
+}
+
+// ProcessConcurrentlyOrdered is like ProcessConcurrently, except that the results are sent to the out chan
+// in the same order that their inputs were read from the in chan.
+// Inputs that fail to process are skipped and passed to `Processor.Cancel`.
+// At most `concurrently` results can wait to be re-sequenced before ProcessConcurrentlyOrdered stops reading from in.
+func ProcessConcurrentlyOrdered[I, O any](ctx context.Context, concurrently int, p Processor[I, O], in <-chan I, cfg Config) <-chan O {
+	concurrently = Workers(ctx, concurrently)
+	out := make(chan O, cfg.OutputBuffer)
+	// Each input gets a result chan that is queued in the order the inputs were read.
+	// Queuing blocks while concurrently results are pending, which bounds the reorder buffer.
+	pending := make(chan chan result[I, O], concurrently)
+	// The result chans are recycled once their result is sent, so that no chan is allocated per input
+	free := make(chan chan result[I, O], concurrently+1)
+	jobs := make(chan job[I, O])
+	ctx, stopped := stopContext(ctx, cfg)
+	cfg.LogStarted(ctx, concurrently)
+	// Run the workers, each of which processes the inputs it is handed until there are none left
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		RunWorkers(ctx, cfg, p, concurrently, func(p Processor[I, O]) {
+			for j := range jobs {
+				o, ok := processOne(ctx, cfg, p, j.in)
+				j.r <- result[I, O]{j.in, o, ok, p}
+			}
+		})
+	}()
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for i, ok := receive(in, cfg); ok; i, ok = receive(in, cfg) {
+			var r chan result[I, O]
+			select {
+			case r = <-free:
+			default:
+				r = make(chan result[I, O], 1)
+			}
+			pending <- r
+			jobs <- job[I, O]{i, r}
+		}
+	}()
+	go func() {
+		defer stopped()
+		defer close(out)
+		defer cfg.LogStopped(ctx)
+		// Wait for each result in order, skipping the ones that were canceled
+		for r := range pending {
+			res := <-r
+			select {
+			case free <- r:
+			default:
+			}
+			if res.ok {
+				send(ctx, cfg, res.by, res.in, res.out, out)
+			}
+		}
+		<-workersDone
+	}()
+	return out
+}
+
+// ProcessWithErrors is like Process, except that the errors returned by `Processor.Process` are sent to the errs chan
+// as a *ProcessError instead of being passed to `Processor.Cancel`.
+// `Processor.Cancel` is only called for the inputs that are canceled by the context.
+// The errs chan is closed when the out chan is closed.
+func ProcessWithErrors[I, O any](ctx context.Context, processor Processor[I, O], in <-chan I, cfg Config) (<-chan O, <-chan error) {
+	out := make(chan O, cfg.OutputBuffer)
+	errs := make(chan error)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		// ProcessWithErrors reads every input of in, it does not stop on `cfg.Stop`
+		rcfg := cfg
+		rcfg.Stop = nil
+		RunWorkers(ctx, cfg, processor, 1, func(processor Processor[I, O]) {
+			for i, ok := next(in, rcfg); ok; i, ok = next(in, rcfg) {
+				if cancelBatches(ctx, cfg, processor, i, in) {
+					continue
+				}
+				cfg.Received()
+				select {
+				// When the context is canceled, Cancel all inputs
+				case <-ctx.Done():
+					Cancel(ctx, cfg, processor, i, &CanceledError{Err: ctx.Err()})
+				// Otherwise, Process all inputs
+				default:
+					result, err := callProcess(ctx, cfg, processor, i)
+					if err == nil {
+						send(ctx, cfg, processor, i, result, out)
+						continue
+					}
+					pErr := &ProcessError{Input: i, Err: err}
+					cfg.Fail(ctx, pErr)
+					if ctx.Err() != nil {
+						// The process was interrupted by the context
+						Cancel(ctx, cfg, processor, i, pErr)
+						continue
+					}
+					select {
+					case errs <- pErr:
+						cfg.Idle.Add(-1)
+					case <-ctx.Done():
+						Cancel(ctx, cfg, processor, i, pErr)
+					}
+				}
+			}
+		})
+	}()
+	return out, errs
+}
+
+// job is an input handed to a worker of ProcessConcurrentlyOrdered, with the chan its result is sent to
+type job[I, O any] struct {
+	in I
+	r  chan result[I, O]
+}
+
+// result is the outcome of processing a single input
+type result[I, O any] struct {
+	in  I
+	out O
+	ok  bool
+	// by is the processor of the worker that processed in
+	by Processor[I, O]
+}
+
+func process[I, O any](
+	ctx context.Context,
+	cfg Config,
+	processor Processor[I, O],
+	i I,
+	out chan<- O,
+) {
+	if result, ok := processOne(ctx, cfg, processor, i); ok {
+		send(ctx, cfg, processor, i, result, out)
+	}
+}
+
+// send sends the result of i to the out chan, unless the context is canceled first,
+// in which case i is canceled with an *UndeliveredError so that a stalled receiver cannot block the stage forever
+func send[I, O any](ctx context.Context, cfg Config, processor Processor[I, O], i I, result O, out chan<- O) {
+	if cfg.Skip != nil && cfg.Skip(result) {
+		cfg.Skipped()
+		return
+	}
+	// Prefer a ready receiver over the canceled context
+	select {
+	case out <- result:
+		cfg.Emitted()
+		return
+	default:
+	}
+	if cfg.TimesBlocked() {
+		defer cfg.SendBlocked(time.Now())
+	}
+	select {
+	case out <- result:
+		cfg.Emitted()
+	case <-ctx.Done():
+		Cancel(ctx, cfg, processor, i, &UndeliveredError{Result: result, Err: ctx.Err()})
+	}
+}
+
+// processOne processes i and returns the result and true if it was not canceled
+func processOne[I, O any](ctx context.Context, cfg Config, processor Processor[I, O], i I) (O, bool) {
+	cfg.Received()
+	var zero O
+	select {
+	// When the context is canceled, Cancel all inputs
+	case <-ctx.Done():
+		Cancel(ctx, cfg, processor, i, &CanceledError{Err: ctx.Err()})
+		return zero, false
+	// Otherwise, Process all inputs
+	default:
+		result, err := callProcess(ctx, cfg, processor, i)
+		if err != nil {
+			pErr := &ProcessError{Input: i, Err: err}
+			cfg.Fail(ctx, pErr)
+			Cancel(ctx, cfg, processor, i, pErr)
+			return zero, false
+		}
+		return result, true
+	}
+}
+
+// callProcess calls `Processor.Process` and, unless it is disabled, converts a panic into a *PanicError
+func callProcess[I, O any](ctx context.Context, cfg Config, processor Processor[I, O], i I) (result O, err error) {
+	if cfg.Metrics != nil {
+		defer cfg.Processed(time.Now())
+	}
+	if cfg.RecoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+	}
+	if cfg.ProfilerLabels {
+		pprof.Do(ctx, pprof.Labels(ProfilerLabel, cfg.Stage), func(ctx context.Context) {
+			result, err = processor.Process(ctx, i)
+		})
+		return result, err
+	}
+	return processor.Process(ctx, i)
+}
+
+// ProfilerLabel is the pprof label that holds the name of the stage when `Config.ProfilerLabels` is set
+const ProfilerLabel = "pipeline_stage"
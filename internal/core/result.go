@@ -0,0 +1,42 @@
+package core
+
+import "context"
+
+// Result is the outcome of processing an input with ProcessIntoResults
+type Result[I, O any] struct {
+	// Value is the result of `Processor.Process`, if Err is nil
+	Value O
+	// Err is a *ProcessError if `Processor.Process` failed, or a *CanceledError if the input was canceled by the context
+	Err error
+	// Input is the input that was processed
+	Input I
+}
+
+// ProcessIntoResults processes each input from the in chan and sends exactly one Result for it to the out chan,
+// whether it was processed, failed or canceled by the context. `Processor.Cancel` is never called.
+// Since no input is ever dropped, the out chan must be read until it is closed, even after the context is canceled.
+func ProcessIntoResults[I, O any](ctx context.Context, processor Processor[I, O], in <-chan I, cfg Config) <-chan Result[I, O] {
+	out := make(chan Result[I, O], cfg.OutputBuffer)
+	go func() {
+		defer close(out)
+		for i := range in {
+			cfg.Received()
+			r := Result[I, O]{Input: i}
+			if err := ctx.Err(); err != nil {
+				r.Err = &CanceledError{Err: err}
+				cfg.Canceled()
+			} else if o, err := callProcess(ctx, cfg, processor, i); err != nil {
+				r.Err = &ProcessError{Input: i, Err: err}
+				cfg.Fail(ctx, r.Err)
+				cfg.Canceled()
+			} else {
+				r.Value = o
+			}
+			out <- r
+			if r.Err == nil {
+				cfg.Emitted()
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,19 @@
+package core
+
+import "context"
+
+// serialKey is the key of the context value set by Serial
+type serialKey struct{}
+
+// Serial returns a context derived from ctx that makes the stages that run with it use a single worker
+func Serial(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serialKey{}, true)
+}
+
+// Workers returns n, or 1 if ctx was derived from a context returned by Serial
+func Workers(ctx context.Context, n int) int {
+	if serial, _ := ctx.Value(serialKey{}).(bool); serial {
+		return 1
+	}
+	return n
+}
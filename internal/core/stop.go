@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// stopContext returns a context derived from ctx that is also canceled `cfg.Grace` after `cfg.Stop` is closed,
+// which interrupts the inputs that are still being processed once the grace period is over.
+// The returned func releases its resources and must be called when the stage is done.
+func stopContext(ctx context.Context, cfg Config) (context.Context, context.CancelFunc) {
+	if cfg.Stop == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-cfg.Stop:
+		case <-ctx.Done():
+			return
+		}
+		timer := cfg.Clock.NewTimer(cfg.Grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// next reads the next input from in and returns false once in is closed or `cfg.Stop` is closed,
+// so that a stopped stage no longer accepts new inputs.
+// If the input is not ready and the stage times its waits, it reports how long it waited for it.
+func next[I any](in <-chan I, cfg Config) (I, bool) {
+	var zero I
+	// Prefer the stop over an input that is ready
+	select {
+	case <-cfg.Stop:
+		return zero, false
+	default:
+	}
+	if cfg.TimesBlocked() {
+		select {
+		case i, open := <-in:
+			return i, open
+		default:
+		}
+		defer cfg.ReceiveBlocked(time.Now())
+	}
+	select {
+	case i, open := <-in:
+		return i, open
+	case <-cfg.Stop:
+		return zero, false
+	}
+}
@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Joined is a pair of inputs from the two channels of Join that share the same key
+type Joined struct {
+	A, B interface{}
+}
+
+// Join pairs the inputs of the `a` and `b` channels that have the same key, as returned by `keyA` and `keyB`,
+// and sends each pair to the out chan as a Joined.
+// An input waits up to `ttl` for its match, so Join never holds more than `ttl` worth of inputs.
+// Inputs with the same key are matched in the order they arrive, so duplicates on one side wait for their own match.
+// Inputs that expire, are still waiting when both channels are closed, or when the context is canceled,
+// are passed to the func set by WithUnmatched, if there is one.
+// After the context is canceled, the remaining inputs are dropped until both channels are closed.
+func Join(ctx context.Context, keyA, keyB func(i interface{}) string, a, b <-chan interface{}, ttl time.Duration, opts ...Option) <-chan Joined {
+	c := newConfig(opts)
+	out := make(chan Joined)
+	go func() {
+		defer close(out)
+		j := &joiner{
+			ttl:       ttl,
+			unmatched: c.unmatched,
+			pending:   [2]map[string][]*joinEntry{{}, {}},
+		}
+		defer j.flush()
+		// Sweep the expired inputs twice per ttl
+		period := ttl / 2
+		if period <= 0 {
+			period = time.Millisecond
+		}
+		sweep := c.Clock.NewTicker(period)
+		defer sweep.Stop()
+		for a != nil || b != nil {
+			var side int
+			var i interface{}
+			var open bool
+			select {
+			case i, open = <-a:
+				if !open {
+					a = nil
+					continue
+				}
+				side = sideA
+			case i, open = <-b:
+				if !open {
+					b = nil
+					continue
+				}
+				side = sideB
+			case now := <-sweep.C():
+				j.expire(now)
+				continue
+			case <-ctx.Done():
+				dropAll(a, b)
+				return
+			}
+			key := keyA
+			if side == sideB {
+				key = keyB
+			}
+			joined, ok := j.add(side, key(i), i, c.Clock.Now())
+			if !ok {
+				continue
+			}
+			select {
+			case out <- joined:
+			case <-ctx.Done():
+				dropAll(a, b)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithUnmatched sets the func that Join and Zip pass the inputs that were never matched to
+func WithUnmatched(unmatched func(i interface{})) Option {
+	return func(c *config) {
+		c.unmatched = unmatched
+	}
+}
+
+const (
+	sideA = iota
+	sideB
+)
+
+// joinEntry is an input of Join that waits for its match
+type joinEntry struct {
+	i       interface{}
+	key     string
+	side    int
+	arrived time.Time
+	matched bool
+}
+
+// joiner holds the inputs of Join that wait for their match
+type joiner struct {
+	ttl       time.Duration
+	unmatched func(i interface{})
+	// pending holds the waiting inputs of each side by key, in the order they arrived
+	pending [2]map[string][]*joinEntry
+	// order holds all of the inputs in the order they arrived, so the oldest ones expire first
+	order []*joinEntry
+}
+
+// add matches i with the oldest waiting input with the same key from the other side,
+// or adds it to the waiting inputs if there is none
+func (j *joiner) add(side int, key string, i interface{}, now time.Time) (Joined, bool) {
+	other := 1 - side
+	if waiting := j.pending[other][key]; len(waiting) > 0 {
+		match := waiting[0]
+		match.matched = true
+		j.remove(other, key)
+		if side == sideA {
+			return Joined{A: i, B: match.i}, true
+		}
+		return Joined{A: match.i, B: i}, true
+	}
+	e := &joinEntry{i: i, key: key, side: side, arrived: now}
+	j.pending[side][key] = append(j.pending[side][key], e)
+	j.order = append(j.order, e)
+	return Joined{}, false
+}
+
+// expire drops the inputs that have waited for `ttl`
+func (j *joiner) expire(now time.Time) {
+	for len(j.order) > 0 {
+		e := j.order[0]
+		if !e.matched {
+			if now.Sub(e.arrived) < j.ttl {
+				return
+			}
+			j.remove(e.side, e.key)
+			j.drop(e.i)
+		}
+		j.order[0] = nil
+		j.order = j.order[1:]
+	}
+}
+
+// flush drops all of the waiting inputs
+func (j *joiner) flush() {
+	for _, e := range j.order {
+		if !e.matched {
+			j.drop(e.i)
+		}
+	}
+	j.order = nil
+}
+
+// remove removes the oldest waiting input with the key from the side
+func (j *joiner) remove(side int, key string) {
+	if waiting := j.pending[side][key]; len(waiting) > 1 {
+		j.pending[side][key] = waiting[1:]
+	} else {
+		delete(j.pending[side], key)
+	}
+}
+
+// drop passes i to the unmatched func if there is one
+func (j *joiner) drop(i interface{}) {
+	if j.unmatched != nil {
+		j.unmatched(i)
+	}
+}
+
+// dropAll reads from the chans until they are closed. Each chan is read in its own goroutine, like Merge does,
+// since the chans may be fed by the same producer, such as the outs of Tee, which blocks on one chan until the other is read.
+func dropAll(ins ...<-chan interface{}) {
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		if in != nil {
+			wg.Add(1)
+			go func(in <-chan interface{}) {
+				defer wg.Done()
+				discard(in)
+			}(in)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// order and payment are the inputs of Join in the tests
+type order struct{ id string }
+type payment struct {
+	orderID string
+	n       int
+}
+
+func TestJoin(t *testing.T) {
+	orderID := func(i interface{}) string { return i.(order).id }
+	paymentOrderID := func(i interface{}) string { return i.(payment).orderID }
+
+	t.Run("pairs out of order arrivals and duplicates, and reports the unmatched inputs", func(t *testing.T) {
+		orders, payments := make(chan interface{}), make(chan interface{})
+		go func() {
+			defer close(payments)
+			defer close(orders)
+			// The payment for 1 arrives before its order
+			payments <- payment{"1", 1}
+			orders <- order{"1"}
+			// 2 is paid twice, the second payment never matches
+			orders <- order{"2"}
+			payments <- payment{"2", 1}
+			payments <- payment{"2", 2}
+			// 3 is never paid
+			orders <- order{"3"}
+		}()
+
+		var unmatched []interface{}
+		var joined []Joined
+		for j := range Join(context.Background(), orderID, paymentOrderID, orders, payments, time.Second, WithUnmatched(func(i interface{}) {
+			unmatched = append(unmatched, i)
+		})) {
+			joined = append(joined, j)
+		}
+
+		if want := []Joined{
+			{order{"1"}, payment{"1", 1}},
+			{order{"2"}, payment{"2", 1}},
+		}; !reflect.DeepEqual(want, joined) {
+			t.Errorf("joined = %+v, want %+v", joined, want)
+		}
+		// Orders first
+		sort.Slice(unmatched, func(i, j int) bool {
+			_, iOrder := unmatched[i].(order)
+			_, jOrder := unmatched[j].(order)
+			return iOrder && !jOrder
+		})
+		if want := []interface{}{order{"3"}, payment{"2", 2}}; !reflect.DeepEqual(want, unmatched) {
+			t.Errorf("unmatched = %+v, want %+v", unmatched, want)
+		}
+	})
+
+	t.Run("inputs that wait longer than ttl expire", func(t *testing.T) {
+		orders, payments := make(chan interface{}), make(chan interface{})
+		expired := make(chan interface{}, 1)
+		out := Join(context.Background(), orderID, paymentOrderID, orders, payments, 20*time.Millisecond, WithUnmatched(func(i interface{}) {
+			expired <- i
+		}))
+
+		orders <- order{"1"}
+		select {
+		case i := <-expired:
+			if i != (order{"1"}) {
+				t.Errorf("expired = %+v, want %+v", i, order{"1"})
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the order did not expire")
+		}
+
+		// The payment arrives too late to be matched
+		payments <- payment{"1", 1}
+		close(orders)
+		close(payments)
+		for j := range out {
+			t.Errorf("joined = %+v, want nothing", j)
+		}
+		if i := <-expired; i != (payment{"1", 1}) {
+			t.Errorf("unmatched = %+v, want %+v", i, payment{"1", 1})
+		}
+	})
+
+	t.Run("the inputs fed by one Tee are drained after the context is canceled", func(t *testing.T) {
+		drainsTee(t, func(ctx context.Context, a, b <-chan interface{}) {
+			key := func(i interface{}) string { return fmt.Sprint(i) }
+			for range Join(ctx, key, key, a, b, time.Second) {
+			}
+		})
+	})
+}
+
+// drainsTee runs `stage` on both outs of a Tee, with a context that is canceled before it starts, and fails
+// unless it returns within 2 seconds: Tee blocks on one out until the other is read, so `stage` must drain both at once
+func drainsTee(t *testing.T, stage func(ctx context.Context, a, b <-chan interface{})) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a, b := Tee(emitN(100))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stage(ctx, a, b)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stage did not return after the context was canceled")
+	}
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+// DecodeError is sent by DecodeJSON when a value cannot be decoded
+type DecodeError struct {
+	// Offset is the byte offset in the reader right after the last value that was decoded,
+	// so the value that failed to decode starts there, after any spaces or comma
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode at offset %d: %s", e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeJSON streams the values of a JSON array, or of newline delimited JSON, read from `r` to the out `<-chan interface{}`.
+// Each value is decoded into a new value returned by `newFn`, which must be a pointer, such as `new(Event)`.
+// Decoding stops at the first value that cannot be decoded, and the error is sent to the errs chan as a *DecodeError with its offset.
+// The errs chan has room for the error, so it never blocks.
+// Both chans are closed at the end of `r`, after an error, or when the context is canceled, which is checked between values.
+func DecodeJSON(ctx context.Context, r io.Reader, newFn func() interface{}) (<-chan interface{}, <-chan error) {
+	out := make(chan interface{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		br := bufio.NewReader(r)
+		dec := json.NewDecoder(br)
+		// A JSON array is decoded value by value, instead of all at once
+		array, skipped, err := startsWith(br, '[')
+		if err != nil {
+			errs <- &DecodeError{Err: err}
+			return
+		}
+		if array {
+			if _, err := dec.Token(); err != nil {
+				errs <- &DecodeError{Err: err}
+				return
+			}
+		}
+		for ctx.Err() == nil {
+			if array && !dec.More() {
+				break
+			}
+			offset := skipped + dec.InputOffset()
+			v := newFn()
+			if err := dec.Decode(v); err == io.EOF && !array {
+				return
+			} else if err != nil {
+				errs <- &DecodeError{Offset: offset, Err: err}
+				return
+			}
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if array && ctx.Err() == nil {
+			// Check that the array is closed
+			if _, err := dec.Token(); err != nil {
+				errs <- &DecodeError{Offset: skipped + dec.InputOffset(), Err: err}
+			}
+		}
+	}()
+	return out, errs
+}
+
+// startsWith skips the spaces at the start of br and returns true if the next byte is b, without consuming it.
+// It also returns the number of spaces it skipped.
+func startsWith(br *bufio.Reader, b byte) (bool, int64, error) {
+	var skipped int64
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			return false, skipped, nil
+		} else if err != nil {
+			return false, skipped, err
+		}
+		if !unicode.IsSpace(rune(c)) {
+			return c == b, skipped, br.UnreadByte()
+		}
+		skipped++
+	}
+}
+
+// EncodeJSON writes every input from the `in <-chan interface{}` to `w` as newline delimited JSON, until it is closed.
+// It stops at the first input that cannot be encoded or written and returns the error,
+// or returns the `Context.Err()` if the context is canceled. The remaining inputs are then discarded in the background,
+// like ForEach, so the stages before EncodeJSON are never blocked.
+func EncodeJSON(ctx context.Context, w io.Writer, in <-chan interface{}) error {
+	enc := json.NewEncoder(w)
+	return ForEach(ctx, in, enc.Encode)
+}
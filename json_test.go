@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// record is a value decoded by DecodeJSON
+type record struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	newRecord := func() interface{} {
+		return &record{}
+	}
+	want := []interface{}{&record{1, "a"}, &record{2, "b"}, &record{3, "c"}}
+
+	for _, test := range []struct {
+		name       string
+		in         string
+		want       []interface{}
+		wantOffset int64
+	}{{
+		name: "a JSON array is streamed",
+		in:   ` [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}, {"id": 3, "name": "c"}]`,
+		want: want,
+	}, {
+		name: "newline delimited JSON is streamed",
+		in:   "{\"id\": 1, \"name\": \"a\"}\n{\"id\": 2, \"name\": \"b\"}\n{\"id\": 3, \"name\": \"c\"}\n",
+		want: want,
+	}, {
+		name: "an empty input has no values",
+		in:   " \n",
+	}, {
+		name:       "a value that is not valid stops the stream at its offset",
+		in:         "{\"id\": 1, \"name\": \"a\"}\n{\"id\": \"2\"}\n{\"id\": 3}\n",
+		want:       want[:1],
+		wantOffset: 22,
+	}, {
+		name:       "a value with a syntax error stops the stream at its offset",
+		in:         `[{"id": 1, "name": "a"}, {"id": 2,, "name": "b"}]`,
+		want:       want[:1],
+		wantOffset: 23,
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			values, errs := DecodeJSON(context.Background(), strings.NewReader(test.in), newRecord)
+			var outs []interface{}
+			for v := range values {
+				outs = append(outs, v)
+			}
+			if !reflect.DeepEqual(test.want, outs) {
+				t.Errorf("values = %+v, want %+v", outs, test.want)
+			}
+			err := <-errs
+			if test.wantOffset == 0 {
+				if err != nil {
+					t.Errorf("err = %s, want nil", err)
+				}
+				return
+			}
+			var dErr *DecodeError
+			if !errors.As(err, &dErr) || dErr.Offset != test.wantOffset {
+				t.Errorf("err = %v, want a *DecodeError at offset %d", err, test.wantOffset)
+			}
+		})
+	}
+
+	t.Run("both chans close when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		values, errs := DecodeJSON(ctx, strings.NewReader(strings.Repeat("{\"id\": 1}\n", 1000)), newRecord)
+		<-values
+		cancel()
+		for range values {
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("err = %s, want nil", err)
+		}
+	})
+}
+
+// failingWriter fails every write after the first n
+type failingWriter struct {
+	n   int
+	err error
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, w.err
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestEncodeJSON(t *testing.T) {
+	t.Run("every input is written as a line of JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := EncodeJSON(context.Background(), &buf, Emit(record{1, "a"}, record{2, "b"})); err != nil {
+			t.Fatalf("err = %s, want nil", err)
+		}
+		if want := "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n"; buf.String() != want {
+			t.Errorf("written = %q, want %q", buf.String(), want)
+		}
+	})
+
+	t.Run("the first write error is returned and the inputs are drained", func(t *testing.T) {
+		errWrite := errors.New("write")
+		in := make(chan interface{})
+		sent := make(chan struct{})
+		go func() {
+			defer close(sent)
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+		}()
+		if err := EncodeJSON(context.Background(), &failingWriter{n: 2, err: errWrite}, in); !errors.Is(err, errWrite) {
+			t.Errorf("err = %v, want %s", err, errWrite)
+		}
+		select {
+		case <-sent:
+		case <-time.After(time.Second):
+			t.Fatal("the inputs were not drained")
+		}
+	})
+
+	t.Run("the first marshal error is returned", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := EncodeJSON(context.Background(), &buf, Emit(record{1, "a"}, make(chan int)))
+		var uErr *json.UnsupportedTypeError
+		if !errors.As(err, &uErr) {
+			t.Errorf("err = %v, want a *json.UnsupportedTypeError", err)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+// Package kafka connects pipelines to Kafka topics through the small Reader and Writer interfaces,
+// which can be implemented on top of any Kafka client, such as the Reader and Writer of segmentio/kafka-go.
+//
+// Source emits the messages of a consumer group as pipeline.Tracked inputs and commits their offsets
+// only once they are acknowledged at the end of the pipeline, for at-least-once processing:
+//
+//	msgs, errs := kafka.Source(ctx, reader)
+//	out := pipeline.Untrack(ctx, pipeline.TrackedProcess(ctx, p, msgs))
+//	err := kafka.Sink(ctx, writer, out)
+package kafka
+
+import (
+	"time"
+)
+
+// Message is a Kafka message
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Time      time.Time
+}
+
+// Option configures Source and Sink
+type Option func(*config)
+
+// config holds the settings of Source and Sink
+type config struct {
+	batchSize    int
+	batchTimeout time.Duration
+	commitGrace  time.Duration
+}
+
+// newConfig applies opts to the default config
+func newConfig(opts []Option) *config {
+	c := &config{
+		batchSize:    100,
+		batchTimeout: time.Second,
+		commitGrace:  5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithBatch sets how many messages Sink writes at once, and how long it waits to fill a batch.
+// The defaults are 100 messages and 1 second.
+func WithBatch(size int, timeout time.Duration) Option {
+	return func(c *config) {
+		c.batchSize = size
+		c.batchTimeout = timeout
+	}
+}
+
+// WithCommitGrace sets how long Source can take to commit the last acknowledged offsets after its context is canceled.
+// The default is 5 seconds.
+func WithCommitGrace(grace time.Duration) Option {
+	return func(c *config) {
+		c.commitGrace = grace
+	}
+}
@@ -0,0 +1,265 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// broker is an in-memory Kafka that implements Reader and Writer
+type broker struct {
+	mu        sync.Mutex
+	messages  []Message
+	fetched   int
+	fetchErr  error
+	commitErr error
+	committed map[int]int64
+	written   [][]Message
+	writeErr  error
+}
+
+// newBroker returns a broker holding n messages per partition, interleaved by offset
+func newBroker(partitions, n int) *broker {
+	b := &broker{committed: make(map[int]int64)}
+	for offset := 0; offset < n; offset++ {
+		for p := 0; p < partitions; p++ {
+			b.messages = append(b.messages, Message{Topic: "t", Partition: p, Offset: int64(offset)})
+		}
+	}
+	return b
+}
+
+func (b *broker) FetchMessage(ctx context.Context) (Message, error) {
+	b.mu.Lock()
+	if b.fetched < len(b.messages) {
+		defer b.mu.Unlock()
+		b.fetched++
+		return b.messages[b.fetched-1], nil
+	}
+	err := b.fetchErr
+	b.mu.Unlock()
+	if err != nil {
+		return Message{}, err
+	}
+	<-ctx.Done()
+	return Message{}, ctx.Err()
+}
+
+func (b *broker) CommitMessages(_ context.Context, msgs ...Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.commitErr != nil {
+		return b.commitErr
+	}
+	for _, msg := range msgs {
+		if offset, ok := b.committed[msg.Partition]; ok && msg.Offset <= offset {
+			return errors.New("offset committed twice")
+		}
+		b.committed[msg.Partition] = msg.Offset
+	}
+	return nil
+}
+
+func (b *broker) WriteMessages(_ context.Context, msgs ...Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.writeErr != nil {
+		return b.writeErr
+	}
+	b.written = append(b.written, msgs)
+	return nil
+}
+
+func (b *broker) commits() map[int]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	commits := make(map[int]int64)
+	for p, offset := range b.committed {
+		commits[p] = offset
+	}
+	return commits
+}
+
+// waitCommits waits for the committed offsets to be want
+func (b *broker) waitCommits(t *testing.T, want map[int]int64) {
+	t.Helper()
+	for start := time.Now(); !reflect.DeepEqual(want, b.commits()) && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	if got := b.commits(); !reflect.DeepEqual(want, got) {
+		t.Errorf("committed = %v, want %v", got, want)
+	}
+}
+
+// receive receives n Tracked messages from out
+func receive(t *testing.T, out <-chan interface{}, n int) []pipeline.Tracked {
+	t.Helper()
+	var ts []pipeline.Tracked
+	for i := 0; i < n; i++ {
+		ts = append(ts, (<-out).(pipeline.Tracked))
+	}
+	return ts
+}
+
+func TestSource(t *testing.T) {
+	t.Run("offsets are committed once every message before them in the partition is acknowledged", func(t *testing.T) {
+		b := newBroker(2, 3)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out, _ := Source(ctx, b)
+		// The messages are p0o0, p1o0, p0o1, p1o1, p0o2, p1o2
+		ts := receive(t, out, 6)
+		ts[2].Ack(nil)
+		ts[4].Ack(nil)
+		ts[1].Ack(nil)
+		b.waitCommits(t, map[int]int64{1: 0})
+		ts[0].Ack(nil)
+		b.waitCommits(t, map[int]int64{0: 2, 1: 0})
+	})
+
+	t.Run("a message acknowledged with an error is never committed, nor the ones after it", func(t *testing.T) {
+		b := newBroker(1, 3)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out, _ := Source(ctx, b)
+		ts := receive(t, out, 3)
+		ts[0].Ack(nil)
+		ts[1].Ack(errors.New("failed"))
+		ts[2].Ack(nil)
+		b.waitCommits(t, map[int]int64{0: 0})
+		time.Sleep(10 * time.Millisecond)
+		b.waitCommits(t, map[int]int64{0: 0})
+	})
+
+	t.Run("a slow pipeline pauses the consumption", func(t *testing.T) {
+		b := newBroker(1, 100)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out, _ := Source(ctx, b)
+		receive(t, out, 2)
+		time.Sleep(10 * time.Millisecond)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		// One message waits to be received
+		if b.fetched != 3 {
+			t.Errorf("fetched = %d, want 3", b.fetched)
+		}
+	})
+
+	t.Run("the acknowledged offsets are committed before errs is closed when the context is canceled", func(t *testing.T) {
+		b := newBroker(1, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		out, errs := Source(ctx, b)
+		for _, t := range receive(t, out, 5) {
+			t.Ack(nil)
+		}
+		cancel()
+		for range out {
+		}
+		for err := range errs {
+			t.Errorf("err = %v, want nil", err)
+		}
+		if want, got := map[int]int64{0: 4}, b.commits(); !reflect.DeepEqual(want, got) {
+			t.Errorf("committed = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("a fetch error is sent to errs and stops the source", func(t *testing.T) {
+		b := newBroker(1, 1)
+		b.fetchErr = errors.New("broker down")
+		out, errs := Source(context.Background(), b)
+		for o := range out {
+			o.(pipeline.Tracked).Ack(nil)
+		}
+		if err := <-errs; err != b.fetchErr {
+			t.Errorf("err = %v, want %v", err, b.fetchErr)
+		}
+		if _, open := <-errs; open {
+			t.Error("errs is still open")
+		}
+	})
+
+	t.Run("messages flow through a tracked pipeline and are committed", func(t *testing.T) {
+		b := newBroker(3, 50)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		msgs, _ := Source(ctx, b)
+		identity := pipeline.ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			return i, nil
+		})
+		out := pipeline.Untrack(ctx, pipeline.TrackedProcess(ctx, identity, msgs, pipeline.WithConcurrency(8)))
+		for n := 0; n < 150; n++ {
+			<-out
+		}
+		b.waitCommits(t, map[int]int64{0: 49, 1: 49, 2: 49})
+	})
+}
+
+func TestSink(t *testing.T) {
+	t.Run("writes the messages in batches and acknowledges them", func(t *testing.T) {
+		b := newBroker(1, 0)
+		var mu sync.Mutex
+		acked := 0
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for n := 0; n < 250; n++ {
+				var i interface{} = Message{Offset: int64(n)}
+				if n%2 == 0 {
+					i = pipeline.Tracked{Value: i, Ack: func(err error) {
+						mu.Lock()
+						defer mu.Unlock()
+						if err == nil {
+							acked++
+						}
+					}}
+				}
+				in <- i
+			}
+		}()
+		if err := Sink(context.Background(), b, in, WithBatch(100, time.Minute)); err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		var sizes []int
+		for _, batch := range b.written {
+			sizes = append(sizes, len(batch))
+		}
+		if want := []int{100, 100, 50}; !reflect.DeepEqual(want, sizes) {
+			t.Errorf("batch sizes = %v, want %v", sizes, want)
+		}
+		if acked != 125 {
+			t.Errorf("acknowledged %d, want 125", acked)
+		}
+	})
+
+	t.Run("the error of a write is returned and acknowledges its batch", func(t *testing.T) {
+		b := newBroker(1, 0)
+		b.writeErr = errors.New("write failed")
+		var errs []error
+		ack := func(err error) {
+			errs = append(errs, err)
+		}
+		in := pipeline.Emit(pipeline.Tracked{Value: Message{}, Ack: ack}, pipeline.Tracked{Value: Message{}, Ack: ack})
+		if err := Sink(context.Background(), b, in, WithBatch(2, time.Minute)); err != b.writeErr {
+			t.Errorf("err = %v, want %v", err, b.writeErr)
+		}
+		if want := []error{b.writeErr, b.writeErr}; !reflect.DeepEqual(want, errs) {
+			t.Errorf("acks = %v, want %v", errs, want)
+		}
+	})
+
+	t.Run("an input that is not a Message is an error", func(t *testing.T) {
+		b := newBroker(1, 0)
+		if err := Sink(context.Background(), b, pipeline.Emit("text"), WithBatch(1, time.Minute)); err == nil {
+			t.Error("err = nil, want an error")
+		}
+		if len(b.written) != 0 {
+			t.Errorf("written = %v, want nothing", b.written)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Writer writes messages to Kafka
+type Writer interface {
+	// WriteMessages writes all of the messages, or returns an error
+	WriteMessages(ctx context.Context, msgs ...Message) error
+}
+
+// Sink writes the messages from the `in <-chan interface{}` with `w`, in batches set by WithBatch.
+// Each input must be a Message, or a pipeline.Tracked whose `Value` is a Message, which is acknowledged once its batch is written.
+// Sink returns the first error of `w`, after acknowledging the batch that failed with it,
+// or the `Context.Err()` if the context is canceled, like pipeline.ForEach.
+// The inputs after the failed batch are discarded without being acknowledged.
+func Sink(ctx context.Context, w Writer, in <-chan interface{}, opts ...Option) error {
+	cfg := newConfig(opts)
+	return pipeline.ForEach(ctx, pipeline.Collect(ctx, cfg.batchSize, cfg.batchTimeout, in), func(i interface{}) error {
+		var acks []func(err error)
+		var err error
+		batch := i.([]interface{})
+		msgs := make([]Message, 0, len(batch))
+		for _, i := range batch {
+			if t, ok := i.(pipeline.Tracked); ok {
+				acks = append(acks, t.Ack)
+				i = t.Value
+			}
+			msg, ok := i.(Message)
+			if !ok && err == nil {
+				err = fmt.Errorf("kafka: cannot write a %T, want a Message", i)
+			}
+			msgs = append(msgs, msg)
+		}
+		if err == nil {
+			err = w.WriteMessages(ctx, msgs...)
+		}
+		ackAll(acks, err)
+		return err
+	})
+}
+
+// ackAll calls each of acks with err
+func ackAll(acks []func(err error), err error) {
+	for _, ack := range acks {
+		ack(err)
+	}
+}
@@ -0,0 +1,168 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Reader reads the messages of a consumer group and commits their offsets
+type Reader interface {
+	// FetchMessage returns the next message, blocking until there is one or the context is canceled
+	FetchMessage(ctx context.Context) (Message, error)
+	// CommitMessages commits the offsets of the given messages, which marks them and the messages before them in their partition as done
+	CommitMessages(ctx context.Context, msgs ...Message) error
+}
+
+// Source emits the messages that `r` fetches as a pipeline.Tracked whose `Value` is a Message.
+// A message is only fetched once the previous one has been received, so a slow pipeline pauses the consumption.
+//
+// The offset of a message is committed once it, and every message fetched before it from the same partition,
+// have been acknowledged with nil, which pipeline.Untrack does when they leave the pipeline.
+// A message that is acknowledged with an error, or never acknowledged, is never committed and neither are the
+// messages after it in its partition, so they are fetched again when the consumer group is rebalanced or restarted.
+// Failures that should not be retried must be handled before that, for example by sending them to a dead letter topic.
+//
+// The first error of `r` is sent to the errs chan and stops Source. When the context is canceled,
+// Source stops fetching and commits the last acknowledged offsets within the grace period of WithCommitGrace.
+// The out chan is closed when Source stops fetching and the errs chan once the last offsets are committed.
+func Source(ctx context.Context, r Reader, opts ...Option) (<-chan interface{}, <-chan error) {
+	cfg := newConfig(opts)
+	out := make(chan interface{})
+	errs := make(chan error, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	o := &offsets{
+		pending: make(map[topicPartition][]*fetched),
+		commit:  make(map[topicPartition]Message),
+		ready:   make(chan struct{}, 1),
+	}
+	// fail stops Source with the first error
+	fail := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+		cancel()
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(out)
+		for {
+			msg, err := r.FetchMessage(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					fail(err)
+				}
+				return
+			}
+			t := o.track(msg)
+			select {
+			case out <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-o.ready:
+				if err := o.commitTo(ctx, r); err != nil && ctx.Err() == nil {
+					fail(err)
+				}
+			case <-ctx.Done():
+				// Commit what was acknowledged before the context was canceled
+				cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.commitGrace)
+				defer cancel()
+				if err := o.commitTo(cctx, r); err != nil {
+					fail(err)
+				}
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		cancel()
+		close(errs)
+	}()
+	return out, errs
+}
+
+// topicPartition identifies the partition of a message
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// fetched is a message that was fetched and is waiting to be acknowledged
+type fetched struct {
+	msg   Message
+	acked bool
+	err   error
+}
+
+// offsets keeps track of the messages that were fetched from each partition until they are acknowledged
+type offsets struct {
+	mu sync.Mutex
+	// pending holds the messages of each partition in the order they were fetched, down to the first one that is not acknowledged
+	pending map[topicPartition][]*fetched
+	// commit holds the last message of each partition that can be committed
+	commit map[topicPartition]Message
+	// ready signals that there is something to commit
+	ready chan struct{}
+}
+
+// track adds msg to the pending messages of its partition and returns it as a Tracked
+func (o *offsets) track(msg Message) pipeline.Tracked {
+	tp := topicPartition{msg.Topic, msg.Partition}
+	f := &fetched{msg: msg}
+	o.mu.Lock()
+	o.pending[tp] = append(o.pending[tp], f)
+	o.mu.Unlock()
+	return pipeline.Tracked{Value: msg, Ack: func(err error) {
+		o.ack(tp, f, err)
+	}}
+}
+
+// ack marks f as acknowledged and moves the commit of its partition past the messages that are done
+func (o *offsets) ack(tp topicPartition, f *fetched, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if f.acked {
+		return
+	}
+	f.acked, f.err = true, err
+	pending := o.pending[tp]
+	n := 0
+	for ; n < len(pending) && pending[n].acked && pending[n].err == nil; n++ {
+		o.commit[tp] = pending[n].msg
+	}
+	if n == 0 {
+		return
+	}
+	o.pending[tp] = pending[n:]
+	select {
+	case o.ready <- struct{}{}:
+	default:
+	}
+}
+
+// commitTo commits the messages that are ready to r
+func (o *offsets) commitTo(ctx context.Context, r Reader) error {
+	o.mu.Lock()
+	msgs := make([]Message, 0, len(o.commit))
+	for tp, msg := range o.commit {
+		msgs = append(msgs, msg)
+		delete(o.commit, tp)
+	}
+	o.mu.Unlock()
+	if len(msgs) == 0 {
+		return nil
+	}
+	return r.CommitMessages(ctx, msgs...)
+}
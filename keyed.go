@@ -0,0 +1,19 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// ProcessKeyed is like ProcessConcurrently, except that the inputs that share the same key are processed sequentially.
+// `keyFn` returns the key of each input, which is hashed to pick one of `concurrency` workers.
+// Inputs with the same key are always processed by the same worker, so their results are sent to the out chan
+// in the order the inputs were read, while inputs with different keys run in parallel.
+// An input waits while its worker is busy, even if other workers are free.
+// ProcessKeyed panics if `concurrency` is not positive.
+func ProcessKeyed(ctx context.Context, concurrency int, keyFn func(i interface{}) string, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	cfg := newConfig(opts).Config
+	cfg.SetConcurrency(concurrency)
+	return core.ProcessKeyed[interface{}, interface{}](ctx, concurrency, keyFn, p, in, cfg)
+}
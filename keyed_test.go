@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// event is an input of ProcessKeyed
+type event struct {
+	account string
+	seq     int
+}
+
+func TestProcessKeyed(t *testing.T) {
+	// Interleave the events of a slow and a fast account
+	in := make(chan interface{})
+	go func() {
+		defer close(in)
+		for seq := 0; seq < 10; seq++ {
+			in <- event{"slow", seq}
+			in <- event{"fast", seq}
+		}
+	}()
+	p := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		if e := i.(event); e.account == "slow" {
+			time.Sleep(time.Duration(10-e.seq) * time.Millisecond)
+		} else {
+			time.Sleep(time.Duration(e.seq) * time.Millisecond)
+		}
+		return i, nil
+	})
+	key := func(i interface{}) string {
+		return i.(event).account
+	}
+
+	seqs := map[string][]int{}
+	for o := range ProcessKeyed(context.Background(), 4, key, p, in) {
+		e := o.(event)
+		seqs[e.account] = append(seqs[e.account], e.seq)
+	}
+
+	want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	for _, account := range []string{"slow", "fast"} {
+		if got := seqs[account]; !reflect.DeepEqual(want, got) {
+			t.Errorf("%s = %v, want %v", account, got, want)
+		}
+	}
+}
+
+func TestProcessKeyedConcurrency(t *testing.T) {
+	for _, concurrency := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ProcessKeyed(%d) did not panic", concurrency)
+				}
+			}()
+			ProcessKeyed(context.Background(), concurrency, func(interface{}) string { return "" }, noopProcessor, Emit(1, 2, 3))
+		}()
+	}
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import "context"
+
+// WithLatestFrom combines each input of the `main` channel with the latest input of the `reference` channel,
+// and sends the output of `combine` to the out chan, which is useful to enrich a stream with slowly changing data.
+// The main inputs wait for the first reference input, unless WithDropEarly is set.
+// The out chan is only closed when the `main` channel is closed, after which the `reference` channel is read until it is closed.
+// If the `reference` channel is closed first, its last input keeps being used, or, if it had none, the main inputs are dropped.
+// After the context is canceled, the remaining inputs are dropped until both channels are closed.
+func WithLatestFrom(ctx context.Context, main, reference <-chan interface{}, combine func(m, r interface{}) interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		var latest interface{}
+		var ready bool
+		for {
+			// Only read the main inputs once there is a reference to combine them with, unless they are dropped
+			read := main
+			if !ready && !c.dropEarly && reference != nil {
+				read = nil
+			}
+			select {
+			case r, open := <-reference:
+				if !open {
+					reference = nil
+					continue
+				}
+				latest, ready = r, true
+			case m, open := <-read:
+				if !open {
+					close(out)
+					dropAll(reference)
+					return
+				}
+				if !ready {
+					c.drop(m)
+					continue
+				}
+				if !send(ctx, combine(m, latest), out) {
+					close(out)
+					dropAll(main, reference)
+					return
+				}
+			case <-ctx.Done():
+				close(out)
+				dropAll(main, reference)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithDropEarly makes WithLatestFrom drop the main inputs that arrive before the first reference input, rather than wait for it.
+// If `dropped` is not nil, it is called with each of them.
+func WithDropEarly(dropped func(i interface{})) Option {
+	return func(c *config) {
+		c.dropEarly = true
+		c.dropped = dropped
+	}
+}
+
+// CombineLatest sends the output of `combine` for the latest inputs of the `a` and `b` channels each time either of them sends one,
+// once both of them have sent one. The out chan is closed when both channels are closed.
+// After the context is canceled, the remaining inputs are dropped until both channels are closed.
+func CombineLatest(ctx context.Context, a, b <-chan interface{}, combine func(x, y interface{}) interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var x, y interface{}
+		var hasX, hasY bool
+		for a != nil || b != nil {
+			select {
+			case i, open := <-a:
+				if !open {
+					a = nil
+					continue
+				}
+				x, hasX = i, true
+			case i, open := <-b:
+				if !open {
+					b = nil
+					continue
+				}
+				y, hasY = i, true
+			case <-ctx.Done():
+				dropAll(a, b)
+				return
+			}
+			if hasX && hasY && !send(ctx, combine(x, y), out) {
+				dropAll(a, b)
+				return
+			}
+		}
+	}()
+	return out
+}
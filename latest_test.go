@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWithLatestFrom(t *testing.T) {
+	join := func(m, r interface{}) interface{} {
+		return fmt.Sprint(m, r)
+	}
+
+	t.Run("main inputs are combined with the latest reference and wait for the first one", func(t *testing.T) {
+		main, reference := make(chan interface{}), make(chan interface{})
+		out := WithLatestFrom(context.Background(), main, reference, join)
+		go func() {
+			main <- 1
+		}()
+		reference <- "a"
+		if o := <-out; o != "1a" {
+			t.Errorf("out = %v, want 1a", o)
+		}
+		reference <- "b"
+		reference <- "c"
+		// The reference closing does not close the out chan
+		close(reference)
+		main <- 2
+		if o := <-out; o != "2c" {
+			t.Errorf("out = %v, want 2c", o)
+		}
+		close(main)
+		if _, open := <-out; open {
+			t.Error("out is open after main is closed")
+		}
+	})
+
+	t.Run("main inputs before the first reference are dropped with WithDropEarly", func(t *testing.T) {
+		main, reference := make(chan interface{}), make(chan interface{})
+		var dropped []interface{}
+		out := WithLatestFrom(context.Background(), main, reference, join, WithDropEarly(func(i interface{}) {
+			dropped = append(dropped, i)
+		}))
+		main <- 1
+		reference <- "a"
+		main <- 2
+		if o := <-out; o != "2a" {
+			t.Errorf("out = %v, want 2a", o)
+		}
+		close(main)
+		close(reference)
+		for range out {
+		}
+		if want := []interface{}{1}; !reflect.DeepEqual(want, dropped) {
+			t.Errorf("dropped = %v, want %v", dropped, want)
+		}
+	})
+
+	t.Run("main inputs are dropped if the reference closes without an input", func(t *testing.T) {
+		reference := make(chan interface{})
+		close(reference)
+		for o := range WithLatestFrom(context.Background(), Emit(1, 2), reference, join) {
+			t.Errorf("out = %v, want nothing", o)
+		}
+	})
+
+	t.Run("the channels fed by one Tee are drained after the context is canceled", func(t *testing.T) {
+		drainsTee(t, func(ctx context.Context, main, reference <-chan interface{}) {
+			for range WithLatestFrom(ctx, main, reference, join) {
+			}
+		})
+	})
+}
+
+func TestCombineLatest(t *testing.T) {
+	a, b := make(chan interface{}), make(chan interface{})
+	out := CombineLatest(context.Background(), a, b, func(x, y interface{}) interface{} {
+		return fmt.Sprint(x, y)
+	})
+	a <- 1
+	a <- 2
+	b <- "a"
+	if o := <-out; o != "2a" {
+		t.Errorf("out = %v, want 2a", o)
+	}
+	b <- "b"
+	if o := <-out; o != "2b" {
+		t.Errorf("out = %v, want 2b", o)
+	}
+	close(b)
+	a <- 3
+	if o := <-out; o != "3b" {
+		t.Errorf("out = %v, want 3b", o)
+	}
+	close(a)
+	if _, open := <-out; open {
+		t.Error("out is open after both channels are closed")
+	}
+}
+
+func TestCombineLatestDrainsTee(t *testing.T) {
+	drainsTee(t, func(ctx context.Context, a, b <-chan interface{}) {
+		for range CombineLatest(ctx, a, b, func(x, y interface{}) interface{} {
+			return fmt.Sprint(x, y)
+		}) {
+		}
+	})
+}
@@ -0,0 +1,306 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
+)
+
+// errSetup is returned by the Setup of the lifecycle processor
+var errSetup = errors.New("setup error")
+
+// lifecycleProcessor records its setups and teardowns, and fails its Process calls unless it is set up
+type lifecycleProcessor struct {
+	*pipelinetest.Processor
+	events *lifecycleEvents
+	// clone makes it implement WorkerCloner, setupErr makes its Setup fail
+	clone, setupErr bool
+	mu              sync.Mutex
+	setUp, tornDown bool
+}
+
+// lifecycleEvents counts the setups and teardowns of a lifecycleProcessor and of its clones
+type lifecycleEvents struct {
+	mu                                  sync.Mutex
+	clones, setups, teardowns           int
+	processedBeforeSetup, afterTeardown bool
+}
+
+func (p *lifecycleProcessor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	p.mu.Lock()
+	ready := p.setUp && !p.tornDown
+	p.mu.Unlock()
+	if !ready {
+		p.events.mu.Lock()
+		p.events.processedBeforeSetup = true
+		p.events.mu.Unlock()
+	}
+	return p.Processor.Process(ctx, i)
+}
+
+func (p *lifecycleProcessor) Setup(ctx context.Context) error {
+	p.events.mu.Lock()
+	p.events.setups++
+	p.events.mu.Unlock()
+	if p.setupErr {
+		return errSetup
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setUp = true
+	return nil
+}
+
+func (p *lifecycleProcessor) Teardown() error {
+	p.events.mu.Lock()
+	p.events.teardowns++
+	p.events.mu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tornDown = true
+	return nil
+}
+
+// clonedProcessor is a lifecycleProcessor that implements WorkerCloner
+type clonedProcessor struct {
+	*lifecycleProcessor
+}
+
+func (p clonedProcessor) CloneForWorker() Processor {
+	p.events.mu.Lock()
+	p.events.clones++
+	p.events.mu.Unlock()
+	return &lifecycleProcessor{Processor: p.Processor, events: p.events}
+}
+
+func TestLifecycle(t *testing.T) {
+	inputs := []interface{}{1, 2, 3, 4, 5, 6, 7, 8}
+	for _, test := range []struct {
+		name          string
+		stage         func(p Processor, in <-chan interface{}) <-chan interface{}
+		clone         bool
+		wantSetups    int
+		wantTeardowns int
+	}{{
+		name: "Process sets up and tears down its processor once",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return Process(context.Background(), p, in)
+		},
+		wantSetups:    1,
+		wantTeardowns: 1,
+	}, {
+		name: "ProcessConcurrently shares a processor that cannot be cloned",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessConcurrently(context.Background(), 4, p, in)
+		},
+		wantSetups:    1,
+		wantTeardowns: 1,
+	}, {
+		name: "ProcessConcurrently sets up a clone for each worker",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessConcurrently(context.Background(), 4, p, in)
+		},
+		clone:         true,
+		wantSetups:    4,
+		wantTeardowns: 4,
+	}, {
+		name: "ProcessConcurrentlyOrdered sets up a clone for each worker",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessConcurrentlyOrdered(context.Background(), 3, p, in)
+		},
+		clone:         true,
+		wantSetups:    3,
+		wantTeardowns: 3,
+	}, {
+		name: "ProcessKeyed sets up a clone for each worker",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessKeyed(context.Background(), 4, func(i interface{}) string {
+				return fmt.Sprint(i)
+			}, p, in)
+		},
+		clone:         true,
+		wantSetups:    4,
+		wantTeardowns: 4,
+	}, {
+		name: "ProcessPriority sets up a clone for each worker",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessPriority(context.Background(), 3, func(i interface{}) int {
+				return i.(int)
+			}, p, in)
+		},
+		clone:         true,
+		wantSetups:    3,
+		wantTeardowns: 3,
+	}, {
+		name: "ProcessSingleflight sets up a clone for each worker",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessSingleflight(context.Background(), func(i interface{}) string {
+				return fmt.Sprint(i)
+			}, p, in, WithConcurrency(3))
+		},
+		clone:         true,
+		wantSetups:    3,
+		wantTeardowns: 3,
+	}, {
+		name: "ProcessAutoscale shares a processor that cannot be cloned",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessAutoscale(context.Background(), 2, 4, p, in)
+		},
+		wantSetups:    1,
+		wantTeardowns: 1,
+	}, {
+		name: "ProcessBatch sets up and tears down its processor once",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessBatch(context.Background(), 3, time.Second, p, in)
+		},
+		wantSetups:    1,
+		wantTeardowns: 1,
+	}, {
+		name: "ProcessBatchConcurrently sets up a clone for each worker",
+		stage: func(p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessBatchConcurrently(context.Background(), 2, 3, time.Second, p, in)
+		},
+		clone:         true,
+		wantSetups:    2,
+		wantTeardowns: 2,
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			events := &lifecycleEvents{}
+			recorder := pipelinetest.NewProcessor()
+			var p Processor = &lifecycleProcessor{Processor: recorder, events: events}
+			if test.clone {
+				p = clonedProcessor{p.(*lifecycleProcessor)}
+			}
+			var outs []interface{}
+			for o := range test.stage(p, Emit(inputs...)) {
+				outs = append(outs, o)
+			}
+			pipelinetest.AssertAllItemsAccountedFor(t, inputs, outs, pipelinetest.Inputs(recorder.Canceled()))
+			events.mu.Lock()
+			defer events.mu.Unlock()
+			if events.setups != test.wantSetups || events.teardowns != test.wantTeardowns {
+				t.Errorf("setups = %d, teardowns = %d, want %d and %d", events.setups, events.teardowns, test.wantSetups, test.wantTeardowns)
+			}
+			if test.clone && events.clones != test.wantSetups {
+				t.Errorf("clones = %d, want %d", events.clones, test.wantSetups)
+			}
+			if events.processedBeforeSetup {
+				t.Error("an input was processed by a processor that was not set up, or already torn down")
+			}
+		})
+	}
+
+	t.Run("a failed Setup fails every input", func(t *testing.T) {
+		events := &lifecycleEvents{}
+		recorder := pipelinetest.NewProcessor()
+		p := &lifecycleProcessor{Processor: recorder, events: events, setupErr: true}
+		var outs []interface{}
+		for o := range ProcessConcurrently(context.Background(), 2, p, Emit(inputs...)) {
+			outs = append(outs, o)
+		}
+		if len(outs) != 0 {
+			t.Errorf("out = %+v, want nothing", outs)
+		}
+		canceled := recorder.Canceled()
+		pipelinetest.AssertAllItemsAccountedFor(t, inputs, nil, pipelinetest.Inputs(canceled))
+		for _, call := range canceled {
+			var sErr *SetupError
+			var pErr *ProcessError
+			if !errors.As(call.Err, &pErr) || !errors.As(call.Err, &sErr) || !errors.Is(call.Err, errSetup) {
+				t.Errorf("Cancel(%v) err = %#v, want a *ProcessError that wraps a *SetupError of %s", call.Input, call.Err, errSetup)
+			}
+		}
+		if len(recorder.Processed()) != 0 {
+			t.Errorf("processed = %+v, want nothing", recorder.Processed())
+		}
+		// The processor that failed to set up is not torn down
+		if events.teardowns != 0 {
+			t.Errorf("teardowns = %d, want 0", events.teardowns)
+		}
+	})
+
+	t.Run("the clones that were set up are torn down when another Setup fails", func(t *testing.T) {
+		events := &lifecycleEvents{}
+		var failed sync.Once
+		p := clonedFailingProcessor{events: events, failed: &failed}
+		for range ProcessConcurrently(context.Background(), 3, p, Emit(inputs...)) {
+		}
+		events.mu.Lock()
+		defer events.mu.Unlock()
+		if events.setups != 3 || events.teardowns != 2 {
+			t.Errorf("setups = %d, teardowns = %d, want 3 and 2", events.setups, events.teardowns)
+		}
+	})
+
+	t.Run("Teardown runs after the inputs that are canceled by the context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		events := &lifecycleEvents{}
+		recorder := pipelinetest.NewProcessor()
+		p := &lifecycleProcessor{Processor: recorder, events: events}
+		in := make(chan interface{})
+		out := Process(ctx, p, in)
+		in <- 1
+		<-out
+		cancel()
+		in <- 2
+		close(in)
+		for range out {
+			t.Error("an input was sent after the context was canceled")
+		}
+		events.mu.Lock()
+		defer events.mu.Unlock()
+		if events.teardowns != 1 {
+			t.Errorf("teardowns = %d, want 1", events.teardowns)
+		}
+		if canceled := recorder.Canceled(); len(canceled) != 1 || canceled[0].Input != 2 {
+			t.Errorf("canceled = %+v, want 2", canceled)
+		}
+	})
+
+	t.Run("the errors of Teardown are passed to WithTeardownErrors", func(t *testing.T) {
+		var errs []error
+		p := teardownPanicProcessor{pipelinetest.NewProcessor()}
+		for range Process(context.Background(), p, Emit(1), WithTeardownErrors(func(err error) {
+			errs = append(errs, err)
+		})) {
+		}
+		var pErr *PanicError
+		if len(errs) != 1 || !errors.As(errs[0], &pErr) {
+			t.Errorf("errs = %+v, want the *PanicError of Teardown", errs)
+		}
+	})
+}
+
+// clonedFailingProcessor is a WorkerCloner whose second clone fails to set up
+type clonedFailingProcessor struct {
+	events *lifecycleEvents
+	failed *sync.Once
+}
+
+func (p clonedFailingProcessor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	return i, nil
+}
+
+func (p clonedFailingProcessor) Cancel(interface{}, error) {}
+
+func (p clonedFailingProcessor) CloneForWorker() Processor {
+	p.events.mu.Lock()
+	defer p.events.mu.Unlock()
+	p.events.clones++
+	return &lifecycleProcessor{Processor: pipelinetest.NewProcessor(), events: p.events, setupErr: p.events.clones == 2}
+}
+
+// teardownPanicProcessor panics in Teardown
+type teardownPanicProcessor struct {
+	*pipelinetest.Processor
+}
+
+func (teardownPanicProcessor) Teardown() error {
+	panic("can not tear down")
+}
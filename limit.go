@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limit passes at most `rate` inputs per second from the `in <-chan interface{}` to the out `<-chan interface{}`.
+// It is implemented as a token bucket that holds up to `burst` tokens, so up to `burst` inputs can pass at once after a quiet period.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// Use Cancel or Process before Limit if the dropped inputs need to be handled.
+// Limit panics if `rate` is not positive.
+func Limit(ctx context.Context, rate float64, burst int, in <-chan interface{}) <-chan interface{} {
+	if !(rate > 0) {
+		panic(fmt.Sprintf("pipeline: limit rate must be positive, got %v", rate))
+	}
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		bucket := newTokenBucket(rate, burst)
+		for i := range in {
+			// Wait for a token, unless the context is canceled
+			if !bucket.wait(ctx) {
+				break
+			}
+			select {
+			case out <- i:
+			case <-ctx.Done():
+			}
+		}
+		// Drop the remaining inputs so that the stages before Limit are never blocked
+		for range in {
+		}
+	}()
+	return out
+}
+
+// tokenBucket is refilled at rate tokens per second up to burst tokens
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full tokenBucket
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available and takes it.
+// It returns false if the context is canceled first.
+func (b *tokenBucket) wait(ctx context.Context) bool {
+	// Refill the bucket with the tokens added since the last call
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return ctx.Err() == nil
+	}
+	// Wait for the missing fraction of a token
+	timer := time.NewTimer(time.Duration((1 - b.tokens) / b.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case now = <-timer.C:
+		b.tokens = 0
+		b.last = now
+		return true
+	}
+}
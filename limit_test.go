@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLimit(t *testing.T) {
+	t.Run("inputs are passed through at the rate limit", func(t *testing.T) {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+		}()
+
+		// 100 inputs at 50 per second should take about 2 seconds
+		start := time.Now()
+		var count int
+		for range Limit(context.Background(), 50, 1, in) {
+			count++
+		}
+		elapsed := time.Since(start)
+		if count != 100 {
+			t.Errorf("count = %d, want 100", count)
+		}
+		if elapsed < 1900*time.Millisecond || elapsed > 2500*time.Millisecond {
+			t.Errorf("elapsed = %s, want about 2s", elapsed)
+		}
+	})
+
+	t.Run("burst inputs pass at once", func(t *testing.T) {
+		start := time.Now()
+		var count int
+		for range Limit(context.Background(), 1, 5, Emit(1, 2, 3, 4, 5)) {
+			count++
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("elapsed = %s, want < 100ms", elapsed)
+		}
+		if count != 5 {
+			t.Errorf("count = %d, want 5", count)
+		}
+	})
+
+	t.Run("cancellation does not leak the goroutine", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+		}()
+
+		// Only a few inputs pass before the context is canceled
+		var count int
+		for range Limit(ctx, 10, 1, in) {
+			count++
+		}
+		if count < 1 || count > 3 {
+			t.Errorf("count = %d, want 1-3", count)
+		}
+
+		// Every goroutine should exit once the inputs are drained
+		time.Sleep(10 * time.Millisecond)
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("goroutines = %d, want <= %d", after, before)
+		}
+	})
+	t.Run("a rate that is not positive panics", func(t *testing.T) {
+		for _, rate := range []float64{0, -1, math.NaN()} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Limit(%v) did not panic", rate)
+					}
+				}()
+				Limit(context.Background(), rate, 1, Emit(1, 2, 3))
+			}()
+		}
+	})
+}
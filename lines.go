@@ -0,0 +1,26 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// EmitLines streams the lines read from `r` to the out `<-chan interface{}` as strings, without their end of line marker,
+// so a file or stdin can be processed without loading it into memory.
+// Lines can be up to 64KB long by default, use WithMaxLineSize for longer lines.
+// The error of the reader, or bufio.ErrTooLong for a line that is too long, is sent to the errs chan, which never blocks.
+// Both chans are closed at the end of `r`, after an error, or when the context is canceled, which is checked between reads.
+func EmitLines(ctx context.Context, r io.Reader, opts ...Option) (<-chan interface{}, <-chan error) {
+	return core.EmitLines(ctx, r, newConfig(opts).maxLineSize, func(line string) interface{} {
+		return line
+	})
+}
+
+// WithMaxLineSize sets the size in bytes of the longest line that EmitLines can read
+func WithMaxLineSize(size int) Option {
+	return func(c *config) {
+		c.maxLineSize = size
+	}
+}
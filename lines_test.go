@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// syntheticLines is a reader of n numbered lines that are generated as they are read
+type syntheticLines struct {
+	n, next int
+	buf     []byte
+}
+
+func (s *syntheticLines) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.next == s.n {
+			return 0, io.EOF
+		}
+		s.buf = []byte(fmt.Sprintf("line %08d %s\n", s.next, strings.Repeat("x", 100)))
+		s.next++
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func TestEmitLines(t *testing.T) {
+	t.Run("every line is emitted without its end of line marker", func(t *testing.T) {
+		lines, errs := EmitLines(context.Background(), strings.NewReader("a\nb\r\n\nc"))
+		var outs []interface{}
+		for l := range lines {
+			outs = append(outs, l)
+		}
+		if want := []interface{}{"a", "b", "", "c"}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("lines = %q, want %q", outs, want)
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("err = %s, want nil", err)
+		}
+	})
+
+	t.Run("a multi-megabyte reader is streamed", func(t *testing.T) {
+		const n = 50000 // ~6MB
+		lines, errs := EmitLines(context.Background(), &syntheticLines{n: n})
+		var count int
+		for l := range lines {
+			if want := fmt.Sprintf("line %08d", count); !strings.HasPrefix(l.(string), want) {
+				t.Fatalf("line %d = %.20q, want it to start with %q", count, l, want)
+			}
+			count++
+		}
+		if count != n {
+			t.Errorf("count = %d, want %d", count, n)
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("err = %s, want nil", err)
+		}
+	})
+
+	t.Run("both chans close when the context is canceled mid-stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		lines, errs := EmitLines(ctx, &syntheticLines{n: 50000})
+		var count int
+		for range lines {
+			if count++; count == 1000 {
+				cancel()
+			}
+		}
+		if count > 1001 {
+			t.Errorf("count = %d, want the lines to stop after 1000", count)
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("err = %s, want nil", err)
+		}
+	})
+
+	t.Run("lines that are too long fail unless the max line size allows them", func(t *testing.T) {
+		long := strings.Repeat("x", 100000)
+		_, errs := EmitLines(context.Background(), strings.NewReader(long+"\n"))
+		if err := <-errs; !errors.Is(err, bufio.ErrTooLong) {
+			t.Errorf("err = %v, want %s", err, bufio.ErrTooLong)
+		}
+
+		lines, errs := EmitLines(context.Background(), strings.NewReader(long+"\nshort\n"), WithMaxLineSize(200000))
+		var outs []interface{}
+		for l := range lines {
+			outs = append(outs, l)
+		}
+		if want := []interface{}{long, "short"}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("got %d lines, want the long and the short line", len(outs))
+		}
+		if err := <-errs; err != nil {
+			t.Errorf("err = %s, want nil", err)
+		}
+	})
+
+	t.Run("the error of the reader is sent to the errs chan", func(t *testing.T) {
+		errRead := errors.New("read")
+		lines, errs := EmitLines(context.Background(), io.MultiReader(strings.NewReader("a\n"), iotest.ErrReader(errRead)))
+		var outs []interface{}
+		for l := range lines {
+			outs = append(outs, l)
+		}
+		if want := []interface{}{"a"}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("lines = %q, want %q", outs, want)
+		}
+		if err := <-errs; !errors.Is(err, errRead) {
+			t.Errorf("err = %v, want %s", err, errRead)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	failOn3 := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		if i == 3 {
+			return nil, errProcess
+		}
+		return i, nil
+	})
+	ctx := context.Background()
+	out := ProcessConcurrently(ctx, 2, failOn3, Emit(1, 2, 3), WithName("check"), WithLogger(logger))
+	out = ProcessBatch(ctx, 2, time.Second, noopProcessor, out, WithName("batch"), WithLogger(logger))
+	for range out {
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for range Process(canceled, noopProcessor, Emit(1), WithName("canceled"), WithLogger(logger)) {
+	}
+
+	logs := buf.String()
+	for _, want := range []string{
+		`level=DEBUG msg="pipeline: stage started" stage=check workers=2`,
+		`level=ERROR msg="pipeline: input canceled" stage=check error="` + errProcess.Error() + `"`,
+		`level=DEBUG msg="pipeline: stage stopped" stage=check`,
+		`level=DEBUG msg="pipeline: batch flushed" stage=batch size=2`,
+		`level=DEBUG msg="pipeline: input canceled" stage=canceled error="context canceled"`,
+	} {
+		if !strings.Contains(logs, want+"\n") {
+			t.Errorf("logs = %s\nwant a line %s", logs, want)
+		}
+	}
+	if strings.Count(logs, "input canceled") != 2 {
+		t.Errorf("logs = %s\nwant 2 canceled inputs", logs)
+	}
+}
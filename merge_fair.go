@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+)
+
+// MergeFair is like Merge, except that it takes turns between the channels of `ins` that have an input ready,
+// so a channel that sends a lot cannot starve the others: while every channel has inputs ready, they are sent
+// to the out chan one from each channel in turn, and an input that becomes ready waits for at most one input
+// of each other channel.
+// Use WithSourceCounts to follow how many inputs each channel sent.
+// The out chan is closed when all of the channels are closed.
+// After the context is canceled, the remaining inputs are dropped until all of the channels are closed.
+func MergeFair(ctx context.Context, ins []<-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		ins := append([]<-chan interface{}(nil), ins...)
+		counts := make([]int64, len(ins))
+		// next is the channel whose turn it is, open the number of channels that are not closed yet
+		next, open := 0, 0
+		for _, in := range ins {
+			if in != nil {
+				open++
+			}
+		}
+		for open > 0 {
+			k, i, received, ready := nextReady(ins, next)
+			if !ready {
+				// No channel has an input ready, so wait for the first one that does
+				k, i, received = waitAny(ctx, ins)
+				if ctx.Err() != nil {
+					dropAll(ins...)
+					return
+				}
+			}
+			if !received {
+				ins[k] = nil
+				open--
+				continue
+			}
+			next = (k + 1) % len(ins)
+			c.Idle.Add(1)
+			sent := send(ctx, i, out)
+			c.Idle.Add(-1)
+			if !sent {
+				dropAll(ins...)
+				return
+			}
+			counts[k]++
+			if c.sourceCounts != nil {
+				c.sourceCounts(k, counts[k])
+			}
+		}
+	}()
+	return out
+}
+
+// WithSourceCounts makes MergeFair call `counted` after each input it sends, with the index of its channel
+// and the number of inputs sent from that channel so far. It must not block.
+func WithSourceCounts(counted func(source int, sent int64)) Option {
+	return func(c *config) {
+		c.sourceCounts = counted
+	}
+}
+
+// nextReady returns the index of the first channel of ins from `start` on that is ready, with its input,
+// or false as `received` if that channel is closed. It returns false as `ready` if no channel is ready.
+func nextReady(ins []<-chan interface{}, start int) (k int, i interface{}, received, ready bool) {
+	for n := range ins {
+		k := (start + n) % len(ins)
+		if ins[k] == nil {
+			continue
+		}
+		select {
+		case i, open := <-ins[k]:
+			return k, i, open, true
+		default:
+		}
+	}
+	return 0, nil, false, false
+}
+
+// waitAny waits for an input of any channel of ins and returns the index of its channel and the input,
+// or false if the channel is closed. It returns early when the context is canceled.
+func waitAny(ctx context.Context, ins []<-chan interface{}) (int, interface{}, bool) {
+	cases := make([]reflect.SelectCase, 0, len(ins)+1)
+	// indexes maps the cases to the channels of ins, since the closed channels are skipped
+	indexes := make([]int, 0, len(ins))
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	for k, in := range ins {
+		if in != nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(in)})
+			indexes = append(indexes, k)
+		}
+	}
+	chosen, i, open := reflect.Select(cases)
+	if chosen == 0 {
+		return 0, nil, false
+	}
+	if !open {
+		return indexes[chosen-1], nil, false
+	}
+	return indexes[chosen-1], i.Interface(), true
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMergeFair(t *testing.T) {
+	// firehose sends n from its own goroutine as fast as it can until the context is canceled
+	firehose := func(ctx context.Context, n int) <-chan interface{} {
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			for send(ctx, n, out) {
+			}
+		}()
+		return out
+	}
+
+	t.Run("channels that are always ready take turns", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		counts := map[int]int64{}
+		out := MergeFair(ctx, []<-chan interface{}{firehose(ctx, 0), firehose(ctx, 1), firehose(ctx, 2)},
+			WithSourceCounts(func(source int, sent int64) { counts[source] = sent }))
+		sent := map[interface{}]int{}
+		for n := 0; n < 300; n++ {
+			sent[<-out]++
+		}
+		cancel()
+		for range out {
+		}
+		for source := 0; source < 3; source++ {
+			// Each firehose may have missed a turn while its goroutine was not ready yet
+			if sent[source] < 90 {
+				t.Errorf("source %d sent %d of 300 inputs, want about 100", source, sent[source])
+			}
+			if counts[source] < int64(sent[source]) {
+				t.Errorf("count of source %d = %d, want at least %d", source, counts[source], sent[source])
+			}
+		}
+	})
+
+	t.Run("a slow channel is not starved by a fast one", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		slow := make(chan interface{})
+		out := MergeFair(ctx, []<-chan interface{}{firehose(ctx, 0), slow})
+		for n := 0; n < 10; n++ {
+			sent := make(chan struct{})
+			go func() {
+				slow <- "slow"
+				close(sent)
+			}()
+			// Once the slow input is ready, it is sent within 2 inputs
+			<-time.After(time.Millisecond)
+			var got bool
+			for k := 0; k < 3 && !got; k++ {
+				got = <-out == "slow"
+			}
+			if !got {
+				t.Fatalf("the slow input was starved")
+			}
+			<-sent
+		}
+		cancel()
+		close(slow)
+		for range out {
+		}
+	})
+
+	t.Run("the out chan is closed when all of the channels are closed", func(t *testing.T) {
+		var n int
+		for range MergeFair(context.Background(), []<-chan interface{}{Emit(1, 2), nil, Emit(3)}) {
+			n++
+		}
+		if n != 3 {
+			t.Errorf("got %d inputs, want 3", n)
+		}
+	})
+	t.Run("the channels fed by one Tee are drained after the context is canceled", func(t *testing.T) {
+		drainsTee(t, func(ctx context.Context, a, b <-chan interface{}) {
+			for range MergeFair(ctx, []<-chan interface{}{a, b}) {
+			}
+		})
+	})
+}
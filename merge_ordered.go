@@ -0,0 +1,254 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// MergeOrdered merges the channels of `ins` into a stream sorted by the sequence numbers returned by `seqFn`,
+// which puts back in order the outputs of a stream that was split for parallel processing.
+// The sequence numbers start at 0 and increase by 1, as set by Stamp. The inputs that arrive ahead of their turn are held
+// until the inputs before them arrive, so MergeOrdered waits for a missing sequence number forever by default:
+// use WithGapTimeout to skip it after a while instead. Up to 1000 inputs are held, use WithMaxPending to change it:
+// when it is exceeded, the gap before the inputs held is skipped right away. Use WithPendingCount to follow how many inputs are held.
+// An input whose sequence number was already sent or skipped is dropped, use WithLateItems to handle it.
+// When all of the channels are closed, the inputs still held are sent in order, skipping the gaps, before the out chan is closed.
+// After the context is canceled, the inputs held and the remaining inputs are dropped until all of the channels are closed.
+// Use MergeOrderedWith to pass options such as WithGapTimeout.
+func MergeOrdered(ctx context.Context, seqFn func(i interface{}) uint64, ins ...<-chan interface{}) <-chan interface{} {
+	return MergeOrderedWith(ctx, seqFn, nil, ins...)
+}
+
+// MergeOrderedWith is like MergeOrdered, with the options `opts`, such as WithGapTimeout:
+//
+//	out := pipeline.MergeOrderedWith(ctx, pipeline.StampedSeq, []pipeline.Option{pipeline.WithGapTimeout(time.Second, nil)}, outs...)
+func MergeOrderedWith(ctx context.Context, seqFn func(i interface{}) uint64, opts []Option, ins ...<-chan interface{}) <-chan interface{} {
+	c := newConfig(opts)
+	if c.maxPending <= 0 {
+		c.maxPending = defaultMaxPending
+	}
+	in := Merge(ins...)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		m := &orderedMerger{held: map[uint64]interface{}{}, cfg: c}
+		// The inputs held are dropped when the context is canceled
+		defer func() {
+			c.Idle.Add(-len(m.held))
+		}()
+		var timer Timer
+		// tick is only set while MergeOrdered waits for a missing sequence number
+		var tick <-chan time.Time
+		if c.gapTimeout > 0 {
+			timer = c.Clock.NewTimer(c.gapTimeout)
+			defer timer.Stop()
+			stopTimer(timer)
+		}
+		for {
+			waiting := m.next
+			select {
+			case i, open := <-in:
+				if !open {
+					for len(m.held) > 0 {
+						if !m.skip(ctx, out) {
+							return
+						}
+					}
+					return
+				}
+				c.Idle.Add(1)
+				if !m.add(ctx, seqFn(i), i, out) {
+					dropAll(in)
+					return
+				}
+			case <-tick:
+				tick = nil
+				if !m.skip(ctx, out) {
+					dropAll(in)
+					return
+				}
+			case <-ctx.Done():
+				dropAll(in)
+				return
+			}
+			if timer == nil {
+				continue
+			}
+			// The timeout starts over each time a new sequence number is missing
+			if len(m.held) == 0 {
+				stopTimer(timer)
+				tick = nil
+			} else if tick == nil || m.next != waiting {
+				stopTimer(timer)
+				timer.Reset(c.gapTimeout)
+				tick = timer.C()
+			}
+		}
+	}()
+	return out
+}
+
+// WithGapTimeout makes MergeOrdered skip a missing sequence number once it has waited `timeout` for it,
+// and pass the first and the last sequence numbers that were skipped to `skipped`, if it is not nil.
+func WithGapTimeout(timeout time.Duration, skipped func(first, last uint64)) Option {
+	return func(c *config) {
+		c.gapTimeout = timeout
+		c.skipped = skipped
+	}
+}
+
+// WithPendingCount makes MergeOrdered call `counted` with the number of inputs it holds each time it changes.
+// It must not block.
+func WithPendingCount(counted func(pending int)) Option {
+	return func(c *config) {
+		c.pendingCount = counted
+	}
+}
+
+// orderedMerger holds the inputs of MergeOrdered that arrived ahead of their turn
+type orderedMerger struct {
+	held map[uint64]interface{}
+	// next is the sequence number of the next input to send
+	next uint64
+	cfg  *config
+}
+
+// add holds i and sends the inputs that are next in sequence, skipping a gap if too many inputs are held.
+// It returns false if the context was canceled.
+func (m *orderedMerger) add(ctx context.Context, seq uint64, i interface{}, out chan<- interface{}) bool {
+	if _, ok := m.held[seq]; ok || seq < m.next {
+		m.cfg.Idle.Add(-1)
+		if m.cfg.late != nil {
+			m.cfg.late(i)
+		}
+		return true
+	}
+	m.held[seq] = i
+	m.counted()
+	if len(m.held) > m.cfg.maxPending {
+		return m.skip(ctx, out)
+	}
+	return m.flush(ctx, out)
+}
+
+// skip moves past the missing sequence numbers to the least sequence number held, and sends the inputs from there.
+// It returns false if the context was canceled.
+func (m *orderedMerger) skip(ctx context.Context, out chan<- interface{}) bool {
+	first := true
+	var least uint64
+	for seq := range m.held {
+		if first || seq < least {
+			least, first = seq, false
+		}
+	}
+	if !first && least > m.next {
+		if m.cfg.skipped != nil {
+			m.cfg.skipped(m.next, least-1)
+		}
+		m.next = least
+	}
+	return m.flush(ctx, out)
+}
+
+// flush sends the inputs held from the next sequence number on, until one is missing.
+// It returns false if the context was canceled.
+func (m *orderedMerger) flush(ctx context.Context, out chan<- interface{}) bool {
+	for {
+		i, ok := m.held[m.next]
+		if !ok {
+			return true
+		}
+		delete(m.held, m.next)
+		m.next++
+		m.counted()
+		sent := send(ctx, i, out)
+		m.cfg.Idle.Add(-1)
+		if !sent {
+			return false
+		}
+	}
+}
+
+// counted reports the number of inputs held to the func set by WithPendingCount
+func (m *orderedMerger) counted() {
+	if m.cfg.pendingCount != nil {
+		m.cfg.pendingCount(len(m.held))
+	}
+}
+
+// Stamped is an input numbered by Stamp
+type Stamped struct {
+	Seq   uint64
+	Value interface{}
+}
+
+// Stamp numbers the inputs of the `in <-chan interface{}` from 0 in the order they arrive, and sends each of them to the out chan as a Stamped,
+// so that MergeOrdered can put them back in order after they were split for parallel processing:
+//
+//	stamped := pipeline.Stamp(ctx, in)
+//	outs := make([]<-chan interface{}, 0, 4)
+//	for _, branch := range pipeline.FanOut(ctx, stamped, 4) {
+//		outs = append(outs, pipeline.Process(ctx, pipeline.WithStamps(p), branch))
+//	}
+//	out := pipeline.MergeOrdered(ctx, pipeline.StampedSeq, outs...)
+//
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// The out chan is closed when the `in <-chan interface{}` is closed.
+func Stamp(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var seq uint64
+		for i := range in {
+			if !send(ctx, Stamped{Seq: seq, Value: i}, out) {
+				discard(in)
+				return
+			}
+			seq++
+		}
+	}()
+	return out
+}
+
+// StampedSeq returns the sequence number of a Stamped, to be passed to MergeOrdered
+func StampedSeq(i interface{}) uint64 {
+	return i.(Stamped).Seq
+}
+
+// WithStamps creates a Processor that processes the Value of each Stamped with `p`,
+// and stamps the output with the sequence number of the input.
+// The Value is passed to `Processor.Cancel` rather than the Stamped.
+// Since an input that fails leaves a gap in the sequence, use WithGapTimeout with MergeOrderedWith to skip it.
+func WithStamps(p Processor) Processor {
+	return &stampedProcessor{processor: p}
+}
+
+// stampedProcessor implements Processor
+type stampedProcessor struct {
+	processor Processor
+}
+
+func (s *stampedProcessor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	stamped := i.(Stamped)
+	out, err := s.processor.Process(ctx, stamped.Value)
+	if err != nil {
+		return nil, err
+	}
+	return Stamped{Seq: stamped.Seq, Value: out}, nil
+}
+
+func (s *stampedProcessor) Cancel(i interface{}, err error) {
+	s.processor.Cancel(unstamp(i), err)
+}
+
+func (s *stampedProcessor) CancelContext(ctx context.Context, i interface{}, err error) {
+	cancelContext(ctx, s.processor, unstamp(i), err)
+}
+
+// unstamp returns the Value of i if it is a Stamped
+func unstamp(i interface{}) interface{} {
+	if stamped, ok := i.(Stamped); ok {
+		return stamped.Value
+	}
+	return i
+}
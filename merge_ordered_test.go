@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeOrdered(t *testing.T) {
+	seqs := func(is ...interface{}) <-chan interface{} {
+		return Emit(is...)
+	}
+	collect := func(out <-chan interface{}) []interface{} {
+		var got []interface{}
+		for i := range out {
+			got = append(got, i)
+		}
+		return got
+	}
+	seqOf := func(i interface{}) uint64 {
+		return uint64(i.(int))
+	}
+
+	t.Run("the inputs are sent in sequence", func(t *testing.T) {
+		out := MergeOrdered(context.Background(), seqOf, seqs(1, 3, 4), seqs(0, 2, 5))
+		want := []interface{}{0, 1, 2, 3, 4, 5}
+		if got := collect(out); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("a missing sequence number is skipped after the gap timeout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := make(chan interface{})
+		type gap struct{ first, last uint64 }
+		skipped := make(chan gap, 1)
+		out := MergeOrderedWith(ctx, seqOf, []Option{WithGapTimeout(10*time.Millisecond, func(first, last uint64) {
+			skipped <- gap{first, last}
+		})}, in)
+		in <- 0
+		if got := <-out; got != 0 {
+			t.Fatalf("got %v, want 0", got)
+		}
+		in <- 3
+		if got := <-out; got != 3 {
+			t.Fatalf("got %v, want 3", got)
+		}
+		if got, want := <-skipped, (gap{1, 2}); got != want {
+			t.Errorf("skipped %v, want %v", got, want)
+		}
+		close(in)
+		if _, open := <-out; open {
+			t.Errorf("the out chan is open")
+		}
+	})
+
+	t.Run("late and duplicate inputs are dropped", func(t *testing.T) {
+		var late []interface{}
+		out := MergeOrderedWith(context.Background(), seqOf, []Option{
+			WithLateItems(func(i interface{}) { late = append(late, i) }),
+		}, seqs(0, 1, 0, 3, 3))
+		if got, want := collect(out), []interface{}{0, 1, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if want := []interface{}{0, 3}; !reflect.DeepEqual(late, want) {
+			t.Errorf("late %v, want %v", late, want)
+		}
+	})
+
+	t.Run("the inputs held are bounded by the max pending", func(t *testing.T) {
+		var max int
+		out := MergeOrderedWith(context.Background(), seqOf, []Option{
+			WithMaxPending(2), WithPendingCount(func(pending int) {
+				if pending > max {
+					max = pending
+				}
+			}),
+		}, seqs(1, 2, 3, 4, 0, 5))
+		if got, want := collect(out), []interface{}{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if max > 3 {
+			t.Errorf("held up to %d inputs, want at most 3", max)
+		}
+	})
+
+	t.Run("the stamps put a split stream back in order", func(t *testing.T) {
+		ctx := context.Background()
+		double := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			// Make the later inputs overtake the earlier ones
+			time.Sleep(time.Duration(10-i.(int)) * time.Millisecond)
+			if i == 7 {
+				return nil, errors.New("failed")
+			}
+			return i.(int) * 2, nil
+		}, func(interface{}, error) {})
+		var outs []<-chan interface{}
+		for _, branch := range FanOut(ctx, Stamp(ctx, Emit(0, 1, 2, 3, 4, 5, 6, 7, 8, 9)), 4) {
+			outs = append(outs, Process(ctx, WithStamps(double), branch))
+		}
+		var got []interface{}
+		for i := range MergeOrderedWith(ctx, StampedSeq, []Option{WithGapTimeout(time.Second, nil)}, outs...) {
+			got = append(got, i.(Stamped).Value)
+		}
+		if want := []interface{}{0, 2, 4, 6, 8, 10, 12, 16, 18}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("the inputs are dropped when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := MergeOrdered(ctx, seqOf, in)
+		in <- 1
+		cancel()
+		in <- 2
+		close(in)
+		if o, open := <-out; open {
+			t.Errorf("out sent %v, want it closed", o)
+		}
+	})
+
+	t.Run("Stamp drains its inputs once the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := Stamp(ctx, in)
+		cancel()
+		// out is never read, so the inputs are only sent if Stamp drains them
+		for i := 0; i < 3; i++ {
+			in <- i
+		}
+		close(in)
+		for range out {
+		}
+	})
+}
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"testing"
 	"time"
 )
@@ -173,3 +174,38 @@ func TestMerge(t *testing.T) {
 	}
 
 }
+
+// TestMergeConcurrentProducers makes sure that every input from producers of very different speeds
+// is received exactly once. Run it with the race detector.
+func TestMergeConcurrentProducers(t *testing.T) {
+	produce := func(start, count int, interval time.Duration) <-chan interface{} {
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			for i := start; i < start+count; i++ {
+				time.Sleep(interval)
+				out <- i
+			}
+		}()
+		return out
+	}
+
+	var outs []int
+	for o := range Merge(
+		produce(0, 100, 0),
+		produce(100, 20, time.Millisecond),
+		produce(120, 5, 10*time.Millisecond),
+	) {
+		outs = append(outs, o.(int))
+	}
+
+	if len(outs) != 125 {
+		t.Fatalf("len(out) = %d, want 125", len(outs))
+	}
+	sort.Ints(outs)
+	for i, o := range outs {
+		if i != o {
+			t.Fatalf("out[%d] = %d, want %d", i, o, i)
+		}
+	}
+}
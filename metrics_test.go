@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithMetrics(t *testing.T) {
+	// failOn fails to process the input n
+	failOn := func(n int) Processor {
+		return ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			if i == n {
+				return nil, errProcess
+			}
+			return i, nil
+		})
+	}
+
+	t.Run("Process reports each input", func(t *testing.T) {
+		m := &MemoryMetrics{}
+		for range Process(context.Background(), failOn(3), Emit(1, 2, 3, 4, 5), WithMetrics("enrich", m)) {
+		}
+		want := StageMetrics{Received: 5, Emitted: 4, Canceled: 1, Processed: 5}
+		if got := m.Stage("enrich"); got.Received != want.Received || got.Emitted != want.Emitted || got.Canceled != want.Canceled || got.Processed != want.Processed {
+			t.Errorf("enrich = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("ProcessConcurrently reports each input under its own stage", func(t *testing.T) {
+		m := &MemoryMetrics{}
+		slow := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			time.Sleep(time.Millisecond)
+			return i, nil
+		})
+		out := ProcessConcurrently(context.Background(), 4, slow, emitN(100), WithMetrics("slow", m))
+		for range Process(context.Background(), noopProcessor, out, WithMetrics("fast", m)) {
+		}
+		for _, stage := range []string{"slow", "fast"} {
+			if got := m.Stage(stage); got.Received != 100 || got.Emitted != 100 || got.Canceled != 0 || got.Processed != 100 {
+				t.Errorf("%s = %+v, want 100 received, emitted and processed", stage, got)
+			}
+		}
+		if got := m.Stage("slow").ProcessTime; got < 100*time.Millisecond {
+			t.Errorf("slow process time = %s, want >= 100ms", got)
+		}
+	})
+
+	t.Run("inputs canceled by the context are reported", func(t *testing.T) {
+		m := &MemoryMetrics{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for range Process(ctx, noopProcessor, Emit(1, 2, 3), WithMetrics("canceled", m)) {
+		}
+		if got := m.Stage("canceled"); got.Received != 3 || got.Canceled != 3 || got.Emitted != 0 || got.Processed != 0 {
+			t.Errorf("canceled = %+v, want 3 received and canceled", got)
+		}
+	})
+
+	t.Run("ProcessBatch reports each batch and each result", func(t *testing.T) {
+		m := &MemoryMetrics{}
+		p := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			return i, nil
+		})
+		for range ProcessBatch(context.Background(), 2, time.Second, p, Emit(1, 2, 3, 4, 5), WithMetrics("batch", m)) {
+		}
+		if got := m.Stage("batch"); got.Received != 3 || got.Emitted != 5 || got.Canceled != 0 || got.Processed != 3 {
+			t.Errorf("batch = %+v, want 3 batches received and processed, and 5 results emitted", got)
+		}
+	})
+
+	// slowly sends 5 inputs 10ms apart
+	slowly := func() <-chan interface{} {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 0; i < 5; i++ {
+				time.Sleep(10 * time.Millisecond)
+				in <- i
+			}
+		}()
+		return in
+	}
+	for _, stage := range []struct {
+		name  string
+		stage func(in <-chan interface{}, m *MemoryMetrics) <-chan interface{}
+	}{{
+		name: "Process",
+		stage: func(in <-chan interface{}, m *MemoryMetrics) <-chan interface{} {
+			return Process(context.Background(), noopProcessor, in, WithMetrics("stage", m))
+		},
+	}, {
+		name: "ProcessConcurrently",
+		stage: func(in <-chan interface{}, m *MemoryMetrics) <-chan interface{} {
+			return ProcessConcurrently(context.Background(), 2, noopProcessor, in, WithMetrics("stage", m))
+		},
+	}, {
+		name: "ProcessBatch",
+		stage: func(in <-chan interface{}, m *MemoryMetrics) <-chan interface{} {
+			p := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+				return i, nil
+			})
+			return ProcessBatch(context.Background(), 1, time.Second, p, in, WithMetrics("stage", m))
+		},
+	}} {
+		stage := stage
+		t.Run(stage.name+" reports the waits for its inputs", func(t *testing.T) {
+			m := &MemoryMetrics{}
+			for range stage.stage(slowly(), m) {
+			}
+			if got := m.Stage("stage"); got.ReceiveBlocked < 40*time.Millisecond || got.Starved() < 0.5 || got.Backpressured() > 0.5 {
+				t.Errorf("stage = %+v, starved %f and backpressured %f, want it starved", got, got.Starved(), got.Backpressured())
+			}
+		})
+		t.Run(stage.name+" reports the waits for its out chan", func(t *testing.T) {
+			m := &MemoryMetrics{}
+			for range stage.stage(emitN(5), m) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if got := m.Stage("stage"); got.SendBlocked < 30*time.Millisecond || got.Backpressured() < 0.5 || got.Starved() > 0.5 {
+				t.Errorf("stage = %+v, starved %f and backpressured %f, want it backpressured", got, got.Starved(), got.Backpressured())
+			}
+		})
+	}
+
+	t.Run("a stage that reported no time is neither starved nor backpressured", func(t *testing.T) {
+		var s StageMetrics
+		if s.Starved() != 0 || s.Backpressured() != 0 {
+			t.Errorf("starved %f and backpressured %f, want 0", s.Starved(), s.Backpressured())
+		}
+	})
+}
+
+func BenchmarkWithMetrics(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{{
+		name: "none",
+	}, {
+		name: "noop",
+		opts: []Option{WithMetrics("noop", NoopMetrics{})},
+	}, {
+		name: "memory",
+		opts: []Option{WithMetrics("memory", &MemoryMetrics{})},
+	}} {
+		bench := bench
+		b.Run(bench.name, func(b *testing.B) {
+			for range Process(context.Background(), noopProcessor, emitN(b.N), bench.opts...) {
+			}
+		})
+	}
+}
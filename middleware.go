@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Middleware wraps a Processor with a cross-cutting behavior, such as retries, timeouts or tracing,
+// like an http middleware wraps an http.Handler.
+// The Processors of Retrying, Timeout, Tracing and Fallback keep the optional interfaces of the Processors they wrap:
+// they set up and tear down the wrapped Processors, clone them for each worker if they are WorkerCloners,
+// and pass the batches of canceled inputs to them if they are BatchCancelers.
+type Middleware func(p Processor) Processor
+
+// Chain composes `mw` into a single Middleware that applies them outermost first:
+// Chain(a, b, c)(p) is a(b(c(p))), so a sees each input first and each result last.
+//
+//	p := pipeline.Chain(
+//		pipeline.Tracing(tracer, "enrich"),
+//		pipeline.Retrying(3, pipeline.ExponentialBackoff(10*time.Millisecond, time.Second)),
+//		pipeline.Timeout(time.Second),
+//	)(enricher)
+//
+// The order matters: above, each of the 3 attempts gets its own second and the span covers all of them,
+// while with Timeout before Retrying, the 3 attempts would share a single second.
+func Chain(mw ...Middleware) Middleware {
+	return func(p Processor) Processor {
+		for k := len(mw) - 1; k >= 0; k-- {
+			p = mw[k](p)
+		}
+		return p
+	}
+}
+
+// Retrying is the Middleware of Retry
+func Retrying(attempts int, backoff BackoffStrategy) Middleware {
+	return RetryingIf(attempts, backoff, nil)
+}
+
+// RetryingIf is the Middleware of RetryIf
+func RetryingIf(attempts int, backoff BackoffStrategy, retryable func(err error) bool) Middleware {
+	return func(p Processor) Processor {
+		return RetryIf(attempts, backoff, retryable, p)
+	}
+}
+
+// Timeout is the Middleware of WithTimeout
+func Timeout(timeout time.Duration) Middleware {
+	return func(p Processor) Processor {
+		return WithTimeout(timeout, p)
+	}
+}
+
+// Tracing is the Middleware of WithTracing
+func Tracing(tracer Tracer, stage string) Middleware {
+	return func(p Processor) Processor {
+		return WithTracing(tracer, stage, p)
+	}
+}
+
+// middleware is the Processor of a Middleware, which forwards the optional interfaces of the Processors it wraps
+type middleware interface {
+	Processor
+	ContextCanceler
+	Setupper
+	Teardowner
+	WorkerCloner
+}
+
+// batchCanceling is a middleware that wraps a BatchCanceler
+type batchCanceling struct {
+	middleware
+	cancelBatch func(is []interface{}, err error)
+}
+
+func (b *batchCanceling) CancelBatch(is []interface{}, err error) {
+	b.cancelBatch(is, err)
+}
+
+// forwardBatches returns m as a BatchCanceler that passes the batches to `cancelBatch` if `wrapped` is a BatchCanceler, or m otherwise
+func forwardBatches(m middleware, wrapped Processor, cancelBatch func(bc BatchCanceler, is []interface{}, err error)) Processor {
+	bc, ok := wrapped.(BatchCanceler)
+	if !ok {
+		return m
+	}
+	return &batchCanceling{middleware: m, cancelBatch: func(is []interface{}, err error) {
+		cancelBatch(bc, is, err)
+	}}
+}
+
+// passBatch passes the batch to bc as it is
+func passBatch(bc BatchCanceler, is []interface{}, err error) {
+	bc.CancelBatch(is, err)
+}
+
+// setupWrapped calls `Setupper.Setup` if p implements it
+func setupWrapped(ctx context.Context, p Processor) error {
+	if s, ok := p.(Setupper); ok {
+		return s.Setup(ctx)
+	}
+	return nil
+}
+
+// teardownWrapped calls `Teardowner.Teardown` if p implements it
+func teardownWrapped(p Processor) error {
+	if t, ok := p.(Teardowner); ok {
+		return t.Teardown()
+	}
+	return nil
+}
+
+// cloneWrapped returns `WorkerCloner.CloneForWorker` if p implements it and can be cloned
+func cloneWrapped(p Processor) (Processor, bool) {
+	c, ok := p.(WorkerCloner)
+	if !ok {
+		return nil, false
+	}
+	clone := c.CloneForWorker()
+	return clone, clone != nil
+}
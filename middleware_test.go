@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
+)
+
+func TestChain(t *testing.T) {
+	t.Run("applies the middleware outermost first", func(t *testing.T) {
+		var calls []string
+		record := func(name string) Middleware {
+			return func(p Processor) Processor {
+				return ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+					calls = append(calls, name+" in")
+					defer func() { calls = append(calls, name+" out") }()
+					return p.Process(ctx, i)
+				})
+			}
+		}
+		p := Chain(record("a"), record("b"))(ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			calls = append(calls, "p")
+			return i, nil
+		}))
+		if _, err := p.Process(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"a in", "b in", "p", "b out", "a out"}; !reflect.DeepEqual(calls, want) {
+			t.Errorf("calls = %v, want %v", calls, want)
+		}
+	})
+
+	t.Run("without middleware it returns the processor", func(t *testing.T) {
+		if p := Chain()(noopProcessor); reflect.ValueOf(p).Pointer() != reflect.ValueOf(noopProcessor).Pointer() {
+			t.Errorf("Chain()(p) = %v, want p", p)
+		}
+	})
+
+	// hangsOnce blocks its first call until its context is done, and returns right away after that
+	hangsOnce := func() Processor {
+		var calls int32
+		return ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return i, nil
+		})
+	}
+	for _, test := range []struct {
+		name    string
+		chain   Middleware
+		wantErr error
+	}{{
+		name:  "Retrying outside Timeout gives each attempt a fresh deadline",
+		chain: Chain(Retrying(2, ConstantBackoff(time.Millisecond)), Timeout(20*time.Millisecond)),
+	}, {
+		name:    "Retrying inside Timeout shares the deadline between the attempts",
+		chain:   Chain(Timeout(20*time.Millisecond), Retrying(2, ConstantBackoff(time.Millisecond))),
+		wantErr: context.DeadlineExceeded,
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			out, err := test.chain(hangsOnce()).Process(context.Background(), 1)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && out != 1 {
+				t.Errorf("out = %v, want 1", out)
+			}
+		})
+	}
+}
+
+// nopTracer starts spans that do nothing
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+// nopSpan is a span of nopTracer
+type nopSpan struct{}
+
+func (nopSpan) End(error) {}
+
+func TestMiddlewareOptionalInterfaces(t *testing.T) {
+	inputs := []interface{}{1, 2, 3, 4, 5, 6, 7, 8}
+	middlewares := map[string]Middleware{
+		"Retrying": Retrying(2, ConstantBackoff(time.Millisecond)),
+		"Timeout":  Timeout(time.Second),
+		"Tracing":  Tracing(nopTracer{}, "stage"),
+		"Fallback": func(p Processor) Processor {
+			return Fallback(p, p)
+		},
+		"Chain": Chain(Tracing(nopTracer{}, "stage"), Retrying(2, ConstantBackoff(time.Millisecond)), Timeout(time.Second)),
+	}
+	for name, mw := range middlewares {
+		mw := mw
+		for _, clone := range []bool{false, true} {
+			clone := clone
+			t.Run(fmt.Sprintf("%s sets up, tears down and clones the processor it wraps, clone=%v", name, clone), func(t *testing.T) {
+				events := &lifecycleEvents{}
+				recorder := pipelinetest.NewProcessor()
+				var p Processor = &lifecycleProcessor{Processor: recorder, events: events}
+				// Each worker has its own processor if it can be cloned
+				want := 1
+				if clone {
+					p, want = clonedProcessor{p.(*lifecycleProcessor)}, 4
+				}
+				if name == "Fallback" {
+					// The primary and the secondary are set up and torn down
+					want *= 2
+				}
+				var outs []interface{}
+				for o := range ProcessConcurrently(context.Background(), 4, mw(p), Emit(inputs...)) {
+					outs = append(outs, o)
+				}
+				pipelinetest.AssertAllItemsAccountedFor(t, inputs, outs, pipelinetest.Inputs(recorder.Canceled()))
+				events.mu.Lock()
+				defer events.mu.Unlock()
+				if events.setups != want || events.teardowns != want {
+					t.Errorf("setups = %d, teardowns = %d, want %d", events.setups, events.teardowns, want)
+				}
+				if events.processedBeforeSetup {
+					t.Error("an input was processed by a processor that was not set up, or already torn down")
+				}
+			})
+		}
+
+		t.Run(name+" passes the batches of canceled inputs to the processor it wraps", func(t *testing.T) {
+			ctx, inputs, in := canceledInputs(10)
+			p := &batchCanceler{}
+			for range Process(ctx, mw(p), in) {
+			}
+			var canceled []interface{}
+			for _, batch := range p.batches {
+				canceled = append(canceled, batch...)
+			}
+			if len(p.canceled) != 0 || !reflect.DeepEqual(inputs, canceled) {
+				t.Errorf("batches = %v, canceled = %v, want the inputs %v in batches", p.batches, p.canceled, inputs)
+			}
+		})
+	}
+
+	t.Run("a processor that is not a BatchCanceler is not made one", func(t *testing.T) {
+		if _, ok := Retrying(2, ConstantBackoff(time.Millisecond))(noopProcessor).(BatchCanceler); ok {
+			t.Error("the processor of Retrying is a BatchCanceler")
+		}
+	})
+}
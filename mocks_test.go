@@ -2,18 +2,25 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
-// mockProcess is a mock of the Processor interface
+// errProcess is wrapped by the errors of the mock processor
+var errProcess = errors.New("process error")
+
+// mockProcess is a mock of the Processor interface.
+// It is safe for concurrent use, so that the concurrent stages can share it.
 type mockProcessor struct {
+	mu                 sync.Mutex
 	processDuration    time.Duration
 	cancelDuration     time.Duration
 	processReturnsErrs bool
 	processed          []interface{}
 	canceled           []interface{}
-	errs               []interface{}
+	errs               []error
 }
 
 // Process waits processDuration before returning its input as its output
@@ -25,8 +32,10 @@ func (m *mockProcessor) Process(ctx context.Context, i interface{}) (interface{}
 		break
 	}
 	if m.processReturnsErrs {
-		return nil, fmt.Errorf("process error: %d", i)
+		return nil, fmt.Errorf("%w: %d", errProcess, i)
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.processed = append(m.processed, i)
 	return i, nil
 }
@@ -34,8 +43,18 @@ func (m *mockProcessor) Process(ctx context.Context, i interface{}) (interface{}
 // Cancel collects all inputs that were canceled in m.canceled
 func (m *mockProcessor) Cancel(i interface{}, err error) {
 	time.Sleep(m.cancelDuration)
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.canceled = append(m.canceled, i)
-	m.errs = append(m.errs, err.Error())
+	m.errs = append(m.errs, err)
+}
+
+// canceledSoFar returns copies of the inputs canceled so far and of their errors,
+// which can be read while the stage is still running
+func (m *mockProcessor) canceledSoFar() ([]interface{}, []error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]interface{}(nil), m.canceled...), append([]error(nil), m.errs...)
 }
 
 // containsAll returns true if a and b contain all of the same elements
@@ -57,3 +76,37 @@ func containsAll(a, b []interface{}) bool {
 	}
 	return true
 }
+
+// errorsMatch returns true if each error in got matches the error at the same index in want with errors.Is
+func errorsMatch(want, got []error) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for k := range want {
+		if !errors.Is(got[k], want[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// errorsMatchAll returns true if each error in got matches a different error in want with errors.Is, in any order
+func errorsMatchAll(want, got []error) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	used := make([]bool, len(want))
+	for _, err := range got {
+		found := false
+		for k := range want {
+			if !used[k] && errors.Is(err, want[k]) {
+				used[k], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+// Package nats connects pipelines to NATS JetStream through small interfaces,
+// which the pull consumers and messages of the nats.go jetstream package already match closely.
+//
+// Source emits the messages of a pull consumer as pipeline.Tracked inputs, acknowledges them once they leave the pipeline,
+// and subscribes again when the connection fails. Sink publishes the outputs of a pipeline to a subject:
+//
+//	msgs := nats.Source(ctx, subscriber, nats.WithMaxInFlight(64))
+//	out := pipeline.Untrack(ctx, pipeline.TrackedProcess(ctx, p, msgs, pipeline.WithConcurrency(64)))
+//	err := nats.Sink(ctx, publisher, "results", out)
+package nats
+
+import (
+	"context"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Msg is a message of a JetStream pull consumer
+type Msg interface {
+	Subject() string
+	Data() []byte
+	// Ack acknowledges the message so that it is not delivered again
+	Ack() error
+	// Nak tells the server to deliver the message again
+	Nak() error
+}
+
+// Consumer is a subscription of a JetStream pull consumer
+type Consumer interface {
+	// Fetch returns up to `max` messages, or none if there are none before its wait time is over.
+	// An error means that the subscription is broken and must be replaced.
+	Fetch(ctx context.Context, max int) ([]Msg, error)
+}
+
+// Subscriber subscribes to a JetStream pull consumer, which it does again each time the Consumer fails
+type Subscriber interface {
+	Subscribe(ctx context.Context) (Consumer, error)
+}
+
+// Publisher publishes messages to NATS
+type Publisher interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+}
+
+// Option configures Source
+type Option func(*config)
+
+// config holds the settings of Source
+type config struct {
+	maxInFlight int
+	backoff     pipeline.BackoffStrategy
+	errored     func(err error)
+}
+
+// newConfig applies opts to the default config
+func newConfig(opts []Option) *config {
+	c := &config{
+		maxInFlight: 100,
+		backoff:     pipeline.ExponentialBackoff(100*time.Millisecond, 10*time.Second),
+		errored:     func(error) {},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithMaxInFlight sets how many messages Source lets into the pipeline before they are acknowledged, which is 100 by default.
+// Setting it to the concurrency of the pipeline keeps every worker busy without holding messages that no worker can take.
+func WithMaxInFlight(n int) Option {
+	return func(c *config) {
+		c.maxInFlight = n
+	}
+}
+
+// WithReconnectBackoff sets how long Source waits before subscribing again after a failure.
+// The default is an exponential backoff from 100ms to 10s.
+func WithReconnectBackoff(backoff pipeline.BackoffStrategy) Option {
+	return func(c *config) {
+		c.backoff = backoff
+	}
+}
+
+// WithErrors passes the errors of the connection, and of acknowledging messages, to `errored`, which must not block
+func WithErrors(errored func(err error)) Option {
+	return func(c *config) {
+		c.errored = errored
+	}
+}
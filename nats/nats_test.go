@@ -0,0 +1,227 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// msg is a Msg that records how it was acknowledged
+type msg struct {
+	data   string
+	server *server
+}
+
+func (m *msg) Subject() string { return "in" }
+func (m *msg) Data() []byte    { return []byte(m.data) }
+
+func (m *msg) Ack() error {
+	m.server.record(&m.server.acked, m.data)
+	return nil
+}
+
+func (m *msg) Nak() error {
+	m.server.record(&m.server.nacked, m.data)
+	return nil
+}
+
+// server is an in-memory JetStream that implements Subscriber, Consumer and Publisher
+type server struct {
+	mu           sync.Mutex
+	pending      []string
+	subscribes   int
+	fetches      []int
+	failFetches  int
+	acked        []string
+	nacked       []string
+	published    []string
+	publishError error
+}
+
+func newServer(n int) *server {
+	s := &server{}
+	for i := 0; i < n; i++ {
+		s.pending = append(s.pending, fmt.Sprint(i))
+	}
+	return s
+}
+
+func (s *server) record(to *[]string, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*to = append(*to, data)
+}
+
+func (s *server) Subscribe(context.Context) (Consumer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribes++
+	return s, nil
+}
+
+func (s *server) Fetch(ctx context.Context, max int) ([]Msg, error) {
+	s.mu.Lock()
+	s.fetches = append(s.fetches, max)
+	if s.failFetches > 0 {
+		s.failFetches--
+		s.mu.Unlock()
+		return nil, errors.New("connection closed")
+	}
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond):
+			return nil, nil
+		}
+	}
+	defer s.mu.Unlock()
+	if max > len(s.pending) {
+		max = len(s.pending)
+	}
+	var msgs []Msg
+	for _, data := range s.pending[:max] {
+		msgs = append(msgs, &msg{data: data, server: s})
+	}
+	s.pending = s.pending[max:]
+	return msgs, nil
+}
+
+func (s *server) Publish(_ context.Context, _ string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.publishError != nil {
+		return s.publishError
+	}
+	s.published = append(s.published, string(data))
+	return nil
+}
+
+func TestSource(t *testing.T) {
+	t.Run("no more than the max in flight messages are emitted before they are acknowledged", func(t *testing.T) {
+		s := newServer(10)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Source(ctx, s, WithMaxInFlight(3))
+		var ts []pipeline.Tracked
+		for n := 0; n < 3; n++ {
+			ts = append(ts, (<-out).(pipeline.Tracked))
+		}
+		select {
+		case o := <-out:
+			t.Fatalf("%v was emitted while 3 messages were in flight", o)
+		case <-time.After(20 * time.Millisecond):
+		}
+		ts[0].Ack(nil)
+		ts[1].Ack(errors.New("failed"))
+		for n := 0; n < 2; n++ {
+			ts = append(ts, (<-out).(pipeline.Tracked))
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if want := []string{"0"}; !reflect.DeepEqual(want, s.acked) {
+			t.Errorf("acked = %v, want %v", s.acked, want)
+		}
+		if want := []string{"1"}; !reflect.DeepEqual(want, s.nacked) {
+			t.Errorf("nacked = %v, want %v", s.nacked, want)
+		}
+		for _, max := range s.fetches {
+			if max > 3 {
+				t.Errorf("fetched %d messages at once, want at most 3", max)
+			}
+		}
+	})
+
+	t.Run("subscribes again after a fetch fails", func(t *testing.T) {
+		s := newServer(2)
+		s.failFetches = 2
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var mu sync.Mutex
+		var errs []error
+		out := Source(ctx, s,
+			WithReconnectBackoff(pipeline.ConstantBackoff(time.Millisecond)),
+			WithErrors(func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+			}))
+		for n := 0; n < 2; n++ {
+			(<-out).(pipeline.Tracked).Ack(nil)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.subscribes != 3 {
+			t.Errorf("subscribes = %d, want 3", s.subscribes)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(errs) != 2 {
+			t.Errorf("errs = %v, want 2 errors", errs)
+		}
+	})
+
+	t.Run("every message is acknowledged once through a pipeline", func(t *testing.T) {
+		s := newServer(100)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		identity := pipeline.ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			return i, nil
+		})
+		out := pipeline.Untrack(ctx, pipeline.TrackedProcess(ctx, identity, Source(ctx, s, WithMaxInFlight(8)), pipeline.WithConcurrency(8)))
+		for n := 0; n < 100; n++ {
+			<-out
+		}
+		// The last message is acknowledged once it has been received
+		acked := func() int {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.acked)
+		}
+		for start := time.Now(); acked() < 100 && time.Since(start) < time.Second; {
+			time.Sleep(time.Millisecond)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.acked) != 100 || len(s.nacked) != 0 {
+			t.Errorf("acked %d and nacked %d, want 100 and 0", len(s.acked), len(s.nacked))
+		}
+	})
+}
+
+func TestSink(t *testing.T) {
+	t.Run("publishes each input and acknowledges the tracked ones", func(t *testing.T) {
+		s := newServer(0)
+		var acks []error
+		in := pipeline.Emit([]byte("a"), "b", pipeline.Tracked{Value: "c", Ack: func(err error) {
+			acks = append(acks, err)
+		}})
+		if err := Sink(context.Background(), s, "out", in); err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		if want := []string{"a", "b", "c"}; !reflect.DeepEqual(want, s.published) {
+			t.Errorf("published = %v, want %v", s.published, want)
+		}
+		if want := []error{nil}; !reflect.DeepEqual(want, acks) {
+			t.Errorf("acks = %v, want %v", acks, want)
+		}
+	})
+
+	t.Run("returns the first error", func(t *testing.T) {
+		s := newServer(0)
+		s.publishError = errors.New("no responders")
+		if err := Sink(context.Background(), s, "out", pipeline.Emit("a", "b")); err != s.publishError {
+			t.Errorf("err = %v, want %v", err, s.publishError)
+		}
+		if err := Sink(context.Background(), newServer(0), "out", pipeline.Emit(1)); err == nil {
+			t.Error("err = nil, want an error for an int")
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Sink publishes each input from the `in <-chan interface{}` to `subject` with `p`.
+// Each input must be a []byte or a string, or a pipeline.Tracked of one, which is acknowledged once it is published.
+// Sink returns the first error of `p`, or the `Context.Err()` if the context is canceled, like pipeline.ForEach.
+func Sink(ctx context.Context, p Publisher, subject string, in <-chan interface{}) error {
+	return pipeline.ForEach(ctx, in, func(i interface{}) error {
+		ack := func(error) {}
+		if t, ok := i.(pipeline.Tracked); ok {
+			ack, i = t.Ack, t.Value
+		}
+		var err error
+		switch data := i.(type) {
+		case []byte:
+			err = p.Publish(ctx, subject, data)
+		case string:
+			err = p.Publish(ctx, subject, []byte(data))
+		default:
+			err = fmt.Errorf("nats: cannot publish a %T, want a []byte or a string", i)
+		}
+		ack(err)
+		return err
+	})
+}
@@ -0,0 +1,118 @@
+package nats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Source subscribes with `s` and emits the messages it fetches as a pipeline.Tracked whose `Value` is a Msg.
+// At most the number of messages of WithMaxInFlight are emitted and not yet acknowledged at any time,
+// so Source stops fetching while the pipeline is busy.
+// A message that is acknowledged with nil, which pipeline.Untrack does when it leaves the pipeline, is acked,
+// and one that is acknowledged with an error is nacked so that it is delivered again.
+//
+// When subscribing or fetching fails, the error is passed to the func of WithErrors and Source subscribes again after a backoff,
+// while the messages in flight stay tracked. Source stops and closes the out `<-chan interface{}` when the context is canceled.
+func Source(ctx context.Context, s Subscriber, opts ...Option) <-chan interface{} {
+	cfg := newConfig(opts)
+	out := make(chan interface{})
+	// slots holds a token for each message that can be let into the pipeline
+	slots := make(chan struct{}, cfg.maxInFlight)
+	for n := 0; n < cfg.maxInFlight; n++ {
+		slots <- struct{}{}
+	}
+	go func() {
+		defer close(out)
+		var consumer Consumer
+		for failures := 0; ctx.Err() == nil; {
+			if consumer == nil {
+				c, err := s.Subscribe(ctx)
+				if err != nil {
+					failures = fail(ctx, cfg, err, failures)
+					continue
+				}
+				consumer = c
+			}
+			// Wait for a free slot, then take every other free slot
+			select {
+			case <-slots:
+			case <-ctx.Done():
+				return
+			}
+			free := 1
+			for ; free < cfg.maxInFlight && len(slots) > 0; free++ {
+				<-slots
+			}
+			msgs, err := consumer.Fetch(ctx, free)
+			if err != nil {
+				release(slots, free)
+				if ctx.Err() == nil {
+					consumer = nil
+					failures = fail(ctx, cfg, err, failures)
+				}
+				continue
+			}
+			failures = 0
+			// The slots that were not used are freed straight away
+			release(slots, free-len(msgs))
+			for n, msg := range msgs {
+				select {
+				case out <- track(cfg, msg, slots):
+				case <-ctx.Done():
+					// The messages that were not emitted are delivered again
+					for _, msg := range msgs[n:] {
+						nak(cfg, msg)
+					}
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// track returns msg as a Tracked that acks or nacks it, then frees its slot.
+// Only its first acknowledgement counts, so that a slot is never freed twice.
+func track(cfg *config, msg Msg, slots chan<- struct{}) pipeline.Tracked {
+	var once sync.Once
+	return pipeline.Tracked{Value: msg, Ack: func(err error) {
+		once.Do(func() {
+			if err != nil {
+				nak(cfg, msg)
+			} else if err := msg.Ack(); err != nil {
+				cfg.errored(err)
+			}
+			slots <- struct{}{}
+		})
+	}}
+}
+
+// nak nacks msg and reports the error if it fails
+func nak(cfg *config, msg Msg) {
+	if err := msg.Nak(); err != nil {
+		cfg.errored(err)
+	}
+}
+
+// release frees n slots
+func release(slots chan<- struct{}, n int) {
+	for ; n > 0; n-- {
+		slots <- struct{}{}
+	}
+}
+
+// fail reports err and waits for the backoff of the next failure, whose number it returns
+func fail(ctx context.Context, cfg *config, err error, failures int) int {
+	cfg.errored(err)
+	failures++
+	timer := time.NewTimer(cfg.backoff(failures))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return failures
+}
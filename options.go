@@ -0,0 +1,235 @@
+package pipeline
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// Option configures the behavior of a stage.
+// Stages ignore the options that do not apply to them.
+type Option func(*config)
+
+// config holds the settings of a stage
+type config struct {
+	core.Config
+	overflow        OverflowPolicy
+	dropped         func(i interface{})
+	jitter          time.Duration
+	unrouted        func(i interface{})
+	unmatched       func(i interface{})
+	emptyWindows    bool
+	eventTime       func(i interface{}) time.Time
+	partialOnCancel bool
+	maxEntries      int
+	duplicates      func(i interface{})
+	coalesce        func(pending, i interface{}) interface{}
+	maxLineSize     int
+	strict          bool
+	immediate       bool
+	errored         func(err error)
+	heartbeat       time.Duration
+	quiet           time.Duration
+	newWatcher      func(dir string) (FileWatcher, error)
+	maxPending      int
+	late            func(i interface{})
+	dropEarly       bool
+	sourceCounts    func(source int, sent int64)
+	gapTimeout      time.Duration
+	skipped         func(first, last uint64)
+	pendingCount    func(pending int)
+	dropCount       func(dropped int64)
+	randSource      rand.Source
+	evictedSessions func(is []interface{})
+	recordedTiming  bool
+	gateLocked      bool
+	markBefore      bool
+	failOpen        bool
+	sharedCopy      func(o interface{}) interface{}
+}
+
+// newConfig applies opts to the default config
+func newConfig(opts []Option) *config {
+	c := &config{
+		Config:   core.DefaultConfig(),
+		overflow: Block,
+	}
+	c.CloneWorker = core.CloneWorker[Processor]
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithPanicRecovery sets whether Process and ProcessConcurrently recover from panics in `Processor.Process`.
+// Panic recovery is enabled by default: the panic is converted into a *PanicError and passed to `Processor.Cancel`
+// with the input that caused it, and the stage keeps processing the next inputs.
+// The panics in `Processor.Cancel` are recovered too, see WithCancelPanics.
+// Disable it to crash fast instead.
+func WithPanicRecovery(recover bool) Option {
+	return func(c *config) {
+		c.RecoverPanics = recover
+	}
+}
+
+// WithName names a stage, which tags its Metrics and the stalls reported by Watch
+func WithName(name string) Option {
+	return func(c *config) {
+		c.Stage = name
+	}
+}
+
+// WithConcurrency makes Process process up to `concurrency` inputs at once, like ProcessConcurrently.
+// It panics if `concurrency` is not positive, or if it conflicts with the concurrency of ProcessConcurrently.
+func WithConcurrency(concurrency int) Option {
+	return func(c *config) {
+		c.SetConcurrency(concurrency)
+	}
+}
+
+// WithOrderedOutput makes the concurrent process stages send their results in the order of their inputs,
+// like ProcessConcurrentlyOrdered.
+// It panics if it is combined with WithUnorderedOutput.
+func WithOrderedOutput() Option {
+	return func(c *config) {
+		c.SetOrdering(core.Ordered)
+	}
+}
+
+// WithUnorderedOutput makes the concurrent process stages send their results as soon as they are ready, which is the default.
+// It panics if it is combined with WithOrderedOutput or used with ProcessConcurrentlyOrdered.
+func WithUnorderedOutput() Option {
+	return func(c *config) {
+		c.SetOrdering(core.Unordered)
+	}
+}
+
+// WithBufferedOutput gives the out chan of the process stages a buffer of `size` results,
+// so their workers do not stall while the receiver is momentarily busy.
+// The results in the buffer were processed successfully, so they are still delivered after the context is canceled:
+// only the results that are waiting for room in the buffer are passed to `Processor.Cancel`.
+// It panics if `size` is negative.
+func WithBufferedOutput(size int) Option {
+	return func(c *config) {
+		c.SetOutputBuffer(size)
+	}
+}
+
+// WithCancelTimeout sets how long `ContextCanceler.CancelContext` can keep running after the stage context is done.
+// The default is 1 second.
+func WithCancelTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.CancelTimeout = timeout
+	}
+}
+
+// WithSerializedCancel makes the workers of the concurrent process stages call `Processor.Cancel` one at a time,
+// for a Processor whose Cancel method is not safe for concurrent use, such as one that writes to a map.
+// Without it, the workers may call `Processor.Cancel` at once, mostly when they drain the in chan after the context is canceled.
+func WithSerializedCancel() Option {
+	return func(c *config) {
+		c.CancelLock = &sync.Mutex{}
+	}
+}
+
+// WithCancelPanics sets the func that the panics recovered in `Processor.Cancel` are passed to,
+// as a *ProcessError that carries the input and wraps a *PanicError. Without it, they are logged by the Logger
+// set with WithLogger, if there is one. The panics are not recovered if WithPanicRecovery(false) is set.
+func WithCancelPanics(panicked func(err error)) Option {
+	return func(c *config) {
+		c.CancelPanicked = panicked
+	}
+}
+
+// WithCancelBatchSize sets the most inputs passed to `BatchCanceler.CancelBatch` at once, 100 by default.
+// A size of 1 turns the batches off, so that every input is passed to `Processor.Cancel`.
+// It panics if `size` is not positive.
+func WithCancelBatchSize(size int) Option {
+	if size < 1 {
+		panic(fmt.Sprintf("pipeline: cancel batch size must be positive, got %d", size))
+	}
+	return func(c *config) {
+		c.CancelBatchSize = size
+	}
+}
+
+// WithTeardownErrors sets the func that the errors of `Teardowner.Teardown` are passed to,
+// including its panics as a *PanicError unless WithPanicRecovery(false) is set.
+// Without it, they are logged by the Logger set with WithLogger, if there is one.
+func WithTeardownErrors(failed func(err error)) Option {
+	return func(c *config) {
+		c.TeardownFailed = failed
+	}
+}
+
+// WithGracefulStop makes the process stages stop reading new inputs once `stop` is closed, for example on SIGTERM,
+// while the inputs they are already processing get up to `grace` to finish.
+// After that their context is canceled, so they are passed to `Processor.Cancel` like on any cancellation.
+// The out chan is closed once the last of them is done. Unlike a canceled context, a stop leaves the inputs
+// that were not read yet in the in chan, so the stages before keep running until their own context is canceled.
+// It applies to Process, ProcessConcurrently and ProcessConcurrentlyOrdered.
+func WithGracefulStop(stop <-chan struct{}, grace time.Duration) Option {
+	return func(c *config) {
+		c.Stop = stop
+		c.Grace = grace
+	}
+}
+
+// WithAutoscaling sets how ProcessAutoscale scales.
+// A worker is added each time an input has waited `window` for a free worker, and a worker is retired after it has been idle for `cooldown`.
+// If `scaled` is not nil, it is called with the new number of workers after each change, which is useful for graphing them.
+// It must not block. The defaults are a window of 10ms and a cooldown of 1s.
+func WithAutoscaling(window, cooldown time.Duration, scaled func(workers int)) Option {
+	return func(c *config) {
+		c.ScaleWindow = window
+		c.ScaleCooldown = cooldown
+		c.Scaled = scaled
+	}
+}
+
+// WithLazyWorkers makes ProcessConcurrently, and Process with WithConcurrency, start a single worker at first,
+// and start another one, up to their concurrency, each time an input has waited `threshold` for a free worker.
+// The workers it starts run until the stage ends, and each one is set up as it starts, see Setupper.
+// If a Setup fails after the first one, no more workers are started, and the error is logged if the stage has a Logger.
+// Attach a WorkerMetrics with WithMetrics to follow the number of workers. It does not apply to WithOrderedOutput.
+// It panics if `threshold` is not positive.
+func WithLazyWorkers(threshold time.Duration) Option {
+	if threshold <= 0 {
+		panic(fmt.Sprintf("pipeline: lazy workers threshold must be positive, got %s", threshold))
+	}
+	return func(c *config) {
+		c.LazyWorkers = threshold
+	}
+}
+
+// drop passes i to the dropped callback if there is one
+func (c *config) drop(i interface{}) {
+	if c.dropped != nil {
+		c.dropped(i)
+	}
+}
+
+// OverflowPolicy decides what a stage does with an input when its receiver is not ready for it
+type OverflowPolicy int
+
+const (
+	// Block waits until the receiver is ready. This is the default.
+	Block OverflowPolicy = iota
+	// DropNewest drops the input that the receiver is not ready for.
+	DropNewest
+	// DropOldest makes room for the input that the receiver is not ready for by dropping the oldest buffered input.
+	// Stages that do not buffer inputs drop the newest input instead.
+	DropOldest
+)
+
+// WithOverflow sets the OverflowPolicy of a stage.
+// If `dropped` is not nil, it is called with every input that is dropped, which is useful for counting them.
+func WithOverflow(policy OverflowPolicy, dropped func(i interface{})) Option {
+	return func(c *config) {
+		c.overflow = policy
+		c.dropped = dropped
+	}
+}
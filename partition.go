@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// PartitionByKey sends each input from the `in <-chan interface{}` to one of `n` out channels, picked by hashing
+// the key that `keyFn` returns for it with 32-bit FNV-1a modulo `n`, the same way ProcessKeyed picks a worker.
+// The inputs that share a key always go to the same out channel, in the order they were read,
+// so each partition can be given its own stages.
+//
+// By default an input waits until its out channel is read, which blocks the inputs of every other partition meanwhile.
+// With WithBufferedOutput, each out channel buffers up to `size` inputs, so a slow partition only blocks the others once its buffer is full.
+// Add WithOverflow to drop the inputs of a partition whose buffer is full instead, so that it never blocks the others.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// The out channels are closed once the `in <-chan interface{}` is closed and their buffered inputs are read.
+// PartitionByKey panics if `n` is not positive.
+func PartitionByKey(ctx context.Context, n int, keyFn func(i interface{}) string, in <-chan interface{}, opts ...Option) []<-chan interface{} {
+	if n < 1 {
+		panic(fmt.Sprintf("pipeline: partitions must be positive, got %d", n))
+	}
+	return partition(ctx, n, func(i interface{}) int {
+		return core.KeyIndex(keyFn(i), n)
+	}, in, opts)
+}
+
+// Partition sends the inputs from the `in <-chan interface{}` for which `pred` returns true to the matched chan,
+// and the others to the unmatched chan, so that `pred` is evaluated once per input rather than by two Filters.
+// The inputs of each side are sent in the order they were read.
+//
+// By default an input waits until its side is read, which blocks the other side meanwhile:
+// a side that is never read stops the other one after its first input.
+// With WithBufferedOutput, each side buffers up to `size` inputs, so a slow side only blocks the other once its buffer is full.
+// Add WithOverflow to drop the inputs of a side whose buffer is full instead, so that it never blocks the other.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// Both chans are closed once the `in <-chan interface{}` is closed and their buffered inputs are read.
+func Partition(ctx context.Context, pred func(i interface{}) bool, in <-chan interface{}, opts ...Option) (matched, unmatched <-chan interface{}) {
+	outs := partition(ctx, 2, func(i interface{}) int {
+		if pred(i) {
+			return 0
+		}
+		return 1
+	}, in, opts)
+	return outs[0], outs[1]
+}
+
+// partition routes the inputs to `n` out channels, each of which has the buffer set by WithBufferedOutput
+func partition(ctx context.Context, n int, route func(i interface{}) int, in <-chan interface{}, opts []Option) []<-chan interface{} {
+	c := newConfig(opts)
+	outs := Route(ctx, route, in, n)
+	if c.OutputBuffer > 0 {
+		for k, out := range outs {
+			outs[k] = Buffer(c.OutputBuffer, out, opts...)
+		}
+	}
+	return outs
+}
@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"context"
+	"hash/fnv"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPartitionByKey(t *testing.T) {
+	key := func(i interface{}) string {
+		return i.(event).account
+	}
+
+	t.Run("the inputs of a key always go to the same partition in order", func(t *testing.T) {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for seq := 0; seq < 10; seq++ {
+				for _, account := range []string{"a", "b", "c", "d", "e"} {
+					in <- event{account, seq}
+				}
+			}
+		}()
+		outs := PartitionByKey(context.Background(), 3, key, in)
+		results := make([]chan []event, len(outs))
+		for k, out := range outs {
+			results[k] = make(chan []event, 1)
+			go func(out <-chan interface{}, result chan<- []event) {
+				var events []event
+				for o := range out {
+					events = append(events, o.(event))
+				}
+				result <- events
+			}(out, results[k])
+		}
+		for k, result := range results {
+			seqs := map[string]int{}
+			for _, e := range <-result {
+				h := fnv.New32a()
+				h.Write([]byte(e.account))
+				if want := int(h.Sum32() % 3); k != want {
+					t.Errorf("%s went to partition %d, want %d", e.account, k, want)
+				}
+				if e.seq != seqs[e.account] {
+					t.Errorf("%s got seq %d, want %d", e.account, e.seq, seqs[e.account])
+				}
+				seqs[e.account]++
+			}
+		}
+	})
+
+	// a and b go to different partitions out of 2
+	a, b := event{account: "a"}, event{account: "b"}
+
+	t.Run("without a buffer a partition that is not read blocks the others", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		outs := PartitionByKey(ctx, 2, key, Emit(a, b))
+		select {
+		case o := <-outs[1]:
+			t.Errorf("got %v while the partition of a was not read", o)
+		case <-time.After(20 * time.Millisecond):
+		}
+	})
+
+	t.Run("a buffered partition that is not read only blocks the others once its buffer is full", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		outs := PartitionByKey(ctx, 2, key, Emit(a, a, b, b, a), WithBufferedOutput(2))
+		for n := 0; n < 2; n++ {
+			select {
+			case <-outs[1]:
+			case <-time.After(time.Second):
+				t.Fatal("b was blocked by the buffered inputs of a")
+			}
+		}
+	})
+
+	t.Run("a partition whose buffer is full drops its inputs with an overflow policy", func(t *testing.T) {
+		var dropped int
+		outs := PartitionByKey(context.Background(), 2, key, Emit(a, a, a, b, a),
+			WithBufferedOutput(1), WithOverflow(DropNewest, func(interface{}) { dropped++ }))
+		var bs int
+		for range outs[1] {
+			bs++
+		}
+		if bs != 1 {
+			t.Errorf("got %d inputs of b, want 1", bs)
+		}
+		for range outs[0] {
+		}
+		if dropped != 3 {
+			t.Errorf("dropped = %d, want 3", dropped)
+		}
+	})
+}
+
+func TestPartition(t *testing.T) {
+	even := func(i interface{}) bool {
+		return i.(int)%2 == 0
+	}
+
+	t.Run("splits the inputs by the predicate", func(t *testing.T) {
+		matched, unmatched := Partition(context.Background(), even, Emit(1, 2, 3, 4, 5, 6, 7))
+		read := func(out <-chan interface{}) <-chan []interface{} {
+			result := make(chan []interface{}, 1)
+			go func() {
+				var os []interface{}
+				for o := range out {
+					os = append(os, o)
+				}
+				result <- os
+			}()
+			return result
+		}
+		evens, odds := read(matched), read(unmatched)
+		if got, want := <-evens, []interface{}{2, 4, 6}; !reflect.DeepEqual(got, want) {
+			t.Errorf("matched = %v, want %v", got, want)
+		}
+		if got, want := <-odds, []interface{}{1, 3, 5, 7}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unmatched = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("a side that is never read blocks the other once its buffer is full", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// 1 and 3 fill the buffer of the unmatched side, then 5 waits for room, which blocks 6
+		matched, _ := Partition(ctx, even, Emit(1, 2, 3, 4, 5, 6, 7), WithBufferedOutput(2))
+		for _, want := range []interface{}{2, 4} {
+			if got := <-matched; got != want {
+				t.Fatalf("matched %v, want %v", got, want)
+			}
+		}
+		select {
+		case o := <-matched:
+			t.Fatalf("matched %v, want the side to be blocked", o)
+		case <-time.After(20 * time.Millisecond):
+		}
+		// Canceling the context unblocks the other side
+		cancel()
+		for o := range matched {
+			t.Errorf("matched %v after the context was canceled", o)
+		}
+	})
+
+	t.Run("with WithOverflow a side that is never read does not block the other", func(t *testing.T) {
+		dropped := make(chan interface{}, 7)
+		matched, _ := Partition(context.Background(), even, Emit(1, 2, 3, 4, 5, 6, 7),
+			WithBufferedOutput(2), WithOverflow(DropNewest, func(i interface{}) { dropped <- i }))
+		var evens []interface{}
+		for o := range matched {
+			evens = append(evens, o)
+		}
+		if want := []interface{}{2, 4, 6}; !reflect.DeepEqual(evens, want) {
+			t.Errorf("matched = %v, want %v", evens, want)
+		}
+		// The unmatched side drops the inputs that do not fit in its buffer on its own
+		for _, want := range []interface{}{5, 7} {
+			if got := <-dropped; got != want {
+				t.Errorf("dropped %v, want %v", got, want)
+			}
+		}
+	})
+}
@@ -1,5 +1,8 @@
 // Pipeline is a go library that helps you build pipelines without worrying about channel management and concurrency.
 // It contains common fan-in and fan-out operations as well as useful utility funcs for batch processing and scaling.
 //
+// The type-safe generic API lives in the `generic` sub-package and is the recommended way to build new pipelines.
+// The `interface{}` based funcs in this package are kept for compatibility.
+//
 // If you have another common use case you would like to see covered by this package, please (open a feature request) https://github.com/deliveryhero/pipeline/issues.
 package pipeline
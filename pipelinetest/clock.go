@@ -0,0 +1,174 @@
+package pipelinetest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// FakeClock is a pipeline.Clock whose time only moves when Advance is called,
+// so that the tests of the time-based stages are exact and never sleep:
+//
+//	clock := pipelinetest.NewFakeClock(time.Time{})
+//	out := pipeline.Delay(ctx, time.Minute, in, pipeline.WithClock(clock))
+//	clock.BlockUntil(1)
+//	clock.Advance(time.Minute)
+//
+// Since the stages start their timers in their own goroutines, use BlockUntil to wait for a timer before advancing past it.
+// It is safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a timer or a ticker of a FakeClock that has not fired yet
+type waiter struct {
+	at time.Time
+	// period is the period of a ticker, 0 for a timer
+	period time.Duration
+	c      chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to `now`
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a chan that receives the time of the clock once it is advanced by `d`
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer returns a Timer that fires once the clock is advanced by `d`
+func (c *FakeClock) NewTimer(d time.Duration) core.Timer {
+	t := &fakeTimer{clock: c, w: &waiter{c: make(chan time.Time, 1)}}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker returns a Ticker that fires each time the clock is advanced by `d`.
+// Like a time.Ticker, it drops the ticks that are not received in time.
+// It panics if `d` is not positive.
+func (c *FakeClock) NewTicker(d time.Duration) core.Ticker {
+	if d <= 0 {
+		panic("pipelinetest: non-positive interval for NewTicker")
+	}
+	w := &waiter{period: d, c: make(chan time.Time, 1)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.at = c.now.Add(d)
+	c.add(w)
+	return &fakeTicker{clock: c, w: w}
+}
+
+// Advance moves the time of the clock forward by `d`, and fires the timers and tickers that are due on the way, in order
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for len(c.waiters) > 0 && !c.waiters[0].at.After(end) {
+		w := c.waiters[0]
+		c.now = w.at
+		select {
+		case w.c <- w.at:
+		default:
+		}
+		c.remove(w)
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+			c.add(w)
+		}
+	}
+	c.now = end
+}
+
+// BlockUntil waits until at least `n` timers and tickers are waiting to fire
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
+
+// add adds w to the waiters in the order they fire
+func (c *FakeClock) add(w *waiter) {
+	k := sort.Search(len(c.waiters), func(k int) bool { return c.waiters[k].at.After(w.at) })
+	c.waiters = append(c.waiters, nil)
+	copy(c.waiters[k+1:], c.waiters[k:])
+	c.waiters[k] = w
+	c.changed.Broadcast()
+}
+
+// remove removes w from the waiters and returns false if it was not waiting
+func (c *FakeClock) remove(w *waiter) bool {
+	for k, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:k], c.waiters[k+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is the Timer of a FakeClock
+type fakeTimer struct {
+	clock *FakeClock
+	w     *waiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t.w)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.remove(t.w)
+	t.w.at = t.clock.now.Add(d)
+	if d <= 0 {
+		// Like a time.Timer, fire right away
+		select {
+		case t.w.c <- t.w.at:
+		default:
+		}
+		return active
+	}
+	t.clock.add(t.w)
+	return active
+}
+
+// fakeTicker is the Ticker of a FakeClock
+type fakeTicker struct {
+	clock *FakeClock
+	w     *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.remove(t.w)
+}
@@ -0,0 +1,63 @@
+package pipelinetest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+	"github.com/deliveryhero/pipeline/pipelinetest"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("the timers fire when the clock is advanced past them", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+		clock.Advance(999 * time.Millisecond)
+		select {
+		case <-timer.C():
+			t.Fatal("the timer fired early")
+		default:
+		}
+		clock.Advance(time.Millisecond)
+		if at := <-timer.C(); !at.Equal(start.Add(time.Second)) {
+			t.Errorf("fired at %s, want %s", at, start.Add(time.Second))
+		}
+		if timer.Stop() {
+			t.Error("Stop returned true for a timer that fired")
+		}
+		if now := clock.Now(); !now.Equal(start.Add(time.Second)) {
+			t.Errorf("now = %s, want %s", now, start.Add(time.Second))
+		}
+	})
+
+	t.Run("a ticker fires at each period", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(start)
+		ticker := clock.NewTicker(time.Second)
+		defer ticker.Stop()
+		for n := 1; n <= 3; n++ {
+			clock.Advance(time.Second)
+			if at := <-ticker.C(); !at.Equal(start.Add(time.Duration(n) * time.Second)) {
+				t.Errorf("tick %d at %s, want %s", n, at, start.Add(time.Duration(n)*time.Second))
+			}
+		}
+	})
+
+	t.Run("a stage ticks without sleeping", func(t *testing.T) {
+		clock := pipelinetest.NewFakeClock(start)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var calls int
+		out := pipeline.EmitEvery(ctx, time.Hour, func(ctx context.Context) (interface{}, error) {
+			calls++
+			return calls, nil
+		}, pipeline.WithClock(clock))
+		clock.BlockUntil(1)
+		clock.Advance(time.Hour)
+		if o := <-out; o != 1 {
+			t.Errorf("out = %v, want 1", o)
+		}
+	})
+}
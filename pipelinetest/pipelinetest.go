@@ -0,0 +1,215 @@
+// Package pipelinetest provides a Processor, a FakeClock and assertions to test the stages and compositions of pipelines.
+//
+// A Processor passes its inputs through after a latency, fails or panics on the inputs it is told to,
+// and records every call to its Process and Cancel methods, so a test can check what happened to each input:
+//
+//	p := pipelinetest.NewProcessor(pipelinetest.WithLatency(10*time.Millisecond), pipelinetest.WithErrorOn(errBad, 3))
+//	var outs []interface{}
+//	for o := range pipeline.ProcessConcurrently(ctx, 4, p, pipeline.Emit(1, 2, 3, 4)) {
+//		outs = append(outs, o)
+//	}
+//	pipelinetest.AssertAllItemsAccountedFor(t, []interface{}{1, 2, 3, 4}, outs, pipelinetest.Inputs(p.Canceled()))
+//
+// Pass a FakeClock to the time-based stages with pipeline.WithClock to test them without sleeping.
+package pipelinetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Call is a recorded call to the Process or Cancel method of a Processor
+type Call struct {
+	// Input is the input the method was called with
+	Input interface{}
+	// Err is the error Process returned, or the error Cancel was called with
+	Err error
+	// Panic is the value Process panicked with, if it did
+	Panic interface{}
+	// At is when the call started
+	At time.Time
+	// Duration is how long the call took
+	Duration time.Duration
+}
+
+// Option configures a Processor
+type Option func(*Processor)
+
+// WithLatency makes Process wait `latency` before it returns, or until its context is canceled
+func WithLatency(latency time.Duration) Option {
+	return WithLatencyFunc(func(interface{}) time.Duration {
+		return latency
+	})
+}
+
+// WithLatencyFunc makes Process wait the latency returned by `latency` for each input before it returns,
+// or until its context is canceled
+func WithLatencyFunc(latency func(i interface{}) time.Duration) Option {
+	return func(p *Processor) {
+		p.latency = latency
+	}
+}
+
+// WithErrorOn makes Process return `err` for the inputs `is`, after the latency
+func WithErrorOn(err error, is ...interface{}) Option {
+	return func(p *Processor) {
+		for _, i := range is {
+			p.errs[i] = err
+		}
+	}
+}
+
+// WithPanicOn makes Process panic with `value` for the inputs `is`, after the latency
+func WithPanicOn(value interface{}, is ...interface{}) Option {
+	return func(p *Processor) {
+		for _, i := range is {
+			p.panics[i] = value
+		}
+	}
+}
+
+// Processor is a pipeline.Processor that returns each input as its output and records its calls.
+// It is safe for concurrent use, so it can be shared by the workers of the concurrent stages.
+type Processor struct {
+	latency   func(i interface{}) time.Duration
+	errs      map[interface{}]error
+	panics    map[interface{}]interface{}
+	mu        sync.Mutex
+	processed []Call
+	canceled  []Call
+}
+
+// NewProcessor returns a Processor configured by opts.
+// WithErrorOn and WithPanicOn only match the inputs that are comparable.
+func NewProcessor(opts ...Option) *Processor {
+	p := &Processor{
+		errs:   map[interface{}]error{},
+		panics: map[interface{}]interface{}{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Process returns i after the latency, unless it is told to fail or panic on i.
+// If the context is canceled during the latency, it returns the `Context.Err()` right away.
+func (p *Processor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	at := time.Now()
+	out, panicked, err := p.process(ctx, i)
+	p.record(&p.processed, Call{Input: i, Err: err, Panic: panicked, At: at, Duration: time.Since(at)})
+	if panicked != nil {
+		panic(panicked)
+	}
+	return out, err
+}
+
+// process returns the output and the error of Process for i, or the value to panic with
+func (p *Processor) process(ctx context.Context, i interface{}) (interface{}, interface{}, error) {
+	if p.latency != nil {
+		timer := time.NewTimer(p.latency(i))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+	if v, ok := lookup(p.panics, i); ok {
+		return nil, v, nil
+	}
+	if err, ok := lookup(p.errs, i); ok {
+		return nil, nil, err
+	}
+	return i, nil, nil
+}
+
+// Cancel records the input and the error it is called with
+func (p *Processor) Cancel(i interface{}, err error) {
+	p.record(&p.canceled, Call{Input: i, Err: err, At: time.Now()})
+}
+
+// Processed returns the calls to Process so far, in the order they returned
+func (p *Processor) Processed() []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Call(nil), p.processed...)
+}
+
+// Canceled returns the calls to Cancel so far, in the order they were made
+func (p *Processor) Canceled() []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Call(nil), p.canceled...)
+}
+
+// record appends call to calls
+func (p *Processor) record(calls *[]Call, call Call) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*calls = append(*calls, call)
+}
+
+// lookup returns the value of i in m, without panicking if i is not comparable
+func lookup[V any](m map[interface{}]V, i interface{}) (v V, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	v, ok = m[i]
+	return v, ok
+}
+
+// Inputs returns the inputs of calls, such as the inputs that were canceled
+func Inputs(calls []Call) []interface{} {
+	is := make([]interface{}, len(calls))
+	for k, call := range calls {
+		is[k] = call.Input
+	}
+	return is
+}
+
+// AssertAllItemsAccountedFor fails the test unless each of the `inputs` is either in `processed` or in `canceled`, exactly once,
+// and nothing else is: no input was lost or duplicated by the pipeline. The order does not matter.
+// `processed` are typically the outputs of a pipeline whose stages pass their inputs through, like a Processor does.
+func AssertAllItemsAccountedFor(t testing.TB, inputs, processed, canceled []interface{}) {
+	t.Helper()
+	// pending holds the inputs that are not accounted for yet by key
+	pending := map[string][]interface{}{}
+	for _, i := range inputs {
+		pending[key(i)] = append(pending[key(i)], i)
+	}
+	var unexpected []interface{}
+	for _, is := range [][]interface{}{processed, canceled} {
+		for _, i := range is {
+			k := key(i)
+			if len(pending[k]) == 0 {
+				unexpected = append(unexpected, i)
+				continue
+			}
+			pending[k] = pending[k][1:]
+		}
+	}
+	var missing []interface{}
+	for _, i := range inputs {
+		if k := key(i); len(pending[k]) > 0 {
+			missing = append(missing, pending[k][0])
+			pending[k] = pending[k][1:]
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("the inputs %v were neither processed nor canceled", missing)
+	}
+	if len(unexpected) > 0 {
+		t.Errorf("the items %v were processed or canceled more often than they were input", unexpected)
+	}
+}
+
+// key identifies an input by its type and value, so that inputs that are not comparable can be counted
+func key(i interface{}) string {
+	return fmt.Sprintf("%T:%#v", i, i)
+}
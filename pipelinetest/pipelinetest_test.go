@@ -0,0 +1,90 @@
+package pipelinetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+	"github.com/deliveryhero/pipeline/pipelinetest"
+)
+
+func TestProcessor(t *testing.T) {
+	errBad := errors.New("bad input")
+
+	t.Run("the calls of a concurrent stage are recorded", func(t *testing.T) {
+		p := pipelinetest.NewProcessor(
+			pipelinetest.WithLatencyFunc(func(i interface{}) time.Duration { return time.Duration(i.(int)) * time.Millisecond }),
+			pipelinetest.WithErrorOn(errBad, 2),
+			pipelinetest.WithPanicOn("boom", 4),
+		)
+		inputs := []interface{}{1, 2, 3, 4, 5}
+		var outs []interface{}
+		for o := range pipeline.ProcessConcurrently(context.Background(), 3, p, pipeline.Emit(inputs...)) {
+			outs = append(outs, o)
+		}
+		pipelinetest.AssertAllItemsAccountedFor(t, inputs, outs, pipelinetest.Inputs(p.Canceled()))
+
+		processed := p.Processed()
+		if len(processed) != 5 {
+			t.Fatalf("got %d calls to Process, want 5", len(processed))
+		}
+		for _, call := range processed {
+			switch {
+			case call.Input == 2 && !errors.Is(call.Err, errBad):
+				t.Errorf("Process(2) returned %v, want %v", call.Err, errBad)
+			case call.Input == 4 && call.Panic != "boom":
+				t.Errorf("Process(4) panicked with %v, want boom", call.Panic)
+			case call.Duration < time.Duration(call.Input.(int))*time.Millisecond:
+				t.Errorf("Process(%d) took %s, want at least its latency", call.Input, call.Duration)
+			}
+		}
+		for _, call := range p.Canceled() {
+			var pErr *pipeline.ProcessError
+			if !errors.As(call.Err, &pErr) || call.At.IsZero() {
+				t.Errorf("Cancel(%v) was called with %v, want a *ProcessError", call.Input, call.Err)
+			}
+		}
+	})
+
+	t.Run("the latency stops when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		p := pipelinetest.NewProcessor(pipelinetest.WithLatency(time.Hour))
+		if _, err := p.Process(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestAssertAllItemsAccountedFor(t *testing.T) {
+	for _, test := range []struct {
+		name                string
+		processed, canceled []interface{}
+		fails               bool
+	}{
+		{name: "every input is accounted for", processed: []interface{}{3, 1}, canceled: []interface{}{2}},
+		{name: "an input is missing", processed: []interface{}{1}, canceled: []interface{}{2}, fails: true},
+		{name: "an input is duplicated", processed: []interface{}{1, 2, 3}, canceled: []interface{}{2}, fails: true},
+		{name: "an item was never input", processed: []interface{}{1, 2, 3, 4}, fails: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rt := &recorder{TB: t}
+			pipelinetest.AssertAllItemsAccountedFor(rt, []interface{}{1, 2, 3}, test.processed, test.canceled)
+			if rt.failed != test.fails {
+				t.Errorf("failed = %t, want %t", rt.failed, test.fails)
+			}
+		})
+	}
+}
+
+// recorder is a testing.TB that records whether it failed instead of failing the test
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Errorf(string, ...interface{}) {
+	r.failed = true
+}
@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// defaultMaxPending is how many inputs ProcessPriority holds when WithMaxPending is not set
+const defaultMaxPending = 1000
+
+// ProcessPriority is like ProcessConcurrently, except that a free worker takes the pending input with the highest priority
+// rather than the oldest one, so urgent inputs overtake the backlog. `priorityFn` returns the priority of each input,
+// the higher the more urgent. Inputs of the same priority are processed in the order they were read.
+// The inputs wait for a free worker in a heap of up to 1000 inputs, use WithMaxPending to change it.
+// While the heap is full, the `in <-chan interface{}` is not read, so the stages before wait.
+// When the context is canceled, the pending inputs and the remaining inputs are passed to `Processor.Cancel`.
+// ProcessPriority panics if `concurrency` is not positive.
+func ProcessPriority(ctx context.Context, concurrency int, priorityFn func(i interface{}) int, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	c.SetConcurrency(concurrency)
+	if c.maxPending <= 0 {
+		c.maxPending = defaultMaxPending
+	}
+	return core.ProcessPriority[interface{}, interface{}](ctx, concurrency, c.maxPending, priorityFn, p, in, c.Config)
+}
+
+// WithMaxPending sets how many inputs ProcessPriority holds while they wait for a free worker,
+// and how many inputs MergeOrdered holds while they wait for their turn.
+// It panics if `n` is not positive.
+func WithMaxPending(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("pipeline: max pending must be positive, got %d", n))
+	}
+	return func(c *config) {
+		c.maxPending = n
+	}
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ticket is an input of ProcessPriority
+type ticket struct {
+	id  int
+	vip bool
+}
+
+func TestProcessPriority(t *testing.T) {
+	priority := func(i interface{}) int {
+		if i.(ticket).vip {
+			return 1
+		}
+		return 0
+	}
+
+	t.Run("a free worker takes the most urgent pending input first", func(t *testing.T) {
+		in := make(chan interface{})
+		started, release := make(chan struct{}), make(chan struct{})
+		p := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			if i.(ticket).id == 0 {
+				close(started)
+				<-release
+			}
+			return i.(ticket).id, nil
+		})
+		out := ProcessPriority(context.Background(), 1, priority, p, in)
+		// The single worker is busy with the first ticket while the burst is read
+		in <- ticket{id: 0}
+		<-started
+		for id := 1; id <= 6; id++ {
+			in <- ticket{id: id, vip: id%3 == 0}
+		}
+		close(in)
+		close(release)
+		var ids []interface{}
+		for id := range out {
+			ids = append(ids, id)
+		}
+		if want := []interface{}{0, 3, 6, 1, 2, 4, 5}; !reflect.DeepEqual(want, ids) {
+			t.Errorf("ids = %v, want %v", ids, want)
+		}
+	})
+
+	t.Run("the in chan is not read while the heap is full", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := make(chan interface{})
+		sent := make(chan int)
+		go func() {
+			defer close(in)
+			for id := 0; ; id++ {
+				select {
+				case in <- ticket{id: id}:
+					sent <- id
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		block := ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		out := ProcessPriority(ctx, 1, priority, block, in, WithMaxPending(2))
+		// One ticket is processed and 2 are pending
+		for n := 0; n < 3; n++ {
+			<-sent
+		}
+		select {
+		case id := <-sent:
+			t.Errorf("ticket %d was read while the heap was full", id)
+		case <-time.After(20 * time.Millisecond):
+		}
+		cancel()
+		for range out {
+		}
+	})
+
+	t.Run("the pending inputs are canceled with the context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		var mu sync.Mutex
+		var canceled []interface{}
+		p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}, func(i interface{}, _ error) {
+			mu.Lock()
+			defer mu.Unlock()
+			canceled = append(canceled, i)
+		})
+		out := ProcessPriority(ctx, 1, priority, p, in)
+		for id := 0; id < 4; id++ {
+			in <- ticket{id: id}
+		}
+		cancel()
+		in <- ticket{id: 4}
+		close(in)
+		for range out {
+		}
+		if len(canceled) != 5 {
+			t.Errorf("canceled %v, want the 5 tickets", canceled)
+		}
+	})
+
+	t.Run("panics on a max pending that is not positive", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithMaxPending(0) did not panic")
+			}
+		}()
+		WithMaxPending(0)
+	})
+}
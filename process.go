@@ -3,63 +3,45 @@ package pipeline
 import (
 	"context"
 
-	"github.com/deliveryhero/pipeline/semaphore"
+	"github.com/deliveryhero/pipeline/internal/core"
 )
 
 // Process takes each input from the `in <-chan interface{}` and calls `Processor.Process` on it.
 // When `Processor.Process` returns an `interface{}`, it will be sent to the output `<-chan interface{}`.
 // If `Processor.Process` returns an error, `Processor.Cancel` will be called with the corresponding input and error message.
 // Finally, if the `Context` is canceled, all inputs remaining in the `in <-chan interface{}` will go directly to `Processor.Cancel`.
-func Process(ctx context.Context, processor Processor, in <-chan interface{}) <-chan interface{} {
-	out := make(chan interface{})
-	go func() {
-		for i := range in {
-			process(ctx, processor, i, out)
-		}
-		close(out)
-	}()
-	return out
+// If `Processor.Process` panics, the panic is passed to `Processor.Cancel` as a *PanicError, unless WithPanicRecovery(false) is set.
+// With WithConcurrency, WithOrderedOutput and WithBufferedOutput, Process covers the variants of ProcessConcurrently
+// without changing its signature.
+//
+// For compile-time type safety, use the `generic` sub-package instead.
+func Process(ctx context.Context, processor Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	return core.Process[interface{}, interface{}](ctx, processor, in, newConfig(opts).Config)
 }
 
 // ProcessConcurrently fans the in channel out to multiple Processors running concurrently,
 // then it fans the out channels of the Processors back into a single out chan
-func ProcessConcurrently(ctx context.Context, concurrently int, p Processor, in <-chan interface{}) <-chan interface{} {
-	// Create the out chan
-	out := make(chan interface{})
-	go func() {
-		// Perform Process concurrently times
-		sem := semaphore.New(concurrently)
-		for i := range in {
-			sem.Add(1)
-			go func(i interface{}) {
-				process(ctx, p, i, out)
-				sem.Done()
-			}(i)
-		}
-		// Close the out chan after all of the Processors finish executing
-		sem.Wait()
-		close(out)
-	}()
-	return out
+func ProcessConcurrently(ctx context.Context, concurrently int, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	cfg := newConfig(opts).Config
+	cfg.SetConcurrency(concurrently)
+	return core.ProcessConcurrently[interface{}, interface{}](ctx, concurrently, p, in, cfg)
 }
 
-func process(
-	ctx context.Context,
-	processor Processor,
-	i interface{},
-	out chan<- interface{},
-) {
-	select {
-	// When the context is canceled, Cancel all inputs
-	case <-ctx.Done():
-		processor.Cancel(i, ctx.Err())
-	// Otherwise, Process all inputs
-	default:
-		result, err := processor.Process(ctx, i)
-		if err != nil {
-			processor.Cancel(i, err)
-			return
-		}
-		out <- result
-	}
+// ProcessConcurrentlyOrdered is like ProcessConcurrently, except that the results are sent to the out chan
+// in the same order that their inputs were read from the in chan.
+// Inputs that fail to process are skipped and passed to `Processor.Cancel`.
+// At most `concurrently` results can wait to be re-sequenced before ProcessConcurrentlyOrdered stops reading from in.
+func ProcessConcurrentlyOrdered(ctx context.Context, concurrently int, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	cfg := newConfig(opts).Config
+	cfg.SetConcurrency(concurrently)
+	cfg.SetOrdering(core.Ordered)
+	return core.ProcessConcurrentlyOrdered[interface{}, interface{}](ctx, concurrently, p, in, cfg)
+}
+
+// ProcessWithErrors is like Process, except that the errors returned by `Processor.Process` are sent to the errs chan
+// as a *ProcessError instead of being passed to `Processor.Cancel`.
+// `Processor.Cancel` is only called for the inputs that are canceled by the context.
+// The errs chan is closed when the out chan is closed, so both chans must be read until they are closed.
+func ProcessWithErrors(ctx context.Context, processor Processor, in <-chan interface{}, opts ...Option) (out <-chan interface{}, errs <-chan error) {
+	return core.ProcessWithErrors[interface{}, interface{}](ctx, processor, in, newConfig(opts).Config)
 }
@@ -4,27 +4,29 @@ import (
 	"context"
 	"time"
 
-	"github.com/deliveryhero/pipeline/semaphore"
+	"github.com/deliveryhero/pipeline/internal/core"
 )
 
 // ProcessBatch collects up to maxSize elements over maxDuration and processes them together as a slice of `interface{}`s.
 // It passed an []interface{} to the `Processor.Process` method and expects a []interface{} back.
 // It passes []interface{} batches of inputs to the `Processor.Cancel` method.
 // If the receiver is backed up, ProcessBatch can holds up to 2x maxSize.
+// With WithMetrics, each batch counts as one received or canceled input and each result as one emitted output.
 func ProcessBatch(
 	ctx context.Context,
 	maxSize int,
 	maxDuration time.Duration,
 	processor Processor,
 	in <-chan interface{},
+	opts ...Option,
 ) <-chan interface{} {
+	cfg := newConfig(opts).Config
 	out := make(chan interface{})
 	go func() {
-		for {
-			if !processOneBatch(ctx, maxSize, maxDuration, processor, in, out) {
-				break
+		core.RunWorkers[interface{}, interface{}](ctx, cfg, processor, 1, func(p core.Processor[interface{}, interface{}]) {
+			for processOneBatch(ctx, cfg, maxSize, maxDuration, p, in, out) {
 			}
-		}
+		})
 		close(out)
 	}()
 	return out
@@ -39,69 +41,95 @@ func ProcessBatchConcurrently(
 	maxDuration time.Duration,
 	processor Processor,
 	in <-chan interface{},
+	opts ...Option,
 ) <-chan interface{} {
+	cfg := newConfig(opts).Config
 	// Create the out chan
 	out := make(chan interface{})
 	go func() {
-		// Perform Process concurrently times
-		sem := semaphore.New(concurrently)
-		lctx, done := context.WithCancel(context.Background())
-		for !isDone(lctx) {
-			sem.Add(1)
-			go func() {
-				if !processOneBatch(ctx, maxSize, maxDuration, processor, in, out) {
-					done()
-				}
-				sem.Done()
-			}()
-		}
+		// Run concurrently workers, each of which collects and processes batches until the in chan is closed
+		core.RunWorkers[interface{}, interface{}](ctx, cfg, processor, core.Workers(ctx, concurrently), func(p core.Processor[interface{}, interface{}]) {
+			for processOneBatch(ctx, cfg, maxSize, maxDuration, p, in, out) {
+			}
+		})
 		// Close the out chan after all of the Processors finish executing
-		sem.Wait()
 		close(out)
-		done() // Satisfy go-vet
 	}()
 	return out
 }
 
-// isDone returns true if the context is canceled
-func isDone(ctx context.Context) bool {
-	select {
-	case <-ctx.Done():
-		return true
-	default:
-		return false
-	}
-}
-
 // processOneBatch processes one batch of inputs from the in chan.
 // It returns true if the in chan is still open.
 func processOneBatch(
 	ctx context.Context,
+	cfg core.Config,
 	maxSize int,
 	maxDuration time.Duration,
 	processor Processor,
 	in <-chan interface{},
 	out chan<- interface{},
 ) (open bool) {
-	// Collect interfaces for batch processing
-	is, open := collect(ctx, maxSize, maxDuration, in)
+	// Collect interfaces for batch processing, the batch is waited for as long as it is collected
+	var collecting time.Time
+	if cfg.TimesBlocked() {
+		collecting = time.Now()
+	}
+	// The inputs of the batch are in flight until its last result is sent, rather than the batch itself
+	idle := cfg.Idle
+	cfg.Idle = nil
+	is, open := collect(ctx, cfg.Clock, idle, maxSize, maxDuration, in)
+	if !collecting.IsZero() {
+		cfg.ReceiveBlocked(collecting)
+	}
 	if is != nil {
+		defer idle.Add(-len(is))
+		cfg.Received()
 		select {
 		// Cancel all inputs during shutdown
 		case <-ctx.Done():
-			processor.Cancel(is, ctx.Err())
+			cancelBatch(ctx, cfg, processor, is, &CanceledError{Err: ctx.Err()})
 		// Otherwise Process the inputs
 		default:
+			start := time.Now()
 			results, err := processor.Process(ctx, is)
+			cfg.Processed(start)
 			if err != nil {
-				processor.Cancel(is, err)
+				pErr := &ProcessError{Input: is, Err: err}
+				cfg.Fail(ctx, pErr)
+				cancelBatch(ctx, cfg, processor, is, pErr)
 				return open
 			}
+			cfg.LogBatch(ctx, len(is))
 			// Split the results back into interfaces
 			for _, result := range results.([]interface{}) {
-				out <- result
+				sendResult(cfg, result, out)
 			}
 		}
 	}
 	return open
 }
+
+// sendResult sends a result of a batch to the out chan, and reports how long it waited if the out chan was not ready
+func sendResult(cfg core.Config, result interface{}, out chan<- interface{}) {
+	select {
+	case out <- result:
+		cfg.Emitted()
+		return
+	default:
+	}
+	if cfg.TimesBlocked() {
+		defer cfg.SendBlocked(time.Now())
+	}
+	out <- result
+	cfg.Emitted()
+}
+
+// cancelBatch passes the batch `is` to `Processor.Cancel` and releases the slots of its inputs, see WithInflightRelease
+func cancelBatch(ctx context.Context, cfg core.Config, processor Processor, is []interface{}, err error) {
+	lim := cfg.InflightRelease
+	cfg.InflightRelease = nil
+	core.Cancel[interface{}, interface{}](ctx, cfg, processor, is, err)
+	if lim != nil {
+		lim.Release(len(is))
+	}
+}
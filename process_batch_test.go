@@ -181,7 +181,7 @@ func Test_processBatch(t *testing.T) {
 		open      bool
 		processed []interface{}
 		canceled  []interface{}
-		errs      []interface{}
+		errs      []error
 	}
 	tests := []struct {
 		name string
@@ -247,9 +247,9 @@ func Test_processBatch(t *testing.T) {
 			}, []interface{}{
 				6, 7, 8, 9, 10,
 			}},
-			errs: []interface{}{
-				"process error: [1 2 3 4 5]",
-				"process error: [6 7 8 9 10]",
+			errs: []error{
+				errProcess,
+				errProcess,
 			},
 		},
 	}, {
@@ -280,11 +280,11 @@ func Test_processBatch(t *testing.T) {
 				[]interface{}{7},
 				[]interface{}{8},
 			},
-			errs: []interface{}{
-				"context deadline exceeded",
-				"context deadline exceeded",
-				"context deadline exceeded",
-				"context deadline exceeded",
+			errs: []error{
+				context.DeadlineExceeded,
+				context.DeadlineExceeded,
+				context.DeadlineExceeded,
+				context.DeadlineExceeded,
 			},
 		},
 	}}
@@ -302,7 +302,7 @@ func Test_processBatch(t *testing.T) {
 				case <-timeout:
 					break loop
 				default:
-					open = processOneBatch(ctx, tt.args.maxSize, tt.args.maxDuration, tt.args.processor, tt.args.in, tt.args.out)
+					open = processOneBatch(ctx, newConfig(nil).Config, tt.args.maxSize, tt.args.maxDuration, tt.args.processor, tt.args.in, tt.args.out)
 					if !open {
 						break loop
 					}
@@ -322,7 +322,7 @@ func Test_processBatch(t *testing.T) {
 				t.Errorf("canceled = %+v, want %+v", tt.args.processor.canceled, tt.want.canceled)
 			}
 			// Expecting canceled errors
-			if !reflect.DeepEqual(tt.args.processor.errs, tt.want.errs) {
+			if !errorsMatch(tt.want.errs, tt.args.processor.errs) {
 				t.Errorf("errs = %+v, want %+v", tt.args.processor.errs, tt.want.errs)
 			}
 		})
@@ -2,9 +2,19 @@ package pipeline
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
 	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
 )
 
 func TestProcess(t *testing.T) {
@@ -20,7 +30,7 @@ func TestProcess(t *testing.T) {
 		open         bool
 		out          []interface{}
 		canceled     []interface{}
-		canceledErrs []interface{}
+		canceledErrs []error
 	}
 	tests := []struct {
 		name string
@@ -50,12 +60,12 @@ func TestProcess(t *testing.T) {
 				open:     false,
 				out:      []interface{}{1, 2, 3, 4, 5},
 				canceled: []interface{}{6, 7, 8, 9, 10},
-				canceledErrs: []interface{}{
-					"context deadline exceeded",
-					"context deadline exceeded",
-					"context deadline exceeded",
-					"context deadline exceeded",
-					"context deadline exceeded",
+				canceledErrs: []error{
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
 				},
 			},
 		}, {
@@ -70,8 +80,8 @@ func TestProcess(t *testing.T) {
 				open:     true,
 				out:      []interface{}{1},
 				canceled: []interface{}{2},
-				canceledErrs: []interface{}{
-					"context deadline exceeded",
+				canceledErrs: []error{
+					context.DeadlineExceeded,
 				},
 			},
 		}, {
@@ -87,10 +97,10 @@ func TestProcess(t *testing.T) {
 				open:     false,
 				out:      nil,
 				canceled: []interface{}{1, 2, 3},
-				canceledErrs: []interface{}{
-					"process error: 1",
-					"process error: 2",
-					"context deadline exceeded",
+				canceledErrs: []error{
+					errProcess,
+					errProcess,
+					context.DeadlineExceeded,
 				},
 			},
 		},
@@ -145,14 +155,15 @@ func TestProcess(t *testing.T) {
 				t.Errorf("%+v != %+v", test.want.out, outs)
 			}
 
-			// Expecting canceled inputs
-			if !reflect.DeepEqual(test.want.canceled, processor.canceled) {
-				t.Errorf("%+v != %+v", test.want.canceled, processor.canceled)
+			// Expecting canceled inputs, which the stage may still be canceling if out is open
+			canceled, errs := processor.canceledSoFar()
+			if !reflect.DeepEqual(test.want.canceled, canceled) {
+				t.Errorf("%+v != %+v", test.want.canceled, canceled)
 			}
 
 			// Expecting canceled errors
-			if !reflect.DeepEqual(test.want.canceledErrs, processor.errs) {
-				t.Errorf("%+v != %+v", test.want.canceledErrs, processor.errs)
+			if !errorsMatch(test.want.canceledErrs, errs) {
+				t.Errorf("%+v != %+v", test.want.canceledErrs, errs)
 			}
 		})
 	}
@@ -172,7 +183,7 @@ func TestProcessConcurrently(t *testing.T) {
 		open         bool
 		out          []interface{}
 		canceled     []interface{}
-		canceledErrs []interface{}
+		canceledErrs []error
 	}
 	tests := []struct {
 		name string
@@ -204,11 +215,11 @@ func TestProcessConcurrently(t *testing.T) {
 				open:     false,
 				out:      []interface{}{1, 2, 3, 4, 5, 6},
 				canceled: []interface{}{7, 8, 9, 10},
-				canceledErrs: []interface{}{
-					"context deadline exceeded",
-					"context deadline exceeded",
-					"context deadline exceeded",
-					"context deadline exceeded",
+				canceledErrs: []error{
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
 				},
 			},
 		}, {
@@ -224,10 +235,10 @@ func TestProcessConcurrently(t *testing.T) {
 				open:     true,
 				out:      []interface{}{1, 2, 3},
 				canceled: []interface{}{4, 5, 6},
-				canceledErrs: []interface{}{
-					"context deadline exceeded",
-					"context deadline exceeded",
-					"context deadline exceeded",
+				canceledErrs: []error{
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
+					context.DeadlineExceeded,
 				},
 			},
 		}, {
@@ -244,10 +255,10 @@ func TestProcessConcurrently(t *testing.T) {
 				open:     false,
 				out:      nil,
 				canceled: []interface{}{1, 2, 3},
-				canceledErrs: []interface{}{
-					"process error: 1",
-					"process error: 2",
-					"context deadline exceeded",
+				canceledErrs: []error{
+					errProcess,
+					errProcess,
+					context.DeadlineExceeded,
 				},
 			},
 		},
@@ -302,15 +313,928 @@ func TestProcessConcurrently(t *testing.T) {
 				t.Errorf("out = %+v, want %+v", outs, test.want.out)
 			}
 
-			// Expecting canceled inputs
-			if !containsAll(test.want.canceled, processor.canceled) {
-				t.Errorf("canceled = %+v, want %+v", processor.canceled, test.want.canceled)
+			// Expecting canceled inputs, which the workers may still be canceling if out is open
+			canceled, errs := processor.canceledSoFar()
+			if !containsAll(test.want.canceled, canceled) {
+				t.Errorf("canceled = %+v, want %+v", canceled, test.want.canceled)
 			}
 
 			// Expecting canceled errors
-			if !containsAll(test.want.canceledErrs, processor.errs) {
-				t.Errorf("canceledErrs = %+v, want %+v", processor.errs, test.want.canceledErrs)
+			if !errorsMatchAll(test.want.canceledErrs, errs) {
+				t.Errorf("canceledErrs = %+v, want %+v", errs, test.want.canceledErrs)
+			}
+		})
+	}
+}
+
+func TestProcessConcurrentlyOrdered(t *testing.T) {
+	t.Run("out is in the same order as in", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Feed 1..100 into the pipeline
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 1; i <= 100; i++ {
+				in <- i
+			}
+		}()
+
+		// Process each input for a random duration and fail every 10th input
+		var mu sync.Mutex
+		var canceled []interface{}
+		p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond) // #nosec
+			if i.(int)%10 == 0 {
+				return nil, fmt.Errorf("process error: %d", i)
+			}
+			return i, nil
+		}, func(i interface{}, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			canceled = append(canceled, i)
+		})
+
+		// Expecting strictly increasing outputs
+		var outs []interface{}
+		prev := 0
+		for o := range ProcessConcurrentlyOrdered(ctx, 8, p, in) {
+			if o.(int) <= prev {
+				t.Errorf("%d was emitted after %d", o, prev)
+			}
+			prev = o.(int)
+			outs = append(outs, o)
+		}
+		if len(outs) != 90 {
+			t.Errorf("len(out) = %d, want 90", len(outs))
+		}
+
+		// Expecting the failed inputs to be canceled
+		if !containsAll([]interface{}{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}, canceled) {
+			t.Errorf("canceled = %+v, want every 10th input", canceled)
+		}
+	})
+
+	t.Run("stops reading from in when the reorder buffer is full", func(t *testing.T) {
+		const concurrently = 4
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Block the first input until the test releases it
+		release := make(chan struct{})
+		var started int32
+		p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			atomic.AddInt32(&started, 1)
+			if i.(int) == 1 {
+				<-release
+			}
+			return i, nil
+		}, nil)
+
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 1; i <= 100; i++ {
+				in <- i
+			}
+		}()
+		out := ProcessConcurrentlyOrdered(ctx, concurrently, p, in)
+
+		// Only the blocked input and the inputs waiting behind it should have been started
+		time.Sleep(100 * time.Millisecond)
+		if s := atomic.LoadInt32(&started); s > concurrently+1 {
+			t.Errorf("started = %d, want <= %d", s, concurrently+1)
+		}
+
+		// Once the first input is released, everything flows through
+		close(release)
+		var count int
+		for range out {
+			count++
+		}
+		if count != 100 {
+			t.Errorf("len(out) = %d, want 100", count)
+		}
+	})
+}
+
+func TestProcessWithErrors(t *testing.T) {
+	t.Run("process errors are sent to errs instead of cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// Fail to process every even number
+		var canceled []interface{}
+		p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			if i.(int)%2 == 0 {
+				return nil, fmt.Errorf("process error: %d", i)
+			}
+			return i, nil
+		}, func(i interface{}, err error) {
+			canceled = append(canceled, i)
+		})
+
+		// Read from out and errs until both are closed
+		var outs, failed []interface{}
+		var errMsgs []string
+		out, errs := ProcessWithErrors(ctx, p, Emit(1, 2, 3, 4, 5))
+		for out != nil || errs != nil {
+			select {
+			case o, open := <-out:
+				if !open {
+					out = nil
+					continue
+				}
+				outs = append(outs, o)
+			case err, open := <-errs:
+				if !open {
+					errs = nil
+					continue
+				}
+				var pErr *ProcessError
+				if !errors.As(err, &pErr) {
+					t.Fatalf("%T is not a *ProcessError", err)
+				}
+				failed = append(failed, pErr.Input)
+				errMsgs = append(errMsgs, err.Error())
+			}
+		}
+
+		if want := []interface{}{1, 3, 5}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if want := []interface{}{2, 4}; !reflect.DeepEqual(want, failed) {
+			t.Errorf("failed = %+v, want %+v", failed, want)
+		}
+		if want := []string{"process error: 2", "process error: 4"}; !reflect.DeepEqual(want, errMsgs) {
+			t.Errorf("errs = %+v, want %+v", errMsgs, want)
+		}
+		if canceled != nil {
+			t.Errorf("canceled = %+v, want nil", canceled)
+		}
+	})
+
+	t.Run("inputs are canceled when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		processor := &mockProcessor{}
+		out, errs := ProcessWithErrors(ctx, processor, Emit(1, 2, 3))
+		for range out {
+			t.Error("nothing should be processed after the context is canceled")
+		}
+		for err := range errs {
+			t.Errorf("unexpected error: %s", err)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, processor.canceled) {
+			t.Errorf("canceled = %+v, want %+v", processor.canceled, want)
+		}
+	})
+}
+
+// panicProcessor panics on every third input
+type panicProcessor struct {
+	mu       sync.Mutex
+	canceled []interface{}
+	errs     []error
+}
+
+func (p *panicProcessor) Process(_ context.Context, i interface{}) (interface{}, error) {
+	if i.(int)%3 == 0 {
+		var m map[string]int
+		m["nil map write"] = i.(int)
+	}
+	return i, nil
+}
+
+func (p *panicProcessor) Cancel(i interface{}, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.canceled = append(p.canceled, i)
+	p.errs = append(p.errs, err)
+}
+
+func TestProcessPanicRecovery(t *testing.T) {
+	for name, process := range map[string]func(ctx context.Context, p Processor, in <-chan interface{}) <-chan interface{}{
+		"Process": func(ctx context.Context, p Processor, in <-chan interface{}) <-chan interface{} {
+			return Process(ctx, p, in)
+		},
+		"ProcessConcurrently": func(ctx context.Context, p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessConcurrently(ctx, 3, p, in)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			processor := &panicProcessor{}
+			var outs []interface{}
+			for o := range process(context.Background(), processor, Emit(1, 2, 3, 4, 5, 6, 7)) {
+				outs = append(outs, o)
+			}
+
+			// The other inputs still flow through and out still closes
+			if want := []interface{}{1, 2, 4, 5, 7}; !containsAll(want, outs) {
+				t.Errorf("out = %+v, want %+v", outs, want)
+			}
+			if want := []interface{}{3, 6}; !containsAll(want, processor.canceled) {
+				t.Errorf("canceled = %+v, want %+v", processor.canceled, want)
+			}
+
+			// The panics are passed to cancel as a *PanicError
+			for _, err := range processor.errs {
+				var pErr *PanicError
+				if !errors.As(err, &pErr) {
+					t.Fatalf("%T is not a *PanicError", err)
+				}
+				if want := "panic: assignment to entry in nil map"; err.Error() != want {
+					t.Errorf("err = %s, want %s", err, want)
+				}
+				if len(pErr.Stack) == 0 {
+					t.Error("the stack trace is missing")
+				}
+			}
+		})
+	}
+}
+
+func TestProcessPanicRecoveryDisabled(t *testing.T) {
+	// Crashing is tested in a sub-process, because a panic in a worker goroutine can not be recovered by the test
+	if os.Getenv("PIPELINE_TEST_CRASH") == "1" {
+		for range Process(context.Background(), &panicProcessor{}, Emit(1, 2, 3), WithPanicRecovery(false)) {
+		}
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestProcessPanicRecoveryDisabled$") // #nosec
+	cmd.Env = append(os.Environ(), "PIPELINE_TEST_CRASH=1")
+	if err := cmd.Run(); err == nil {
+		t.Error("the process did not crash")
+	}
+}
+
+// noopProcessor returns each input as it is
+var noopProcessor = ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+	return i, nil
+})
+
+func BenchmarkProcessConcurrently(b *testing.B) {
+	for _, concurrently := range []int{1, 8, 64} {
+		concurrently := concurrently
+		b.Run(fmt.Sprintf("concurrently=%d", concurrently), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for range ProcessConcurrently(context.Background(), concurrently, noopProcessor, emitN(1000000)) {
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkProcess processes b.N inputs with noopProcessor, so ns/op and allocs/op are the cost of the stage per input
+func BenchmarkProcess(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "serial"},
+		{name: "concurrently=8", opts: []Option{WithConcurrency(8)}},
+		{name: "concurrently=64", opts: []Option{WithConcurrency(64)}},
+		{name: "concurrently=8/ordered", opts: []Option{WithConcurrency(8), WithOrderedOutput()}},
+	} {
+		bench := bench
+		b.Run(bench.name, func(b *testing.B) {
+			in := emitN(b.N)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range Process(context.Background(), noopProcessor, in, bench.opts...) {
+			}
+		})
+	}
+}
+
+func TestProcessCancelErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Fail input 1, then cancel the context while processing input 2
+	var errs []error
+	p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+		if i == 1 {
+			return nil, errProcess
+		}
+		cancel()
+		return i, nil
+	}, func(i interface{}, err error) {
+		errs = append(errs, err)
+	})
+	for range Process(ctx, p, Emit(1, 2, 3)) {
+	}
+
+	if len(errs) != 2 {
+		t.Fatalf("errs = %+v, want 2 errors", errs)
+	}
+	// The error returned by Process is wrapped in a *ProcessError
+	var pErr *ProcessError
+	if !errors.As(errs[0], &pErr) || pErr.Input != 1 || !errors.Is(errs[0], errProcess) {
+		t.Errorf("errs[0] = %#v, want a *ProcessError wrapping %s", errs[0], errProcess)
+	}
+	if errors.Is(errs[0], ErrCanceled) {
+		t.Errorf("errs[0] = %s, want it not to match ErrCanceled", errs[0])
+	}
+	// The input that was never processed matches ErrCanceled and the context error
+	if !errors.Is(errs[1], ErrCanceled) || !errors.Is(errs[1], context.Canceled) {
+		t.Errorf("errs[1] = %#v, want it to match ErrCanceled and %s", errs[1], context.Canceled)
+	}
+	if errs[1].Error() != context.Canceled.Error() {
+		t.Errorf("errs[1] = %q, want %q", errs[1], context.Canceled)
+	}
+}
+
+func TestProcessSerializedCancel(t *testing.T) {
+	// Every input fails, so the workers all call Cancel
+	var running, maxRunning int32
+	canceled := map[interface{}]error{}
+	p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+		return nil, errProcess
+	}, func(i interface{}, err error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		time.Sleep(time.Millisecond)
+		// A map is not safe for concurrent use, so the race detector catches overlapping calls
+		canceled[i] = err
+	})
+	for range ProcessConcurrently(context.Background(), 8, p, emitN(50), WithSerializedCancel()) {
+	}
+	if len(canceled) != 50 {
+		t.Errorf("canceled %d inputs, want 50", len(canceled))
+	}
+	if maxRunning != 1 {
+		t.Errorf("up to %d calls to Cancel ran at once, want 1", maxRunning)
+	}
+}
+
+func TestProcessCancelPanics(t *testing.T) {
+	p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+		return nil, errProcess
+	}, func(i interface{}, err error) {
+		panic("cancel failed")
+	})
+	var mu sync.Mutex
+	var panics []error
+	panicked := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		panics = append(panics, err)
+	}
+	// The out chan is closed even though every call to Cancel panics
+	for range ProcessConcurrently(context.Background(), 4, p, Emit(1, 2, 3, 4, 5), WithCancelPanics(panicked)) {
+	}
+	if len(panics) != 5 {
+		t.Fatalf("got %d panics, want 5", len(panics))
+	}
+	for _, err := range panics {
+		var pErr *ProcessError
+		var panicErr *PanicError
+		if !errors.As(err, &pErr) || pErr.Input == nil || !errors.As(err, &panicErr) || panicErr.Value != "cancel failed" {
+			t.Errorf("err = %#v, want a *ProcessError wrapping the *PanicError of the cancel", err)
+		}
+	}
+}
+
+func TestProcessAbandonedOut(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		process func(ctx context.Context, p Processor, in <-chan interface{}) <-chan interface{}
+	}{{
+		"Process",
+		func(ctx context.Context, p Processor, in <-chan interface{}) <-chan interface{} {
+			return Process(ctx, p, in)
+		},
+	}, {
+		"ProcessConcurrently",
+		func(ctx context.Context, p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessConcurrently(ctx, 4, p, in)
+		},
+	}, {
+		"ProcessConcurrentlyOrdered",
+		func(ctx context.Context, p Processor, in <-chan interface{}) <-chan interface{} {
+			return ProcessConcurrentlyOrdered(ctx, 4, p, in)
+		},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			ctx, cancel := context.WithCancel(context.Background())
+
+			// Read a single output, then stop reading and cancel the context
+			var canceled int32
+			p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+				return i, nil
+			}, func(i interface{}, err error) {
+				// The inputs were either never processed or processed but not delivered
+				if errors.Is(err, ErrCanceled) || errors.Is(err, ErrUndelivered) {
+					atomic.AddInt32(&canceled, 1)
+				}
+			})
+			<-test.process(ctx, p, emitN(10))
+			cancel()
+
+			// The workers give up on their sends and exit
+			time.Sleep(50 * time.Millisecond)
+			if after := runtime.NumGoroutine(); after > before {
+				t.Errorf("goroutines = %d, want <= %d", after, before)
+			}
+			if got := atomic.LoadInt32(&canceled); got != 9 {
+				t.Errorf("canceled = %d, want 9", got)
+			}
+		})
+	}
+}
+
+func TestProcessUndelivered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Input 1 is processed, but its result is never received
+	processed := make(chan struct{})
+	var mu sync.Mutex
+	errs := map[interface{}]error{}
+	p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+		if i == 1 {
+			defer close(processed)
+		}
+		return i.(int) * 10, nil
+	}, func(i interface{}, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs[i] = err
+	})
+	in := make(chan interface{}, 3)
+	in <- 1
+	out := Process(ctx, p, in)
+	<-processed
+	cancel()
+	in <- 2
+	in <- 3
+	close(in)
+	for range out {
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("errs = %+v, want 3 errors", errs)
+	}
+	// Input 1 was processed, so it is undelivered and carries its result
+	var uErr *UndeliveredError
+	if !errors.As(errs[1], &uErr) || uErr.Result != 10 || !errors.Is(errs[1], context.Canceled) || errors.Is(errs[1], ErrCanceled) {
+		t.Errorf("errs[1] = %#v, want an *UndeliveredError of 10 wrapping %s", errs[1], context.Canceled)
+	}
+	if !errors.Is(errs[1], ErrUndelivered) || errs[1].Error() != "undelivered: context canceled" {
+		t.Errorf("errs[1] = %q, want it to match ErrUndelivered", errs[1])
+	}
+	// The inputs read after the cancel were never processed
+	for _, i := range []interface{}{2, 3} {
+		if !errors.Is(errs[i], ErrCanceled) || errors.Is(errs[i], ErrUndelivered) {
+			t.Errorf("errs[%v] = %#v, want it to match ErrCanceled only", i, errs[i])
+		}
+	}
+}
+
+func TestProcessOptions(t *testing.T) {
+	sleep := func(d func(i int) time.Duration) Processor {
+		return ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			time.Sleep(d(i.(int)))
+			return i, nil
+		})
+	}
+
+	t.Run("WithConcurrency processes inputs at once", func(t *testing.T) {
+		p := sleep(func(int) time.Duration { return 50 * time.Millisecond })
+		start := time.Now()
+		var outs []interface{}
+		for o := range Process(context.Background(), p, emitN(8), WithConcurrency(4)) {
+			outs = append(outs, o)
+		}
+		if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+			t.Errorf("elapsed = %s, want about 100ms", elapsed)
+		}
+		if want := []interface{}{0, 1, 2, 3, 4, 5, 6, 7}; !containsAll(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("WithOrderedOutput keeps the order of the inputs", func(t *testing.T) {
+		// Earlier inputs take longer
+		p := sleep(func(i int) time.Duration { return time.Duration(20-i) * time.Millisecond })
+		var outs []interface{}
+		for o := range Process(context.Background(), p, emitN(20), WithConcurrency(8), WithOrderedOutput()) {
+			outs = append(outs, o)
+		}
+		for k, o := range outs {
+			if o != k {
+				t.Fatalf("out = %+v, want 0 to 19 in order", outs)
+			}
+		}
+	})
+
+	t.Run("WithBufferedOutput processes inputs ahead of the receiver", func(t *testing.T) {
+		var processed int32
+		p := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			atomic.AddInt32(&processed, 1)
+			return i, nil
+		})
+		out := Process(context.Background(), p, emitN(10), WithBufferedOutput(3))
+
+		// 3 results fill the buffer and the 4th waits to be sent
+		time.Sleep(50 * time.Millisecond)
+		if got := atomic.LoadInt32(&processed); got != 4 {
+			t.Errorf("processed = %d, want 4", got)
+		}
+		if got := len(out); got != 3 {
+			t.Errorf("len(out) = %d, want 3", got)
+		}
+		for range out {
+		}
+	})
+
+	t.Run("WithBufferedOutput delivers the buffered results after the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p := pipelinetest.NewProcessor()
+		inputs := make([]interface{}, 10)
+		for k := range inputs {
+			inputs[k] = k
+		}
+		out := Process(ctx, p, Emit(inputs...), WithBufferedOutput(3))
+
+		// Cancel once the buffer is full
+		for len(out) < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		if len(outs) < 3 || outs[0] != 0 || outs[1] != 1 || outs[2] != 2 {
+			t.Errorf("out = %+v, want it to start with the buffered results 0, 1 and 2", outs)
+		}
+		// The result waiting for room in the buffer may be canceled or delivered, but never both
+		pipelinetest.AssertAllItemsAccountedFor(t, inputs, outs, pipelinetest.Inputs(p.Canceled()))
+	})
+
+	t.Run("conflicting options panic", func(t *testing.T) {
+		for name, start := range map[string]func(){
+			"ordered and unordered": func() {
+				Process(context.Background(), noopProcessor, Emit(), WithOrderedOutput(), WithUnorderedOutput())
+			},
+			"ProcessConcurrentlyOrdered and unordered": func() {
+				ProcessConcurrentlyOrdered(context.Background(), 2, noopProcessor, Emit(), WithUnorderedOutput())
+			},
+			"two concurrencies": func() {
+				ProcessConcurrently(context.Background(), 2, noopProcessor, Emit(), WithConcurrency(4))
+			},
+			"zero concurrency": func() {
+				Process(context.Background(), noopProcessor, Emit(), WithConcurrency(0))
+			},
+			"negative buffer": func() {
+				Process(context.Background(), noopProcessor, Emit(), WithBufferedOutput(-1))
+			},
+		} {
+			start := start
+			t.Run(name, func(t *testing.T) {
+				defer func() {
+					if recover() == nil {
+						t.Error("the options did not panic")
+					}
+				}()
+				start()
+			})
+		}
+	})
+}
+
+func TestProcessGracefulStop(t *testing.T) {
+	stages := map[string]func(ctx context.Context, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{}{
+		"Process": func(ctx context.Context, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+			return Process(ctx, p, in, opts...)
+		},
+		"ProcessConcurrently": func(ctx context.Context, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+			return ProcessConcurrently(ctx, 3, p, in, opts...)
+		},
+		"ProcessConcurrentlyOrdered": func(ctx context.Context, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+			return ProcessConcurrentlyOrdered(ctx, 3, p, in, opts...)
+		},
+		"ProcessSingleflight": func(ctx context.Context, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+			return ProcessSingleflight(ctx, func(i interface{}) string {
+				return fmt.Sprint(i)
+			}, p, in, append(opts, WithConcurrency(3))...)
+		},
+	}
+	tests := []struct {
+		name     string
+		grace    time.Duration
+		finished bool
+	}{{
+		name:     "a grace period longer than the work left lets every input finish",
+		grace:    time.Second,
+		finished: true,
+	}, {
+		name:     "a grace period shorter than the work left cancels the inputs still processing",
+		grace:    5 * time.Millisecond,
+		finished: false,
+	}}
+	for name, stage := range stages {
+		name, stage := name, stage
+		for _, test := range tests {
+			test := test
+			t.Run(name+" "+test.name, func(t *testing.T) {
+				var mu sync.Mutex
+				var canceled []interface{}
+				var errs []error
+				p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+					select {
+					case <-time.After(50 * time.Millisecond):
+						return i, nil
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+				}, func(i interface{}, err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					canceled = append(canceled, i)
+					errs = append(errs, err)
+				})
+				in := make(chan interface{})
+				stop := make(chan struct{})
+				out := stage(context.Background(), p, in, WithGracefulStop(stop, test.grace), WithBufferedOutput(3))
+				// Every input is being processed once the next one is received
+				inputs := []interface{}{1, 2, 3}
+				if name == "Process" {
+					inputs = inputs[:1]
+				}
+				for _, i := range inputs {
+					in <- i
+				}
+				close(stop)
+
+				var outs []interface{}
+				for o := range out {
+					outs = append(outs, o)
+				}
+				select {
+				case in <- 4:
+					t.Error("an input was read after the stage was stopped")
+				default:
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				wantOuts, wantCanceled := inputs, []interface{}(nil)
+				if !test.finished {
+					wantOuts, wantCanceled = nil, inputs
+				}
+				if !containsAll(wantOuts, outs) {
+					t.Errorf("out = %+v, want %+v", outs, wantOuts)
+				}
+				if !containsAll(wantCanceled, canceled) {
+					t.Errorf("canceled = %+v, want %+v", canceled, wantCanceled)
+				}
+				for _, err := range errs {
+					if !errors.Is(err, context.Canceled) {
+						t.Errorf("err = %v, want %v", err, context.Canceled)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestProcessGracefulStopClock(t *testing.T) {
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	canceled := make(chan error, 1)
+	processing := make(chan struct{})
+	p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+		close(processing)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, func(_ interface{}, err error) {
+		canceled <- err
+	})
+	in := make(chan interface{})
+	stop := make(chan struct{})
+	out := Process(context.Background(), p, in, WithGracefulStop(stop, time.Hour), WithClock(clock))
+	in <- 1
+	<-processing
+	close(stop)
+	// The grace period only ends when the clock is advanced past it
+	clock.BlockUntil(1)
+	select {
+	case err := <-canceled:
+		t.Fatalf("canceled with %v before the grace period was over", err)
+	default:
+	}
+	clock.Advance(time.Hour)
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	for range out {
+	}
+}
+
+// batchCanceler records the batches passed to CancelBatch, and the inputs passed to Cancel.
+// Each call takes `delay`, like a round trip to a store.
+type batchCanceler struct {
+	mu       sync.Mutex
+	delay    time.Duration
+	batches  [][]interface{}
+	canceled []interface{}
+	errs     []error
+}
+
+func (p *batchCanceler) Process(_ context.Context, i interface{}) (interface{}, error) {
+	return i, nil
+}
+
+func (p *batchCanceler) Cancel(i interface{}, err error) {
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.canceled = append(p.canceled, i)
+	p.errs = append(p.errs, err)
+}
+
+func (p *batchCanceler) CancelBatch(is []interface{}, err error) {
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// The batch is not reused by the stage, but copy it in case it ever is
+	p.batches = append(p.batches, append([]interface{}(nil), is...))
+	p.errs = append(p.errs, err)
+}
+
+// canceledInputs returns n inputs, a closed chan of them, and a context that is canceled already
+func canceledInputs(n int) (context.Context, []interface{}, <-chan interface{}) {
+	inputs := make([]interface{}, n)
+	in := make(chan interface{}, n)
+	for i := range inputs {
+		inputs[i] = i
+		in <- i
+	}
+	close(in)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx, inputs, in
+}
+
+func TestProcessBatchCanceler(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		opts        []Option
+		withErrors  bool
+		wantBatches bool
+		maxBatch    int
+	}{
+		{name: "serial", wantBatches: true, maxBatch: 100},
+		{name: "concurrently", opts: []Option{WithConcurrency(4)}, wantBatches: true, maxBatch: 100},
+		{name: "batch size", opts: []Option{WithCancelBatchSize(7)}, wantBatches: true, maxBatch: 7},
+		{name: "with errors", withErrors: true, wantBatches: true, maxBatch: 100},
+		{name: "batch size 1 cancels each input", opts: []Option{WithCancelBatchSize(1)}},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx, inputs, in := canceledInputs(250)
+			p := &batchCanceler{}
+			if test.withErrors {
+				out, errs := ProcessWithErrors(ctx, p, in, test.opts...)
+				go func() {
+					for range errs {
+					}
+				}()
+				for range out {
+				}
+			} else {
+				for range Process(ctx, p, in, test.opts...) {
+				}
+			}
+
+			var canceled []interface{}
+			for _, batch := range p.batches {
+				if len(batch) == 0 || len(batch) > test.maxBatch {
+					t.Errorf("len(batch) = %d, want 1 to %d", len(batch), test.maxBatch)
+				}
+				canceled = append(canceled, batch...)
+			}
+			if test.wantBatches == (len(p.canceled) > 0) {
+				t.Errorf("batches = %d, canceled = %d, want only batches: %t", len(p.batches), len(p.canceled), test.wantBatches)
+			}
+			canceled = append(canceled, p.canceled...)
+			pipelinetest.AssertAllItemsAccountedFor(t, inputs, nil, canceled)
+			for _, err := range p.errs {
+				if !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+					t.Errorf("err = %#v, want it to match ErrCanceled and %s", err, context.Canceled)
+				}
 			}
 		})
 	}
 }
+
+func TestWithCancelBatchSizePanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("WithCancelBatchSize(0) did not panic")
+		}
+	}()
+	WithCancelBatchSize(0)
+}
+
+// BenchmarkCancelDrain drains b.N inputs after the context is done, with a canceler that takes 10µs a call.
+// CancelBatch makes a call per 100 inputs rather than a call per input.
+func BenchmarkCancelDrain(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Cancel", opts: []Option{WithCancelBatchSize(1)}},
+		{name: "CancelBatch"},
+	} {
+		bench := bench
+		b.Run(bench.name, func(b *testing.B) {
+			ctx, _, in := canceledInputs(b.N)
+			p := &batchCanceler{delay: 10 * time.Microsecond}
+			b.ResetTimer()
+			for range Process(ctx, p, in, bench.opts...) {
+			}
+		})
+	}
+}
+
+// lazyProcessor passes each input to started and returns it once release is closed.
+// Each of its clones counts its Setup in setups.
+type lazyProcessor struct {
+	started chan interface{}
+	release chan struct{}
+	setups  *int32
+}
+
+func (p *lazyProcessor) Process(_ context.Context, i interface{}) (interface{}, error) {
+	p.started <- i
+	<-p.release
+	return i, nil
+}
+
+func (p *lazyProcessor) Cancel(interface{}, error) {}
+
+func (p *lazyProcessor) CloneForWorker() Processor {
+	return &lazyProcessor{started: p.started, release: p.release, setups: p.setups}
+}
+
+func (p *lazyProcessor) Setup(context.Context) error {
+	atomic.AddInt32(p.setups, 1)
+	return nil
+}
+
+func TestProcessLazyWorkers(t *testing.T) {
+	clock := pipelinetest.NewFakeClock(time.Time{})
+	m := &MemoryMetrics{}
+	var setups int32
+	p := &lazyProcessor{started: make(chan interface{}), release: make(chan struct{}), setups: &setups}
+	in := make(chan interface{})
+	out := Process(context.Background(), p, in,
+		WithConcurrency(3), WithLazyWorkers(time.Second), WithClock(clock), WithMetrics("lazy", m))
+
+	// The first worker takes the first input
+	in <- 1
+	<-p.started
+	if got := m.Stage("lazy").Workers; got != 1 {
+		t.Fatalf("workers = %d, want 1", got)
+	}
+	// The second input waits for a free worker until the threshold has passed
+	in <- 2
+	clock.BlockUntil(1)
+	clock.Advance(time.Second - time.Nanosecond)
+	select {
+	case i := <-p.started:
+		t.Fatalf("%v started before the threshold", i)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Nanosecond)
+	if i := <-p.started; i != 2 {
+		t.Fatalf("started %v, want 2", i)
+	}
+	if got := m.Stage("lazy").Workers; got != 2 {
+		t.Errorf("workers = %d, want 2", got)
+	}
+
+	close(p.release)
+	close(in)
+	var outs []interface{}
+	for o := range out {
+		outs = append(outs, o)
+	}
+	if len(outs) != 2 {
+		t.Errorf("out = %+v, want 2 outputs", outs)
+	}
+	// Only the workers that were started are set up
+	if setups != 2 {
+		t.Errorf("setups = %d, want 2", setups)
+	}
+}
@@ -1,6 +1,10 @@
 package pipeline
 
-import "context"
+import (
+	"context"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
 
 // Processor represents a blocking operation in a pipeline. Implementing `Processor` will allow you to add
 // business logic to your pipelines without directly managing channels. This simplifies your unit tests
@@ -11,10 +15,60 @@ type Processor interface {
 	Process(ctx context.Context, i interface{}) (interface{}, error)
 
 	// Cancel is called if process returns an error or if the context is canceled while there are still items in the `in <-chan interface{}`.
+	// Use errors.Is(err, ErrCanceled) to tell the inputs that were canceled by the context from the ones that failed,
+	// whose err is a *ProcessError, and errors.Is(err, ErrUndelivered) to tell the inputs that were processed
+	// but whose result was not received before the context was done.
+	// The workers of the concurrent stages may call Cancel at once, unless WithSerializedCancel is set.
 	Cancel(i interface{}, err error)
 }
 
-// NewProcessor creates a process and cancel func
+// ContextCanceler is an optional interface of a Processor that needs a context to bound the work it does when an input is canceled,
+// such as writing the input to a fallback store.
+// If a Processor implements it, CancelContext is called instead of `Processor.Cancel`.
+// Its context carries the values of the stage context and is done a grace period after the stage context is done,
+// which is 1 second by default and can be changed with WithCancelTimeout.
+type ContextCanceler interface {
+	CancelContext(ctx context.Context, i interface{}, err error)
+}
+
+// BatchCanceler is an optional interface of a Processor whose cancels are cheaper in bulk, such as a network write.
+// If a Processor implements it, Process, ProcessConcurrently and ProcessWithErrors pass the inputs they drain
+// once the context is done to CancelBatch rather than to `Processor.Cancel` one at a time, with a *CanceledError.
+// Each batch holds the inputs that are ready at once, up to 100 by default, which can be changed with WithCancelBatchSize.
+// The inputs that fail or are interrupted while they are processed are still passed to `Processor.Cancel`.
+type BatchCanceler interface {
+	CancelBatch(is []interface{}, err error)
+}
+
+// Setupper is an optional interface of a Processor that acquires resources, such as a DB connection, before its first input.
+// If a Processor implements it, Setup is called once by the process stages, including ProcessKeyed, ProcessPriority and ProcessAutoscale,
+// and by ProcessBatch and ProcessBatchConcurrently, before the first call to `Processor.Process`.
+// If Setup fails, every input of the stage fails with a *SetupError, which is passed to `Processor.Cancel` wrapped in a *ProcessError.
+type Setupper = core.Setupper
+
+// Teardowner is an optional interface of a Processor that releases the resources it acquired in Setup.
+// If a Processor implements it, Teardown is called once by the process stages after the last call to `Processor.Process`,
+// once the `in <-chan interface{}` is closed, which the stages wait for even after the context is canceled,
+// so that the inputs that are canceled are passed to `Processor.Cancel` before the Processor is torn down.
+// It also runs if `Processor.Process` panics and WithPanicRecovery(false) is set.
+// Its error is logged, or passed to the func set by WithTeardownErrors.
+type Teardowner = core.Teardowner
+
+// SetupError is the error of the inputs of a stage whose `Setupper.Setup` failed, which wraps the error of Setup
+type SetupError = core.SetupError
+
+// WorkerCloner is an optional interface of a Processor whose resources cannot be shared by the workers of the concurrent stages.
+// If a Processor implements it, the concurrent stages, such as ProcessConcurrently, ProcessKeyed, ProcessPriority,
+// ProcessAutoscale and ProcessBatchConcurrently, call CloneForWorker once for each worker,
+// and set up and tear down each clone, so that each worker owns its resources.
+// Otherwise, or if CloneForWorker returns nil, as the Middleware Processors do when the Processor they wrap cannot be cloned,
+// the workers share the Processor, which is set up and torn down once.
+type WorkerCloner interface {
+	CloneForWorker() Processor
+}
+
+// NewProcessor creates a process and cancel func.
+// The cancel func may be nil, in which case canceled inputs are ignored.
 func NewProcessor(
 	process func(ctx context.Context, i interface{}) (interface{}, error),
 	cancel func(i interface{}, err error),
@@ -33,5 +87,29 @@ func (p *processor) Process(ctx context.Context, i interface{}) (interface{}, er
 }
 
 func (p *processor) Cancel(i interface{}, err error) {
-	p.cancel(i, err)
+	if p.cancel != nil {
+		p.cancel(i, err)
+	}
+}
+
+// ProcessorFunc is an adapter that allows a bare process func to be used as a Processor.
+// Its Cancel method is a no-op.
+type ProcessorFunc func(ctx context.Context, i interface{}) (interface{}, error)
+
+// Process calls f(ctx, i)
+func (f ProcessorFunc) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	return f(ctx, i)
+}
+
+// Cancel does nothing
+func (f ProcessorFunc) Cancel(interface{}, error) {}
+
+// cancelContext passes i to `ContextCanceler.CancelContext` if p implements it, otherwise to `Processor.Cancel`.
+// It lets the Processors that wrap another Processor pass the context on.
+func cancelContext(ctx context.Context, p Processor, i interface{}, err error) {
+	if c, ok := p.(ContextCanceler); ok {
+		c.CancelContext(ctx, i, err)
+		return
+	}
+	p.Cancel(i, err)
 }
@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewProcessor(t *testing.T) {
+	t.Run("cancel is passed canceled inputs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var canceled []interface{}
+		p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			return i, nil
+		}, func(i interface{}, err error) {
+			canceled = append(canceled, i)
+		})
+		for range Process(ctx, p, Emit(1, 2, 3)) {
+			t.Error("nothing should be processed after the context is canceled")
+		}
+
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, canceled) {
+			t.Errorf("canceled = %+v, want %+v", canceled, want)
+		}
+	})
+
+	t.Run("nil cancel does not panic when the context is canceled mid-stream", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		p := NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(20 * time.Millisecond):
+				return i, nil
+			}
+		}, nil)
+
+		var outs []interface{}
+		for o := range ProcessConcurrently(ctx, 2, p, Emit(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)) {
+			outs = append(outs, o)
+		}
+		if len(outs) == 0 || len(outs) == 10 {
+			t.Errorf("len(outs) = %d, want some but not all inputs processed", len(outs))
+		}
+	})
+}
+
+func TestProcessorFunc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	double := ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+		time.Sleep(40 * time.Millisecond)
+		return i.(int) * 2, nil
+	})
+
+	var outs []interface{}
+	for o := range Process(ctx, double, Emit(1, 2, 3, 4, 5)) {
+		outs = append(outs, o)
+	}
+	if want := []interface{}{2, 4, 6}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+}
+
+// ctxKey is the type of the context values in the tests
+type ctxKey string
+
+// contextCanceler records the contexts passed to CancelContext
+type contextCanceler struct {
+	ProcessorFunc
+	canceled []interface{}
+	ctxs     []context.Context
+}
+
+func (c *contextCanceler) CancelContext(ctx context.Context, i interface{}, err error) {
+	c.canceled = append(c.canceled, i)
+	c.ctxs = append(c.ctxs, ctx)
+}
+
+func TestContextCanceler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey("key"), "value"))
+	cancel()
+
+	c := &contextCanceler{ProcessorFunc: func(ctx context.Context, i interface{}) (interface{}, error) {
+		return i, nil
+	}}
+	p := Retry(2, ConstantBackoff(0), c)
+	for range Process(ctx, p, Emit(1, 2), WithCancelTimeout(20*time.Millisecond)) {
+		t.Error("nothing should be processed after the context is canceled")
+	}
+
+	// CancelContext is used instead of Cancel, even through a wrapping Processor
+	if want := []interface{}{1, 2}; !reflect.DeepEqual(want, c.canceled) {
+		t.Fatalf("canceled = %+v, want %+v", c.canceled, want)
+	}
+	// Each context keeps the values of the stage context and is only done once CancelContext returns
+	for _, cctx := range c.ctxs {
+		if v := cctx.Value(ctxKey("key")); v != "value" {
+			t.Errorf("value = %v, want value", v)
+		}
+		if cctx.Err() == nil {
+			t.Error("the context should be done after CancelContext returns")
+		}
+	}
+
+	// While CancelContext runs, its context is done after the grace period
+	var elapsed time.Duration
+	var errDuring error
+	slow := &struct {
+		Processor
+		ContextCanceler
+	}{NewProcessor(nil, nil), cancelerFunc(func(ctx context.Context, i interface{}, err error) {
+		start := time.Now()
+		errDuring = ctx.Err()
+		<-ctx.Done()
+		elapsed = time.Since(start)
+	})}
+	for range Process(ctx, slow, Emit(1), WithCancelTimeout(20*time.Millisecond)) {
+	}
+	if errDuring != nil {
+		t.Errorf("err = %v, want the context to be live during the grace period", errDuring)
+	}
+	if elapsed < 20*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("elapsed = %s, want about 20ms", elapsed)
+	}
+}
+
+// cancelerFunc is an adapter that allows a func to be used as a ContextCanceler
+type cancelerFunc func(ctx context.Context, i interface{}, err error)
+
+func (f cancelerFunc) CancelContext(ctx context.Context, i interface{}, err error) {
+	f(ctx, i, err)
+}
@@ -0,0 +1,178 @@
+// Package prom exports the metrics of pipeline stages to Prometheus.
+//
+// A Collector registers one set of metrics for all the stages of a pipeline, labeled only by the name of the stage,
+// so that the number of series does not grow with the inputs. Attach it to a stage with the option of Stage,
+// and wrap the Processor of the stage with Instrument to count its errors and the size of its batches:
+//
+//	c := prom.MustNewCollector(prometheus.DefaultRegisterer)
+//	out := pipeline.ProcessConcurrently(ctx, 8, c.Instrument("enrich", p), in, c.Stage("enrich"))
+package prom
+
+import (
+	"context"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option configures a Collector
+type Option func(*config)
+
+// config holds the settings of a Collector
+type config struct {
+	namespace    string
+	buckets      []float64
+	batchBuckets []float64
+}
+
+// newConfig applies opts to the default config
+func newConfig(opts []Option) *config {
+	c := &config{
+		namespace:    "pipeline",
+		buckets:      prometheus.DefBuckets,
+		batchBuckets: prometheus.ExponentialBuckets(1, 2, 11),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithNamespace sets the prefix of the names of the metrics, which is "pipeline" by default
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithBuckets sets the buckets in seconds of the process_duration_seconds histogram, which are prometheus.DefBuckets by default
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// WithBatchBuckets sets the buckets of the batch_size histogram, which are the powers of 2 from 1 to 1024 by default
+func WithBatchBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.batchBuckets = buckets
+	}
+}
+
+// Collector holds the metrics of the stages of a pipeline:
+//   - items_in_total, items_out_total and items_canceled_total count the inputs that the stages read, emit and cancel
+//   - errors_total counts the errors returned by the processors wrapped with Instrument
+//   - process_duration_seconds observes the duration of each call to `Processor.Process`
+//   - batch_size observes the size of the batches processed by the processors wrapped with Instrument
+type Collector struct {
+	in, out, canceled, errors *prometheus.CounterVec
+	duration, batchSize       *prometheus.HistogramVec
+}
+
+// NewCollector creates the metrics of a Collector and registers them with `reg`,
+// which is the prometheus.DefaultRegisterer if it is nil
+func NewCollector(reg prometheus.Registerer, opts ...Option) (*Collector, error) {
+	cfg := newConfig(opts)
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	labels := []string{"stage"}
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: cfg.namespace, Name: name, Help: help}, labels)
+	}
+	c := &Collector{
+		in:       counter("items_in_total", "Number of inputs read by the stage."),
+		out:      counter("items_out_total", "Number of results emitted by the stage."),
+		canceled: counter("items_canceled_total", "Number of inputs canceled by the stage."),
+		errors:   counter("errors_total", "Number of errors returned by the processor of the stage."),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Name:      "process_duration_seconds",
+			Help:      "Duration of the calls to the processor of the stage.",
+			Buckets:   cfg.buckets,
+		}, labels),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Name:      "batch_size",
+			Help:      "Number of inputs of the batches processed by the stage.",
+			Buckets:   cfg.batchBuckets,
+		}, labels),
+	}
+	for _, collector := range []prometheus.Collector{c.in, c.out, c.canceled, c.errors, c.duration, c.batchSize} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// MustNewCollector is like NewCollector but panics if the metrics cannot be registered
+func MustNewCollector(reg prometheus.Registerer, opts ...Option) *Collector {
+	c, err := NewCollector(reg, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Stage returns the option that reports the events of a stage under the label `stage`.
+// The series of the stage are resolved once here, so that the workers of the stage only update them.
+// `stage` must be the name of a stage, not a value that depends on the inputs.
+func (c *Collector) Stage(stage string) pipeline.Option {
+	return pipeline.WithMetrics(stage, &stageMetrics{
+		in:       c.in.WithLabelValues(stage),
+		out:      c.out.WithLabelValues(stage),
+		canceled: c.canceled.WithLabelValues(stage),
+		duration: c.duration.WithLabelValues(stage),
+	})
+}
+
+// Instrument wraps `p` to count its errors and observe the size of its batches under the label `stage`
+func (c *Collector) Instrument(stage string, p pipeline.Processor) pipeline.Processor {
+	return &instrumented{
+		Processor: p,
+		errors:    c.errors.WithLabelValues(stage),
+		batchSize: c.batchSize.WithLabelValues(stage),
+	}
+}
+
+// stageMetrics is the pipeline.Metrics of a single stage, whose series are already resolved
+type stageMetrics struct {
+	in, out, canceled prometheus.Counter
+	duration          prometheus.Observer
+}
+
+func (m *stageMetrics) ItemReceived(string) {
+	m.in.Inc()
+}
+
+func (m *stageMetrics) ItemEmitted(string) {
+	m.out.Inc()
+}
+
+func (m *stageMetrics) ItemCanceled(string) {
+	m.canceled.Inc()
+}
+
+func (m *stageMetrics) ProcessDuration(_ string, d time.Duration) {
+	m.duration.Observe(d.Seconds())
+}
+
+// instrumented is the pipeline.Processor returned by Instrument
+type instrumented struct {
+	pipeline.Processor
+	errors    prometheus.Counter
+	batchSize prometheus.Observer
+}
+
+func (i *instrumented) Process(ctx context.Context, in interface{}) (interface{}, error) {
+	if batch, ok := in.([]interface{}); ok {
+		i.batchSize.Observe(float64(len(batch)))
+	}
+	out, err := i.Processor.Process(ctx, in)
+	if err != nil {
+		i.errors.Inc()
+	}
+	return out, err
+}
@@ -0,0 +1,32 @@
+package prom_test
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/deliveryhero/pipeline"
+	"github.com/deliveryhero/pipeline/example/processors"
+	"github.com/deliveryhero/pipeline/prom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func ExampleCollector() {
+	ctx := context.Background()
+
+	// Register the metrics of the pipeline with a registry that is exposed on /metrics
+	reg := prometheus.NewRegistry()
+	c := prom.MustNewCollector(reg)
+	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+
+	// Report the events of the stage as "multiply", and count the errors of its processor
+	p := pipeline.ProcessConcurrently(ctx, 4,
+		c.Instrument("multiply", &processors.Multiplier{Factor: 10}),
+		pipeline.Emit(1, 2, 3, 4, 5, 6),
+		c.Stage("multiply"),
+	)
+	for result := range p {
+		log.Printf("result: %d\n", result)
+	}
+}
@@ -0,0 +1,79 @@
+package prom
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// failOdd fails on odd ints, and passes batches on
+var failOdd = pipeline.NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+	if batch, ok := i.([]interface{}); ok {
+		return batch, nil
+	}
+	if i.(int)%2 == 1 {
+		return nil, errors.New("odd")
+	}
+	return i, nil
+}, func(interface{}, error) {})
+
+// scrape returns the lines of the metrics exposed by reg that start with prefix
+func scrape(t *testing.T, reg *prometheus.Registry, prefix string) []string {
+	t.Helper()
+	srv := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := MustNewCollector(reg, WithNamespace("test"))
+	ctx := context.Background()
+	out := pipeline.ProcessConcurrently(ctx, 4, c.Instrument("check", failOdd), pipeline.Emit(1, 2, 3, 4, 5), c.Stage("check"))
+	out = pipeline.ProcessBatch(ctx, 2, time.Second, c.Instrument("count", failOdd), out, c.Stage("count"))
+	for range out {
+	}
+
+	want := map[string]bool{
+		`test_items_in_total{stage="check"} 5`:                 true,
+		`test_items_out_total{stage="check"} 2`:                true,
+		`test_items_canceled_total{stage="check"} 3`:           true,
+		`test_errors_total{stage="check"} 3`:                   true,
+		`test_process_duration_seconds_count{stage="check"} 5`: true,
+		`test_batch_size_count{stage="count"} 1`:               true,
+		`test_batch_size_sum{stage="count"} 2`:                 true,
+	}
+	for _, line := range scrape(t, reg, "test_") {
+		delete(want, line)
+	}
+	for line := range want {
+		t.Errorf("%s was not scraped", line)
+	}
+
+	if _, err := NewCollector(reg, WithNamespace("test")); err == nil {
+		t.Error("err = nil, want an error for metrics that are already registered")
+	}
+}
@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
+)
+
+// accountingCase is a random run of a process stage for the accounting property
+type accountingCase struct {
+	stage       string
+	items       int
+	concurrency int
+	batchSize   int
+	maxDuration time.Duration
+	errRate     float64
+	cancelAfter time.Duration
+	seed        int64
+}
+
+// accountingStages run a process stage on `in` and return its out chan and the chan of its dead letters, if it has one
+var accountingStages = map[string]func(ctx context.Context, c accountingCase, p Processor, in <-chan interface{}) (<-chan interface{}, <-chan error){
+	"Process": func(ctx context.Context, c accountingCase, p Processor, in <-chan interface{}) (<-chan interface{}, <-chan error) {
+		return Process(ctx, p, in), nil
+	},
+	"ProcessConcurrently": func(ctx context.Context, c accountingCase, p Processor, in <-chan interface{}) (<-chan interface{}, <-chan error) {
+		return ProcessConcurrently(ctx, c.concurrency, p, in), nil
+	},
+	"ProcessConcurrently/lazy": func(ctx context.Context, c accountingCase, p Processor, in <-chan interface{}) (<-chan interface{}, <-chan error) {
+		return ProcessConcurrently(ctx, c.concurrency, p, in, WithLazyWorkers(c.maxDuration/4+time.Microsecond)), nil
+	},
+	"ProcessConcurrentlyOrdered": func(ctx context.Context, c accountingCase, p Processor, in <-chan interface{}) (<-chan interface{}, <-chan error) {
+		return ProcessConcurrentlyOrdered(ctx, c.concurrency, p, in), nil
+	},
+	"ProcessWithErrors": func(ctx context.Context, c accountingCase, p Processor, in <-chan interface{}) (<-chan interface{}, <-chan error) {
+		return ProcessWithErrors(ctx, p, in)
+	},
+	"ProcessBatch": func(ctx context.Context, c accountingCase, p Processor, in <-chan interface{}) (<-chan interface{}, <-chan error) {
+		return ProcessBatch(ctx, c.batchSize, c.maxDuration, p, in), nil
+	},
+	"ProcessBatchConcurrently": func(ctx context.Context, c accountingCase, p Processor, in <-chan interface{}) (<-chan interface{}, <-chan error) {
+		return ProcessBatchConcurrently(ctx, c.concurrency, c.batchSize, c.maxDuration, p, in), nil
+	},
+}
+
+// randomAccountingCase draws a case of `stage` from rng
+func randomAccountingCase(rng *rand.Rand, stage string) accountingCase {
+	maxDuration := time.Duration(rng.Intn(2000)) * time.Microsecond
+	return accountingCase{
+		stage:       stage,
+		items:       rng.Intn(200),
+		concurrency: 1 + rng.Intn(8),
+		batchSize:   1 + rng.Intn(10),
+		maxDuration: maxDuration,
+		errRate:     rng.Float64() / 2,
+		// Cancel anywhere from right away to after the last input
+		cancelAfter: time.Duration(rng.Int63n(int64(50*time.Millisecond) + 1)),
+		seed:        rng.Int63(),
+	}
+}
+
+// accountingProcessor fails a random share of its inputs after a random duration, and records the inputs it cancels
+type accountingProcessor struct {
+	mu          sync.Mutex
+	rng         *rand.Rand
+	errRate     float64
+	maxDuration time.Duration
+	canceled    []interface{}
+}
+
+func (p *accountingProcessor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	p.mu.Lock()
+	fail := p.rng.Float64() < p.errRate
+	d := time.Duration(p.rng.Int63n(int64(p.maxDuration) + 1))
+	p.mu.Unlock()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if fail {
+		return nil, errProcess
+	}
+	return i, nil
+}
+
+func (p *accountingProcessor) Cancel(i interface{}, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.canceled = append(p.canceled, batchInputs(i)...)
+}
+
+// batchInputs returns the inputs of a batch, or i itself if it is not a batch
+func batchInputs(i interface{}) []interface{} {
+	if is, ok := i.([]interface{}); ok {
+		return is
+	}
+	return []interface{}{i}
+}
+
+// checkAccounting runs c and checks that every input is either sent to out, canceled or dead-lettered exactly once,
+// that out is closed, and that the stage leaves no goroutine behind
+func checkAccounting(t *testing.T, c accountingCase) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	inputs := make([]interface{}, c.items)
+	for k := range inputs {
+		inputs[k] = k
+	}
+	p := &accountingProcessor{rng: rand.New(rand.NewSource(c.seed)), errRate: c.errRate, maxDuration: c.maxDuration} // #nosec
+	ctx, cancel := context.WithTimeout(context.Background(), c.cancelAfter)
+	defer cancel()
+	out, errs := accountingStages[c.stage](ctx, c, p, Emit(inputs...))
+
+	var outs, dead []interface{}
+	timeout := time.After(10 * time.Second)
+	for out != nil || errs != nil {
+		select {
+		case o, open := <-out:
+			if !open {
+				out = nil
+				continue
+			}
+			outs = append(outs, o)
+		case err, open := <-errs:
+			if !open {
+				errs = nil
+				continue
+			}
+			var pErr *ProcessError
+			if !errors.As(err, &pErr) {
+				t.Fatalf("%+v: dead letter %v is not a *ProcessError", c, err)
+			}
+			dead = append(dead, batchInputs(pErr.Input)...)
+		case <-timeout:
+			t.Fatalf("%+v: out is still open", c)
+		}
+	}
+	p.mu.Lock()
+	canceled := append(append([]interface{}(nil), p.canceled...), dead...)
+	p.mu.Unlock()
+	pipelinetest.AssertAllItemsAccountedFor(t, inputs, outs, canceled)
+
+	// The goroutines of the stage exit once out is closed, give them a moment to return
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%+v: goroutines = %d, want <= %d", c, after, before)
+	}
+}
+
+// TestProcessAccounting checks the accounting property on random runs of every process stage.
+// A failure logs its case, whose seed reproduces the decisions of the processor.
+func TestProcessAccounting(t *testing.T) {
+	runs := 20
+	if testing.Short() {
+		runs = 5
+	}
+	seed := time.Now().UnixNano()
+	t.Logf("seed = %d", seed)
+	rng := rand.New(rand.NewSource(seed)) // #nosec
+	for stage := range accountingStages {
+		t.Run(stage, func(t *testing.T) {
+			for n := 0; n < runs; n++ {
+				checkAccounting(t, randomAccountingCase(rng, stage))
+			}
+		})
+	}
+}
+
+// FuzzProcessAccounting checks the accounting property on the runs drawn from the fuzzed seeds,
+// run it with `go test -fuzz FuzzProcessAccounting`
+func FuzzProcessAccounting(f *testing.F) {
+	for _, seed := range []int64{0, 1, 42} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		rng := rand.New(rand.NewSource(seed)) // #nosec
+		for _, stage := range []string{"Process", "ProcessConcurrently", "ProcessConcurrently/lazy", "ProcessConcurrentlyOrdered", "ProcessWithErrors", "ProcessBatch", "ProcessBatchConcurrently"} {
+			t.Run(stage, func(t *testing.T) {
+				checkAccounting(t, randomAccountingCase(rng, stage))
+			})
+		}
+	})
+}
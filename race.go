@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+)
+
+// Race creates a Processor that calls the Process method of each of `ps` at once on the same input,
+// such as lookups on several replicas, and returns the first result that succeeds.
+// The calls share a context derived from the stage context, which is canceled as soon as a call succeeds,
+// so the calls that lost are abandoned: they should return once their context is done, and their results are discarded.
+// A call that panics counts as a failure with a *PanicError. Race only fails if every call fails,
+// with an error that joins the errors of all of the calls, or with the `Context.Err()` if the stage context is done first.
+// Failed and canceled inputs are passed to the `Processor.Cancel` of the first Processor.
+// Race panics if `ps` is empty.
+func Race(ps ...Processor) Processor {
+	if len(ps) == 0 {
+		panic("pipeline: race of no processors")
+	}
+	return race(ps)
+}
+
+// race implements Processor
+type race []Processor
+
+// raceResult is the outcome of a call of a race
+type raceResult struct {
+	out interface{}
+	err error
+}
+
+func (r race) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The results chan is buffered so that the calls that lost never block
+	results := make(chan raceResult, len(r))
+	for _, p := range r {
+		go func(p Processor) {
+			defer func() {
+				if v := recover(); v != nil {
+					results <- raceResult{err: &PanicError{Value: v, Stack: debug.Stack()}}
+				}
+			}()
+			out, err := p.Process(ctx, i)
+			results <- raceResult{out, err}
+		}(p)
+	}
+	errs := make([]error, 0, len(r))
+	for len(errs) < len(r) {
+		select {
+		case res := <-results:
+			if res.err == nil {
+				return res.out, nil
+			}
+			errs = append(errs, res.err)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (r race) Cancel(i interface{}, err error) {
+	r[0].Cancel(i, err)
+}
+
+func (r race) CancelContext(ctx context.Context, i interface{}, err error) {
+	cancelContext(ctx, r[0], i, err)
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package pipeline
+
+// raceEnabled tells the tests whether the race detector is on, which makes sync.Pool drop a part of the values put back at random
+const raceEnabled = true
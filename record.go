@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// recordHeaderSize is the size of the header of a record written by Record:
+// the arrival time in Unix nanoseconds, the size of the encoded input and its CRC-32, all big-endian
+const recordHeaderSize = 8 + 4 + 4
+
+// maxRecordSize bounds the size of a record that Replay reads, so that a corrupted size cannot exhaust the memory
+const maxRecordSize = 64 << 20
+
+// ErrCorruptRecord is wrapped by the *ReplayError of a record whose checksum does not match its content
+var ErrCorruptRecord = errors.New("corrupt record")
+
+// ReplayError is sent by Replay for a record that cannot be replayed
+type ReplayError struct {
+	// Offset is the byte offset of the record in the reader
+	Offset int64
+	Err    error
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("replay at offset %d: %s", e.Offset, e.Err)
+}
+
+func (e *ReplayError) Unwrap() error {
+	return e.Err
+}
+
+// Record passes the inputs from the `in <-chan interface{}` to the out `<-chan interface{}` untouched,
+// and writes each of them to `w` as it arrives, encoded by `encode`, so that Replay can emit them again later, such as in a test:
+//
+//	f, err := os.Create("orders.rec")
+//	// ...
+//	in = pipeline.Record(ctx, f, json.Marshal, in)
+//
+// Each record is written with a single call to `w.Write` and holds the time the input arrived, its size and its checksum.
+// The inputs that cannot be encoded are not recorded, and the first error of `w` stops the recording,
+// but the inputs are always passed on. Use WithErrors to handle these errors rather than skipping them silently.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func Record(ctx context.Context, w io.Writer, encode func(i interface{}) ([]byte, error), in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var buf []byte
+		recording := true
+		for i := range in {
+			if recording {
+				var err error
+				buf, err = appendRecord(buf[:0], c.Clock.Now(), encode, i)
+				if err == nil {
+					_, err = w.Write(buf)
+					recording = err == nil
+				}
+				if err != nil && c.errored != nil {
+					c.errored(err)
+				}
+			}
+			if !send(ctx, i, out) {
+				// Drop the remaining inputs so that the stages before Record are never blocked
+				discard(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// appendRecord appends the record of i, which arrived at t, to buf
+func appendRecord(buf []byte, t time.Time, encode func(i interface{}) ([]byte, error), i interface{}) ([]byte, error) {
+	b, err := encode(i)
+	if err != nil {
+		return buf, err
+	}
+	if len(b) > maxRecordSize {
+		return buf, fmt.Errorf("pipeline: record of %d bytes is larger than %d bytes", len(b), maxRecordSize)
+	}
+	buf = binary.BigEndian.AppendUint64(buf, uint64(t.UnixNano()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(b)))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(b))
+	return append(buf, b...), nil
+}
+
+// Replay emits the inputs that Record wrote to `r` to the out `<-chan interface{}`, decoded by `decode`, in the order they were recorded.
+// They are emitted as fast as they are read by default, use WithRecordedTiming to wait between them as long as between their arrivals.
+// A record that is corrupt or that `decode` fails on is skipped, and its *ReplayError is sent to the errs chan.
+// A record that is cut short stops the replay, after its *ReplayError, since the records after it cannot be found.
+// The errs chan must be read along with the out chan, since sending an error blocks until it is received or the context is canceled.
+// Both chans are closed at the end of `r`, after an error that stops the replay, or when the context is canceled.
+func Replay(ctx context.Context, r io.Reader, decode func(b []byte) (interface{}, error), opts ...Option) (<-chan interface{}, <-chan error) {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	errs := make(chan error)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		br := bufio.NewReader(r)
+		// report sends the *ReplayError of err at offset and returns false once the context is canceled
+		report := func(offset int64, err error) bool {
+			select {
+			case errs <- &ReplayError{Offset: offset, Err: err}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		var offset int64
+		var last time.Time
+		header := make([]byte, recordHeaderSize)
+		for ctx.Err() == nil {
+			if _, err := io.ReadFull(br, header); err == io.EOF {
+				return
+			} else if err != nil {
+				report(offset, err)
+				return
+			}
+			at := time.Unix(0, int64(binary.BigEndian.Uint64(header)))
+			size := binary.BigEndian.Uint32(header[8:])
+			if size > maxRecordSize {
+				report(offset, fmt.Errorf("%w: size of %d bytes", ErrCorruptRecord, size))
+				return
+			}
+			b := make([]byte, size)
+			if _, err := io.ReadFull(br, b); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				report(offset, err)
+				return
+			}
+			recordOffset := offset
+			offset += recordHeaderSize + int64(size)
+			if crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(header[12:]) {
+				if !report(recordOffset, ErrCorruptRecord) {
+					return
+				}
+				continue
+			}
+			i, err := decode(b)
+			if err != nil {
+				if !report(recordOffset, err) {
+					return
+				}
+				continue
+			}
+			if c.recordedTiming && !last.IsZero() && at.After(last) {
+				wait := c.Clock.NewTimer(at.Sub(last))
+				select {
+				case <-wait.C():
+				case <-ctx.Done():
+					wait.Stop()
+					return
+				}
+			}
+			last = at
+			if !send(ctx, i, out) {
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
+// WithRecordedTiming makes Replay wait between the inputs as long as between their arrivals when they were recorded
+func WithRecordedTiming() Option {
+	return func(c *config) {
+		c.recordedTiming = true
+	}
+}
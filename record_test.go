@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline/pipelinetest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	encode := func(i interface{}) ([]byte, error) {
+		return json.Marshal(i)
+	}
+	decode := func(b []byte) (interface{}, error) {
+		var s string
+		err := json.Unmarshal(b, &s)
+		return s, err
+	}
+	// replay replays r and returns what it emitted and the errors it sent
+	replay := func(r io.Reader, opts ...Option) ([]interface{}, []error) {
+		out, errs := Replay(context.Background(), r, decode, opts...)
+		var outs []interface{}
+		var errors []error
+		for out != nil || errs != nil {
+			select {
+			case o, open := <-out:
+				if !open {
+					out = nil
+					continue
+				}
+				outs = append(outs, o)
+			case err, open := <-errs:
+				if !open {
+					errs = nil
+					continue
+				}
+				errors = append(errors, err)
+			}
+		}
+		return outs, errors
+	}
+	// record returns the records of the strings
+	record := func(ss ...string) []byte {
+		var b bytes.Buffer
+		for range Record(context.Background(), &b, encode, Emit(toInterfaces(ss)...)) {
+		}
+		return b.Bytes()
+	}
+
+	t.Run("the recorded inputs are passed on and replayed", func(t *testing.T) {
+		var b bytes.Buffer
+		var outs []interface{}
+		for o := range Record(context.Background(), &b, encode, Emit("a", "b", "c")) {
+			outs = append(outs, o)
+		}
+		want := []interface{}{"a", "b", "c"}
+		if !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		replayed, errs := replay(&b)
+		if !reflect.DeepEqual(want, replayed) || len(errs) > 0 {
+			t.Errorf("replayed = %+v and %+v, want %+v", replayed, errs, want)
+		}
+	})
+
+	t.Run("a corrupt record is skipped and reported", func(t *testing.T) {
+		b := record("a", "b", "c")
+		// Flip a byte of the content of "b", which starts after the record of "a"
+		offset := recordHeaderSize + len(`"a"`)
+		b[offset+recordHeaderSize+1] ^= 0xff
+		replayed, errs := replay(bytes.NewReader(b))
+		if want := []interface{}{"a", "c"}; !reflect.DeepEqual(want, replayed) {
+			t.Errorf("replayed = %+v, want %+v", replayed, want)
+		}
+		var rErr *ReplayError
+		if len(errs) != 1 || !errors.As(errs[0], &rErr) || rErr.Offset != int64(offset) || !errors.Is(errs[0], ErrCorruptRecord) {
+			t.Errorf("errs = %+v, want a *ReplayError of ErrCorruptRecord at offset %d", errs, offset)
+		}
+	})
+
+	t.Run("a record that cannot be decoded is skipped and reported", func(t *testing.T) {
+		var b bytes.Buffer
+		for range Record(context.Background(), &b, encode, Emit("a", 1, "c")) {
+		}
+		replayed, errs := replay(&b)
+		if want := []interface{}{"a", "c"}; !reflect.DeepEqual(want, replayed) {
+			t.Errorf("replayed = %+v, want %+v", replayed, want)
+		}
+		var jErr *json.UnmarshalTypeError
+		if len(errs) != 1 || !errors.As(errs[0], &jErr) {
+			t.Errorf("errs = %+v, want the error of decode", errs)
+		}
+	})
+
+	t.Run("a record that is cut short stops the replay", func(t *testing.T) {
+		b := record("a", "b")
+		replayed, errs := replay(bytes.NewReader(b[:len(b)-1]))
+		if want := []interface{}{"a"}; !reflect.DeepEqual(want, replayed) {
+			t.Errorf("replayed = %+v, want %+v", replayed, want)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], io.ErrUnexpectedEOF) {
+			t.Errorf("errs = %+v, want %s", errs, io.ErrUnexpectedEOF)
+		}
+	})
+
+	t.Run("the errors of encode and of the writer are reported and the inputs are passed on", func(t *testing.T) {
+		failed := errors.New("failed")
+		w := &failingWriter{n: 1, err: failed}
+		var errs []error
+		var outs []interface{}
+		for o := range Record(context.Background(), w, encode, Emit("a", func() {}, "b", "c"), WithErrors(func(err error) {
+			errs = append(errs, err)
+		})) {
+			outs = append(outs, o)
+		}
+		if len(outs) != 4 {
+			t.Errorf("out = %+v, want 4 inputs", outs)
+		}
+		// The func cannot be encoded, and the writer fails on "b", which stops the recording before "c"
+		var jErr *json.UnsupportedTypeError
+		if len(errs) != 2 || !errors.As(errs[0], &jErr) || errs[1] != failed {
+			t.Errorf("errs = %+v, want the error of encode and %s", errs, failed)
+		}
+	})
+
+	t.Run("WithRecordedTiming waits as long as between the arrivals", func(t *testing.T) {
+		start := time.Unix(1000, 0)
+		var b []byte
+		for k, s := range []string{"a", "b", "c"} {
+			b, _ = appendRecord(b, start.Add(time.Duration(k)*time.Minute), encode, s)
+		}
+		clock := pipelinetest.NewFakeClock(time.Time{})
+		out, errs := Replay(context.Background(), bytes.NewReader(b), decode, WithRecordedTiming(), WithClock(clock))
+		go func() {
+			for range errs {
+			}
+		}()
+		if o := <-out; o != "a" {
+			t.Errorf("replayed %v, want a", o)
+		}
+		for _, want := range []string{"b", "c"} {
+			clock.BlockUntil(1)
+			clock.Advance(time.Minute - time.Nanosecond)
+			select {
+			case o := <-out:
+				t.Fatalf("replayed %v before its time", o)
+			default:
+			}
+			clock.Advance(time.Nanosecond)
+			if o := <-out; o != want {
+				t.Errorf("replayed %v, want %s", o, want)
+			}
+		}
+		if _, open := <-out; open {
+			t.Error("out is still open")
+		}
+	})
+}
+
+// toInterfaces returns ss as a []interface{}
+func toInterfaces(ss []string) []interface{} {
+	is := make([]interface{}, len(ss))
+	for k, s := range ss {
+		is[k] = s
+	}
+	return is
+}
@@ -0,0 +1,106 @@
+// Package redisstream feeds pipelines from a Redis stream through a consumer group,
+// with the few commands it needs behind the Client interface, which is a thin wrapper over any Redis client.
+//
+// Source emits the messages of the stream as pipeline.Tracked inputs, acknowledges them with XACK once they leave the pipeline,
+// and claims the messages that other consumers of the group left pending for too long, such as when they crashed:
+//
+//	msgs := redisstream.Source(ctx, client, "orders", "billing", hostname, redisstream.WithMaxOutstanding(64))
+//	for o := range pipeline.Untrack(ctx, pipeline.TrackedProcess(ctx, p, msgs, pipeline.WithConcurrency(64))) {
+//		// ...
+//	}
+package redisstream
+
+import (
+	"context"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Message is an entry of a stream
+type Message struct {
+	ID     string
+	Values map[string]interface{}
+}
+
+// Client runs the stream commands of a consumer group
+type Client interface {
+	// ReadGroup reads up to `count` messages that were never delivered to the group, waiting up to `block` for one,
+	// as XREADGROUP GROUP group consumer COUNT count BLOCK block STREAMS stream > does.
+	// It returns no messages and no error when there are none.
+	ReadGroup(ctx context.Context, stream, group, consumer string, count int, block time.Duration) ([]Message, error)
+	// Ack acknowledges the messages of `ids`, as XACK does
+	Ack(ctx context.Context, stream, group string, ids ...string) error
+	// AutoClaim gives `consumer` up to `count` messages that have been pending for at least `minIdle`,
+	// scanning the pending messages from `start`, as XAUTOCLAIM does.
+	// It returns the start of the next scan, which is "0-0" once every pending message has been scanned.
+	AutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int) ([]Message, string, error)
+}
+
+// Option configures Source
+type Option func(*config)
+
+// config holds the settings of Source
+type config struct {
+	maxOutstanding int
+	block          time.Duration
+	minIdle        time.Duration
+	claimInterval  time.Duration
+	backoff        pipeline.BackoffStrategy
+	errored        func(err error)
+}
+
+// newConfig applies opts to the default config
+func newConfig(opts []Option) *config {
+	c := &config{
+		maxOutstanding: 100,
+		block:          5 * time.Second,
+		minIdle:        5 * time.Minute,
+		claimInterval:  time.Minute,
+		backoff:        pipeline.ExponentialBackoff(100*time.Millisecond, 10*time.Second),
+		errored:        func(error) {},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithMaxOutstanding sets how many messages Source lets into the pipeline before they are acknowledged, which is 100 by default
+func WithMaxOutstanding(n int) Option {
+	return func(c *config) {
+		c.maxOutstanding = n
+	}
+}
+
+// WithBlock sets how long each read waits for new messages, which is 5 seconds by default
+func WithBlock(block time.Duration) Option {
+	return func(c *config) {
+		c.block = block
+	}
+}
+
+// WithClaim sets how long a message must be pending before Source claims it, 5 minutes by default,
+// and how often Source looks for such messages, every minute by default.
+// `minIdle` must be longer than the time it takes to process a message, or messages are processed twice.
+func WithClaim(minIdle, interval time.Duration) Option {
+	return func(c *config) {
+		c.minIdle = minIdle
+		c.claimInterval = interval
+	}
+}
+
+// WithReadBackoff sets how long Source waits before reading again after a failure.
+// The default is an exponential backoff from 100ms to 10s.
+func WithReadBackoff(backoff pipeline.BackoffStrategy) Option {
+	return func(c *config) {
+		c.backoff = backoff
+	}
+}
+
+// WithErrors passes the errors of the commands to `errored`, which must not block
+func WithErrors(errored func(err error)) Option {
+	return func(c *config) {
+		c.errored = errored
+	}
+}
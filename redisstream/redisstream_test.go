@@ -0,0 +1,232 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// pending is a message delivered to a consumer and not acknowledged yet
+type pending struct {
+	consumer  string
+	delivered time.Time
+}
+
+// server is an in-memory stream with a single consumer group that implements Client
+type server struct {
+	mu         sync.Mutex
+	entries    []string
+	next       int
+	pending    map[string]pending
+	reads      []int
+	failReads  int
+	acked      []string
+	autoClaims int
+}
+
+func newServer(n int) *server {
+	s := &server{pending: map[string]pending{}}
+	for i := 0; i < n; i++ {
+		s.entries = append(s.entries, fmt.Sprintf("%d-0", i))
+	}
+	return s
+}
+
+func (s *server) ReadGroup(ctx context.Context, _, _, consumer string, count int, block time.Duration) ([]Message, error) {
+	s.mu.Lock()
+	s.reads = append(s.reads, count)
+	if s.failReads > 0 {
+		s.failReads--
+		s.mu.Unlock()
+		return nil, errors.New("connection reset")
+	}
+	if s.next == len(s.entries) {
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond):
+			return nil, nil
+		}
+	}
+	defer s.mu.Unlock()
+	var msgs []Message
+	for ; s.next < len(s.entries) && len(msgs) < count; s.next++ {
+		id := s.entries[s.next]
+		s.pending[id] = pending{consumer: consumer, delivered: time.Now()}
+		msgs = append(msgs, Message{ID: id})
+	}
+	return msgs, nil
+}
+
+func (s *server) Ack(_ context.Context, _, _ string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.pending, id)
+		s.acked = append(s.acked, id)
+	}
+	return nil
+}
+
+// AutoClaim scans the pending messages one at a time, so that each claim needs as many calls as there are pending messages
+func (s *server) AutoClaim(_ context.Context, _, _, consumer string, minIdle time.Duration, start string, _ int) ([]Message, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.autoClaims++
+	var ids []string
+	for id := range s.pending {
+		if id >= start {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, "0-0", nil
+	}
+	sort.Strings(ids)
+	next := "0-0"
+	if len(ids) > 1 {
+		next = ids[1]
+	}
+	p := s.pending[ids[0]]
+	if time.Since(p.delivered) < minIdle {
+		return nil, next, nil
+	}
+	s.pending[ids[0]] = pending{consumer: consumer, delivered: time.Now()}
+	return []Message{{ID: ids[0]}}, next, nil
+}
+
+func TestSource(t *testing.T) {
+	t.Run("no more than the max outstanding messages are emitted before they are acknowledged", func(t *testing.T) {
+		s := newServer(10)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Source(ctx, s, "in", "group", "c1", WithMaxOutstanding(3))
+		var ts []pipeline.Tracked
+		for n := 0; n < 3; n++ {
+			ts = append(ts, (<-out).(pipeline.Tracked))
+		}
+		select {
+		case o := <-out:
+			t.Fatalf("%v was emitted while 3 messages were outstanding", o)
+		case <-time.After(20 * time.Millisecond):
+		}
+		ts[0].Ack(nil)
+		ts[0].Ack(nil)
+		ts[1].Ack(errors.New("failed"))
+		for n := 0; n < 2; n++ {
+			ts = append(ts, (<-out).(pipeline.Tracked))
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if want := []string{"0-0"}; !reflect.DeepEqual(want, s.acked) {
+			t.Errorf("acked = %v, want %v", s.acked, want)
+		}
+		if _, ok := s.pending["1-0"]; !ok {
+			t.Error("the message acknowledged with an error is not pending anymore")
+		}
+		for _, count := range s.reads {
+			if count > 3 {
+				t.Errorf("read %d messages at once, want at most 3", count)
+			}
+		}
+	})
+
+	t.Run("the messages left pending by another consumer are claimed first", func(t *testing.T) {
+		s := newServer(4)
+		for _, id := range []string{"0-0", "1-0"} {
+			s.pending[id] = pending{consumer: "crashed", delivered: time.Now().Add(-time.Hour)}
+		}
+		s.next = 2
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Source(ctx, s, "in", "group", "c1", WithClaim(time.Minute, time.Hour))
+		var ids []string
+		for n := 0; n < 4; n++ {
+			t := (<-out).(pipeline.Tracked)
+			ids = append(ids, t.Value.(Message).ID)
+			t.Ack(nil)
+		}
+		if want := []string{"0-0", "1-0", "2-0", "3-0"}; !reflect.DeepEqual(want, ids) {
+			t.Errorf("ids = %v, want %v", ids, want)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.autoClaims != 2 {
+			t.Errorf("autoClaims = %d, want a single scan of the 2 pending messages", s.autoClaims)
+		}
+	})
+
+	t.Run("a message that is claimed while it is outstanding is not emitted twice", func(t *testing.T) {
+		s := newServer(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Source(ctx, s, "in", "group", "c1", WithClaim(0, time.Millisecond))
+		first := (<-out).(pipeline.Tracked)
+		select {
+		case o := <-out:
+			t.Fatalf("%v was emitted twice", o)
+		case <-time.After(20 * time.Millisecond):
+		}
+		first.Ack(nil)
+	})
+
+	t.Run("reads again after a failure", func(t *testing.T) {
+		s := newServer(2)
+		s.failReads = 2
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var mu sync.Mutex
+		var errs []error
+		out := Source(ctx, s, "in", "group", "c1",
+			WithReadBackoff(pipeline.ConstantBackoff(time.Millisecond)),
+			WithErrors(func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+			}))
+		for n := 0; n < 2; n++ {
+			(<-out).(pipeline.Tracked).Ack(nil)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(errs) != 2 {
+			t.Errorf("errs = %v, want 2 errors", errs)
+		}
+	})
+
+	t.Run("every message is acknowledged once through a pipeline", func(t *testing.T) {
+		s := newServer(100)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		identity := pipeline.ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			return i, nil
+		})
+		msgs := Source(ctx, s, "in", "group", "c1", WithMaxOutstanding(8))
+		out := pipeline.Untrack(ctx, pipeline.TrackedProcess(ctx, identity, msgs, pipeline.WithConcurrency(8)))
+		for n := 0; n < 100; n++ {
+			<-out
+		}
+		// The last message is acknowledged once it has been received
+		acked := func() int {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.acked)
+		}
+		for start := time.Now(); acked() < 100 && time.Since(start) < time.Second; {
+			time.Sleep(time.Millisecond)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.acked) != 100 || len(s.pending) != 0 {
+			t.Errorf("acked %d and left %d pending, want 100 and 0", len(s.acked), len(s.pending))
+		}
+	})
+}
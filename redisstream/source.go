@@ -0,0 +1,154 @@
+package redisstream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Source reads the messages of `stream` as `consumer` of `group` and emits them as a pipeline.Tracked whose `Value` is a Message.
+// At most the number of messages of WithMaxOutstanding are emitted and not yet acknowledged at any time,
+// so Source stops reading while the pipeline is busy.
+// A message that is acknowledged with nil, which pipeline.Untrack does when it leaves the pipeline, is acknowledged with XACK.
+// One that is acknowledged with an error stays pending, so that it is claimed again once it has been idle for the time of WithClaim.
+//
+// Every interval of WithClaim, Source claims the messages of the group that have been pending for too long and emits them
+// before it reads new ones, so that the messages of a consumer that crashed are not lost.
+// When a command fails, the error is passed to the func of WithErrors and Source tries again after a backoff.
+// Source stops and closes the out `<-chan interface{}` when the context is canceled.
+// The messages that are acknowledged after that are still acknowledged with XACK.
+func Source(ctx context.Context, client Client, stream, group, consumer string, opts ...Option) <-chan interface{} {
+	cfg := newConfig(opts)
+	s := &source{
+		client:   client,
+		stream:   stream,
+		group:    group,
+		consumer: consumer,
+		cfg:      cfg,
+		slots:    make(chan struct{}, cfg.maxOutstanding),
+		inFlight: make(map[string]bool),
+	}
+	release(s.slots, s.cfg.maxOutstanding)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		// cursor is where the next claim starts, nextClaim when the next scan of the pending messages starts
+		cursor, nextClaim := "0-0", time.Now()
+		for failures := 0; ctx.Err() == nil; {
+			// Wait for a free slot, then take every other free slot
+			select {
+			case <-s.slots:
+			case <-ctx.Done():
+				return
+			}
+			free := 1
+			for ; free < s.cfg.maxOutstanding && len(s.slots) > 0; free++ {
+				<-s.slots
+			}
+			var msgs []Message
+			var err error
+			if claiming := !time.Now().Before(nextClaim); claiming {
+				msgs, cursor, err = client.AutoClaim(ctx, stream, group, consumer, s.cfg.minIdle, cursor, free)
+				if err == nil && cursor == "0-0" {
+					nextClaim = time.Now().Add(s.cfg.claimInterval)
+				}
+			} else {
+				msgs, err = client.ReadGroup(ctx, stream, group, consumer, free, s.cfg.block)
+			}
+			if err != nil {
+				release(s.slots, free)
+				if ctx.Err() == nil {
+					failures = fail(ctx, s.cfg, err, failures)
+				}
+				continue
+			}
+			failures = 0
+			ts := s.track(msgs)
+			// The slots that were not used are freed straight away
+			release(s.slots, free-len(ts))
+			for n, t := range ts {
+				select {
+				case out <- t:
+				case <-ctx.Done():
+					// The messages that were not emitted stay pending until they are claimed
+					for _, t := range ts[n:] {
+						s.forget(t.Value.(Message).ID)
+					}
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// source holds the state of a Source
+type source struct {
+	client                  Client
+	stream, group, consumer string
+	cfg                     *config
+	// slots holds a token for each message that can be let into the pipeline
+	slots chan struct{}
+
+	mu sync.Mutex
+	// inFlight holds the IDs of the messages that were emitted and not acknowledged yet
+	inFlight map[string]bool
+}
+
+// track returns the messages of msgs that are not in flight already, which a claim can return
+// when a message takes longer than the min idle time, as Tracked that acknowledge them and free their slot
+func (s *source) track(msgs []Message) []pipeline.Tracked {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ts []pipeline.Tracked
+	for _, msg := range msgs {
+		if s.inFlight[msg.ID] {
+			continue
+		}
+		s.inFlight[msg.ID] = true
+		msg := msg
+		var once sync.Once
+		ts = append(ts, pipeline.Tracked{Value: msg, Ack: func(err error) {
+			// Only the first acknowledgement counts, so that a slot is never freed twice
+			once.Do(func() {
+				if err == nil {
+					if err := s.client.Ack(context.Background(), s.stream, s.group, msg.ID); err != nil {
+						s.cfg.errored(err)
+					}
+				}
+				s.forget(msg.ID)
+			})
+		}})
+	}
+	return ts
+}
+
+// forget frees the slot of the message of id
+func (s *source) forget(id string) {
+	s.mu.Lock()
+	delete(s.inFlight, id)
+	s.mu.Unlock()
+	s.slots <- struct{}{}
+}
+
+// release frees n slots
+func release(slots chan<- struct{}, n int) {
+	for ; n > 0; n-- {
+		slots <- struct{}{}
+	}
+}
+
+// fail reports err and waits for the backoff of the next failure, whose number it returns
+func fail(ctx context.Context, cfg *config, err error, failures int) int {
+	cfg.errored(err)
+	failures++
+	timer := time.NewTimer(cfg.backoff(failures))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return failures
+}
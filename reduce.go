@@ -0,0 +1,84 @@
+package pipeline
+
+import "context"
+
+// Reduce combines every input from the `in <-chan interface{}` into one value, starting with `seed`,
+// and sends that value to the out chan once the `in <-chan interface{}` is closed.
+// If the context is canceled first, nothing is sent, unless WithPartialOnCancel is set,
+// in which case the value accumulated so far is sent.
+// Either way, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func Reduce(ctx context.Context, seed interface{}, fn func(acc, i interface{}) interface{}, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		acc := seed
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					out <- acc
+					return
+				}
+				if ctx.Err() == nil {
+					acc = fn(acc, i)
+					continue
+				}
+			case <-ctx.Done():
+			}
+			// The context is canceled, keep dropping inputs while the partial value waits to be received
+			if c.partialOnCancel {
+				sendDropping(out, acc, in)
+			}
+			for range in {
+			}
+			return
+		}
+	}()
+	return out
+}
+
+// Scan is like Reduce, except that it sends the accumulated value to the out chan after every input.
+// After the context is canceled, nothing more is sent and the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func Scan(ctx context.Context, seed interface{}, fn func(acc, i interface{}) interface{}, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		acc := seed
+		for i := range in {
+			if ctx.Err() != nil {
+				break
+			}
+			acc = fn(acc, i)
+			select {
+			case out <- acc:
+			case <-ctx.Done():
+			}
+		}
+		for range in {
+		}
+	}()
+	return out
+}
+
+// WithPartialOnCancel makes Reduce send the value it has accumulated so far when the context is canceled
+func WithPartialOnCancel() Option {
+	return func(c *config) {
+		c.partialOnCancel = true
+	}
+}
+
+// sendDropping sends v to out while dropping the inputs from in, so the stages before it are never blocked
+func sendDropping(out chan<- interface{}, v interface{}, in <-chan interface{}) {
+	for {
+		select {
+		case out <- v:
+			return
+		case _, open := <-in:
+			if !open {
+				out <- v
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func sum(acc, i interface{}) interface{} {
+	return acc.(int) + i.(int)
+}
+
+func TestReduce(t *testing.T) {
+	t.Run("sends the accumulated value when in closes", func(t *testing.T) {
+		outs, err := ToSlice(context.Background(), Reduce(context.Background(), 0, sum, Emit(1, 2, 3, 4)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []interface{}{10}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("builds a lookup map", func(t *testing.T) {
+		index := func(acc, i interface{}) interface{} {
+			m := acc.(map[string]int)
+			m[i.(string)] = len(m)
+			return m
+		}
+		out := <-Reduce(context.Background(), map[string]int{}, index, Emit("a", "b"))
+		if want := map[string]int{"a": 0, "b": 1}; !reflect.DeepEqual(want, out) {
+			t.Errorf("out = %+v, want %+v", out, want)
+		}
+	})
+
+	for _, test := range []struct {
+		name string
+		opts []Option
+		want []interface{}
+	}{
+		{"sends nothing when the context is canceled", nil, nil},
+		{"sends the partial value when the context is canceled", []Option{WithPartialOnCancel()}, []interface{}{3}},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			in := make(chan interface{})
+			added := make(chan struct{}, 2)
+			out := Reduce(ctx, 0, func(acc, i interface{}) interface{} {
+				defer func() { added <- struct{}{} }()
+				return sum(acc, i)
+			}, in, test.opts...)
+
+			// Read out while in is being fed
+			result := make(chan []interface{})
+			go func() {
+				var outs []interface{}
+				for o := range out {
+					outs = append(outs, o)
+				}
+				result <- outs
+			}()
+			in <- 1
+			in <- 2
+			<-added
+			<-added
+			cancel()
+			// The remaining inputs are dropped
+			in <- 3
+			close(in)
+			outs := <-result
+			if !reflect.DeepEqual(test.want, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	t.Run("sends the running value after every input", func(t *testing.T) {
+		outs, err := ToSlice(context.Background(), Scan(context.Background(), 0, sum, Emit(1, 2, 3, 4)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []interface{}{1, 3, 6, 10}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("stops when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := make(chan interface{})
+		out := Scan(ctx, 0, sum, in)
+		go func() {
+			defer close(in)
+			for i := 1; i <= 4; i++ {
+				in <- i
+			}
+		}()
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+			if len(outs) == 2 {
+				cancel()
+			}
+		}
+		if want := []interface{}{1, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+}
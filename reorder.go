@@ -0,0 +1,181 @@
+package pipeline
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Reorder sorts the inputs of a nearly ordered stream, such as events that arrive up to a few seconds out of order.
+// Each input is held for up to `lateness` after it arrives, and is sent to the out `<-chan interface{}`
+// in the order of `less` once an input that is not less than it has been held that long,
+// so Reorder holds about as many inputs as arrive within `lateness`.
+// An input that arrives after a greater input was sent is too late to be put in order: it is dropped,
+// use WithLateItems to handle it. Equal inputs are sent in the order they arrived.
+// The inputs still held are sent in order when the `in <-chan interface{}` is closed, before the out chan is closed.
+// After the context is canceled, the inputs still held and the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// Reorder panics if `lateness` is not positive.
+func Reorder(ctx context.Context, lateness time.Duration, less func(a, b interface{}) bool, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	if lateness <= 0 {
+		panic(fmt.Sprintf("pipeline: reorder lateness must be positive, got %s", lateness))
+	}
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		r := &reorderer{held: heldHeap{less: less}, late: c.late}
+		timer := c.Clock.NewTimer(lateness)
+		defer timer.Stop()
+		stopTimer(timer)
+		// tick is only set while an input is held
+		var tick <-chan time.Time
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					for r.held.Len() > 0 {
+						if !send(ctx, heap.Pop(&r.held).(*heldItem).i, out) {
+							return
+						}
+					}
+					return
+				}
+				if r.add(i, c.Clock.Now()) && tick == nil {
+					timer.Reset(lateness)
+					tick = timer.C()
+				}
+			case now := <-tick:
+				tick = nil
+				if !r.release(ctx, now.Add(-lateness), out) {
+					dropAll(in)
+					return
+				}
+				if next, ok := r.next(); ok {
+					timer.Reset(next.Add(lateness).Sub(c.Clock.Now()))
+					tick = timer.C()
+				}
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before Reorder are never blocked
+				dropAll(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithLateItems passes the inputs that arrive too late for Reorder to sort them to `late`, rather than dropping them silently
+func WithLateItems(late func(i interface{})) Option {
+	return func(c *config) {
+		c.late = late
+	}
+}
+
+// reorderer holds the inputs of Reorder
+type reorderer struct {
+	held heldHeap
+	// arrivals are the held inputs in the order they arrived, including the ones that were sent early
+	arrivals []*heldItem
+	// last is the last input that was sent, if sent is true
+	last interface{}
+	sent bool
+	late func(i interface{})
+}
+
+// heldItem is an input held by Reorder
+type heldItem struct {
+	i       interface{}
+	arrived time.Time
+	seq     uint64
+	sent    bool
+}
+
+// add holds i, unless it is less than an input that was already sent, and returns true if it was held
+func (r *reorderer) add(i interface{}, now time.Time) bool {
+	if r.sent && r.held.less(i, r.last) {
+		if r.late != nil {
+			r.late(i)
+		}
+		return false
+	}
+	h := &heldItem{i: i, arrived: now, seq: r.held.seq}
+	heap.Push(&r.held, h)
+	r.arrivals = append(r.arrivals, h)
+	return true
+}
+
+// release sends, in order, the inputs up to the greatest input that arrived by `cutoff`.
+// The inputs less than it are sent early since any input that arrives after it is late anyway.
+// It returns false if the context was canceled.
+func (r *reorderer) release(ctx context.Context, cutoff time.Time, out chan<- interface{}) bool {
+	var max *heldItem
+	for len(r.arrivals) > 0 && !r.arrivals[0].arrived.After(cutoff) {
+		h := r.arrivals[0]
+		r.arrivals[0] = nil
+		r.arrivals = r.arrivals[1:]
+		if !h.sent && (max == nil || !r.held.less(h.i, max.i)) {
+			max = h
+		}
+	}
+	if max == nil {
+		return true
+	}
+	for r.held.Len() > 0 && !r.held.less(max.i, r.held.items[0].i) {
+		h := heap.Pop(&r.held).(*heldItem)
+		h.sent, r.last, r.sent = true, h.i, true
+		if !send(ctx, h.i, out) {
+			return false
+		}
+	}
+	return true
+}
+
+// next returns when the oldest input that is still held arrived
+func (r *reorderer) next() (time.Time, bool) {
+	for len(r.arrivals) > 0 && r.arrivals[0].sent {
+		r.arrivals[0] = nil
+		r.arrivals = r.arrivals[1:]
+	}
+	if len(r.arrivals) == 0 {
+		return time.Time{}, false
+	}
+	return r.arrivals[0].arrived, true
+}
+
+// heldHeap is a heap.Interface of held inputs whose top is the least input that arrived first
+type heldHeap struct {
+	items []*heldItem
+	less  func(a, b interface{}) bool
+	seq   uint64
+}
+
+func (h *heldHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *heldHeap) Less(a, b int) bool {
+	if h.less(h.items[a].i, h.items[b].i) {
+		return true
+	}
+	if h.less(h.items[b].i, h.items[a].i) {
+		return false
+	}
+	return h.items[a].seq < h.items[b].seq
+}
+
+func (h *heldHeap) Swap(a, b int) {
+	h.items[a], h.items[b] = h.items[b], h.items[a]
+}
+
+func (h *heldHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(*heldItem))
+	h.seq++
+}
+
+func (h *heldHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items[len(h.items)-1] = nil
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
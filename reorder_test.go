@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReorder(t *testing.T) {
+	less := func(a, b interface{}) bool {
+		return a.(int) < b.(int)
+	}
+
+	t.Run("the inputs are sorted within the lateness", func(t *testing.T) {
+		const lateness = 30 * time.Millisecond
+		in := make(chan interface{})
+		var late []interface{}
+		out := Reorder(context.Background(), lateness, less, in, WithLateItems(func(i interface{}) {
+			late = append(late, i)
+		}))
+		go func() {
+			defer close(in)
+			for _, i := range []int{3, 1, 2, 5} {
+				in <- i
+			}
+			time.Sleep(2 * lateness)
+			// 4 arrives after 5 was sent
+			for _, i := range []int{4, 7, 6} {
+				in <- i
+			}
+		}()
+		start := time.Now()
+		first := <-out
+		if waited := time.Since(start); waited < lateness {
+			t.Errorf("the first input was sent after %s, want it held for %s", waited, lateness)
+		}
+		got := []interface{}{first}
+		for o := range out {
+			got = append(got, o)
+		}
+		if want := []interface{}{1, 2, 3, 5, 6, 7}; !reflect.DeepEqual(want, got) {
+			t.Errorf("out = %v, want %v", got, want)
+		}
+		if want := []interface{}{4}; !reflect.DeepEqual(want, late) {
+			t.Errorf("late = %v, want %v", late, want)
+		}
+	})
+
+	t.Run("the inputs that are held are sent in order when the in chan is closed", func(t *testing.T) {
+		var got []interface{}
+		for o := range Reorder(context.Background(), time.Hour, less, Emit(4, 2, 2, 9, 1)) {
+			got = append(got, o)
+		}
+		if want := []interface{}{1, 2, 2, 4, 9}; !reflect.DeepEqual(want, got) {
+			t.Errorf("out = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("the inputs are dropped when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := Reorder(ctx, time.Hour, less, in)
+		in <- 1
+		cancel()
+		in <- 2
+		close(in)
+		if o, open := <-out; open {
+			t.Errorf("out sent %v, want it closed", o)
+		}
+	})
+
+	t.Run("panics on a lateness that is not positive", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Reorder(0) did not panic")
+			}
+		}()
+		Reorder(context.Background(), 0, less, Emit())
+	})
+}
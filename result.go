@@ -0,0 +1,22 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// Result is the outcome of processing an input with ProcessIntoResults.
+// Value is the result of `Processor.Process` if Err is nil.
+// Otherwise Err is a *ProcessError if `Processor.Process` failed, or a *CanceledError that matches ErrCanceled
+// if the input was canceled by the context.
+type Result = core.Result[interface{}, interface{}]
+
+// ProcessIntoResults is like Process, except that failures are sent down the out chan instead of being passed to `Processor.Cancel`,
+// so a single sink can handle both. Each input from the `in <-chan interface{}` becomes exactly one Result,
+// including the inputs that are canceled after the context is canceled, so no input is ever lost.
+// `Processor.Cancel` is never called.
+// Since no input is ever dropped, the out chan must be read until it is closed, even after the context is canceled.
+func ProcessIntoResults(ctx context.Context, p Processor, in <-chan interface{}, opts ...Option) <-chan Result {
+	return core.ProcessIntoResults[interface{}, interface{}](ctx, p, in, newConfig(opts).Config)
+}
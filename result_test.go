@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestProcessIntoResults(t *testing.T) {
+	t.Run("failures are sent down the out chan", func(t *testing.T) {
+		p := NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+			if i == 2 {
+				return nil, errProcess
+			}
+			return i.(int) * 10, nil
+		}, func(i interface{}, err error) {
+			t.Errorf("Cancel was called with %v and %s", i, err)
+		})
+		var results []Result
+		for r := range ProcessIntoResults(context.Background(), p, Emit(1, 2, 3)) {
+			results = append(results, r)
+		}
+		if len(results) != 3 {
+			t.Fatalf("results = %+v, want 3 results", results)
+		}
+		for n, want := range []Result{{Value: 10, Input: 1}, {Err: errProcess, Input: 2}, {Value: 30, Input: 3}} {
+			r := results[n]
+			if r.Input != want.Input || r.Value != want.Value || !errors.Is(r.Err, want.Err) || (r.Err == nil) != (want.Err == nil) {
+				t.Errorf("result %d = %+v, want %+v", n, r, want)
+			}
+		}
+		var pErr *ProcessError
+		if !errors.As(results[1].Err, &pErr) || pErr.Input != 2 {
+			t.Errorf("result 1 err = %#v, want a *ProcessError of 2", results[1].Err)
+		}
+	})
+
+	t.Run("every input becomes exactly one result under random cancellation", func(t *testing.T) {
+		const n = 1000
+		rng := rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec
+		slow := ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+			if i.(int)%10 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			return i, ctx.Err()
+		})
+		for run := 0; run < 20; run++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rng.Intn(int(20*time.Millisecond))))
+			seen := make([]int, n)
+			var processed, canceled int
+			for r := range ProcessIntoResults(ctx, slow, emitN(n)) {
+				seen[r.Input.(int)]++
+				switch {
+				case r.Err == nil:
+					processed++
+				case errors.Is(r.Err, ErrCanceled) || errors.Is(r.Err, context.DeadlineExceeded):
+					canceled++
+				default:
+					t.Errorf("result %+v has an unexpected error", r)
+				}
+			}
+			cancel()
+			for i, count := range seen {
+				if count != 1 {
+					t.Fatalf("run %d: input %d has %d results, want 1", run, i, count)
+				}
+			}
+			if processed+canceled != n {
+				t.Errorf("run %d: %d processed + %d canceled, want %d", run, processed, canceled, n)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// BackoffStrategy returns how long to wait before retrying after the given failed attempt.
+// The first failed attempt is 1.
+type BackoffStrategy func(attempt int) time.Duration
+
+// ConstantBackoff waits the same duration between every attempt
+func ConstantBackoff(d time.Duration) BackoffStrategy {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff doubles the wait after each attempt, starting at base and never exceeding max.
+// Each wait is randomized between half and all of its duration to prevent retries from happening in lockstep.
+func ExponentialBackoff(base, max time.Duration) BackoffStrategy {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		if half := int64(d / 2); half > 0 {
+			return time.Duration(half + rand.Int63n(half)) // #nosec
+		}
+		return d
+	}
+}
+
+// Retry creates a Processor that calls `Processor.Process` up to `attempts` times until it succeeds,
+// waiting between each attempt for the duration returned by the `backoff` strategy.
+// Only the error from the final attempt is returned, which is what will eventually be passed to `Processor.Cancel`.
+// If the context is canceled while waiting, Retry stops and returns the `Context.Err()`.
+func Retry(attempts int, backoff BackoffStrategy, p Processor) Processor {
+	return RetryIf(attempts, backoff, nil, p)
+}
+
+// RetryIf works like Retry but only tries again when `retryable` returns true for the error of an attempt,
+// so that only transient errors such as deadlocks or timeouts are retried.
+// Any other error is returned straight away. A nil `retryable` retries every error.
+func RetryIf(attempts int, backoff BackoffStrategy, retryable func(err error) bool, p Processor) Processor {
+	return forwardBatches(&retry{
+		attempts:  attempts,
+		backoff:   backoff,
+		retryable: retryable,
+		processor: p,
+	}, p, passBatch)
+}
+
+// retry implements Processor
+type retry struct {
+	attempts  int
+	backoff   BackoffStrategy
+	retryable func(err error) bool
+	processor Processor
+}
+
+func (r *retry) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	for attempt := 1; ; attempt++ {
+		out, err := r.processor.Process(ctx, i)
+		if err == nil || attempt >= r.attempts || (r.retryable != nil && !r.retryable(err)) {
+			return out, err
+		}
+		// Wait before trying again
+		timer := time.NewTimer(r.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *retry) Cancel(i interface{}, err error) {
+	r.processor.Cancel(i, err)
+}
+
+func (r *retry) CancelContext(ctx context.Context, i interface{}, err error) {
+	cancelContext(ctx, r.processor, i, err)
+}
+
+func (r *retry) Setup(ctx context.Context) error {
+	return setupWrapped(ctx, r.processor)
+}
+
+func (r *retry) Teardown() error {
+	return teardownWrapped(r.processor)
+}
+
+func (r *retry) CloneForWorker() Processor {
+	c, ok := cloneWrapped(r.processor)
+	if !ok {
+		return nil
+	}
+	return RetryIf(r.attempts, r.backoff, r.retryable, c)
+}
@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// flakyProcessor fails the first failures times that Process is called for each input
+type flakyProcessor struct {
+	failures int
+	attempts map[interface{}]int
+	canceled []interface{}
+	errs     []error
+}
+
+func (f *flakyProcessor) Process(_ context.Context, i interface{}) (interface{}, error) {
+	if f.attempts == nil {
+		f.attempts = make(map[interface{}]int)
+	}
+	f.attempts[i]++
+	if f.attempts[i] <= f.failures {
+		return nil, fmt.Errorf("attempt %d failed", f.attempts[i])
+	}
+	return i, nil
+}
+
+func (f *flakyProcessor) Cancel(i interface{}, err error) {
+	f.canceled = append(f.canceled, i)
+	f.errs = append(f.errs, err)
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("an input that fails twice then succeeds is emitted", func(t *testing.T) {
+		flaky := &flakyProcessor{failures: 2}
+		var outs []interface{}
+		for o := range Process(context.Background(), Retry(3, ConstantBackoff(time.Millisecond), flaky), Emit(1, 2)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if flaky.canceled != nil {
+			t.Errorf("canceled = %+v, want nil", flaky.canceled)
+		}
+		if want := map[interface{}]int{1: 3, 2: 3}; !reflect.DeepEqual(want, flaky.attempts) {
+			t.Errorf("attempts = %+v, want %+v", flaky.attempts, want)
+		}
+	})
+
+	t.Run("the error from the final attempt is passed to cancel", func(t *testing.T) {
+		flaky := &flakyProcessor{failures: 5}
+		for range Process(context.Background(), Retry(3, ExponentialBackoff(time.Millisecond, 4*time.Millisecond), flaky), Emit(1)) {
+			t.Error("nothing should be emitted")
+		}
+		if want := []interface{}{1}; !reflect.DeepEqual(want, flaky.canceled) {
+			t.Errorf("canceled = %+v, want %+v", flaky.canceled, want)
+		}
+		if len(flaky.errs) != 1 || flaky.errs[0].Error() != "attempt 3 failed" {
+			t.Errorf("errs = %+v, want [attempt 3 failed]", flaky.errs)
+		}
+	})
+
+	t.Run("waiting is interrupted when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		flaky := &flakyProcessor{failures: 5}
+		start := time.Now()
+		for range Process(ctx, Retry(3, ConstantBackoff(time.Minute), flaky), Emit(1)) {
+			t.Error("nothing should be emitted")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("retry took %s after the context was canceled", elapsed)
+		}
+		if len(flaky.errs) != 1 || !errors.Is(flaky.errs[0], context.DeadlineExceeded) {
+			t.Errorf("errs = %+v, want [context deadline exceeded]", flaky.errs)
+		}
+	})
+}
+
+func TestRetryIf(t *testing.T) {
+	t.Run("only retryable errors are retried", func(t *testing.T) {
+		flaky := &flakyProcessor{failures: 5}
+		retryable := func(err error) bool {
+			return err.Error() == "attempt 1 failed"
+		}
+		for range Process(context.Background(), RetryIf(5, ConstantBackoff(time.Millisecond), retryable, flaky), Emit(1)) {
+			t.Error("nothing should be emitted")
+		}
+		if want := map[interface{}]int{1: 2}; !reflect.DeepEqual(want, flaky.attempts) {
+			t.Errorf("attempts = %+v, want %+v", flaky.attempts, want)
+		}
+		if len(flaky.errs) != 1 || flaky.errs[0].Error() != "attempt 2 failed" {
+			t.Errorf("errs = %+v, want [attempt 2 failed]", flaky.errs)
+		}
+	})
+
+	t.Run("a nil retryable retries every error", func(t *testing.T) {
+		flaky := &flakyProcessor{failures: 2}
+		for range Process(context.Background(), RetryIf(3, ConstantBackoff(time.Millisecond), nil, flaky), Emit(1)) {
+		}
+		if flaky.canceled != nil {
+			t.Errorf("canceled = %+v, want nil", flaky.canceled)
+		}
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	for attempt, max := range []time.Duration{10, 20, 40, 50, 50} {
+		max *= time.Millisecond
+		if d := backoff(attempt + 1); d < max/2 || d > max {
+			t.Errorf("backoff(%d) = %s, want between %s and %s", attempt+1, d, max/2, max)
+		}
+	}
+}
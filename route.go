@@ -0,0 +1,52 @@
+package pipeline
+
+import "context"
+
+// Route sends each input from the `in <-chan interface{}` to one of `n` out channels,
+// picked by the index that `route` returns for it.
+// Unlike FanOut, the out channel of each input depends only on its content.
+// Inputs with an index outside of [0, n) are dropped and passed to the func set by WithUnrouted, if there is one.
+// Inputs are routed one at a time, so an out channel that is not being read blocks all of them.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// All of the out channels are closed when the `in <-chan interface{}` is closed.
+func Route(ctx context.Context, route func(i interface{}) int, in <-chan interface{}, n int, opts ...Option) []<-chan interface{} {
+	c := newConfig(opts)
+	outs := make([]chan interface{}, n)
+	results := make([]<-chan interface{}, n)
+	for k := range outs {
+		outs[k] = make(chan interface{})
+		results[k] = outs[k]
+	}
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		for i := range in {
+			k := route(i)
+			if k < 0 || k >= n {
+				if c.unrouted != nil {
+					c.unrouted(i)
+				}
+				continue
+			}
+			select {
+			case outs[k] <- i:
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before Route are never blocked
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return results
+}
+
+// WithUnrouted sets the func that Route passes the inputs to when they have no out channel
+func WithUnrouted(unrouted func(i interface{})) Option {
+	return func(c *config) {
+		c.unrouted = unrouted
+	}
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRoute(t *testing.T) {
+	t.Run("inputs are routed by content", func(t *testing.T) {
+		// Route valid orders to 0 and invalid ones to 1, and leave the rest unrouted
+		route := func(i interface{}) int {
+			switch {
+			case i.(int) < 0:
+				return 1
+			case i.(int) >= 100:
+				return 2
+			default:
+				return 0
+			}
+		}
+		var unrouted []interface{}
+		outs := Route(context.Background(), route, Emit(1, -2, 3, 100, -5, 6), 2, WithUnrouted(func(i interface{}) {
+			unrouted = append(unrouted, i)
+		}))
+
+		var wg sync.WaitGroup
+		got := make([][]interface{}, len(outs))
+		for k, out := range outs {
+			wg.Add(1)
+			go func(k int, out <-chan interface{}) {
+				defer wg.Done()
+				for o := range out {
+					got[k] = append(got[k], o)
+				}
+			}(k, out)
+		}
+		wg.Wait()
+
+		if want := [][]interface{}{{1, 3, 6}, {-2, -5}}; !reflect.DeepEqual(want, got) {
+			t.Errorf("outs = %+v, want %+v", got, want)
+		}
+		if want := []interface{}{100}; !reflect.DeepEqual(want, unrouted) {
+			t.Errorf("unrouted = %+v, want %+v", unrouted, want)
+		}
+	})
+
+	t.Run("the outs close after the context is canceled even if nothing reads them", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		outs := Route(ctx, func(interface{}) int { return 0 }, in, 2)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer close(in)
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+		}()
+		cancel()
+		<-done
+		for _, out := range outs {
+			select {
+			case <-waitClosed(out):
+			case <-time.After(time.Second):
+				t.Fatal("out was not closed")
+			}
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import "context"
+
+// Stage is a step of a pipeline that Run starts with the context of the pipeline.
+// It reads from the `in <-chan interface{}`, which is nil for the first stage, and returns its out chan.
+// Any stage of this package fits once its other arguments are bound:
+//
+//	err := pipeline.Run(ctx,
+//		func(ctx context.Context, _ <-chan interface{}) <-chan interface{} {
+//			return pipeline.EmitContext(ctx, 1, 2, 3)
+//		},
+//		func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+//			return pipeline.ProcessConcurrently(ctx, 4, p, in, pipeline.WithFailFastFrom(ctx))
+//		},
+//		pipeline.Sink(func(i interface{}) error {
+//			return save(i)
+//		}),
+//	)
+type Stage func(ctx context.Context, in <-chan interface{}) <-chan interface{}
+
+// runKey is the context key of the FailFast of Run
+type runKey struct{}
+
+// Run wires the stages together in order, runs them, and waits for the last stage to close its out chan,
+// discarding what it emits, which is why the last stage is usually a Sink.
+// It returns the first error of the pipeline: the *ProcessError of a stage attached with WithFailFastFrom,
+// the error of a Sink, or the `Context.Err()` of ctx. Either of the first two cancels the context of the stages.
+//
+// The stages of this package close their out chan only once their goroutines are done and their in chan is closed,
+// and Run waits for every stage to close its out chan, so no goroutine of the pipeline is left running when Run returns.
+// Stages that close their out chan early, like Take, keep draining their in chan until the stages before them stop,
+// which they do because Run cancels the context of the stages once the last stage is done.
+// EmitFunc closes its out chan while its func may still be running, since that func cannot be interrupted.
+// Run is the same as Start followed by `Handle.Wait`.
+func Run(ctx context.Context, stages ...Stage) error {
+	return Start(ctx, stages...).Wait()
+}
+
+// Sink creates the last Stage of Run, which calls `fn` with each input.
+// The first error of `fn` stops the pipeline and is returned by Run. The remaining inputs are discarded.
+func Sink(fn func(i interface{}) error) Stage {
+	return func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			for i := range in {
+				if err := fn(i); err != nil {
+					if ff, ok := ctx.Value(runKey{}).(*FailFast); ok {
+						ff.fail(err)
+					}
+					// Drop the remaining inputs so that the stages before Sink are never blocked
+					discard(in)
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// WithFailFastFrom attaches a stage that runs in Run to the FailFast of Run, see WithFailFast,
+// so that the first input that `Processor.Process` fails stops the pipeline and is returned by Run.
+// It does nothing when ctx is not the context of a Run.
+func WithFailFastFrom(ctx context.Context) Option {
+	return func(c *config) {
+		if ff, ok := ctx.Value(runKey{}).(*FailFast); ok {
+			c.Failed = ff.fail
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipelineGoroutines returns the stacks of the goroutines running code of this module by their goroutine header,
+// leaving out the goroutines of the tests themselves
+func pipelineGoroutines() map[string]string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	gs := make(map[string]string)
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if !strings.Contains(g, "/pipeline") || strings.Contains(g, "testing.") {
+			continue
+		}
+		header := g[:strings.Index(g, " [")]
+		gs[header] = g
+	}
+	return gs
+}
+
+// checkNoNewGoroutines fails the test if a goroutine of the module is running that was not in before.
+// The goroutines of the stages return right after they close their out chan, so they get a moment to do so.
+func checkNoNewGoroutines(t *testing.T, before map[string]string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		left := map[string]string{}
+		for header, g := range pipelineGoroutines() {
+			if _, ok := before[header]; !ok {
+				left[header] = g
+			}
+		}
+		if len(left) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			for _, g := range left {
+				t.Errorf("goroutine left running:\n%s", g)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// endless is a source Stage that emits 1, 2, 3... until the context is canceled
+func endless(ctx context.Context, _ <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for n := 1; send(ctx, n, out); n++ {
+		}
+	}()
+	return out
+}
+
+func TestRun(t *testing.T) {
+	double := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		return i.(int) * 2, nil
+	})
+	failed := errors.New("failed")
+	failOn := func(n int) Processor {
+		return ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			if i == n {
+				return nil, failed
+			}
+			return i, nil
+		})
+	}
+
+	t.Run("runs every stage and returns nil", func(t *testing.T) {
+		before := pipelineGoroutines()
+		var outs []interface{}
+		err := Run(context.Background(),
+			func(ctx context.Context, _ <-chan interface{}) <-chan interface{} {
+				return EmitContext(ctx, 1, 2, 3)
+			},
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return ProcessConcurrently(ctx, 2, double, in, WithOrderedOutput())
+			},
+			Sink(func(i interface{}) error {
+				outs = append(outs, i)
+				return nil
+			}),
+		)
+		checkNoNewGoroutines(t, before)
+		if err != nil {
+			t.Errorf("err = %v, want nil", err)
+		}
+		if want := []interface{}{2, 4, 6}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("a stage attached with WithFailFastFrom stops the pipeline with its error", func(t *testing.T) {
+		before := pipelineGoroutines()
+		err := Run(context.Background(),
+			endless,
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return ProcessConcurrently(ctx, 4, failOn(100), in, WithFailFastFrom(ctx))
+			},
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Collect(ctx, 10, time.Millisecond, in)
+			},
+			Sink(func(interface{}) error {
+				return nil
+			}),
+		)
+		checkNoNewGoroutines(t, before)
+		var pErr *ProcessError
+		if !errors.As(err, &pErr) || pErr.Input != 100 || !errors.Is(err, failed) {
+			t.Errorf("err = %v, want the *ProcessError of 100", err)
+		}
+	})
+
+	t.Run("the error of a sink stops the pipeline", func(t *testing.T) {
+		before := pipelineGoroutines()
+		err := Run(context.Background(),
+			endless,
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Process(ctx, double, in, WithConcurrency(4))
+			},
+			Sink(func(i interface{}) error {
+				if i == 20 {
+					return failed
+				}
+				return nil
+			}),
+		)
+		checkNoNewGoroutines(t, before)
+		if err != failed {
+			t.Errorf("err = %v, want %v", err, failed)
+		}
+	})
+
+	t.Run("a canceled context stops the pipeline with its error", func(t *testing.T) {
+		before := pipelineGoroutines()
+		ctx, cancel := context.WithCancel(context.Background())
+		err := Run(ctx,
+			endless,
+			func(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+				return Process(ctx, double, in)
+			},
+			Sink(func(i interface{}) error {
+				if i == 20 {
+					cancel()
+				}
+				return nil
+			}),
+		)
+		checkNoNewGoroutines(t, before)
+		if err != context.Canceled {
+			t.Errorf("err = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("WithFailFastFrom does nothing outside of Run", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Process(context.Background(), failOn(2), Emit(1, 2, 3), WithFailFastFrom(context.Background())) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+}
+
+func TestBuilderRun(t *testing.T) {
+	before := pipelineGoroutines()
+	failed := errors.New("failed")
+	var outs []interface{}
+	err := New(context.Background()).
+		Emit(1, 2, 3).
+		Collect(2, time.Minute).
+		Run(func(i interface{}) error {
+			outs = append(outs, i)
+			if len(outs) == 2 {
+				return failed
+			}
+			return nil
+		})
+	checkNoNewGoroutines(t, before)
+	if err != failed {
+		t.Errorf("err = %v, want %v", err, failed)
+	}
+	if want := []interface{}{[]interface{}{1, 2}, []interface{}{3}}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+
+	if err := New(context.Background()).Run(func(interface{}) error { return nil }); err != errNoSource {
+		t.Errorf("err = %v, want %v", err, errNoSource)
+	}
+}
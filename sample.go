@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Sample passes every `everyN`-th input from the `in <-chan interface{}` to the out `<-chan interface{}`,
+// starting with the first one, and drops the others. Use WithDropCount to count the inputs that are dropped.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// Sample panics if `everyN` is not positive.
+func Sample(ctx context.Context, everyN int, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	if everyN < 1 {
+		panic(fmt.Sprintf("pipeline: sample every n must be positive, got %d", everyN))
+	}
+	var n int
+	return sample(ctx, func() bool {
+		keep := n%everyN == 0
+		n++
+		return keep
+	}, in, newConfig(opts))
+}
+
+// SampleRate passes each input from the `in <-chan interface{}` to the out `<-chan interface{}` with the given `probability`,
+// and drops the others. Use WithDropCount to count the inputs that are dropped,
+// and WithRandSource to make the sample reproducible in tests.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// SampleRate panics if `probability` is not between 0 and 1.
+func SampleRate(ctx context.Context, probability float64, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	if probability < 0 || probability > 1 {
+		panic(fmt.Sprintf("pipeline: sample probability must be between 0 and 1, got %v", probability))
+	}
+	c := newConfig(opts)
+	rng := c.rand()
+	return sample(ctx, func() bool {
+		return rng.Float64() < probability
+	}, in, c)
+}
+
+// sample passes the inputs that `keep` returns true for to the out chan, and counts the others as dropped
+func sample(ctx context.Context, keep func() bool, in <-chan interface{}, c *config) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var dropped int64
+		for i := range in {
+			if !keep() {
+				dropped++
+				c.countDrop(dropped)
+				continue
+			}
+			if !send(ctx, i, out) {
+				// Drop the remaining inputs so that the stages before the sample are never blocked
+				dropAll(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// SampleEvery passes at most one input per `interval` from the `in <-chan interface{}` to the out `<-chan interface{}`:
+// at the end of each interval it sends the latest input that arrived during it, if any, and drops the ones before it,
+// which suits updating a dashboard. Use WithDropCount to count the inputs that are dropped.
+// The latest input is sent when the `in <-chan interface{}` is closed, before the out chan is closed.
+// After the context is canceled, the latest input and the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func SampleEvery(ctx context.Context, interval time.Duration, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		ticker := c.Clock.NewTicker(interval)
+		defer ticker.Stop()
+		var latest interface{}
+		var hasLatest bool
+		var dropped int64
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					if hasLatest && ctx.Err() == nil {
+						send(ctx, latest, out)
+					}
+					return
+				}
+				if hasLatest {
+					dropped++
+					c.countDrop(dropped)
+				}
+				latest, hasLatest = i, true
+			case <-ticker.C():
+				if !hasLatest {
+					continue
+				}
+				if !send(ctx, latest, out) {
+					dropAll(in)
+					return
+				}
+				latest, hasLatest = nil, false
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before SampleEvery are never blocked
+				dropAll(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithDropCount makes Sample, SampleRate and SampleEvery call `counted` with the number of inputs they dropped so far
+// after each input they drop, and ExpireAfter with the number of inputs that expired so far. It must not block.
+func WithDropCount(counted func(dropped int64)) Option {
+	return func(c *config) {
+		c.dropCount = counted
+	}
+}
+
+// WithRandSource sets the source of the random numbers of SampleRate and of the jitter of Delay,
+// which makes them reproducible in tests. A rand.Source is not safe for concurrent use, so do not share it between stages.
+func WithRandSource(src rand.Source) Option {
+	return func(c *config) {
+		c.randSource = src
+	}
+}
+
+// countDrop reports the number of inputs dropped to the func set by WithDropCount, if there is one
+func (c *config) countDrop(dropped int64) {
+	if c.dropCount != nil {
+		c.dropCount(dropped)
+	}
+}
+
+// rand returns a generator that uses the source set by WithRandSource,
+// or a new source seeded with the time so that the stages and processes differ
+func (c *config) rand() *rand.Rand {
+	if c.randSource != nil {
+		return rand.New(c.randSource) // #nosec
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec
+}
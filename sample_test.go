@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSample(t *testing.T) {
+	t.Run("every n-th input is passed", func(t *testing.T) {
+		var dropped int64
+		var outs []interface{}
+		for o := range Sample(context.Background(), 3, Emit(1, 2, 3, 4, 5, 6, 7), WithDropCount(func(n int64) { dropped = n })) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 4, 7}; !reflect.DeepEqual(outs, want) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if dropped != 4 {
+			t.Errorf("dropped = %d, want 4", dropped)
+		}
+	})
+
+	t.Run("panics on an every n that is not positive", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Sample(0) did not panic")
+			}
+		}()
+		Sample(context.Background(), 0, Emit())
+	})
+}
+
+func TestSampleRate(t *testing.T) {
+	inputs := make([]interface{}, 1000)
+	for k := range inputs {
+		inputs[k] = k
+	}
+	sampled := func(seed int64) []interface{} {
+		var outs []interface{}
+		for o := range SampleRate(context.Background(), 0.1, Emit(inputs...), WithRandSource(rand.NewSource(seed))) {
+			outs = append(outs, o)
+		}
+		return outs
+	}
+
+	t.Run("the same source makes the same sample", func(t *testing.T) {
+		a, b := sampled(42), sampled(42)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("the samples of the same seed differ: %v and %v", a, b)
+		}
+		if len(a) < 50 || len(a) > 150 {
+			t.Errorf("sampled %d of 1000 inputs, want about 100", len(a))
+		}
+	})
+
+	t.Run("panics on a probability above 1", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("SampleRate(2) did not panic")
+			}
+		}()
+		SampleRate(context.Background(), 2, Emit())
+	})
+}
+
+func TestSampleEvery(t *testing.T) {
+	t.Run("the latest input of each interval is passed", func(t *testing.T) {
+		in := make(chan interface{})
+		var dropped int64
+		out := SampleEvery(context.Background(), 50*time.Millisecond, in, WithDropCount(func(n int64) { dropped = n }))
+		in <- 1
+		in <- 2
+		in <- 3
+		if o := <-out; o != 3 {
+			t.Errorf("out = %v, want 3", o)
+		}
+		in <- 4
+		close(in)
+		if o := <-out; o != 4 {
+			t.Errorf("out = %v, want 4", o)
+		}
+		if _, open := <-out; open {
+			t.Errorf("the out chan is open")
+		}
+		if dropped != 2 {
+			t.Errorf("dropped = %d, want 2", dropped)
+		}
+	})
+
+	t.Run("the inputs are dropped when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := SampleEvery(ctx, time.Hour, in)
+		in <- 1
+		cancel()
+		in <- 2
+		close(in)
+		if o, open := <-out; open {
+			t.Errorf("out sent %v, want it closed", o)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Sequence creates a Processor that runs each of the Processors in order on the same input,
+// passing the output of one Processor to the next, without an extra goroutine or channel in between.
+// It stops at the first error or panic, and only the Processor that failed has its `Processor.Cancel` called,
+// with the original input of the Sequence.
+// Inputs that are canceled before they are processed are passed to the `Processor.Cancel` of the first Processor.
+func Sequence(ps ...Processor) Processor {
+	return sequence(ps)
+}
+
+// sequence implements Processor
+type sequence []Processor
+
+// sequenceError records which Processor of a sequence failed
+type sequenceError struct {
+	index int
+	err   error
+}
+
+func (e *sequenceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sequenceError) Unwrap() error {
+	return e.err
+}
+
+// sequencePanic records which Processor of a sequence panicked.
+// It is panicked again in place of the original value, so that the stage handles it like any other panic.
+type sequencePanic struct {
+	index int
+	value interface{}
+}
+
+func (p *sequencePanic) String() string {
+	return fmt.Sprint(p.value)
+}
+
+func (s sequence) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	var err error
+	for k, p := range s {
+		if i, err = s.process(ctx, k, p, i); err != nil {
+			return nil, &sequenceError{index: k, err: err}
+		}
+	}
+	return i, nil
+}
+
+// process calls the Process method of the Processor at index k and records the index of a panic
+func (s sequence) process(ctx context.Context, k int, p Processor, i interface{}) (interface{}, error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(&sequencePanic{index: k, value: r})
+		}
+	}()
+	return p.Process(ctx, i)
+}
+
+func (s sequence) Cancel(i interface{}, err error) {
+	if p, err := s.failed(i, err); p != nil {
+		p.Cancel(i, err)
+	}
+}
+
+func (s sequence) CancelContext(ctx context.Context, i interface{}, err error) {
+	if p, err := s.failed(i, err); p != nil {
+		cancelContext(ctx, p, i, err)
+	}
+}
+
+// failed returns the Processor that failed to process i, and the error to pass to its Cancel method
+func (s sequence) failed(i interface{}, err error) (Processor, error) {
+	if len(s) == 0 {
+		return nil, err
+	}
+	var sErr *sequenceError
+	if errors.As(err, &sErr) {
+		return s[sErr.index], err
+	}
+	var pErr *PanicError
+	if errors.As(err, &pErr) {
+		if sp, ok := pErr.Value.(*sequencePanic); ok {
+			// Restore the original panic value
+			return s[sp.index], &ProcessError{Input: i, Err: &PanicError{Value: sp.value, Stack: pErr.Stack}}
+		}
+	}
+	return s[0], err
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSequence(t *testing.T) {
+	// step records the goroutine count when it runs and the inputs passed to its Cancel
+	type step struct {
+		Processor
+		canceled []interface{}
+	}
+	var goroutines []int
+	newStep := func(fn func(i int) (int, error)) *step {
+		s := &step{}
+		s.Processor = NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+			goroutines = append(goroutines, runtime.NumGoroutine())
+			return fn(i.(int))
+		}, func(i interface{}, err error) {
+			s.canceled = append(s.canceled, i)
+		})
+		return s
+	}
+	errOdd := errors.New("odd")
+	add := newStep(func(i int) (int, error) { return i + 1, nil })
+	even := newStep(func(i int) (int, error) {
+		if i%2 != 0 {
+			return 0, errOdd
+		}
+		return i, nil
+	})
+	double := newStep(func(i int) (int, error) { return i * 2, nil })
+
+	var outs []interface{}
+	for o := range Process(context.Background(), Sequence(add, even, double), Emit(1, 2, 3, 4)) {
+		outs = append(outs, o)
+	}
+
+	if want := []interface{}{4, 8}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+	// Only the failing Processor is canceled, with the original input
+	if add.canceled != nil || double.canceled != nil {
+		t.Errorf("canceled = %+v and %+v, want nothing", add.canceled, double.canceled)
+	}
+	if want := []interface{}{2, 4}; !reflect.DeepEqual(want, even.canceled) {
+		t.Errorf("canceled = %+v, want %+v", even.canceled, want)
+	}
+	// The Processors run in the calling goroutine, so no goroutine is ever started
+	goroutines = nil
+	before := runtime.NumGoroutine()
+	if o, err := Sequence(add, even, double).Process(context.Background(), 1); err != nil || o != 4 {
+		t.Errorf("Process(1) = %v, %v, want 4, nil", o, err)
+	}
+	for _, g := range goroutines {
+		if g > before {
+			t.Errorf("goroutines = %v, want at most %d", goroutines, before)
+		}
+	}
+
+	t.Run("canceled inputs go to the first Processor", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		first, second := newStep(func(i int) (int, error) { return i, nil }), newStep(func(i int) (int, error) { return i, nil })
+		for range Process(ctx, Sequence(first, second), Emit(1, 2)) {
+		}
+		if want := []interface{}{1, 2}; !reflect.DeepEqual(want, first.canceled) {
+			t.Errorf("canceled = %+v, want %+v", first.canceled, want)
+		}
+		if second.canceled != nil {
+			t.Errorf("canceled = %+v, want nothing", second.canceled)
+		}
+	})
+}
+
+func TestSequencePanic(t *testing.T) {
+	var canceled [2][]interface{}
+	var errs []error
+	ok := NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+		return i, nil
+	}, func(i interface{}, err error) {
+		canceled[0] = append(canceled[0], i)
+	})
+	panics := NewProcessor(func(context.Context, interface{}) (interface{}, error) {
+		panic("oops")
+	}, func(i interface{}, err error) {
+		canceled[1] = append(canceled[1], i)
+		errs = append(errs, err)
+	})
+
+	for range Process(context.Background(), Sequence(ok, panics), Emit(1)) {
+		t.Error("nothing should be processed")
+	}
+
+	// Only the Processor that panicked is canceled, with the original panic value
+	if canceled[0] != nil {
+		t.Errorf("canceled = %+v, want nothing", canceled[0])
+	}
+	if want := []interface{}{1}; !reflect.DeepEqual(want, canceled[1]) {
+		t.Errorf("canceled = %+v, want %+v", canceled[1], want)
+	}
+	var pErr *PanicError
+	if len(errs) != 1 || !errors.As(errs[0], &pErr) || pErr.Value != "oops" {
+		t.Errorf("errs = %+v, want a *PanicError of oops", errs)
+	}
+}
+
+func TestSequenceContextCanceler(t *testing.T) {
+	failing := &contextCanceler{ProcessorFunc: func(context.Context, interface{}) (interface{}, error) {
+		return nil, errProcess
+	}}
+	for range Process(context.Background(), Sequence(noopProcessor, failing), Emit(1)) {
+		t.Error("nothing should be processed")
+	}
+	if want := []interface{}{1}; !reflect.DeepEqual(want, failing.canceled) {
+		t.Errorf("canceled = %+v, want %+v", failing.canceled, want)
+	}
+}
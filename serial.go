@@ -0,0 +1,23 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// SerialExecution returns a context derived from ctx that collapses the concurrency of the stages that run with it,
+// to tell a bug of the logic of a pipeline from a bug of its concurrency, or to compare its output to a golden file:
+//
+//	out := pipeline.ProcessConcurrently(pipeline.SerialExecution(ctx), 8, p, in)
+//
+// ProcessConcurrently, ProcessConcurrentlyOrdered, ProcessAutoscale, ProcessKeyed, ProcessBatchConcurrently,
+// and the stages built on them, such as ApplyConcurrently and ExpandConcurrently, then run a single worker whatever their concurrency,
+// so each of them processes its inputs one at a time and sends its results in the order it read its inputs.
+// Given the same inputs, a chain of such stages then sends the same outputs in the same order on every run.
+// ProcessPriority runs a single worker too, but its order still depends on the inputs pending when the worker is free.
+// It does not make deterministic the stages that depend on which of several chans is ready first, such as Merge,
+// nor the stages that depend on the time, such as Collect, unless they are given a fake Clock with WithClock.
+func SerialExecution(ctx context.Context) context.Context {
+	return core.Serial(ctx)
+}
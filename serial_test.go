@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSerialExecution(t *testing.T) {
+	// jittery takes a random time to double each input, which reorders the results of the concurrent stages
+	jittery := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond) // #nosec
+		return i.(int) * 2, nil
+	})
+	batched := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		return i, nil
+	})
+	var want []interface{}
+	for i := 0; i < 50; i++ {
+		want = append(want, i*4)
+	}
+
+	for _, test := range []struct {
+		name  string
+		stage func(ctx context.Context, in <-chan interface{}, m Metrics) <-chan interface{}
+	}{{
+		name: "ProcessConcurrently",
+		stage: func(ctx context.Context, in <-chan interface{}, m Metrics) <-chan interface{} {
+			return ProcessConcurrently(ctx, 8, jittery, in, WithMetrics("stage", m))
+		},
+	}, {
+		name: "ProcessAutoscale",
+		stage: func(ctx context.Context, in <-chan interface{}, m Metrics) <-chan interface{} {
+			return ProcessAutoscale(ctx, 4, 8, jittery, in, WithMetrics("stage", m))
+		},
+	}, {
+		name: "ProcessKeyed",
+		stage: func(ctx context.Context, in <-chan interface{}, m Metrics) <-chan interface{} {
+			key := func(i interface{}) string {
+				return string(rune('a' + i.(int)%8))
+			}
+			return ProcessKeyed(ctx, 8, key, jittery, in, WithMetrics("stage", m))
+		},
+	}, {
+		name: "ProcessBatchConcurrently",
+		stage: func(ctx context.Context, in <-chan interface{}, m Metrics) <-chan interface{} {
+			return ProcessConcurrently(ctx, 8, jittery, ProcessBatchConcurrently(ctx, 8, 1, time.Second, batched, in), WithMetrics("stage", m))
+		},
+	}} {
+		test := test
+		t.Run(test.name+" runs a single worker and keeps the order of its inputs", func(t *testing.T) {
+			ctx := SerialExecution(context.Background())
+			m := &MemoryMetrics{}
+			var outs []interface{}
+			for o := range ProcessConcurrently(ctx, 8, jittery, test.stage(ctx, emitN(50), m)) {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(want, outs) {
+				t.Errorf("out = %+v, want %+v", outs, want)
+			}
+			if workers := m.Stage("stage").Workers; workers != 1 {
+				t.Errorf("workers = %d, want 1", workers)
+			}
+		})
+	}
+
+	t.Run("Builder.Serial runs every stage serially", func(t *testing.T) {
+		var outs []interface{}
+		err := New(context.Background()).Serial().
+			From(emitN(50)).
+			ProcessConcurrently(8, jittery).
+			ProcessConcurrently(8, jittery).
+			Sink(func(i interface{}) error {
+				outs = append(outs, i)
+				return nil
+			})
+		if err != nil || !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v and %v, want %+v", outs, err, want)
+		}
+	})
+}
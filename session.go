@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"time"
+)
+
+// SessionWindow groups the inputs from the `in <-chan interface{}` into a session per key, as returned by `keyFn`,
+// and sends the inputs of a session to the out chan once no input of its key has arrived for `gap`.
+// A session is forgotten once it is sent, so the next input of its key starts a new session.
+// Use WithMaxEntries to bound the number of open sessions, in which case the session that has been idle the longest
+// is evicted to make room for a new one: it is passed to the func set by WithEvictedSessions if there is one,
+// and sent to the out chan early otherwise.
+// The open sessions are flushed, from the one that has been idle the longest, when the `in <-chan interface{}` is closed
+// or the context is canceled, after which the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// SessionWindow panics if `gap` is not positive.
+func SessionWindow(ctx context.Context, keyFn func(i interface{}) string, gap time.Duration, in <-chan interface{}, opts ...Option) <-chan []interface{} {
+	if gap <= 0 {
+		panic(fmt.Sprintf("pipeline: session gap must be positive, got %s", gap))
+	}
+	c := newConfig(opts)
+	out := make(chan []interface{})
+	go func() {
+		defer close(out)
+		s := &sessions{
+			gap:        gap,
+			maxEntries: c.maxEntries,
+			evicted:    c.evictedSessions,
+			out:        out,
+			order:      list.New(),
+			keys:       map[string]*list.Element{},
+		}
+		timer := c.Clock.NewTimer(gap)
+		stopTimer(timer)
+		defer timer.Stop()
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					s.flush()
+					return
+				}
+				now := c.Clock.Now()
+				s.add(keyFn(i), i, now)
+				s.schedule(timer, now)
+			case now := <-timer.C():
+				s.expire(now)
+				s.schedule(timer, c.Clock.Now())
+			case <-ctx.Done():
+				s.flush()
+				// Drop the remaining inputs so that the stages before SessionWindow are never blocked
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithEvictedSessions sets the func that SessionWindow passes the sessions it evicts to, see WithMaxEntries.
+// It must not block.
+func WithEvictedSessions(evicted func(is []interface{})) Option {
+	return func(c *config) {
+		c.evictedSessions = evicted
+	}
+}
+
+// session is an open session of SessionWindow
+type session struct {
+	key  string
+	is   []interface{}
+	last time.Time
+}
+
+// sessions holds the open sessions of SessionWindow.
+// It is only used by the SessionWindow goroutine, so it needs no locking.
+type sessions struct {
+	gap        time.Duration
+	maxEntries int
+	evicted    func(is []interface{})
+	out        chan<- []interface{}
+	// order holds the sessions from the least to the most recently active, which is also the order they end in
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+// add adds i to the session of key at now, and evicts the session that has been idle the longest if there are too many
+func (s *sessions) add(key string, i interface{}, now time.Time) {
+	if e, ok := s.keys[key]; ok {
+		ses := e.Value.(*session)
+		ses.is = append(ses.is, i)
+		ses.last = now
+		s.order.MoveToBack(e)
+		return
+	}
+	s.keys[key] = s.order.PushBack(&session{key: key, is: []interface{}{i}, last: now})
+	if s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		ses := s.remove(s.order.Front())
+		if s.evicted != nil {
+			s.evicted(ses.is)
+			return
+		}
+		s.out <- ses.is
+	}
+}
+
+// expire sends the sessions that have been idle for `gap` or more at now
+func (s *sessions) expire(now time.Time) {
+	for e := s.order.Front(); e != nil && now.Sub(e.Value.(*session).last) >= s.gap; e = s.order.Front() {
+		s.out <- s.remove(e).is
+	}
+}
+
+// flush sends every open session
+func (s *sessions) flush() {
+	for e := s.order.Front(); e != nil; e = s.order.Front() {
+		s.out <- s.remove(e).is
+	}
+}
+
+// schedule sets timer to fire when the session that has been idle the longest ends
+func (s *sessions) schedule(timer Timer, now time.Time) {
+	stopTimer(timer)
+	if e := s.order.Front(); e != nil {
+		timer.Reset(e.Value.(*session).last.Add(s.gap).Sub(now))
+	}
+}
+
+// remove removes the session e and returns it
+func (s *sessions) remove(e *list.Element) *session {
+	s.order.Remove(e)
+	ses := e.Value.(*session)
+	delete(s.keys, ses.key)
+	return ses
+}
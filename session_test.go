@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionWindow(t *testing.T) {
+	// The key of an input is its first letter
+	key := func(i interface{}) string {
+		return i.(string)[:1]
+	}
+	collect := func(out <-chan []interface{}) [][]interface{} {
+		var sessions [][]interface{}
+		for s := range out {
+			sessions = append(sessions, s)
+		}
+		return sessions
+	}
+
+	t.Run("a session ends once its key is idle for the gap", func(t *testing.T) {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			in <- "a1"
+			in <- "b1"
+			time.Sleep(40 * time.Millisecond)
+			in <- "a2"
+			time.Sleep(150 * time.Millisecond)
+		}()
+		start := time.Now()
+		out := SessionWindow(context.Background(), key, 80*time.Millisecond, in)
+		if s := <-out; !reflect.DeepEqual([]interface{}{"b1"}, s) {
+			t.Errorf("session = %+v, want [b1]", s)
+		}
+		if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > 115*time.Millisecond {
+			t.Errorf("the session ended after %s, want 80ms", elapsed)
+		}
+		if s := <-out; !reflect.DeepEqual([]interface{}{"a1", "a2"}, s) {
+			t.Errorf("session = %+v, want [a1 a2]", s)
+		}
+		if elapsed := time.Since(start); elapsed < 120*time.Millisecond || elapsed > 155*time.Millisecond {
+			t.Errorf("the session ended after %s, want 120ms", elapsed)
+		}
+		if _, open := <-out; open {
+			t.Error("out is still open")
+		}
+	})
+
+	for _, test := range []struct {
+		name        string
+		withEvicted bool
+		opts        []Option
+		want        [][]interface{}
+		wantEvicted [][]interface{}
+	}{{
+		name: "the open sessions are flushed when in is closed",
+		want: [][]interface{}{{"c1"}, {"b1", "b2"}, {"a1", "a2"}},
+	}, {
+		name: "the session idle the longest is sent early to make room",
+		opts: []Option{WithMaxEntries(2)},
+		want: [][]interface{}{{"a1"}, {"c1"}, {"b1", "b2"}, {"a2"}},
+	}, {
+		name:        "the evicted sessions are passed to WithEvictedSessions",
+		withEvicted: true,
+		opts:        []Option{WithMaxEntries(2)},
+		want:        [][]interface{}{{"b1", "b2"}, {"a2"}},
+		wantEvicted: [][]interface{}{{"a1"}, {"c1"}},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var evicted [][]interface{}
+			opts := test.opts
+			if test.withEvicted {
+				opts = append(opts, WithEvictedSessions(func(is []interface{}) {
+					evicted = append(evicted, is)
+				}))
+			}
+			sessions := collect(SessionWindow(context.Background(), key, time.Hour, Emit("a1", "b1", "c1", "b2", "a2"), opts...))
+			if !reflect.DeepEqual(test.want, sessions) {
+				t.Errorf("sessions = %+v, want %+v", sessions, test.want)
+			}
+			if !reflect.DeepEqual(test.wantEvicted, evicted) {
+				t.Errorf("evicted = %+v, want %+v", evicted, test.wantEvicted)
+			}
+		})
+	}
+
+	t.Run("the open sessions are flushed when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := SessionWindow(ctx, key, time.Hour, in)
+		in <- "a1"
+		cancel()
+		if s := <-out; !reflect.DeepEqual([]interface{}{"a1"}, s) {
+			t.Errorf("session = %+v, want [a1]", s)
+		}
+		// The inputs after the cancel are dropped
+		in <- "a2"
+		close(in)
+		if _, open := <-out; open {
+			t.Error("out is still open")
+		}
+	})
+
+	t.Run("a session is forgotten once it is sent", func(t *testing.T) {
+		in := make(chan interface{})
+		out := SessionWindow(context.Background(), key, 20*time.Millisecond, in)
+		for n := 1; n <= 3; n++ {
+			in <- fmt.Sprintf("a%d", n)
+			if s := <-out; !reflect.DeepEqual([]interface{}{fmt.Sprintf("a%d", n)}, s) {
+				t.Errorf("session = %+v, want [a%d]", s, n)
+			}
+		}
+		close(in)
+		if _, open := <-out; open {
+			t.Error("out is still open")
+		}
+	})
+
+	t.Run("a gap that is not positive panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("SessionWindow did not panic")
+			}
+		}()
+		SessionWindow(context.Background(), key, 0, Emit("a1"))
+	})
+}
@@ -0,0 +1,147 @@
+package pipeline
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// ProcessSingleflight is like Process, except that the inputs whose key, as returned by `keyFn`, is already being processed
+// are not processed again: they wait for the call in flight and share its result, or its error.
+// Once the call is done, the next input with the key starts a new call, so only the concurrent duplicates are suppressed:
+//
+//	out := pipeline.ProcessSingleflight(ctx, userID, fetchProfile, in, pipeline.WithConcurrency(8))
+//
+// The inputs are processed by the workers of the stage, so set WithConcurrency for the duplicates to overlap:
+// up to `concurrency` inputs are processed at once, including the ones that wait for the call of their key.
+// The results are sent in the order of their inputs, unless WithUnorderedOutput is set.
+// The other options are those of Process, such as WithStop, WithMetrics and the lifecycle of the Processor.
+//
+// Every input that shares a call is sent the same result, so if it is a pointer, a map or a slice,
+// the stages after ProcessSingleflight see the same value several times and must not change it.
+// Use WithSharedCopy to send each input that shares a call a copy of the result instead.
+// If the call fails, each input that shares it is passed to `Processor.Cancel` with a *ProcessError of the same error.
+// After the context is canceled, the remaining inputs are passed to `Processor.Cancel`.
+func ProcessSingleflight(ctx context.Context, keyFn func(i interface{}) string, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	if c.Ordering == core.UnspecifiedOrder {
+		c.Ordering = core.Ordered
+	}
+	f := &flights{calls: map[string]*flight{}}
+	return core.Process[interface{}, interface{}](ctx, newSingleflight(f, keyFn, c.sharedCopy, p), in, c.Config)
+}
+
+// WithSharedCopy makes ProcessSingleflight send `copy` of the result of a call to each input that shares it,
+// rather than the result itself, so that the stages after it can change the results they receive.
+// The input that started the call is sent the result itself.
+func WithSharedCopy(copy func(o interface{}) interface{}) Option {
+	return func(c *config) {
+		c.sharedCopy = copy
+	}
+}
+
+// flightJoins counts the inputs that joined a call in flight of ProcessSingleflight, which tells the tests when the duplicates wait
+var flightJoins int64
+
+// flight is a call to `Processor.Process` of ProcessSingleflight, whose result the inputs with the same key share
+type flight struct {
+	// done is closed once out and err are set
+	done chan struct{}
+	out  interface{}
+	err  error
+}
+
+// flights holds the calls in flight of ProcessSingleflight by key
+type flights struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// join returns the call in flight of key and true, or starts a new one and returns false
+func (fs *flights) join(key string) (*flight, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if f, ok := fs.calls[key]; ok {
+		atomic.AddInt64(&flightJoins, 1)
+		return f, true
+	}
+	f := &flight{done: make(chan struct{})}
+	fs.calls[key] = f
+	return f, false
+}
+
+// land forgets the call f of key, so that the next input with key starts a new one, and wakes up the inputs that share it
+func (fs *flights) land(key string, f *flight) {
+	fs.mu.Lock()
+	delete(fs.calls, key)
+	fs.mu.Unlock()
+	close(f.done)
+}
+
+// newSingleflight returns the Processor of ProcessSingleflight, whose workers share the calls in flight of `fs`
+func newSingleflight(fs *flights, keyFn func(i interface{}) string, copy func(o interface{}) interface{}, p Processor) Processor {
+	return forwardBatches(&singleflight{
+		flights:   fs,
+		keyFn:     keyFn,
+		copy:      copy,
+		processor: p,
+	}, p, passBatch)
+}
+
+// singleflight implements Processor
+type singleflight struct {
+	flights   *flights
+	keyFn     func(i interface{}) string
+	copy      func(o interface{}) interface{}
+	processor Processor
+}
+
+func (s *singleflight) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	key := s.keyFn(i)
+	f, shared := s.flights.join(key)
+	if shared {
+		<-f.done
+		if f.err != nil || s.copy == nil {
+			return f.out, f.err
+		}
+		return s.copy(f.out), nil
+	}
+	defer func() {
+		// The inputs that share the call fail with the panic, which the stage then handles for this input
+		if r := recover(); r != nil {
+			f.err = &PanicError{Value: r, Stack: debug.Stack()}
+			s.flights.land(key, f)
+			panic(r)
+		}
+	}()
+	f.out, f.err = s.processor.Process(ctx, i)
+	s.flights.land(key, f)
+	return f.out, f.err
+}
+
+func (s *singleflight) Cancel(i interface{}, err error) {
+	s.processor.Cancel(i, err)
+}
+
+func (s *singleflight) CancelContext(ctx context.Context, i interface{}, err error) {
+	cancelContext(ctx, s.processor, i, err)
+}
+
+func (s *singleflight) Setup(ctx context.Context) error {
+	return setupWrapped(ctx, s.processor)
+}
+
+func (s *singleflight) Teardown() error {
+	return teardownWrapped(s.processor)
+}
+
+func (s *singleflight) CloneForWorker() Processor {
+	c, ok := cloneWrapped(s.processor)
+	if !ok {
+		return nil
+	}
+	return newSingleflight(s.flights, s.keyFn, s.copy, c)
+}
@@ -0,0 +1,148 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProcessSingleflight(t *testing.T) {
+	byValue := func(i interface{}) string {
+		return fmt.Sprint(i)
+	}
+	// duplicates sends n equal inputs to ProcessSingleflight, whose call blocks until every other duplicate joined it,
+	// and returns their results, the errors they are canceled with and the number of calls
+	duplicates := func(n int, process func() (interface{}, error), opts ...Option) ([]interface{}, []error, int32) {
+		var calls int32
+		var mu sync.Mutex
+		var canceled []error
+		release := make(chan struct{})
+		p := NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return process()
+		}, func(i interface{}, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			canceled = append(canceled, err)
+		})
+		joins := atomic.LoadInt64(&flightJoins)
+		out := ProcessSingleflight(context.Background(), byValue, p, Emit(make([]interface{}, n)...), append(opts, WithConcurrency(n))...)
+		for atomic.LoadInt64(&flightJoins)-joins < int64(n-1) {
+			runtime.Gosched()
+		}
+		close(release)
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		return outs, canceled, atomic.LoadInt32(&calls)
+	}
+
+	t.Run("the duplicates in flight share a single call", func(t *testing.T) {
+		const n = 10
+		outs, _, calls := duplicates(n, func() (interface{}, error) {
+			return []int{0}, nil
+		})
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+		if len(outs) != n {
+			t.Fatalf("got %d results, want %d", len(outs), n)
+		}
+		// Every duplicate is sent the same slice
+		for _, o := range outs {
+			if &o.([]int)[0] != &outs[0].([]int)[0] {
+				t.Errorf("results do not alias each other")
+			}
+		}
+	})
+
+	t.Run("WithSharedCopy sends the duplicates a copy", func(t *testing.T) {
+		copyInts := func(o interface{}) interface{} {
+			return append([]int(nil), o.([]int)...)
+		}
+		outs, _, calls := duplicates(2, func() (interface{}, error) {
+			return []int{0}, nil
+		}, WithSharedCopy(copyInts))
+		if calls != 1 || len(outs) != 2 {
+			t.Fatalf("got %d results of %d calls, want 2 results of 1 call", len(outs), calls)
+		}
+		first, second := outs[0].([]int), outs[1].([]int)
+		if !reflect.DeepEqual(first, second) || &first[0] == &second[0] {
+			t.Errorf("results = %v at %p and %v at %p, want equal copies", first, first, second, second)
+		}
+	})
+
+	t.Run("the duplicates share the error of the call", func(t *testing.T) {
+		outs, canceled, calls := duplicates(3, func() (interface{}, error) {
+			return nil, errProcess
+		})
+		if len(outs) != 0 {
+			t.Errorf("unexpected results %v", outs)
+		}
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+		if len(canceled) != 3 {
+			t.Fatalf("canceled %d inputs, want 3", len(canceled))
+		}
+		for _, err := range canceled {
+			if !errors.Is(err, errProcess) {
+				t.Errorf("canceled with %v, want %v", err, errProcess)
+			}
+		}
+	})
+
+	t.Run("the inputs are counted like the ones of Process", func(t *testing.T) {
+		var m MemoryMetrics
+		outs, _, _ := duplicates(3, func() (interface{}, error) {
+			return 0, nil
+		}, WithMetrics("singleflight", &m))
+		got := m.Stage("singleflight")
+		if len(outs) != 3 || got.Received != 3 || got.Emitted != 3 || got.Processed != 3 || got.InFlight() != 0 {
+			t.Errorf("metrics = %+v for %d results, want 3 inputs received, processed and emitted", got, len(outs))
+		}
+	})
+
+	t.Run("a panic fails every input that shares the call", func(t *testing.T) {
+		outs, canceled, _ := duplicates(2, func() (interface{}, error) {
+			panic("boom")
+		})
+		if len(outs) != 0 || len(canceled) != 2 {
+			t.Fatalf("got %d results and %d canceled inputs, want 0 and 2", len(outs), len(canceled))
+		}
+		for _, err := range canceled {
+			var pErr *PanicError
+			if !errors.As(err, &pErr) || pErr.Value != "boom" {
+				t.Errorf("canceled with %v, want the *PanicError of boom", err)
+			}
+		}
+	})
+
+	t.Run("the results keep the order of the inputs and a key is called again once it is done", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := map[interface{}]int{}
+		p := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[i]++
+			return i.(int) * 2, nil
+		})
+		var outs []interface{}
+		for o := range ProcessSingleflight(context.Background(), byValue, p, Emit(3, 1, 2, 1, 0), WithConcurrency(4)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{6, 2, 4, 2, 0}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %v, want %v", outs, want)
+		}
+		if n := calls[1]; n < 1 || n > 2 {
+			t.Errorf("1 was processed %d times, want 1 or 2", n)
+		}
+	})
+}
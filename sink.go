@@ -0,0 +1,53 @@
+package pipeline
+
+import "context"
+
+// Drain discards every input from the `in <-chan interface{}` until it is closed or the context is canceled.
+// It returns the `Context.Err()` if the context was canceled first.
+// When the context is canceled, the remaining inputs are discarded in the background,
+// so the stages before Drain are never blocked.
+func Drain(ctx context.Context, in <-chan interface{}) error {
+	return ForEach(ctx, in, func(interface{}) error {
+		return nil
+	})
+}
+
+// ToSlice collects every input from the `in <-chan interface{}` into a slice until it is closed.
+// If the context is canceled first, it returns the inputs collected so far along with the `Context.Err()`
+// and the remaining inputs are discarded in the background.
+func ToSlice(ctx context.Context, in <-chan interface{}) ([]interface{}, error) {
+	var is []interface{}
+	err := ForEach(ctx, in, func(i interface{}) error {
+		is = append(is, i)
+		return nil
+	})
+	return is, err
+}
+
+// ForEach calls `fn` with every input from the `in <-chan interface{}` until it is closed.
+// It stops and returns the error if `fn` returns an error or the `Context.Err()` if the context is canceled.
+// After ForEach returns early, the remaining inputs are discarded in the background until the `in <-chan interface{}` is closed,
+// so the goroutines of the stages before ForEach can finish instead of blocking forever.
+func ForEach(ctx context.Context, in <-chan interface{}, fn func(i interface{}) error) error {
+	for {
+		select {
+		case i, open := <-in:
+			if !open {
+				return nil
+			}
+			if err := fn(i); err != nil {
+				go discard(in)
+				return err
+			}
+		case <-ctx.Done():
+			go discard(in)
+			return ctx.Err()
+		}
+	}
+}
+
+// discard reads from in until it is closed
+func discard(in <-chan interface{}) {
+	for range in {
+	}
+}
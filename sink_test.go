@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	t.Run("returns nil when in closes", func(t *testing.T) {
+		if err := Drain(context.Background(), Emit(1, 2, 3)); err != nil {
+			t.Errorf("err = %s, want nil", err)
+		}
+	})
+
+	t.Run("returns the context error when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := Drain(ctx, make(chan interface{})); !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want %s", err, context.Canceled)
+		}
+	})
+}
+
+func TestToSlice(t *testing.T) {
+	t.Run("collects every input", func(t *testing.T) {
+		is, err := ToSlice(context.Background(), Emit(1, 2, 3))
+		if err != nil {
+			t.Errorf("err = %s, want nil", err)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, is) {
+			t.Errorf("is = %+v, want %+v", is, want)
+		}
+	})
+
+	t.Run("returns an error if the context is canceled before in closes", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+		is, err := ToSlice(ctx, Delay(context.Background(), 100*time.Millisecond, Emit(1, 2, 3)))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want %s", err, context.DeadlineExceeded)
+		}
+		if want := []interface{}{1, 2}; !reflect.DeepEqual(want, is) {
+			t.Errorf("is = %+v, want %+v", is, want)
+		}
+	})
+}
+
+func TestForEach(t *testing.T) {
+	t.Run("upstream goroutines exit when ForEach returns early", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+
+		// Stop after the third input
+		errStop := errors.New("stop")
+		var seen []interface{}
+		err := ForEach(context.Background(), Process(context.Background(), &mockProcessor{}, Emit(1, 2, 3, 4, 5, 6)), func(i interface{}) error {
+			seen = append(seen, i)
+			if len(seen) == 3 {
+				return errStop
+			}
+			return nil
+		})
+
+		if !errors.Is(err, errStop) {
+			t.Errorf("err = %v, want %s", err, errStop)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, seen) {
+			t.Errorf("seen = %+v, want %+v", seen, want)
+		}
+
+		// The Process and Emit goroutines should finish once the rest of the inputs are discarded
+		time.Sleep(50 * time.Millisecond)
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("goroutines = %d, want <= %d", after, before)
+		}
+	})
+}
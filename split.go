@@ -2,11 +2,15 @@ package pipeline
 
 // Split takes an interface from Collect and splits it back out into individual elements
 // Useful for batch processing pipelines (`input chan -> Collect -> Process -> Split -> Cancel -> output chan`).
+// A Tracked `[]interface{}` is split into a Tracked per element, which acknowledge it once all of them are acknowledged.
 func Split(in <-chan interface{}) <-chan interface{} {
 	out := make(chan interface{})
 	go func() {
 		defer close(out)
 		for is := range in {
+			if t, ok := is.(Tracked); ok {
+				is = splitTracked(t)
+			}
 			for _, i := range is.([]interface{}) {
 				out <- i
 			}
@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+)
+
+// EmitRows scans each of the `rows` with `scan` and sends the results to the out `<-chan interface{}`,
+// so the results of a query can be processed without loading them all into memory.
+// The first error from `scan` or from iterating the rows is sent to the errs chan, which never blocks.
+// The rows are closed, and both chans with them, once every row has been read, after an error, or when the context is canceled.
+func EmitRows(ctx context.Context, rows *sql.Rows, scan func(*sql.Rows) (interface{}, error)) (<-chan interface{}, <-chan error) {
+	out := make(chan interface{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		defer rows.Close()
+		for ctx.Err() == nil && rows.Next() {
+			row, err := scan(rows)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- row:
+			}
+		}
+		if err := rows.Err(); err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+	return out, errs
+}
+
+// InsertBatch creates a Processor that passes each batch of inputs to `insert`, for example to write them to a database
+// in a single statement. Use it with ProcessBatch, which batches the inputs itself and emits each of them once their batch is inserted,
+// or with Process after Collect, which emits the whole batch.
+// A batch that fails with an error for which `deadlock` returns true is tried again up to `attempts` times, like RetryIf.
+// A batch that still fails is passed to `cancel` with its error, like any other failed input, so it can be logged or sent to a dead letter queue.
+// `cancel` may be nil.
+func InsertBatch(
+	attempts int,
+	backoff BackoffStrategy,
+	deadlock func(err error) bool,
+	insert func(ctx context.Context, batch []interface{}) error,
+	cancel func(batch []interface{}, err error),
+) Processor {
+	return RetryIf(attempts, backoff, deadlock, &inserter{
+		insert: insert,
+		cancel: cancel,
+	})
+}
+
+// inserter implements Processor
+type inserter struct {
+	insert func(ctx context.Context, batch []interface{}) error
+	cancel func(batch []interface{}, err error)
+}
+
+func (n *inserter) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	if err := n.insert(ctx, asBatch(i)); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (n *inserter) Cancel(i interface{}, err error) {
+	if n.cancel != nil {
+		n.cancel(asBatch(i), err)
+	}
+}
+
+// asBatch returns a batch of inputs as a []interface{}, treating anything else as a batch of one
+func asBatch(i interface{}) []interface{} {
+	if batch, ok := i.([]interface{}); ok {
+		return batch
+	}
+	return []interface{}{i}
+}
@@ -0,0 +1,237 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDB is a database/sql driver whose queries return the rows 1 to n in a single "n" column,
+// failing with err after failAfter rows when err is set
+type fakeDB struct {
+	n         int
+	failAfter int
+	err       error
+	mu        sync.Mutex
+	closed    int
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return f, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+func (f *fakeDB) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (f *fakeDB) Close() error                                 { return nil }
+func (f *fakeDB) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+
+func (f *fakeDB) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{db: f}, nil
+}
+
+func (f *fakeDB) rowsClosed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// fakeRows implements driver.Rows
+type fakeRows struct {
+	db   *fakeDB
+	next int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+
+func (r *fakeRows) Close() error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	r.db.closed++
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.db.err != nil && r.next == r.db.failAfter {
+		return r.db.err
+	}
+	if r.next == r.db.n {
+		return io.EOF
+	}
+	r.next++
+	dest[0] = int64(r.next)
+	return nil
+}
+
+func queryFake(t *testing.T, f *fakeDB) *sql.Rows {
+	t.Helper()
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	rows, err := db.QueryContext(context.Background(), "SELECT n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func scanInt(rows *sql.Rows) (interface{}, error) {
+	var n int
+	err := rows.Scan(&n)
+	return n, err
+}
+
+func TestEmitRows(t *testing.T) {
+	rowsErr := errors.New("connection reset")
+	scanErr := errors.New("bad row")
+	tests := []struct {
+		name    string
+		db      *fakeDB
+		scan    func(*sql.Rows) (interface{}, error)
+		wantOut []interface{}
+		wantErr error
+	}{{
+		name:    "every row is scanned and emitted in order",
+		db:      &fakeDB{n: 3},
+		scan:    scanInt,
+		wantOut: []interface{}{1, 2, 3},
+	}, {
+		name:    "no rows",
+		db:      &fakeDB{},
+		scan:    scanInt,
+		wantOut: nil,
+	}, {
+		name:    "an error iterating the rows is sent to errs",
+		db:      &fakeDB{n: 3, failAfter: 2, err: rowsErr},
+		scan:    scanInt,
+		wantOut: []interface{}{1, 2},
+		wantErr: rowsErr,
+	}, {
+		name: "a scan error is sent to errs and stops the rows",
+		db:   &fakeDB{n: 3},
+		scan: func(rows *sql.Rows) (interface{}, error) {
+			n, err := scanInt(rows)
+			if n == 2 {
+				return nil, scanErr
+			}
+			return n, err
+		},
+		wantOut: []interface{}{1},
+		wantErr: scanErr,
+	}}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			out, errs := EmitRows(context.Background(), queryFake(t, test.db), test.scan)
+			var outs []interface{}
+			for o := range out {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(test.wantOut, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.wantOut)
+			}
+			if err := <-errs; !errors.Is(err, test.wantErr) {
+				t.Errorf("err = %v, want %v", err, test.wantErr)
+			}
+			if closed := test.db.rowsClosed(); closed != 1 {
+				t.Errorf("rows closed %d times, want 1", closed)
+			}
+		})
+	}
+
+	t.Run("the rows are closed when the context is canceled", func(t *testing.T) {
+		db := &fakeDB{n: 1000}
+		ctx, cancel := context.WithCancel(context.Background())
+		out, errs := EmitRows(ctx, queryFake(t, db), scanInt)
+		<-out
+		cancel()
+		select {
+		case <-waitClosed(out):
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed after the context was canceled")
+		}
+		if err, open := <-errs; err != nil || open {
+			t.Errorf("errs = %v, %v, want nil, false", err, open)
+		}
+		if closed := db.rowsClosed(); closed != 1 {
+			t.Errorf("rows closed %d times, want 1", closed)
+		}
+	})
+}
+
+func TestInsertBatch(t *testing.T) {
+	deadlock := errors.New("deadlock detected")
+	isDeadlock := func(err error) bool {
+		return errors.Is(err, deadlock)
+	}
+
+	t.Run("each batch is inserted and its inputs are emitted by ProcessBatch", func(t *testing.T) {
+		var inserted [][]interface{}
+		insert := func(_ context.Context, batch []interface{}) error {
+			inserted = append(inserted, batch)
+			return nil
+		}
+		var outs []interface{}
+		p := InsertBatch(3, ConstantBackoff(time.Millisecond), isDeadlock, insert, nil)
+		for o := range ProcessBatch(context.Background(), 2, time.Minute, p, Emit(1, 2, 3)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if want := [][]interface{}{{1, 2}, {3}}; !reflect.DeepEqual(want, inserted) {
+			t.Errorf("inserted = %+v, want %+v", inserted, want)
+		}
+	})
+
+	t.Run("a deadlocked batch is retried", func(t *testing.T) {
+		attempts := 0
+		insert := func(context.Context, []interface{}) error {
+			if attempts++; attempts < 3 {
+				return deadlock
+			}
+			return nil
+		}
+		p := InsertBatch(3, ConstantBackoff(time.Millisecond), isDeadlock, insert, func([]interface{}, error) {
+			t.Error("nothing should be canceled")
+		})
+		var outs []interface{}
+		for o := range Process(context.Background(), p, Collect(context.Background(), 2, time.Minute, Emit(1, 2))) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{[]interface{}{1, 2}}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if attempts != 3 {
+			t.Errorf("attempts = %d, want 3", attempts)
+		}
+	})
+
+	t.Run("a batch that fails for another reason is canceled without retrying", func(t *testing.T) {
+		failed := errors.New("constraint violation")
+		attempts := 0
+		insert := func(context.Context, []interface{}) error {
+			attempts++
+			return failed
+		}
+		var canceled [][]interface{}
+		var errs []error
+		p := InsertBatch(3, ConstantBackoff(time.Millisecond), isDeadlock, insert, func(batch []interface{}, err error) {
+			canceled = append(canceled, batch)
+			errs = append(errs, err)
+		})
+		for range ProcessBatch(context.Background(), 2, time.Minute, p, Emit(1, 2)) {
+			t.Error("nothing should be emitted")
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+		if want := [][]interface{}{{1, 2}}; !reflect.DeepEqual(want, canceled) {
+			t.Errorf("canceled = %+v, want %+v", canceled, want)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], failed) {
+			t.Errorf("errs = %v, want [%v]", errs, failed)
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package sqs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Source long-polls the queue at `queueURL` and emits its messages as a pipeline.Tracked whose `Value` is a Message.
+// A batch of up to 10 messages is only received once the previous one has been emitted, so a slow pipeline pauses the consumption.
+//
+// While a message is in flight, its visibility timeout is extended on a heartbeat so no other consumer receives it.
+// Once it is acknowledged with nil, which pipeline.Untrack does when it leaves the pipeline, it is deleted in a batch of up to 10.
+// Once it is acknowledged with an error, it is made visible again so it can be received again.
+// A message that is still in flight at the maximum visibility of WithMaxVisibility is passed to the func of WithExpiring.
+//
+// Source stops receiving and closes the out `<-chan interface{}` when the context is canceled,
+// then it deletes the messages that were already acknowledged within the grace period of WithGrace.
+// The messages acknowledged after that become visible again once their visibility timeout is over.
+func Source(ctx context.Context, client SQSAPI, queueURL string, opts ...Option) <-chan interface{} {
+	s := &source{
+		client:   client,
+		queueURL: queueURL,
+		cfg:      newConfig(opts),
+		inFlight: make(map[string]*inFlight),
+		flushNow: make(chan struct{}, 1),
+	}
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for failures := 0; ctx.Err() == nil; {
+			msgs, err := client.ReceiveMessages(ctx, queueURL, maxBatch, s.cfg.wait, s.cfg.visibility)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.cfg.errored(err)
+					failures++
+					wait(ctx, s.cfg.backoff(failures))
+				}
+				continue
+			}
+			failures = 0
+			ts := make([]pipeline.Tracked, len(msgs))
+			for n, msg := range msgs {
+				ts[n] = s.track(msg)
+			}
+			for n, t := range ts {
+				select {
+				case out <- t:
+				case <-ctx.Done():
+					// The messages that were not emitted become visible again once their visibility timeout is over
+					for _, t := range ts[n:] {
+						s.forget(t.Value.(Message))
+					}
+					return
+				}
+			}
+		}
+	}()
+	go s.keep(ctx)
+	return out
+}
+
+// wait waits for d or until the context is canceled
+func wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// inFlight is a message that is being processed
+type inFlight struct {
+	msg      Message
+	expiring bool
+}
+
+// source holds the messages of Source from when they are received until they are deleted or made visible again
+type source struct {
+	client   SQSAPI
+	queueURL string
+	cfg      *config
+	mu       sync.Mutex
+	inFlight map[string]*inFlight
+	deletes  []string
+	nacks    []string
+	flushNow chan struct{}
+}
+
+// track adds msg to the messages in flight and returns it as a Tracked that deletes it, or makes it visible again, when it is acknowledged
+func (s *source) track(msg Message) pipeline.Tracked {
+	if msg.Received.IsZero() {
+		msg.Received = time.Now()
+	}
+	s.mu.Lock()
+	s.inFlight[msg.ReceiptHandle] = &inFlight{msg: msg}
+	s.mu.Unlock()
+	return pipeline.Tracked{Value: msg, Ack: func(err error) {
+		s.ack(msg, err)
+	}}
+}
+
+// forget stops extending the visibility timeout of msg
+func (s *source) forget(msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, msg.ReceiptHandle)
+}
+
+// ack queues msg to be deleted, or to be made visible again if err is not nil
+func (s *source) ack(msg Message, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.inFlight[msg.ReceiptHandle]; !ok {
+		return
+	}
+	delete(s.inFlight, msg.ReceiptHandle)
+	if err != nil {
+		s.nacks = append(s.nacks, msg.ReceiptHandle)
+	} else {
+		s.deletes = append(s.deletes, msg.ReceiptHandle)
+	}
+	if len(s.deletes) >= maxBatch || err != nil {
+		select {
+		case s.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// keep extends the visibility timeout of the messages in flight every half of it,
+// and flushes the acknowledged messages, until the context is canceled
+func (s *source) keep(ctx context.Context) {
+	heartbeat := time.NewTicker(s.cfg.visibility / 2)
+	defer heartbeat.Stop()
+	flush := time.NewTicker(s.cfg.flush)
+	defer flush.Stop()
+	for {
+		select {
+		case <-heartbeat.C:
+			s.extend(ctx)
+		case <-flush.C:
+			s.flush(ctx)
+		case <-s.flushNow:
+			s.flush(ctx)
+		case <-ctx.Done():
+			gctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.grace)
+			defer cancel()
+			s.flush(gctx)
+			return
+		}
+	}
+}
+
+// extend extends the visibility timeout of the messages in flight,
+// except for those that have reached the maximum visibility, which are reported as expiring instead
+func (s *source) extend(ctx context.Context) {
+	var extend []string
+	var expiring []Message
+	s.mu.Lock()
+	for handle, f := range s.inFlight {
+		if time.Since(f.msg.Received)+s.cfg.visibility <= s.cfg.maxVisibility {
+			extend = append(extend, handle)
+		} else if !f.expiring {
+			f.expiring = true
+			expiring = append(expiring, f.msg)
+		}
+	}
+	s.mu.Unlock()
+	for _, msg := range expiring {
+		s.cfg.expiring(msg)
+	}
+	for _, handle := range extend {
+		if err := s.client.ChangeVisibility(ctx, s.queueURL, handle, s.cfg.visibility); err != nil && ctx.Err() == nil {
+			s.cfg.errored(err)
+		}
+	}
+}
+
+// flush deletes the acknowledged messages in batches of up to 10, and makes the ones that failed visible again
+func (s *source) flush(ctx context.Context) {
+	s.mu.Lock()
+	deletes, nacks := s.deletes, s.nacks
+	s.deletes, s.nacks = nil, nil
+	s.mu.Unlock()
+	for len(deletes) > 0 {
+		n := len(deletes)
+		if n > maxBatch {
+			n = maxBatch
+		}
+		if err := s.client.DeleteMessages(ctx, s.queueURL, deletes[:n]); err != nil {
+			s.cfg.errored(err)
+		}
+		deletes = deletes[n:]
+	}
+	for _, handle := range nacks {
+		if err := s.client.ChangeVisibility(ctx, s.queueURL, handle, 0); err != nil {
+			s.cfg.errored(err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package sqs feeds pipelines from an AWS SQS queue through the small SQSAPI interface,
+// which can be implemented on top of any version of the AWS SDK.
+//
+// Source emits the messages of a queue as pipeline.Tracked inputs, keeps them invisible to other consumers
+// while they are being processed, and deletes them once they are acknowledged at the end of the pipeline:
+//
+//	msgs := sqs.Source(ctx, client, queueURL)
+//	for o := range pipeline.Untrack(ctx, pipeline.TrackedProcess(ctx, p, msgs)) {
+//		// ...
+//	}
+package sqs
+
+import (
+	"context"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// Message is a message received from a queue
+type Message struct {
+	ID            string
+	ReceiptHandle string
+	Body          string
+	Attributes    map[string]string
+	// Received is when the message was received, which bounds how long its visibility timeout can be extended
+	Received time.Time
+}
+
+// SQSAPI is the part of the SQS API that Source uses
+type SQSAPI interface {
+	// ReceiveMessages receives up to `max` messages from the queue, waiting up to `wait` for at least one,
+	// and hides them from other consumers for `visibility`
+	ReceiveMessages(ctx context.Context, queueURL string, max int, wait, visibility time.Duration) ([]Message, error)
+	// ChangeVisibility hides the message of `receiptHandle` for `visibility` from now, or makes it visible again if it is 0
+	ChangeVisibility(ctx context.Context, queueURL, receiptHandle string, visibility time.Duration) error
+	// DeleteMessages deletes the messages of up to 10 `receiptHandles`
+	DeleteMessages(ctx context.Context, queueURL string, receiptHandles []string) error
+}
+
+// maxBatch is the largest number of messages that SQS receives or deletes at once
+const maxBatch = 10
+
+// Option configures Source
+type Option func(*config)
+
+// config holds the settings of Source
+type config struct {
+	wait          time.Duration
+	visibility    time.Duration
+	maxVisibility time.Duration
+	flush         time.Duration
+	backoff       pipeline.BackoffStrategy
+	errored       func(err error)
+	expiring      func(msg Message)
+	grace         time.Duration
+}
+
+// newConfig applies opts to the default config
+func newConfig(opts []Option) *config {
+	c := &config{
+		wait:          20 * time.Second,
+		visibility:    30 * time.Second,
+		maxVisibility: 12 * time.Hour,
+		flush:         100 * time.Millisecond,
+		backoff:       pipeline.ExponentialBackoff(100*time.Millisecond, 10*time.Second),
+		errored:       func(error) {},
+		expiring:      func(Message) {},
+		grace:         5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithWaitTime sets how long each receive waits for messages, which is 20 seconds by default
+func WithWaitTime(wait time.Duration) Option {
+	return func(c *config) {
+		c.wait = wait
+	}
+}
+
+// WithVisibility sets the visibility timeout of the messages, which is extended every half of it while they are in flight.
+// The default is 30 seconds.
+func WithVisibility(visibility time.Duration) Option {
+	return func(c *config) {
+		c.visibility = visibility
+	}
+}
+
+// WithMaxVisibility sets how long after it was received the visibility timeout of a message can be extended to,
+// which is the 12 hours that SQS allows by default
+func WithMaxVisibility(max time.Duration) Option {
+	return func(c *config) {
+		c.maxVisibility = max
+	}
+}
+
+// WithFlushInterval sets how long the acknowledged messages wait to be deleted in a batch of up to 10, which is 100ms by default
+func WithFlushInterval(flush time.Duration) Option {
+	return func(c *config) {
+		c.flush = flush
+	}
+}
+
+// WithReceiveBackoff sets how long Source waits to receive again after a receive fails.
+// The default is an exponential backoff from 100ms to 10s.
+func WithReceiveBackoff(backoff pipeline.BackoffStrategy) Option {
+	return func(c *config) {
+		c.backoff = backoff
+	}
+}
+
+// WithErrors passes the errors of the SQS API to `errored`, which must not block.
+// Source does not stop on them: it receives again after a backoff, and a message that could not be deleted
+// or extended becomes visible again and is received again.
+func WithErrors(errored func(err error)) Option {
+	return func(c *config) {
+		c.errored = errored
+	}
+}
+
+// WithExpiring passes each message whose processing outlives the maximum visibility of WithMaxVisibility to `expiring`,
+// since it will become visible to other consumers before it is acknowledged.
+// It is called once per message and must not block.
+func WithExpiring(expiring func(msg Message)) Option {
+	return func(c *config) {
+		c.expiring = expiring
+	}
+}
+
+// WithGrace sets how long Source can take to delete the acknowledged messages after its context is canceled.
+// The default is 5 seconds.
+func WithGrace(grace time.Duration) Option {
+	return func(c *config) {
+		c.grace = grace
+	}
+}
@@ -0,0 +1,230 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// queue is an in-memory SQS queue that implements SQSAPI
+type queue struct {
+	mu          sync.Mutex
+	messages    []Message
+	receives    int
+	receiveErr  error
+	extended    map[string]int
+	visible     []string
+	deleted     []string
+	deleteCalls [][]string
+}
+
+func newQueue(n int) *queue {
+	q := &queue{extended: make(map[string]int)}
+	for i := 0; i < n; i++ {
+		q.messages = append(q.messages, Message{ID: fmt.Sprint(i), ReceiptHandle: fmt.Sprintf("h%d", i), Body: fmt.Sprint(i)})
+	}
+	return q
+}
+
+func (q *queue) ReceiveMessages(ctx context.Context, _ string, max int, wait, _ time.Duration) ([]Message, error) {
+	q.mu.Lock()
+	q.receives++
+	if err := q.receiveErr; err != nil {
+		q.receiveErr = nil
+		q.mu.Unlock()
+		return nil, err
+	}
+	if len(q.messages) == 0 {
+		q.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+			return nil, nil
+		}
+	}
+	defer q.mu.Unlock()
+	if max > len(q.messages) {
+		max = len(q.messages)
+	}
+	msgs := q.messages[:max]
+	q.messages = q.messages[max:]
+	return msgs, nil
+}
+
+func (q *queue) ChangeVisibility(_ context.Context, _, handle string, visibility time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if visibility == 0 {
+		q.visible = append(q.visible, handle)
+	} else {
+		q.extended[handle]++
+	}
+	return nil
+}
+
+func (q *queue) DeleteMessages(_ context.Context, _ string, handles []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.deleteCalls = append(q.deleteCalls, append([]string(nil), handles...))
+	q.deleted = append(q.deleted, handles...)
+	return nil
+}
+
+// snapshot returns copies of the deleted and visible handles, sorted
+func (q *queue) snapshot() (deleted, visible []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	deleted = append([]string(nil), q.deleted...)
+	visible = append([]string(nil), q.visible...)
+	sort.Strings(deleted)
+	sort.Strings(visible)
+	return deleted, visible
+}
+
+// eventually retries check until it returns true or a second has passed
+func eventually(t *testing.T, check func() bool, msg string) {
+	t.Helper()
+	for start := time.Now(); !check(); time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal(msg)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	t.Run("acknowledged messages are deleted in batches of up to 10", func(t *testing.T) {
+		q := newQueue(25)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Source(ctx, q, "url", WithWaitTime(time.Millisecond), WithFlushInterval(10*time.Millisecond))
+		for n := 0; n < 25; n++ {
+			(<-out).(pipeline.Tracked).Ack(nil)
+		}
+		eventually(t, func() bool {
+			deleted, _ := q.snapshot()
+			return len(deleted) == 25
+		}, "not every message was deleted")
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for _, handles := range q.deleteCalls {
+			if len(handles) > maxBatch {
+				t.Errorf("deleted %d messages at once, want at most %d", len(handles), maxBatch)
+			}
+		}
+		if len(q.deleteCalls) > 5 {
+			t.Errorf("deleted in %d calls, want batches", len(q.deleteCalls))
+		}
+	})
+
+	t.Run("a message acknowledged with an error is made visible again", func(t *testing.T) {
+		q := newQueue(2)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Source(ctx, q, "url", WithWaitTime(time.Millisecond), WithFlushInterval(time.Millisecond))
+		(<-out).(pipeline.Tracked).Ack(errors.New("failed"))
+		(<-out).(pipeline.Tracked).Ack(nil)
+		eventually(t, func() bool {
+			deleted, visible := q.snapshot()
+			return reflect.DeepEqual(deleted, []string{"h1"}) && reflect.DeepEqual(visible, []string{"h0"})
+		}, "h0 was not made visible or h1 was not deleted")
+	})
+
+	t.Run("the visibility of messages in flight is extended until they are acknowledged", func(t *testing.T) {
+		q := newQueue(2)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Source(ctx, q, "url", WithWaitTime(time.Millisecond), WithVisibility(20*time.Millisecond))
+		first, second := (<-out).(pipeline.Tracked), (<-out).(pipeline.Tracked)
+		first.Ack(nil)
+		time.Sleep(55 * time.Millisecond)
+		q.mu.Lock()
+		extended := map[string]int{"h0": q.extended["h0"], "h1": q.extended["h1"]}
+		q.mu.Unlock()
+		if extended["h0"] != 0 || extended["h1"] < 3 {
+			t.Errorf("extended = %v, want h1 extended every 10ms and h0 never", extended)
+		}
+		second.Ack(nil)
+	})
+
+	t.Run("a message that outlives the maximum visibility is reported once", func(t *testing.T) {
+		q := newQueue(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var mu sync.Mutex
+		var expiring []Message
+		out := Source(ctx, q, "url",
+			WithWaitTime(time.Millisecond),
+			WithVisibility(10*time.Millisecond),
+			WithMaxVisibility(30*time.Millisecond),
+			WithExpiring(func(msg Message) {
+				mu.Lock()
+				defer mu.Unlock()
+				expiring = append(expiring, msg)
+			}))
+		<-out
+		time.Sleep(80 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		if len(expiring) != 1 || expiring[0].ID != "0" {
+			t.Errorf("expiring = %+v, want message 0 once", expiring)
+		}
+	})
+
+	t.Run("a slow pipeline pauses the consumption", func(t *testing.T) {
+		q := newQueue(100)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := Source(ctx, q, "url", WithWaitTime(time.Millisecond))
+		<-out
+		time.Sleep(10 * time.Millisecond)
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.receives != 1 {
+			t.Errorf("receives = %d, want 1", q.receives)
+		}
+	})
+
+	t.Run("receive errors are reported and the source receives again", func(t *testing.T) {
+		q := newQueue(1)
+		q.receiveErr = errors.New("throttled")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errs := make(chan error, 1)
+		out := Source(ctx, q, "url",
+			WithWaitTime(time.Millisecond),
+			WithReceiveBackoff(pipeline.ConstantBackoff(time.Millisecond)),
+			WithErrors(func(err error) {
+				errs <- err
+			}))
+		if msg := (<-out).(pipeline.Tracked).Value.(Message); msg.ID != "0" {
+			t.Errorf("msg = %+v, want message 0", msg)
+		}
+		if err := <-errs; err.Error() != "throttled" {
+			t.Errorf("err = %v, want throttled", err)
+		}
+	})
+
+	t.Run("the acknowledged messages are deleted when the context is canceled", func(t *testing.T) {
+		q := newQueue(3)
+		ctx, cancel := context.WithCancel(context.Background())
+		out := Source(ctx, q, "url", WithWaitTime(time.Millisecond), WithFlushInterval(time.Hour))
+		for n := 0; n < 3; n++ {
+			(<-out).(pipeline.Tracked).Ack(nil)
+		}
+		cancel()
+		for range out {
+		}
+		eventually(t, func() bool {
+			deleted, _ := q.snapshot()
+			return len(deleted) == 3
+		}, "the acknowledged messages were not deleted")
+	})
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+)
+
+// defaultHeartbeat is how often NewSSEHandler writes a comment to idle clients when WithHeartbeat is not set
+const defaultHeartbeat = 15 * time.Second
+
+// NewSSEHandler returns an http.Handler that streams the outputs of a pipeline to the client as Server-Sent Events.
+// Each request builds its own pipeline by calling `source` with a context that is canceled as soon as
+// the client disconnects or a write fails, so that the goroutines of that pipeline stop with it.
+// Each output is encoded by `marshal` into the data of one event; the outputs it fails on are skipped,
+// use WithErrors to handle them. A comment is written every 15s while no event is, so that proxies keep
+// the connection open, use WithHeartbeat to change the interval.
+// The response ends when the out chan of the pipeline is closed.
+//
+// To send the outputs of one shared pipeline to every client, make `source` subscribe to a fan-out of it
+// and unsubscribe when its context is canceled.
+func NewSSEHandler(source func(ctx context.Context) <-chan interface{}, marshal func(i interface{}) ([]byte, error), opts ...Option) http.Handler {
+	cfg := newConfig(opts)
+	if cfg.heartbeat <= 0 {
+		cfg.heartbeat = defaultHeartbeat
+	}
+	return &sseHandler{source: source, marshal: marshal, cfg: cfg}
+}
+
+// sseHandler is the http.Handler returned by NewSSEHandler
+type sseHandler struct {
+	source  func(ctx context.Context) <-chan interface{}
+	marshal func(i interface{}) ([]byte, error)
+	cfg     *config
+}
+
+func (h *sseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	out := h.source(ctx)
+	// The pipeline closes out once it sees the cancellation, which may take a while
+	defer func() { go discard(out) }()
+	ticker := time.NewTicker(h.cfg.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case i, open := <-out:
+			if !open {
+				return
+			}
+			data, err := h.marshal(i)
+			if err != nil {
+				if h.cfg.errored != nil {
+					h.cfg.errored(err)
+				}
+				continue
+			}
+			if _, err := w.Write(sseEvent(data)); err != nil {
+				return
+			}
+			ticker.Reset(h.cfg.heartbeat)
+		case <-ticker.C:
+			if _, err := w.Write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// sseEvent encodes data as the data lines of one event
+func sseEvent(data []byte) []byte {
+	var b bytes.Buffer
+	for _, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(bytes.TrimSuffix(line, []byte("\r")))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// WithHeartbeat sets how often NewSSEHandler writes a comment to a client that got no event, 15s by default
+func WithHeartbeat(interval time.Duration) Option {
+	return func(c *config) {
+		c.heartbeat = interval
+	}
+}
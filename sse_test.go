@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSSEHandler(t *testing.T) {
+	t.Run("outputs are sent as events until the pipeline is done", func(t *testing.T) {
+		var errs []error
+		h := NewSSEHandler(func(ctx context.Context) <-chan interface{} {
+			return Emit("a", "multi\nline", func() {})
+		}, func(i interface{}) ([]byte, error) {
+			if s, ok := i.(string); ok {
+				return []byte(s), nil
+			}
+			return json.Marshal(i)
+		}, WithErrors(func(err error) { errs = append(errs, err) }))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+		if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+			t.Errorf("Content-Type = %q, want text/event-stream", got)
+		}
+		if want := "data: a\n\ndata: multi\ndata: line\n\n"; w.Body.String() != want {
+			t.Errorf("body = %q, want %q", w.Body.String(), want)
+		}
+		if len(errs) != 1 {
+			t.Errorf("errs = %v, want the marshal error of the func", errs)
+		}
+	})
+
+	t.Run("a heartbeat is sent while there are no events", func(t *testing.T) {
+		srv := httptest.NewServer(NewSSEHandler(func(ctx context.Context) <-chan interface{} {
+			out := make(chan interface{})
+			go func() {
+				<-ctx.Done()
+				close(out)
+			}()
+			return out
+		}, json.Marshal, WithHeartbeat(10*time.Millisecond)))
+		defer srv.Close()
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		if err != nil || line != ": heartbeat\n" {
+			t.Errorf("line = %q, %v, want a heartbeat comment", line, err)
+		}
+	})
+
+	t.Run("a client disconnect cancels its pipeline", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		canceled := make(chan struct{})
+		h := NewSSEHandler(func(ctx context.Context) <-chan interface{} {
+			out := make(chan interface{})
+			go func() {
+				defer close(out)
+				defer close(canceled)
+				for i := 0; ; i++ {
+					select {
+					case out <- i:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return out
+		}, json.Marshal)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		w := httptest.NewRecorder()
+		go func() {
+			defer close(done)
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx))
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("the pipeline was not canceled after the client disconnected")
+		}
+		<-done
+		if !strings.HasPrefix(w.Body.String(), "data: 0\n\ndata: 1\n\n") {
+			t.Errorf("body = %q, want the events sent before the disconnect", w.Body.String())
+		}
+		for start := time.Now(); runtime.NumGoroutine() > before && time.Since(start) < time.Second; {
+			time.Sleep(time.Millisecond)
+		}
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("goroutines = %d, want <= %d", after, before)
+		}
+	})
+
+	t.Run("a writer that cannot flush is an error", func(t *testing.T) {
+		h := NewSSEHandler(func(ctx context.Context) <-chan interface{} {
+			t.Error("the pipeline was built")
+			return nil
+		}, json.Marshal)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(struct{ http.ResponseWriter }{w}, httptest.NewRequest(http.MethodGet, "/events", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("code = %d, want %d", w.Code, http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"encoding/json"
+
+	"github.com/deliveryhero/pipeline/internal/core"
+)
+
+// Stats counts the inputs of the stages it is attached to with WithMetrics, using atomic adds only.
+// It is an expvar.Var, so it can be published to /debug/vars:
+//
+//	stats := pipeline.NewStats()
+//	expvar.Publish("pipeline", stats)
+//	out := pipeline.Process(ctx, enrich, in, pipeline.WithMetrics("enrich", stats))
+//
+// It also holds how long each stage waited for its inputs and for its out chan, see BlockedMetrics,
+// which tells whether a stage is starved by the stages before it or backpressured by the stages after it.
+// Combine it with WithProfilerLabels to attribute the CPU time of each stage in profiles.
+type Stats struct {
+	MemoryMetrics
+}
+
+// NewStats returns an empty Stats
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+// stageStats are the counts of a stage in the JSON of Stats
+type stageStats struct {
+	InFlight  int64 `json:"in_flight"`
+	Received  int64 `json:"received"`
+	Emitted   int64 `json:"emitted"`
+	Canceled  int64 `json:"canceled"`
+	Skipped   int64 `json:"skipped,omitempty"`
+	Processed int64 `json:"processed"`
+	// ProcessTime is in seconds
+	ProcessTime float64 `json:"process_time"`
+	Workers     int64   `json:"workers,omitempty"`
+	// ReceiveBlocked and SendBlocked are in seconds, Starved and Backpressured are their share of the time of the stage
+	ReceiveBlocked float64 `json:"receive_blocked"`
+	SendBlocked    float64 `json:"send_blocked"`
+	Starved        float64 `json:"starved"`
+	Backpressured  float64 `json:"backpressured"`
+}
+
+// String returns the counts of each stage as JSON, keyed by the name of the stage
+func (s *Stats) String() string {
+	stages := map[string]stageStats{}
+	for name, m := range s.Stages() {
+		stages[name] = stageStats{
+			InFlight:       m.InFlight(),
+			Received:       m.Received,
+			Emitted:        m.Emitted,
+			Canceled:       m.Canceled,
+			Skipped:        m.Skipped,
+			Processed:      m.Processed,
+			ProcessTime:    m.ProcessTime.Seconds(),
+			Workers:        m.Workers,
+			ReceiveBlocked: m.ReceiveBlocked.Seconds(),
+			SendBlocked:    m.SendBlocked.Seconds(),
+			Starved:        m.Starved(),
+			Backpressured:  m.Backpressured(),
+		}
+	}
+	b, err := json.Marshal(stages)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// WithProfilerLabels labels each call to `Processor.Process` with a "pipeline_stage" label that holds the name of the stage,
+// as set by WithName or WithMetrics, so CPU profiles attribute the time spent in Process to the stage.
+func WithProfilerLabels() Option {
+	return func(c *config) {
+		c.ProfilerLabels = true
+	}
+}
+
+// ProfilerLabel is the name of the pprof label set by WithProfilerLabels
+const ProfilerLabel = core.ProfilerLabel
@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	stats := NewStats()
+	// The stats are read as an expvar.Var, without publishing them, since a name can only be published once per process
+	var published expvar.Var = stats
+
+	// read returns the counts of the stage from the var
+	read := func(stage string) stageStats {
+		var stages map[string]stageStats
+		if err := json.Unmarshal([]byte(published.String()), &stages); err != nil {
+			t.Fatalf("the stats are not JSON: %s", err)
+		}
+		return stages[stage]
+	}
+
+	// Block on input 2 until the test has read the stats
+	blocked, release := make(chan struct{}), make(chan struct{})
+	p := NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+		if i == 2 {
+			close(blocked)
+			<-release
+		}
+		if i == 3 {
+			return nil, errProcess
+		}
+		return i, nil
+	}, nil)
+	out := Process(context.Background(), p, Emit(1, 2, 3), WithMetrics("enrich", stats))
+
+	<-out
+	<-blocked
+	if got := read("enrich"); got.InFlight != 1 || got.Received != 2 || got.Emitted != 1 {
+		t.Errorf("enrich = %+v while 2 is processed, want 1 in flight, 2 received and 1 emitted", got)
+	}
+	close(release)
+	for range out {
+	}
+	if got := read("enrich"); got.InFlight != 0 || got.Received != 3 || got.Emitted != 2 || got.Canceled != 1 || got.Processed != 3 {
+		t.Errorf("enrich = %+v, want 3 received and processed, 2 emitted and 1 canceled", got)
+	}
+}
+
+func TestWithProfilerLabels(t *testing.T) {
+	var labels []string
+	p := ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+		label, _ := pprof.Label(ctx, ProfilerLabel)
+		labels = append(labels, label)
+		return i, nil
+	})
+	for range Process(context.Background(), p, Emit(1, 2), WithName("enrich"), WithProfilerLabels()) {
+	}
+	if len(labels) != 2 || labels[0] != "enrich" || labels[1] != "enrich" {
+		t.Errorf("labels = %q, want the enrich label for both inputs", labels)
+	}
+}
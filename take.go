@@ -0,0 +1,76 @@
+package pipeline
+
+import "context"
+
+// Take passes the first `n` inputs from the `in <-chan interface{}` to the out `<-chan interface{}`, then closes the out chan.
+// The inputs after the first `n` are dropped until the `in <-chan interface{}` is closed,
+// so the stages before Take are never blocked. Cancel their context to stop them from producing the inputs that Take drops.
+// After the context is canceled, the out chan is closed and the remaining inputs are dropped as well.
+func Take(ctx context.Context, n int, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		for taken := 0; taken < n; taken++ {
+			i, open := <-in
+			if !open || !send(ctx, i, out) {
+				break
+			}
+		}
+		close(out)
+		// Drop the remaining inputs so that the stages before Take are never blocked
+		for range in {
+		}
+	}()
+	return out
+}
+
+// Skip drops the first `n` inputs from the `in <-chan interface{}` and passes the rest to the out `<-chan interface{}`.
+// After the context is canceled, the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func Skip(ctx context.Context, n int, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var skipped int
+		for i := range in {
+			if skipped < n {
+				skipped++
+				continue
+			}
+			if !send(ctx, i, out) {
+				break
+			}
+		}
+		// Drop the remaining inputs so that the stages before Skip are never blocked
+		for range in {
+		}
+	}()
+	return out
+}
+
+// TakeWhile passes the inputs from the `in <-chan interface{}` to the out `<-chan interface{}` until `pred` returns false,
+// then closes the out chan. The input that `pred` returned false for and every input after it are dropped
+// until the `in <-chan interface{}` is closed, like Take.
+func TakeWhile(ctx context.Context, pred func(i interface{}) bool, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		for i := range in {
+			if !pred(i) || !send(ctx, i, out) {
+				break
+			}
+		}
+		close(out)
+		// Drop the remaining inputs so that the stages before TakeWhile are never blocked
+		for range in {
+		}
+	}()
+	return out
+}
+
+// send sends i to out and returns true, or returns false if the context is canceled first
+func send(ctx context.Context, i interface{}, out chan<- interface{}) bool {
+	select {
+	case out <- i:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
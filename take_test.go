@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		n    int
+		in   []interface{}
+		want []interface{}
+	}{{
+		name: "the first n inputs are passed through",
+		n:    3,
+		in:   []interface{}{1, 2, 3, 4, 5},
+		want: []interface{}{1, 2, 3},
+	}, {
+		name: "every input is passed through when there are fewer than n",
+		n:    10,
+		in:   []interface{}{1, 2, 3},
+		want: []interface{}{1, 2, 3},
+	}, {
+		name: "nothing is passed through when n is 0",
+		n:    0,
+		in:   []interface{}{1, 2, 3},
+		want: nil,
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var outs []interface{}
+			for o := range Take(context.Background(), test.n, Emit(test.in...)) {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(test.want, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want)
+			}
+		})
+	}
+
+	t.Run("out closes after n inputs while in remains open", func(t *testing.T) {
+		in := make(chan interface{})
+		defer close(in)
+		out := Take(context.Background(), 2, in)
+		in <- 1
+		<-out
+		in <- 2
+		<-out
+		select {
+		case <-waitClosed(out):
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed after n inputs")
+		}
+		// The inputs after the first n are dropped
+		in <- 3
+	})
+
+	t.Run("upstream goroutines exit when Take truncates a Process stream", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+
+		var outs []interface{}
+		for o := range Take(context.Background(), 5, ProcessConcurrently(context.Background(), 4, noopProcessor, emitN(1000))) {
+			outs = append(outs, o)
+		}
+		if len(outs) != 5 {
+			t.Errorf("len(out) = %d, want 5", len(outs))
+		}
+
+		// The Process and Emit goroutines should finish once the rest of the inputs are dropped
+		deadline := time.Now().Add(time.Second)
+		after := runtime.NumGoroutine()
+		for after > before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			after = runtime.NumGoroutine()
+		}
+		if after > before {
+			t.Errorf("goroutines = %d, want <= %d", after, before)
+		}
+	})
+
+	t.Run("out closes when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := Take(ctx, 100, emitN(1000))
+		<-out
+		cancel()
+		select {
+		case <-waitClosed(out):
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed after the context was canceled")
+		}
+	})
+}
+
+func TestSkip(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		n    int
+		in   []interface{}
+		want []interface{}
+	}{{
+		name: "the first n inputs are dropped",
+		n:    2,
+		in:   []interface{}{1, 2, 3, 4, 5},
+		want: []interface{}{3, 4, 5},
+	}, {
+		name: "nothing is passed through when there are fewer than n",
+		n:    10,
+		in:   []interface{}{1, 2, 3},
+		want: nil,
+	}, {
+		name: "every input is passed through when n is 0",
+		n:    0,
+		in:   []interface{}{1, 2, 3},
+		want: []interface{}{1, 2, 3},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var outs []interface{}
+			for o := range Skip(context.Background(), test.n, Emit(test.in...)) {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(test.want, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want)
+			}
+		})
+	}
+}
+
+func TestTakeWhile(t *testing.T) {
+	lessThan := func(n int) func(interface{}) bool {
+		return func(i interface{}) bool {
+			return i.(int) < n
+		}
+	}
+
+	t.Run("inputs are passed through until the predicate fails", func(t *testing.T) {
+		var outs []interface{}
+		for o := range TakeWhile(context.Background(), lessThan(3), Emit(1, 2, 3, 1, 2)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 2}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("upstream goroutines exit when the predicate fails", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+
+		for range TakeWhile(context.Background(), lessThan(5), Process(context.Background(), noopProcessor, emitN(1000))) {
+		}
+
+		deadline := time.Now().Add(time.Second)
+		after := runtime.NumGoroutine()
+		for after > before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			after = runtime.NumGoroutine()
+		}
+		if after > before {
+			t.Errorf("goroutines = %d, want <= %d", after, before)
+		}
+	})
+}
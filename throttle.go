@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Throttle passes at most one input per `interval` from the `in <-chan interface{}` to the out `<-chan interface{}`.
+// An input that arrives when no interval is running is sent right away and starts an interval.
+// The inputs that arrive during an interval are dropped, unless WithCoalesce is set,
+// in which case they are merged and the result is sent when the interval ends, which starts the next interval.
+// A merged input that is pending when the `in <-chan interface{}` is closed is sent before the out chan is closed.
+// After the context is canceled, the pending input and the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func Throttle(ctx context.Context, interval time.Duration, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		timer := c.Clock.NewTimer(interval)
+		defer timer.Stop()
+		stopTimer(timer)
+		// tick is only set while an interval is running
+		var tick <-chan time.Time
+		var pending interface{}
+		var hasPending bool
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					if hasPending && ctx.Err() == nil {
+						send(ctx, pending, out)
+					}
+					return
+				}
+				switch {
+				case tick == nil:
+					if !send(ctx, i, out) {
+						dropAll(in)
+						return
+					}
+					timer.Reset(interval)
+					tick = timer.C()
+				case c.coalesce == nil:
+					// Drop the input
+				case hasPending:
+					pending = c.coalesce(pending, i)
+				default:
+					pending, hasPending = i, true
+				}
+			case <-tick:
+				if !hasPending {
+					tick = nil
+					continue
+				}
+				if !send(ctx, pending, out) {
+					dropAll(in)
+					return
+				}
+				pending, hasPending = nil, false
+				timer.Reset(interval)
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before Throttle are never blocked
+				dropAll(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Debounce waits for `quiet` without inputs from the `in <-chan interface{}` before it sends the last input to the out `<-chan interface{}`.
+// Each input restarts the wait and replaces the pending input, unless WithCoalesce is set,
+// in which case it is merged into the pending input instead.
+// The pending input is sent when the `in <-chan interface{}` is closed, before the out chan is closed.
+// After the context is canceled, the pending input and the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+func Debounce(ctx context.Context, quiet time.Duration, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	c := newConfig(opts)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		timer := c.Clock.NewTimer(quiet)
+		defer timer.Stop()
+		stopTimer(timer)
+		// tick is only set while an input is pending
+		var tick <-chan time.Time
+		var pending interface{}
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					if tick != nil && ctx.Err() == nil {
+						send(ctx, pending, out)
+					}
+					return
+				}
+				if tick != nil && c.coalesce != nil {
+					pending = c.coalesce(pending, i)
+				} else {
+					pending = i
+				}
+				stopTimer(timer)
+				timer.Reset(quiet)
+				tick = timer.C()
+			case <-tick:
+				tick = nil
+				if !send(ctx, pending, out) {
+					dropAll(in)
+					return
+				}
+				pending = nil
+			case <-ctx.Done():
+				// Drop the remaining inputs so that the stages before Debounce are never blocked
+				dropAll(in)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithCoalesce makes Throttle and Debounce merge the inputs they would otherwise drop.
+// `merge` is called with the pending input and the next one, and returns the new pending input.
+func WithCoalesce(merge func(pending, i interface{}) interface{}) Option {
+	return func(c *config) {
+		c.coalesce = merge
+	}
+}
+
+// stopTimer stops timer and drains its chan, so that it can be reset
+func stopTimer(timer Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C():
+		default:
+		}
+	}
+}
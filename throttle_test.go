@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// timedEmit sends each input after the delay before it, then closes the returned chan
+func timedEmit(delays []time.Duration, is ...interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for n, i := range is {
+			time.Sleep(delays[n])
+			out <- i
+		}
+	}()
+	return out
+}
+
+func TestThrottle(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	sum := func(pending, i interface{}) interface{} {
+		return pending.(int) + i.(int)
+	}
+	// 1 starts an interval, 2 and 3 arrive during it, 4 arrives after it ended
+	delays := []time.Duration{0, 10 * time.Millisecond, 10 * time.Millisecond, 200 * time.Millisecond}
+
+	for _, test := range []struct {
+		name string
+		opts []Option
+		want []interface{}
+	}{{
+		name: "inputs during an interval are dropped",
+		want: []interface{}{1, 4},
+	}, {
+		name: "inputs during an interval are coalesced and sent when it ends",
+		opts: []Option{WithCoalesce(sum)},
+		want: []interface{}{1, 5, 4},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var outs []interface{}
+			for o := range Throttle(context.Background(), interval, timedEmit(delays, 1, 2, 3, 4), test.opts...) {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(test.want, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want)
+			}
+		})
+	}
+
+	t.Run("the coalesced input is flushed when in closes", func(t *testing.T) {
+		start := time.Now()
+		var outs []interface{}
+		for o := range Throttle(context.Background(), time.Hour, Emit(1, 2, 3), WithCoalesce(sum)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{1, 5}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		if elapsed := time.Since(start); elapsed > interval {
+			t.Errorf("elapsed = %s, want < %s", elapsed, interval)
+		}
+	})
+
+	t.Run("out closes when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := Throttle(ctx, time.Hour, in, WithCoalesce(sum))
+		in <- 1
+		<-out
+		in <- 2
+		cancel()
+		close(in)
+		select {
+		case <-waitClosed(out):
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed after the context was canceled and in was closed")
+		}
+	})
+}
+
+func TestDebounce(t *testing.T) {
+	const quiet = 50 * time.Millisecond
+	collect := func(pending, i interface{}) interface{} {
+		if is, ok := pending.([]interface{}); ok {
+			return append(is, i)
+		}
+		return []interface{}{pending, i}
+	}
+	// 1, 2 and 3 arrive in a burst, then 4 arrives after a quiet period
+	delays := []time.Duration{0, 10 * time.Millisecond, 10 * time.Millisecond, 150 * time.Millisecond}
+
+	for _, test := range []struct {
+		name string
+		opts []Option
+		want []interface{}
+	}{{
+		name: "the last input of a burst is sent after the quiet period",
+		want: []interface{}{3, 4},
+	}, {
+		name: "the inputs of a burst are coalesced",
+		opts: []Option{WithCoalesce(collect)},
+		want: []interface{}{[]interface{}{1, 2, 3}, 4},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var outs []interface{}
+			for o := range Debounce(context.Background(), quiet, timedEmit(delays, 1, 2, 3, 4), test.opts...) {
+				outs = append(outs, o)
+			}
+			if !reflect.DeepEqual(test.want, outs) {
+				t.Errorf("out = %+v, want %+v", outs, test.want)
+			}
+		})
+	}
+
+	t.Run("the pending input is flushed when in closes", func(t *testing.T) {
+		var outs []interface{}
+		for o := range Debounce(context.Background(), time.Hour, Emit(1, 2, 3)) {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{3}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+	})
+
+	t.Run("out closes when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := Debounce(ctx, time.Hour, in)
+		in <- 1
+		cancel()
+		close(in)
+		select {
+		case <-waitClosed(out):
+		case <-time.After(time.Second):
+			t.Fatal("out was not closed after the context was canceled and in was closed")
+		}
+	})
+}
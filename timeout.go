@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeout creates a Processor that gives each call to `Processor.Process` at most `timeout` to finish.
+// Each call runs with a context derived from the stage context with `context.WithTimeout`.
+// If the call does not return in time, `context.DeadlineExceeded` is returned right away, which is passed to `Processor.Cancel`,
+// and the stage moves on to the next input. The result of the abandoned call is discarded whenever it returns.
+func WithTimeout(timeout time.Duration, p Processor) Processor {
+	return forwardBatches(&timeoutProcessor{
+		timeout:   timeout,
+		processor: p,
+	}, p, passBatch)
+}
+
+// timeoutProcessor implements Processor
+type timeoutProcessor struct {
+	timeout   time.Duration
+	processor Processor
+}
+
+// timeoutResult is the outcome of a call to `Processor.Process`
+type timeoutResult struct {
+	out      interface{}
+	err      error
+	panicked bool
+	panic    interface{}
+}
+
+func (t *timeoutProcessor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	// The result chan is buffered so an abandoned call never blocks
+	done := make(chan timeoutResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- timeoutResult{panicked: true, panic: r}
+			}
+		}()
+		out, err := t.processor.Process(ctx, i)
+		done <- timeoutResult{out: out, err: err}
+	}()
+	select {
+	case r := <-done:
+		if r.panicked {
+			// Panic in the stage's goroutine so that it is handled like any other panic
+			panic(r.panic)
+		}
+		return r.out, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *timeoutProcessor) Cancel(i interface{}, err error) {
+	t.processor.Cancel(i, err)
+}
+
+func (t *timeoutProcessor) CancelContext(ctx context.Context, i interface{}, err error) {
+	cancelContext(ctx, t.processor, i, err)
+}
+
+func (t *timeoutProcessor) Setup(ctx context.Context) error {
+	return setupWrapped(ctx, t.processor)
+}
+
+func (t *timeoutProcessor) Teardown() error {
+	return teardownWrapped(t.processor)
+}
+
+func (t *timeoutProcessor) CloneForWorker() Processor {
+	c, ok := cloneWrapped(t.processor)
+	if !ok {
+		return nil
+	}
+	return WithTimeout(t.timeout, c)
+}
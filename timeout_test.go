@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	// Input 3 ignores its context and hangs for a long time
+	var canceled []interface{}
+	var errs []error
+	p := WithTimeout(50*time.Millisecond, NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+		if i == 3 {
+			time.Sleep(time.Second)
+		}
+		return i, nil
+	}, func(i interface{}, err error) {
+		canceled = append(canceled, i)
+		errs = append(errs, err)
+	}))
+
+	start := time.Now()
+	var outs []interface{}
+	for o := range Process(context.Background(), p, Emit(1, 2, 3, 4, 5)) {
+		outs = append(outs, o)
+	}
+
+	// The slow input does not stall the stage
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("elapsed = %s, want about 50ms", elapsed)
+	}
+	if want := []interface{}{1, 2, 4, 5}; !reflect.DeepEqual(want, outs) {
+		t.Errorf("out = %+v, want %+v", outs, want)
+	}
+	if want := []interface{}{3}; !reflect.DeepEqual(want, canceled) {
+		t.Errorf("canceled = %+v, want %+v", canceled, want)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("errs = %+v, want [%s]", errs, context.DeadlineExceeded)
+	}
+}
+
+func TestWithTimeoutPanic(t *testing.T) {
+	var errs []error
+	p := WithTimeout(time.Second, NewProcessor(func(ctx context.Context, i interface{}) (interface{}, error) {
+		panic("oops")
+	}, func(i interface{}, err error) {
+		errs = append(errs, err)
+	}))
+	for range Process(context.Background(), p, Emit(1)) {
+		t.Error("nothing should be processed")
+	}
+	var pErr *PanicError
+	if len(errs) != 1 || !errors.As(errs[0], &pErr) {
+		t.Errorf("errs = %+v, want a *PanicError", errs)
+	}
+}
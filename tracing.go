@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+)
+
+// Item is an input that carries its own context, such as the trace context of the request it came from.
+// WithTracing starts the span of an Item as a child of the span in its context,
+// and sends the result on as an Item whose context holds the new span, so the next traced stage continues the trace.
+// Only the values of the context are used, the stage context still decides when processing is canceled.
+type Item struct {
+	Ctx   context.Context
+	Value interface{}
+}
+
+// Tracer starts the spans of WithTracing.
+// It is small enough to be implemented on top of any tracing library, such as OpenTelemetry.
+type Tracer interface {
+	// Start starts a span named `name` as a child of the span in ctx, if there is one,
+	// and returns a context that holds the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// End ends the span. err is nil if the input was processed, or the reason it was not, which should mark the span as failed.
+	End(err error)
+}
+
+// WithTracing creates a Processor that starts a span named `stage` for each input.
+// If the input is an Item, the span is a child of the span in the context of the Item,
+// `Processor.Process` and `Processor.Cancel` receive the value of the Item, and the result is sent on as an Item.
+// Otherwise, the span is a child of the span in the stage context.
+// The span ends with the error of `Processor.Process` when it returns.
+// An input that is canceled before it is processed gets a span of its own that ends with the cancel error,
+// so the trace of every input ends whichever way it leaves the stage.
+func WithTracing(tracer Tracer, stage string, p Processor) Processor {
+	t := &tracingProcessor{
+		tracer:    tracer,
+		stage:     stage,
+		processor: p,
+	}
+	return forwardBatches(t, p, t.cancelBatch)
+}
+
+// errPanicked ends the span of a call to `Processor.Process` that panicked
+var errPanicked = errors.New("pipeline: Process panicked")
+
+// tracingProcessor implements Processor
+type tracingProcessor struct {
+	tracer    Tracer
+	stage     string
+	processor Processor
+}
+
+func (t *tracingProcessor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	item, isItem := i.(Item)
+	if isItem {
+		ctx = withValues(ctx, item.Ctx)
+		i = item.Value
+	}
+	ctx, span := t.tracer.Start(ctx, t.stage)
+	// The span also ends if Process panics
+	err := errPanicked
+	defer func() {
+		span.End(err)
+	}()
+	out, err := t.processor.Process(ctx, i)
+	if err != nil || !isItem {
+		return out, err
+	}
+	return Item{Ctx: ctx, Value: out}, nil
+}
+
+func (t *tracingProcessor) Cancel(i interface{}, err error) {
+	t.CancelContext(context.Background(), i, err)
+}
+
+func (t *tracingProcessor) CancelContext(ctx context.Context, i interface{}, err error) {
+	item, isItem := i.(Item)
+	if isItem {
+		ctx = withValues(ctx, item.Ctx)
+		i = item.Value
+		// Pass the value of the Item to the Processor, like Process does
+		if pErr, ok := err.(*ProcessError); ok {
+			err = &ProcessError{Input: i, Err: pErr.Err}
+		}
+	}
+	// The span of an input that failed to process already ended with its error
+	var pErr *ProcessError
+	if !errors.As(err, &pErr) {
+		var span Span
+		ctx, span = t.tracer.Start(ctx, t.stage)
+		span.End(err)
+	}
+	cancelContext(ctx, t.processor, i, err)
+}
+
+func (t *tracingProcessor) Setup(ctx context.Context) error {
+	return setupWrapped(ctx, t.processor)
+}
+
+func (t *tracingProcessor) Teardown() error {
+	return teardownWrapped(t.processor)
+}
+
+func (t *tracingProcessor) CloneForWorker() Processor {
+	c, ok := cloneWrapped(t.processor)
+	if !ok {
+		return nil
+	}
+	return WithTracing(t.tracer, t.stage, c)
+}
+
+// cancelBatch ends a span for each input of the batch, like CancelContext, and passes their values to bc
+func (t *tracingProcessor) cancelBatch(bc BatchCanceler, is []interface{}, err error) {
+	values := make([]interface{}, len(is))
+	for k, i := range is {
+		ctx := context.Background()
+		if item, isItem := i.(Item); isItem {
+			ctx, i = item.Ctx, item.Value
+		}
+		_, span := t.tracer.Start(ctx, t.stage)
+		span.End(err)
+		values[k] = i
+	}
+	bc.CancelBatch(values, err)
+}
+
+// withValues returns a context that is canceled with ctx, but looks values up in `values` before ctx
+func withValues(ctx, values context.Context) context.Context {
+	if values == nil {
+		return ctx
+	}
+	return &valuesContext{Context: ctx, values: values}
+}
+
+// valuesContext is a context that looks values up in another context first
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *valuesContext) Value(key interface{}) interface{} {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
@@ -0,0 +1,42 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+func ExampleWithTracing() {
+	tracer := &recordingTracer{}
+	ctx := context.Background()
+
+	// Each request starts a trace, which is carried into the pipeline by an Item
+	var items []interface{}
+	for _, req := range []string{"a", "b"} {
+		reqCtx, span := tracer.Start(ctx, "request "+req)
+		span.End(nil)
+		items = append(items, pipeline.Item{Ctx: reqCtx, Value: req})
+	}
+
+	upper := pipeline.ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		return strings.ToUpper(i.(string)), nil
+	})
+	exclaim := pipeline.ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		return i.(string) + "!", nil
+	})
+
+	// Each stage starts a span that is a child of the span of the stage before it
+	p := pipeline.Process(ctx, pipeline.WithTracing(tracer, "upper", upper), pipeline.Emit(items...))
+	p = pipeline.Process(ctx, pipeline.WithTracing(tracer, "exclaim", exclaim), p)
+
+	for out := range p {
+		item := out.(pipeline.Item)
+		fmt.Printf("%s: %s\n", item.Value, spanOf(item.Ctx).path())
+	}
+
+	// Output:
+	// A!: exclaim <- upper <- request a
+	// B!: exclaim <- upper <- request b
+}
@@ -0,0 +1,141 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deliveryhero/pipeline"
+)
+
+// recordingTracer records the spans that have ended
+type recordingTracer struct {
+	mu    sync.Mutex
+	ended []*recordedSpan
+}
+
+// recordedSpan is a span of the recordingTracer
+type recordedSpan struct {
+	tracer *recordingTracer
+	name   string
+	parent *recordedSpan
+	err    error
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, pipeline.Span) {
+	s := &recordedSpan{tracer: t, name: name, parent: spanOf(ctx)}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *recordedSpan) End(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.err = err
+	s.tracer.ended = append(s.tracer.ended, s)
+}
+
+// path returns the names of the span and its ancestors
+func (s *recordedSpan) path() string {
+	var names []string
+	for ; s != nil; s = s.parent {
+		names = append(names, s.name)
+	}
+	return strings.Join(names, " <- ")
+}
+
+// spanOf returns the span in ctx, or nil if there is none
+func spanOf(ctx context.Context) *recordedSpan {
+	s, _ := ctx.Value(spanKey{}).(*recordedSpan)
+	return s
+}
+
+func TestWithTracing(t *testing.T) {
+	errFail := errors.New("fail")
+	p := pipeline.NewProcessor(func(_ context.Context, i interface{}) (interface{}, error) {
+		if i == 2 {
+			return nil, errFail
+		}
+		return i, nil
+	}, func(i interface{}, err error) {
+		if _, ok := i.(pipeline.Item); ok {
+			t.Errorf("Cancel received the Item %+v instead of its value", i)
+		}
+		var pErr *pipeline.ProcessError
+		if errors.As(err, &pErr) {
+			if _, ok := pErr.Input.(pipeline.Item); ok {
+				t.Errorf("the *ProcessError holds the Item %+v instead of its value", pErr.Input)
+			}
+		}
+	})
+
+	t.Run("the span of a failed input ends with its error", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		reqCtx, _ := tracer.Start(context.Background(), "request")
+		for range pipeline.Process(context.Background(), pipeline.WithTracing(tracer, "stage", p), pipeline.Emit(
+			pipeline.Item{Ctx: reqCtx, Value: 1},
+			pipeline.Item{Ctx: reqCtx, Value: 2},
+		)) {
+		}
+		if len(tracer.ended) != 2 {
+			t.Fatalf("ended %d spans, want 2", len(tracer.ended))
+		}
+		for n, want := range []error{nil, errFail} {
+			s := tracer.ended[n]
+			if s.path() != "stage <- request" || !errors.Is(s.err, want) || (want == nil) != (s.err == nil) {
+				t.Errorf("span %d = %s with %v, want stage <- request with %v", n, s.path(), s.err, want)
+			}
+		}
+	})
+
+	t.Run("the inputs canceled by the context get a span that ends with the cancel error", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		reqCtx, _ := tracer.Start(context.Background(), "request")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for range pipeline.Process(ctx, pipeline.WithTracing(tracer, "stage", p), pipeline.Emit(
+			pipeline.Item{Ctx: reqCtx, Value: 1},
+			pipeline.Item{Ctx: reqCtx, Value: 3},
+		)) {
+		}
+		if len(tracer.ended) != 2 {
+			t.Fatalf("ended %d spans, want 2", len(tracer.ended))
+		}
+		for n, s := range tracer.ended {
+			if s.path() != "stage <- request" || !errors.Is(s.err, pipeline.ErrCanceled) {
+				t.Errorf("span %d = %s with %v, want stage <- request with %s", n, s.path(), s.err, pipeline.ErrCanceled)
+			}
+		}
+	})
+
+	t.Run("the span ends when Process panics", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		panics := pipeline.ProcessorFunc(func(context.Context, interface{}) (interface{}, error) {
+			panic("boom")
+		})
+		for range pipeline.Process(context.Background(), pipeline.WithTracing(tracer, "stage", panics), pipeline.Emit(1)) {
+		}
+		if len(tracer.ended) != 1 || tracer.ended[0].err == nil {
+			t.Errorf("ended = %+v, want 1 span with an error", tracer.ended)
+		}
+	})
+
+	t.Run("inputs that are not Items use the span of the stage context", func(t *testing.T) {
+		tracer := &recordingTracer{}
+		ctx, _ := tracer.Start(context.Background(), "job")
+		var outs []interface{}
+		for out := range pipeline.Process(ctx, pipeline.WithTracing(tracer, "stage", p), pipeline.Emit(1)) {
+			outs = append(outs, out)
+		}
+		if len(outs) != 1 || outs[0] != 1 {
+			t.Errorf("out = %+v, want [1]", outs)
+		}
+		if len(tracer.ended) != 1 || tracer.ended[0].path() != "stage <- job" {
+			t.Errorf("ended = %+v, want stage <- job", tracer.ended)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracked is an input that must be acknowledged once, and only once, when it has left the pipeline,
+// such as a message from a queue that may only be acknowledged after its last stage for at-least-once processing.
+// `Ack` is called with nil once the output of the input is emitted by Untrack at the end of the pipeline,
+// or with the error of the stage that failed or canceled it.
+//
+// A Tracked rides along through TrackedProcess, through Collect, whose batches TrackedProcess acknowledges member by member,
+// and through Split, whose elements are acknowledged together.
+// Other stages that drop their inputs, like Filter or Take, do not acknowledge them.
+type Tracked struct {
+	Value interface{}
+	Ack   func(err error)
+}
+
+// TrackedProcess is like Process, except that it processes the `Value` of each Tracked input and sends the result on
+// as a Tracked with the same `Ack`. The inputs that are passed to `Processor.Cancel` are acknowledged with their error.
+// A `[]interface{}` batch of Tracked inputs from Collect is processed as the `[]interface{}` of their values,
+// and acknowledging its result acknowledges each of them.
+// Inputs that are not Tracked are processed as they are.
+func TrackedProcess(ctx context.Context, p Processor, in <-chan interface{}, opts ...Option) <-chan interface{} {
+	return Process(ctx, &trackedProcessor{processor: p}, in, opts...)
+}
+
+// trackedProcessor implements Processor
+type trackedProcessor struct {
+	processor Processor
+}
+
+func (t *trackedProcessor) Process(ctx context.Context, i interface{}) (interface{}, error) {
+	v, ack, ok := untrack(i)
+	if !ok {
+		return t.processor.Process(ctx, i)
+	}
+	out, err := t.processor.Process(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	return Tracked{Value: out, Ack: ack}, nil
+}
+
+func (t *trackedProcessor) Cancel(i interface{}, err error) {
+	t.CancelContext(context.Background(), i, err)
+}
+
+func (t *trackedProcessor) CancelContext(ctx context.Context, i interface{}, err error) {
+	v, ack, ok := untrack(i)
+	if !ok {
+		cancelContext(ctx, t.processor, i, err)
+		return
+	}
+	// The cancel error refers to the value that the processor knows about
+	if pErr, isProcessError := err.(*ProcessError); isProcessError {
+		err = &ProcessError{Input: v, Err: pErr.Err}
+	}
+	cancelContext(ctx, t.processor, v, err)
+	ack(err)
+}
+
+// untrack returns the value and the ack of a Tracked, or of a batch of them, and false if i is neither
+func untrack(i interface{}) (interface{}, func(err error), bool) {
+	switch i := i.(type) {
+	case Tracked:
+		return i.Value, i.Ack, true
+	case []interface{}:
+		if len(i) == 0 {
+			return nil, nil, false
+		}
+		vs := make([]interface{}, len(i))
+		acks := make([]func(err error), len(i))
+		for n, t := range i {
+			tracked, ok := t.(Tracked)
+			if !ok {
+				return nil, nil, false
+			}
+			vs[n], acks[n] = tracked.Value, tracked.Ack
+		}
+		return vs, func(err error) {
+			for _, ack := range acks {
+				ack(err)
+			}
+		}, true
+	}
+	return nil, nil, false
+}
+
+// splitTracked splits a Tracked `[]interface{}` into a Tracked per element,
+// which acknowledge t with the first error among them once all of them are acknowledged
+func splitTracked(t Tracked) []interface{} {
+	is := t.Value.([]interface{})
+	if len(is) == 0 {
+		t.Ack(nil)
+		return nil
+	}
+	var mu sync.Mutex
+	pending := len(is)
+	var first error
+	ack := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if first == nil {
+			first = err
+		}
+		if pending--; pending == 0 {
+			t.Ack(first)
+		}
+	}
+	split := make([]interface{}, len(is))
+	for n, i := range is {
+		split[n] = Tracked{Value: i, Ack: ack}
+	}
+	return split
+}
+
+// Untrack ends a pipeline of Tracked inputs: it sends the `Value` of each of them to the out `<-chan interface{}`
+// and acknowledges it with nil once it has been received. Inputs that are not Tracked are sent as they are.
+// After the context is canceled, the remaining inputs are acknowledged with a *CanceledError until the `in <-chan interface{}` is closed.
+func Untrack(ctx context.Context, in <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for i := range in {
+			t, tracked := i.(Tracked)
+			if tracked {
+				i = t.Value
+			}
+			if ctx.Err() != nil || !send(ctx, i, out) {
+				if tracked {
+					t.Ack(&CanceledError{Err: ctx.Err()})
+				}
+				break
+			}
+			if tracked {
+				t.Ack(nil)
+			}
+		}
+		// Drop the remaining inputs so that the stages before Untrack are never blocked
+		for i := range in {
+			if t, ok := i.(Tracked); ok {
+				t.Ack(&CanceledError{Err: ctx.Err()})
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// acks records the acknowledgements of Tracked ints
+type acks struct {
+	mu   sync.Mutex
+	errs map[int][]error
+}
+
+func (a *acks) track(n int) Tracked {
+	return Tracked{Value: n, Ack: func(err error) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.errs == nil {
+			a.errs = make(map[int][]error)
+		}
+		a.errs[n] = append(a.errs[n], err)
+	}}
+}
+
+// emitTracked emits n Tracked ints without watching a context, so that every input enters the pipeline
+func (a *acks) emitTracked(n int) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			out <- a.track(i)
+		}
+	}()
+	return out
+}
+
+// check fails the test unless each of the n inputs was acknowledged exactly once and returns their errors
+func (a *acks) check(t *testing.T, n int) map[int]error {
+	t.Helper()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	errs := make(map[int]error)
+	for i := 0; i < n; i++ {
+		if len(a.errs[i]) != 1 {
+			t.Errorf("%d was acknowledged %d times, want 1", i, len(a.errs[i]))
+			continue
+		}
+		errs[i] = a.errs[i][0]
+	}
+	return errs
+}
+
+func TestTracked(t *testing.T) {
+	failed := errors.New("failed")
+	failOn3 := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		if i == 3 {
+			return nil, failed
+		}
+		return i, nil
+	})
+	sum := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+		total := 0
+		for _, n := range i.([]interface{}) {
+			total += n.(int)
+		}
+		return []interface{}{total}, nil
+	})
+
+	t.Run("every input is acknowledged once after the last stage", func(t *testing.T) {
+		var a acks
+		ctx := context.Background()
+		in := a.emitTracked(6)
+		out := Untrack(ctx, TrackedProcess(ctx, failOn3, in))
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		if want := []interface{}{0, 1, 2, 4, 5}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		for i, err := range a.check(t, 6) {
+			var pErr *ProcessError
+			if i == 3 && (!errors.As(err, &pErr) || pErr.Input != 3 || !errors.Is(err, failed)) {
+				t.Errorf("3 was acknowledged with %v, want the *ProcessError of 3", err)
+			} else if i != 3 && err != nil {
+				t.Errorf("%d was acknowledged with %v, want nil", i, err)
+			}
+		}
+	})
+
+	t.Run("a batch is acknowledged member by member, and a split with its first error", func(t *testing.T) {
+		var a acks
+		ctx := context.Background()
+		in := a.emitTracked(6)
+		batches := TrackedProcess(ctx, sum, Collect(ctx, 3, time.Minute, in))
+		out := Untrack(ctx, TrackedProcess(ctx, failOn3, Split(batches)))
+		var outs []interface{}
+		for o := range out {
+			outs = append(outs, o)
+		}
+		// The batches are {0, 1, 2} and {3, 4, 5}, the sum 3 of the first one fails
+		if want := []interface{}{12}; !reflect.DeepEqual(want, outs) {
+			t.Errorf("out = %+v, want %+v", outs, want)
+		}
+		for i, err := range a.check(t, 6) {
+			if i < 3 && !errors.Is(err, failed) {
+				t.Errorf("%d was acknowledged with %v, want %v", i, err, failed)
+			} else if i >= 3 && err != nil {
+				t.Errorf("%d was acknowledged with %v, want nil", i, err)
+			}
+		}
+	})
+
+	t.Run("every input is acknowledged once when the context is canceled", func(t *testing.T) {
+		const n = 200
+		var a acks
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sleep := ProcessorFunc(func(ctx context.Context, i interface{}) (interface{}, error) {
+			select {
+			case <-time.After(time.Duration(rand.Intn(100)) * time.Microsecond):
+				return i, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+		batches := TrackedProcess(ctx, sleep, Collect(ctx, 10, time.Millisecond, TrackedProcess(ctx, failOn3, a.emitTracked(n), WithConcurrency(4))))
+		out := Untrack(ctx, TrackedProcess(ctx, sleep, Split(batches), WithConcurrency(4)))
+		var outs []int
+		for o := range out {
+			if outs = append(outs, o.(int)); len(outs) == n/4 {
+				cancel()
+			}
+		}
+		emitted := make(map[int]bool)
+		for _, o := range outs {
+			emitted[o] = true
+		}
+		// The inputs of a batch are acknowledged with nil only if every element of its split is emitted
+		for i, err := range a.check(t, n) {
+			if err == nil && !emitted[i] {
+				t.Errorf("%d was acknowledged with nil but not emitted", i)
+			}
+		}
+		if len(outs) == n-1 {
+			t.Error("every input was emitted, want the context to cancel some")
+		}
+	})
+
+	t.Run("Untrack acknowledges the remaining inputs after the context is canceled", func(t *testing.T) {
+		var a acks
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for range Untrack(ctx, a.emitTracked(3)) {
+		}
+		for i, err := range a.check(t, 3) {
+			var cErr *CanceledError
+			if !errors.As(err, &cErr) {
+				t.Errorf("%d was acknowledged with %v, want a *CanceledError", i, err)
+			}
+		}
+	})
+}
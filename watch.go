@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Stall describes a stage watched by Watch that has stopped making progress
+type Stall struct {
+	// Stage is the name of the stage, as set by WithName
+	Stage string
+	// Pending is the number of inputs the stage has read but not sent to its out chan yet.
+	// Inputs that the stage drops or cancels stay pending.
+	Pending int
+	// Waiting is true if an input is waiting for the stage to read it
+	Waiting bool
+	// For is how long the stage has not read an input or sent an output
+	For time.Duration
+}
+
+// Watch runs `stage` on the `in <-chan interface{}` and reports to `onStall` when the stage stops making progress,
+// which is useful to find the stage that a hanging pipeline is stuck on.
+// The stage is stalled when it has neither read an input nor sent an output for `stall`
+// while an input is waiting for it or it has read more inputs than it has sent outputs.
+// `onStall` is called again every `stall` for as long as the stage is stalled. It must not block.
+// Name the stage with WithName. Since Watch only wraps the chans of the stage, it works with any stage:
+//
+//	out := pipeline.Watch(time.Minute, logStall, func(in <-chan interface{}) <-chan interface{} {
+//		return pipeline.Process(ctx, enrich, in)
+//	}, in, pipeline.WithName("enrich"))
+//
+// The out chan is closed when the out chan of the stage is closed.
+// Watch keeps watching after the context of the stage is canceled, since a stage that hangs while it shuts down is stalled too.
+func Watch(
+	stall time.Duration,
+	onStall func(s Stall),
+	stage func(in <-chan interface{}) <-chan interface{},
+	in <-chan interface{},
+	opts ...Option,
+) <-chan interface{} {
+	cfg := newConfig(opts)
+	w := &watcher{
+		stall:  Stall{Stage: cfg.Stage},
+		last:   cfg.Clock.Now(),
+		logger: cfg.Logger,
+		clock:  cfg.Clock,
+	}
+	stageIn := make(chan interface{})
+	go func() {
+		defer close(stageIn)
+		for i := range in {
+			w.update(func(s *Stall) { s.Waiting = true })
+			stageIn <- i
+			w.update(func(s *Stall) { s.Waiting, s.Pending = false, s.Pending+1 })
+		}
+	}()
+	stageOut := stage(stageIn)
+	out := make(chan interface{})
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		defer close(done)
+		for o := range stageOut {
+			w.update(func(s *Stall) { s.Pending-- })
+			out <- o
+		}
+	}()
+	go w.watch(stall, onStall, done)
+	return out
+}
+
+// watcher tracks the progress of a stage
+type watcher struct {
+	mu    sync.Mutex
+	stall Stall
+	// last is when the stage last read an input or sent an output
+	last   time.Time
+	logger *slog.Logger
+	clock  Clock
+}
+
+// update applies f to the state of the stage and records that it made progress
+func (w *watcher) update(f func(s *Stall)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f(&w.stall)
+	w.last = w.clock.Now()
+}
+
+// watch calls onStall every `stall` that the stage has not made progress, until done is closed
+func (w *watcher) watch(stall time.Duration, onStall func(s Stall), done <-chan struct{}) {
+	// Check often enough to report a stall soon after it reaches each multiple of `stall`
+	period := stall / 4
+	if period <= 0 {
+		period = time.Millisecond
+	}
+	ticker := w.clock.NewTicker(period)
+	defer ticker.Stop()
+	var last time.Time
+	var reported int
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C():
+			w.mu.Lock()
+			s := w.stall
+			if !w.last.Equal(last) {
+				last, reported = w.last, 0
+			}
+			w.mu.Unlock()
+			s.For = now.Sub(last)
+			if (s.Waiting || s.Pending > 0) && s.For >= time.Duration(reported+1)*stall {
+				reported++
+				if w.logger != nil {
+					w.logger.LogAttrs(context.Background(), slog.LevelWarn, "pipeline: stage stalled", slog.String("stage", s.Stage),
+						slog.Int("pending", s.Pending), slog.Bool("waiting", s.Waiting), slog.Duration("for", s.For))
+				}
+				onStall(s)
+			}
+		}
+	}
+}
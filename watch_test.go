@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	t.Run("a stage that blocks is reported with its name", func(t *testing.T) {
+		// Block forever on input 3, until the test is over
+		release := make(chan struct{})
+		p := ProcessorFunc(func(_ context.Context, i interface{}) (interface{}, error) {
+			if i == 3 {
+				<-release
+			}
+			return i, nil
+		})
+
+		var mu sync.Mutex
+		var stalls []Stall
+		out := Watch(50*time.Millisecond, func(s Stall) {
+			mu.Lock()
+			defer mu.Unlock()
+			stalls = append(stalls, s)
+		}, func(in <-chan interface{}) <-chan interface{} {
+			return Process(context.Background(), p, in)
+		}, Emit(1, 2, 3, 4, 5), WithName("enrich"))
+
+		// 1 and 2 are processed, then the stage stalls on 3
+		<-out
+		<-out
+		time.Sleep(130 * time.Millisecond)
+		close(release)
+		for range out {
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(stalls) != 2 {
+			t.Fatalf("stalls = %+v, want 2 stalls", stalls)
+		}
+		for n, s := range stalls {
+			if s.Stage != "enrich" || s.Pending != 1 || !s.Waiting {
+				t.Errorf("stall %d = %+v, want enrich with 1 pending and 1 waiting input", n, s)
+			}
+			if min := time.Duration(n+1) * 50 * time.Millisecond; s.For < min {
+				t.Errorf("stall %d lasted %s, want >= %s", n, s.For, min)
+			}
+		}
+	})
+
+	t.Run("a stage that makes progress is not reported", func(t *testing.T) {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			for i := 0; i < 10; i++ {
+				time.Sleep(10 * time.Millisecond)
+				in <- i
+			}
+			// A quiet in chan is not a stall either
+			time.Sleep(100 * time.Millisecond)
+		}()
+		out := Watch(50*time.Millisecond, func(s Stall) {
+			t.Errorf("stalled: %+v", s)
+		}, func(in <-chan interface{}) <-chan interface{} {
+			return Process(context.Background(), noopProcessor, in)
+		}, in)
+		for range out {
+		}
+	})
+}
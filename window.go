@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Window groups the inputs from the `in <-chan interface{}` into windows of `size` that start every `slide`,
+// and sends the inputs of each window to the out chan once the window ends.
+// The windows are tumbling when `slide` equals `size`, and sliding when it is shorter, in which case an input can be in several windows.
+// By default an input's time is when it arrives and the windows start when Window is called.
+// With WithEventTime, the time is taken from the input instead, the windows start at the time of the first input,
+// and a window ends once an input at or after its end arrives. Inputs that arrive after their windows ended are dropped.
+// Empty windows are skipped unless WithEmptyWindows is set.
+// The windows that have not ended are flushed when the `in <-chan interface{}` is closed or the context is canceled,
+// after which the remaining inputs are dropped until the `in <-chan interface{}` is closed.
+// Window panics unless `size` is positive and `slide` is positive and at most `size`.
+func Window(ctx context.Context, size, slide time.Duration, in <-chan interface{}, opts ...Option) <-chan []interface{} {
+	if size <= 0 || slide <= 0 || slide > size {
+		panic(fmt.Sprintf("pipeline: window needs 0 < slide <= size, got size %s and slide %s", size, slide))
+	}
+	c := newConfig(opts)
+	out := make(chan []interface{})
+	w := &windows{
+		size:    size,
+		slide:   slide,
+		empty:   c.emptyWindows,
+		out:     out,
+		last:    -1,
+		windows: map[int64][]interface{}{},
+	}
+	// Arrival time windows start now and end on a timer
+	var timer Timer
+	var tick <-chan time.Time
+	if c.eventTime == nil {
+		w.start(c.Clock.Now())
+		timer = c.Clock.NewTimer(size)
+		tick = timer.C()
+	}
+	go func() {
+		defer close(out)
+		if timer != nil {
+			defer timer.Stop()
+		}
+		for {
+			select {
+			case i, open := <-in:
+				if !open {
+					w.flush()
+					return
+				}
+				if c.eventTime == nil {
+					w.add(c.Clock.Now(), i)
+					continue
+				}
+				t := c.eventTime(i)
+				w.add(t, i)
+				w.end(t)
+			case now := <-tick:
+				w.end(now)
+				timer.Reset(w.endOf(w.next).Sub(c.Clock.Now()))
+			case <-ctx.Done():
+				w.flush()
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WithEmptyWindows makes Window send the windows that have no inputs
+func WithEmptyWindows() Option {
+	return func(c *config) {
+		c.emptyWindows = true
+	}
+}
+
+// WithEventTime makes Window take the time of each input from `eventTime` instead of when the input arrives
+func WithEventTime(eventTime func(i interface{}) time.Time) Option {
+	return func(c *config) {
+		c.eventTime = eventTime
+	}
+}
+
+// windows holds the open windows of Window by their index, starting with 0 at origin
+type windows struct {
+	size, slide time.Duration
+	empty       bool
+	out         chan<- []interface{}
+	started     bool
+	origin      time.Time
+	// next is the index of the next window to end
+	next int64
+	// last is the highest index of a window with inputs
+	last    int64
+	windows map[int64][]interface{}
+}
+
+// start sets the origin of the windows, once
+func (w *windows) start(t time.Time) {
+	if !w.started {
+		w.origin, w.started = t, true
+	}
+}
+
+// endOf returns when window k ends
+func (w *windows) endOf(k int64) time.Time {
+	return w.origin.Add(time.Duration(k)*w.slide + w.size)
+}
+
+// add adds i to every window that has not ended and contains t
+func (w *windows) add(t time.Time, i interface{}) {
+	w.start(t)
+	d := t.Sub(w.origin)
+	first, last := floorDiv(d-w.size, w.slide)+1, floorDiv(d, w.slide)
+	if first < w.next {
+		first = w.next
+	}
+	for k := first; k <= last; k++ {
+		w.windows[k] = append(w.windows[k], i)
+		if k > w.last {
+			w.last = k
+		}
+	}
+}
+
+// end sends the windows that end at or before now
+func (w *windows) end(now time.Time) {
+	for w.started && !w.endOf(w.next).After(now) {
+		w.send(w.next)
+		w.next++
+	}
+}
+
+// flush sends the windows that have not ended yet
+func (w *windows) flush() {
+	for ; w.next <= w.last; w.next++ {
+		w.send(w.next)
+	}
+}
+
+// send sends window k, unless it is empty and empty windows are skipped
+func (w *windows) send(k int64) {
+	is, ok := w.windows[k]
+	if !ok && !w.empty {
+		return
+	}
+	delete(w.windows, k)
+	w.out <- is
+}
+
+// floorDiv returns a / b rounded down
+func floorDiv(a, b time.Duration) int64 {
+	q := int64(a / b)
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	// at is an input with an event time in seconds
+	type at int
+	eventTime := func(i interface{}) time.Time {
+		return time.Unix(int64(i.(at)), 0)
+	}
+	type args struct {
+		size, slide time.Duration
+		opts        []Option
+	}
+	for _, test := range []struct {
+		name string
+		args args
+		want [][]interface{}
+	}{{
+		"tumbling windows",
+		args{10 * time.Second, 10 * time.Second, nil},
+		[][]interface{}{{at(0), at(1), at(5)}, {at(12)}, {at(25)}},
+	}, {
+		"sliding windows skip the empty ones",
+		args{10 * time.Second, 5 * time.Second, nil},
+		[][]interface{}{{at(0), at(1), at(5)}, {at(5), at(12)}, {at(12)}, {at(25)}, {at(25)}},
+	}, {
+		"sliding windows with empty windows",
+		args{10 * time.Second, 5 * time.Second, []Option{WithEmptyWindows()}},
+		[][]interface{}{{at(0), at(1), at(5)}, {at(5), at(12)}, {at(12)}, nil, {at(25)}, {at(25)}},
+	}} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			opts := append([]Option{WithEventTime(eventTime)}, test.args.opts...)
+			var windows [][]interface{}
+			for w := range Window(context.Background(), test.args.size, test.args.slide, Emit(at(0), at(1), at(5), at(12), at(25)), opts...) {
+				windows = append(windows, w)
+			}
+			if !reflect.DeepEqual(test.want, windows) {
+				t.Errorf("windows = %+v, want %+v", windows, test.want)
+			}
+		})
+	}
+
+	t.Run("arrival time windows end on time and the last one is flushed", func(t *testing.T) {
+		in := make(chan interface{})
+		go func() {
+			defer close(in)
+			in <- 1
+			in <- 2
+			time.Sleep(150 * time.Millisecond)
+			in <- 3
+		}()
+		start := time.Now()
+		out := Window(context.Background(), 100*time.Millisecond, 100*time.Millisecond, in)
+
+		if w := <-out; !reflect.DeepEqual([]interface{}{1, 2}, w) {
+			t.Errorf("window = %+v, want [1 2]", w)
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 140*time.Millisecond {
+			t.Errorf("the window ended after %s, want 100ms", elapsed)
+		}
+		if w := <-out; !reflect.DeepEqual([]interface{}{3}, w) {
+			t.Errorf("window = %+v, want [3]", w)
+		}
+		if _, open := <-out; open {
+			t.Error("out is still open")
+		}
+	})
+
+	t.Run("the open windows are flushed when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan interface{})
+		out := Window(ctx, time.Hour, time.Hour, in)
+		in <- 1
+		cancel()
+		if w := <-out; !reflect.DeepEqual([]interface{}{1}, w) {
+			t.Errorf("window = %+v, want [1]", w)
+		}
+		close(in)
+		if _, open := <-out; open {
+			t.Error("out is still open")
+		}
+	})
+	t.Run("invalid sizes and slides panic", func(t *testing.T) {
+		for _, d := range [][2]time.Duration{{0, 0}, {-time.Second, time.Second}, {time.Second, 0}, {time.Second, -time.Second}, {time.Second, 2 * time.Second}} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Window(%s, %s) did not panic", d[0], d[1])
+					}
+				}()
+				Window(context.Background(), d[0], d[1], Emit(1, 2, 3))
+			}()
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import "context"
+
+// Zip pairs the n-th inputs of the `a` and `b` channels and sends each pair to the out chan, with the input of `a` first.
+// The out chan is closed as soon as either channel is closed, since no more pairs can be made.
+// The input waiting for its pair then is passed to the func set by WithUnmatched, if there is one, before the out chan is closed,
+// and so are the remaining inputs of the other channel until it is closed too.
+// When the context is canceled, the inputs of the pair that is being made are passed to that func before the out chan is closed,
+// and the remaining inputs are dropped until both channels are closed.
+func Zip(ctx context.Context, a, b <-chan interface{}, opts ...Option) <-chan [2]interface{} {
+	return zip(ctx, a, b, func(x, y interface{}) [2]interface{} {
+		return [2]interface{}{x, y}
+	}, newConfig(opts))
+}
+
+// ZipWith is like Zip, except that each pair is combined into the output of `combine`
+func ZipWith(ctx context.Context, a, b <-chan interface{}, combine func(x, y interface{}) interface{}, opts ...Option) <-chan interface{} {
+	return zip(ctx, a, b, combine, newConfig(opts))
+}
+
+// zip sends the outputs of `combine` for the pairs of a and b to the out chan
+func zip[T any](ctx context.Context, a, b <-chan interface{}, combine func(x, y interface{}) T, c *config) <-chan T {
+	out := make(chan T)
+	unmatched := func(is ...interface{}) {
+		if c.unmatched != nil {
+			for _, i := range is {
+				c.unmatched(i)
+			}
+		}
+	}
+	go func() {
+		for {
+			// Wait for both inputs of the next pair, in whichever order they arrive
+			var x, y interface{}
+			ra, rb := a, b
+			for ra != nil || rb != nil {
+				select {
+				case i, open := <-ra:
+					if !open {
+						unmatched(held(y, rb == nil)...)
+						close(out)
+						for i := range b {
+							unmatched(i)
+						}
+						return
+					}
+					x, ra = i, nil
+				case i, open := <-rb:
+					if !open {
+						unmatched(held(x, ra == nil)...)
+						close(out)
+						for i := range a {
+							unmatched(i)
+						}
+						return
+					}
+					y, rb = i, nil
+				case <-ctx.Done():
+					unmatched(append(held(x, ra == nil), held(y, rb == nil)...)...)
+					close(out)
+					dropAll(a, b)
+					return
+				}
+			}
+			select {
+			case out <- combine(x, y):
+			case <-ctx.Done():
+				unmatched(x, y)
+				close(out)
+				dropAll(a, b)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// held returns i in a slice if it was received
+func held(i interface{}, received bool) []interface{} {
+	if received {
+		return []interface{}{i}
+	}
+	return nil
+}
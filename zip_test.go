@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestZip(t *testing.T) {
+	t.Run("the n-th inputs are paired until a channel is closed", func(t *testing.T) {
+		unmatched := make(chan interface{}, 2)
+		var got [][2]interface{}
+		for pair := range Zip(context.Background(), Emit(1, 2, 3, 4), Emit("a", "b"), WithUnmatched(func(i interface{}) {
+			unmatched <- i
+		})) {
+			got = append(got, pair)
+		}
+		if want := [][2]interface{}{{1, "a"}, {2, "b"}}; !reflect.DeepEqual(want, got) {
+			t.Errorf("pairs = %v, want %v", got, want)
+		}
+		// The leftovers of a are reported after the out chan is closed
+		for _, want := range []interface{}{3, 4} {
+			if i := <-unmatched; i != want {
+				t.Errorf("unmatched = %v, want %v", i, want)
+			}
+		}
+	})
+
+	t.Run("ZipWith combines the pairs", func(t *testing.T) {
+		var got []interface{}
+		for o := range ZipWith(context.Background(), Emit(1, 2), Emit("a", "b"), func(x, y interface{}) interface{} {
+			return fmt.Sprint(x, y)
+		}) {
+			got = append(got, o)
+		}
+		if want := []interface{}{"1a", "2b"}; !reflect.DeepEqual(want, got) {
+			t.Errorf("out = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("the input of a pair that is being made is not lost on cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		a, b := make(chan interface{}), make(chan interface{})
+		var mu sync.Mutex
+		var unmatched []interface{}
+		out := Zip(ctx, a, b, WithUnmatched(func(i interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			unmatched = append(unmatched, i)
+		}))
+		a <- 1
+		cancel()
+		if _, open := <-out; open {
+			t.Error("out is open after the context is canceled")
+		}
+		close(a)
+		close(b)
+		mu.Lock()
+		defer mu.Unlock()
+		if want := []interface{}{1}; !reflect.DeepEqual(want, unmatched) {
+			t.Errorf("unmatched = %v, want %v", unmatched, want)
+		}
+	})
+
+	t.Run("the inputs fed by one Tee are drained after the context is canceled", func(t *testing.T) {
+		drainsTee(t, func(ctx context.Context, a, b <-chan interface{}) {
+			for range Zip(ctx, a, b) {
+			}
+		})
+	})
+}